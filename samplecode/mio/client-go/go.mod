@@ -0,0 +1,7 @@
+module github.com/apache/incubator-teaclave-sgx-sdk/samplecode/mio/client-go
+
+go 1.21
+
+require github.com/apache/incubator-teaclave-sgx-sdk/samplecode/ue-ra/ratls v0.0.0
+
+replace github.com/apache/incubator-teaclave-sgx-sdk/samplecode/ue-ra/ratls => ../../ue-ra/ratls
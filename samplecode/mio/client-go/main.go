@@ -1,46 +1,237 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
-	"crypto/x509"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	targetURL   = flag.String("url", envOr("MIO_URL", DEFAULTURL), "URL requested ($MIO_URL)")
+	caFile      = flag.String("ca", envOr("MIO_CA", DEFAULTCA), "PEM CA bundle the server certificate must chain to ($MIO_CA)")
+	hostHeader  = flag.String("host", envOr("MIO_HOST", ""), "Host header sent instead of the host of -url ($MIO_HOST)")
+	headers     = headerFlags{}
+	concurrency = flag.Int("c", 20, "number of concurrent workers")
+	requests    = flag.Int("n", 20, "total number of requests, 0 for no limit (requires -d)")
+	duration    = flag.Duration("d", 0, "stop sending requests after this duration, 0 for no limit")
+	qps         = flag.Float64("qps", 0, "target requests per second over all workers, 0 for as fast as possible")
+	timeout     = flag.Duration("timeout", 10*time.Second, "timeout of each request, 0 for none")
+	verbose     = flag.Bool("v", false, "print every response body")
+	format      = flag.String("format", "text", "report format: text, json or csv")
+	output      = flag.String("o", "", "write the report to this file instead of stdout")
+	certFile    = flag.String("cert", "", "PEM client certificate presented to servers requiring client authentication, e.g. an RA-TLS certificate")
+	keyFile     = flag.String("key", "", "PEM private key of -cert")
+	httpVersion = flag.String("http", "1.1", "HTTP version offered to the server: 1.1, or 2 to negotiate HTTP/2 with ALPN and multiplex requests")
+
+	ra       = flag.Bool("ra", false, "verify the RA-TLS certificate of the enclave server instead of trusting -ca")
+	raStatus = flag.String("ra-quote-status", "permissive", "accepted IAS quote statuses with -ra: strict, permissive or a comma separated list")
+	raPolicy = flag.String("ra-policy", "", "JSON file of allowed mr_enclave/mr_signer values with -ra")
+	raDebug  = flag.Bool("ra-allow-debug", true, "accept enclaves running in debug mode with -ra, as the samples are built")
 )
 
 func main() {
-	pool := x509.NewCertPool()
-	caCertPath := "./ca.cert"
+	flag.Var(headers, "H", "extra request header \"Name: value\", may be repeated")
+	flag.Parse()
+	if *concurrency < 1 {
+		fmt.Fprintln(os.Stderr, "-c must be at least 1")
+		os.Exit(2)
+	}
+	if *requests < 0 || *qps < 0 || *duration < 0 || *timeout < 0 {
+		fmt.Fprintln(os.Stderr, "-n, -d, -qps and -timeout must not be negative")
+		os.Exit(2)
+	}
+	if *format != "text" && *format != "json" && *format != "csv" {
+		fmt.Fprintf(os.Stderr, "unknown -format %q\n", *format)
+		os.Exit(2)
+	}
+	if *httpVersion != "1.1" && *httpVersion != "2" {
+		fmt.Fprintf(os.Stderr, "unknown -http %q\n", *httpVersion)
+		os.Exit(2)
+	}
+	if (*certFile == "") != (*keyFile == "") {
+		fmt.Fprintln(os.Stderr, "-cert and -key must be given together")
+		os.Exit(2)
+	}
+	if _, err := newRequest(); err != nil {
+		fmt.Fprintln(os.Stderr, "-url:", err)
+		os.Exit(2)
+	}
+	if *requests == 0 && *duration == 0 {
+		fmt.Fprintln(os.Stderr, "-n 0 runs until -d elapses, which must be set")
+		os.Exit(2)
+	}
+
+	var tlsConfig *tls.Config
+	if *ra {
+		verifier, err := newVerifier()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		tlsConfig = raConfig(verifier)
+	} else {
+		pool, err := rootCAs()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		tlsConfig = &tls.Config{RootCAs: pool}
+	}
 
-	caCrt, err := ioutil.ReadFile(caCertPath)
+	certs, err := clientCertificates()
 	if err != nil {
-		fmt.Println("ReadFile err:", err)
+		fmt.Println("LoadX509KeyPair err:", err)
 		return
 	}
-	pool.AppendCertsFromPEM(caCrt)
+	tlsConfig.Certificates = certs
 
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{RootCAs: pool},
+	client := &http.Client{Transport: newTransport(tlsConfig), Timeout: *timeout}
+
+	ctx := context.Background()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
 	}
 
-	wg := sync.WaitGroup{}
-	wg.Add(20)
+	var sent, failed int64
+	jobs := make(chan struct{})
+	go dispatch(ctx, jobs)
 
-	for i:=0;i<20;i++{
+	start := time.Now()
+	var mu sync.Mutex
+	var latencies []time.Duration
+	protocols := make(map[string]int64)
+	wg := sync.WaitGroup{}
+	wg.Add(*concurrency)
+	for i := 0; i < *concurrency; i++ {
 		go func() {
-			client := &http.Client{Transport: tr}
-			resp, err := client.Get("https://localhost:8443")
-			if err != nil {
-				fmt.Println("Get error:", err)
-				return
+			defer wg.Done()
+			var local []time.Duration
+			localProtocols := make(map[string]int64)
+			for range jobs {
+				atomic.AddInt64(&sent, 1)
+				t := time.Now()
+				proto, err := get(client)
+				if err != nil {
+					atomic.AddInt64(&failed, 1)
+					fmt.Fprintln(os.Stderr, "Get error:", err)
+					continue
+				}
+				local = append(local, time.Since(t))
+				localProtocols[proto]++
+			}
+			mu.Lock()
+			latencies = append(latencies, local...)
+			for proto, n := range localProtocols {
+				protocols[proto] += n
 			}
-			defer resp.Body.Close()
-			body, err := ioutil.ReadAll(resp.Body)
-			fmt.Println(string(body))
-			wg.Done()
+			mu.Unlock()
 		}()
 	}
 
 	wg.Wait()
+	report := newReport(latencies, sent, failed, time.Since(start))
+	report.Attestations = atomic.LoadInt64(&attestations)
+	report.Protocols = protocols
+	out := os.Stdout
+	if *output != "" {
+		var err error
+		if out, err = os.Create(*output); err != nil {
+			fmt.Println("Create err:", err)
+			return
+		}
+		defer out.Close()
+	}
+	if err := report.write(out, *format); err != nil {
+		fmt.Println("Write report err:", err)
+	}
+}
+
+// dispatch hands out one job per request to the workers, at most
+// *requests in total, paced to *qps, until ctx is done. It closes jobs
+// when it stops.
+func dispatch(ctx context.Context, jobs chan<- struct{}) {
+	defer close(jobs)
+	var tick <-chan time.Time
+	if *qps > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / *qps))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for i := 0; *requests == 0 || i < *requests; i++ {
+		if tick != nil {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case jobs <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// clientCertificates returns the -cert certificate with its -key, or none.
+func clientCertificates() ([]tls.Certificate, error) {
+	if *certFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return []tls.Certificate{cert}, nil
+}
+
+// newTransport returns the transport of the workers, speaking the
+// -http version.
+func newTransport(tlsConfig *tls.Config) *http.Transport {
+	tr := &http.Transport{
+		TLSClientConfig:     tlsConfig,
+		MaxIdleConnsPerHost: *concurrency,
+	}
+	if *httpVersion == "2" {
+		// A custom TLS config disables HTTP/2 unless asked for. Servers
+		// that do not negotiate h2 are still spoken to in HTTP/1.1.
+		tr.ForceAttemptHTTP2 = true
+	} else {
+		tr.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return tr
+}
+
+// get requests the target and returns the protocol of the response, e.g.
+// "HTTP/2.0". Error statuses count as failures.
+func get(client *http.Client) (string, error) {
+	req, err := newRequest()
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if *verbose {
+		fmt.Println(string(body))
+	}
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("%s %s", resp.Proto, resp.Status)
+	}
+	return resp.Proto, nil
 }
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDispatch(t *testing.T) {
+	n, rate := *requests, *qps
+	defer func() { *requests, *qps = n, rate }()
+	tests := []struct {
+		name     string
+		requests int
+		qps      float64
+		duration time.Duration
+		// wantJobs is the number of jobs handed out, -1 for at least one.
+		wantJobs int
+		wantMin  time.Duration
+	}{
+		{name: "request limit", requests: 5, wantJobs: 5},
+		{name: "paced", requests: 3, qps: 100, wantJobs: 3, wantMin: 30 * time.Millisecond},
+		{name: "duration", duration: 20 * time.Millisecond, wantJobs: -1, wantMin: 20 * time.Millisecond},
+		{name: "duration before first tick", requests: 100, qps: 10, duration: 20 * time.Millisecond, wantJobs: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*requests, *qps = tt.requests, tt.qps
+			ctx := context.Background()
+			if tt.duration > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.duration)
+				defer cancel()
+			}
+			jobs := make(chan struct{})
+			start := time.Now()
+			go dispatch(ctx, jobs)
+			got := 0
+			for range jobs {
+				got++
+			}
+			if tt.wantJobs < 0 && got == 0 || tt.wantJobs >= 0 && got != tt.wantJobs {
+				t.Errorf("dispatch() handed out %d jobs, want %d", got, tt.wantJobs)
+			}
+			if elapsed := time.Since(start); elapsed < tt.wantMin {
+				t.Errorf("dispatch() took %v, want at least %v", elapsed, tt.wantMin)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	url := *targetURL
+	defer func() { *targetURL = url }()
+	tests := []struct {
+		name    string
+		status  int
+		want    string
+		wantErr bool
+	}{
+		{name: "OK", status: http.StatusOK, want: "HTTP/1.1"},
+		{name: "not modified", status: http.StatusNotModified, want: "HTTP/1.1"},
+		{name: "not found", status: http.StatusNotFound, wantErr: true},
+		{name: "server error", status: http.StatusInternalServerError, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+			*targetURL = srv.URL
+			got, err := get(srv.Client())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("get() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	url, version := *targetURL, *httpVersion
+	defer func() { *targetURL, *httpVersion = url, version }()
+	tests := []struct {
+		name    string
+		version string
+		h2      bool
+		want    string
+	}{
+		{name: "HTTP/1.1", version: "1.1", h2: true, want: "HTTP/1.1"},
+		{name: "HTTP/2", version: "2", h2: true, want: "HTTP/2.0"},
+		{name: "HTTP/2 not offered by the server", version: "2", want: "HTTP/1.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+			srv.EnableHTTP2 = tt.h2
+			srv.StartTLS()
+			defer srv.Close()
+			roots := x509.NewCertPool()
+			roots.AddCert(srv.Certificate())
+
+			*targetURL, *httpVersion = srv.URL, tt.version
+			tr := newTransport(&tls.Config{RootCAs: roots})
+			defer tr.CloseIdleConnections()
+			got, err := get(&http.Client{Transport: tr})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("get() protocol = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// writeKeyPair writes a self-signed certificate and its key as PEM files
+// in dir and returns their paths.
+func writeKeyPair(t *testing.T, dir, name string) (certPath, keyPath string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPath, keyPath = filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certPath, keyPath
+}
+
+func TestClientCertificates(t *testing.T) {
+	cert, key := *certFile, *keyFile
+	defer func() { *certFile, *keyFile = cert, key }()
+	dir := t.TempDir()
+	clientCert, clientKey := writeKeyPair(t, dir, "client")
+	_, otherKey := writeKeyPair(t, dir, "other")
+	tests := []struct {
+		name    string
+		cert    string
+		key     string
+		want    int
+		wantErr bool
+	}{
+		{name: "none"},
+		{name: "key pair", cert: clientCert, key: clientKey, want: 1},
+		{name: "key of another certificate", cert: clientCert, key: otherKey, wantErr: true},
+		{name: "key as certificate", cert: clientKey, key: clientKey, wantErr: true},
+		{name: "missing file", cert: filepath.Join(dir, "missing.crt"), key: clientKey, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*certFile, *keyFile = tt.cert, tt.key
+			got, err := clientCertificates()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("clientCertificates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.want {
+				t.Errorf("clientCertificates() = %d certificates, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
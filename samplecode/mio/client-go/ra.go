@@ -0,0 +1,52 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"sync/atomic"
+
+	"github.com/apache/incubator-teaclave-sgx-sdk/samplecode/ue-ra/ratls"
+)
+
+// attestations counts the RA-TLS certificates verified, one per full
+// handshake.
+var attestations int64
+
+// newVerifier returns the RA-TLS verifier configured by the -ra-* flags.
+func newVerifier() (*ratls.Verifier, error) {
+	statuses, err := ratls.ParseQuoteStatusPolicy(*raStatus)
+	if err != nil {
+		return nil, err
+	}
+	verifier := &ratls.Verifier{
+		QuoteStatuses: statuses,
+		AllowDebug:    *raDebug,
+	}
+	if *raPolicy != "" {
+		if verifier.Measurements, err = ratls.LoadMeasurementPolicy(*raPolicy); err != nil {
+			return nil, err
+		}
+	}
+	return verifier, nil
+}
+
+// raConfig returns a TLS config that accepts the enclave server only if
+// its certificate carries valid attestation evidence.
+func raConfig(verifier *ratls.Verifier) *tls.Config {
+	return &tls.Config{
+		// The enclave certificate is self-signed, its trust comes from the
+		// attestation evidence checked in VerifyPeerCertificate.
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("server presented no certificate")
+			}
+			if _, err := verifier.VerifyPeerChain("", rawCerts); err != nil {
+				return err
+			}
+			atomic.AddInt64(&attestations, 1)
+			return nil
+		},
+	}
+}
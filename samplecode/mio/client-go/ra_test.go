@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/apache/incubator-teaclave-sgx-sdk/samplecode/ue-ra/ratls"
+)
+
+// testEnclaveCert returns a self-signed certificate carrying the quote of
+// a simulation mode enclave, whose report_data binds its key if bind.
+func testEnclaveCert(t *testing.T, bind bool) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spki, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quote := ratls.EPIDQuote{Header: ratls.EPIDQuoteHeader{Version: 2, SignType: ratls.EPIDLinkable}}
+	if bind {
+		digest := sha256.Sum256(spki)
+		copy(quote.ReportBody.ReportData[:], digest[:])
+	}
+	var raw bytes.Buffer
+	binary.Write(&raw, binary.LittleEndian, quote.Header)
+	binary.Write(&raw, binary.LittleEndian, quote.ReportBody)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "enclave"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		// Netscape comment, where the samples put their evidence
+		ExtraExtensions: []pkix.Extension{{Id: asn1.ObjectIdentifier{2, 16, 840, 1, 113730, 1, 13}, Value: raw.Bytes()}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestNewVerifier(t *testing.T) {
+	status, policy, debug := *raStatus, *raPolicy, *raDebug
+	defer func() { *raStatus, *raPolicy, *raDebug = status, policy, debug }()
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	allowlist := write("policy.json", `{"allowed": [{"mr_signer": "bb"}]}`)
+	malformed := write("malformed.json", `{"allowed": `)
+	tests := []struct {
+		name         string
+		status       string
+		policy       string
+		wantStatuses ratls.QuoteStatusPolicy
+		wantPolicy   bool
+		wantErr      bool
+	}{
+		{name: "permissive", status: "permissive", wantStatuses: ratls.PermissiveQuoteStatus},
+		{name: "strict", status: "strict", wantStatuses: ratls.StrictQuoteStatus},
+		{name: "status list", status: "OK, SW_HARDENING_NEEDED", wantStatuses: ratls.QuoteStatusPolicy{Allowed: []string{"OK", "SW_HARDENING_NEEDED"}}},
+		{name: "empty status list", status: " , ", wantErr: true},
+		{name: "measurement policy", status: "strict", policy: allowlist, wantStatuses: ratls.StrictQuoteStatus, wantPolicy: true},
+		{name: "missing policy", status: "strict", policy: filepath.Join(dir, "missing.json"), wantErr: true},
+		{name: "malformed policy", status: "strict", policy: malformed, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*raStatus, *raPolicy, *raDebug = tt.status, tt.policy, false
+			got, err := newVerifier()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newVerifier() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got.QuoteStatuses, tt.wantStatuses) || (got.Measurements != nil) != tt.wantPolicy || got.AllowDebug {
+				t.Errorf("newVerifier() = %+v", got)
+			}
+		})
+	}
+}
+
+func TestRAConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		rawCerts [][]byte
+		wantErr  bool
+	}{
+		{name: "attested", rawCerts: [][]byte{testEnclaveCert(t, true)}},
+		{name: "key not bound", rawCerts: [][]byte{testEnclaveCert(t, false)}, wantErr: true},
+		{name: "malformed", rawCerts: [][]byte{[]byte("certificate")}, wantErr: true},
+		{name: "no certificate", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := atomic.LoadInt64(&attestations)
+			config := raConfig(&ratls.Verifier{AllowSimulation: true})
+			err := config.VerifyPeerCertificate(tt.rawCerts, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyPeerCertificate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			want := before
+			if err == nil {
+				want++
+			}
+			if got := atomic.LoadInt64(&attestations); got != want {
+				t.Errorf("attestations = %d, want %d", got, want)
+			}
+		})
+	}
+}
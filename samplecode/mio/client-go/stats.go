@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Report summarizes a run: request counts, throughput and the latency
+// distribution of the successful requests.
+type Report struct {
+	Requests int64 `json:"requests"`
+	Failed   int64 `json:"failed"`
+	// Attestations is the number of RA-TLS certificates verified with -ra.
+	Attestations int64 `json:"attestations,omitempty"`
+	// Protocols counts the successful requests by response protocol.
+	Protocols map[string]int64 `json:"protocols"`
+	// Duration is the wall time of the run in seconds.
+	Duration float64 `json:"duration_s"`
+	// Throughput is the number of successful requests per second.
+	Throughput float64  `json:"throughput_rps"`
+	Latency    Latency  `json:"latency_ms"`
+	Histogram  []Bucket `json:"histogram"`
+	latencies  []float64
+}
+
+// Latency percentiles, in milliseconds.
+type Latency struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+// Bucket counts the requests whose latency is at most LE milliseconds and
+// above the bound of the previous bucket.
+type Bucket struct {
+	LE    float64 `json:"le_ms"`
+	Count int     `json:"count"`
+}
+
+// newReport computes the report of a run of elapsed time from the
+// latencies of its successful requests.
+func newReport(latencies []time.Duration, requests, failed int64, elapsed time.Duration) *Report {
+	r := &Report{Requests: requests, Failed: failed, Duration: elapsed.Seconds(), Histogram: []Bucket{}}
+	if elapsed > 0 {
+		r.Throughput = float64(len(latencies)) / elapsed.Seconds()
+	}
+	if len(latencies) == 0 {
+		return r
+	}
+	ms := make([]float64, len(latencies))
+	var sum float64
+	for i, d := range latencies {
+		ms[i] = float64(d) / float64(time.Millisecond)
+		sum += ms[i]
+	}
+	sort.Float64s(ms)
+	r.latencies = ms
+	r.Latency = Latency{
+		Min:  ms[0],
+		Mean: sum / float64(len(ms)),
+		P50:  percentile(ms, 50),
+		P90:  percentile(ms, 90),
+		P99:  percentile(ms, 99),
+		Max:  ms[len(ms)-1],
+	}
+	r.Histogram = histogram(ms)
+	return r
+}
+
+// percentile returns the nearest-rank percentile p of the sorted samples.
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// histogram counts the sorted samples in buckets whose bounds double from
+// 0.5ms, up to the first bound above the maximum.
+func histogram(sorted []float64) []Bucket {
+	var buckets []Bucket
+	le, i := 0.5, 0
+	for i < len(sorted) {
+		n := 0
+		for i < len(sorted) && sorted[i] <= le {
+			n++
+			i++
+		}
+		buckets = append(buckets, Bucket{LE: le, Count: n})
+		le *= 2
+	}
+	return buckets
+}
+
+// write prints r in format "text", "json" or "csv".
+func (r *Report) write(w io.Writer, format string) error {
+	switch format {
+	case "text":
+		return r.writeText(w)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case "csv":
+		return r.writeCSV(w)
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// protocols returns the response protocols of r in sorted order.
+func (r *Report) protocols() []string {
+	protos := make([]string, 0, len(r.Protocols))
+	for proto := range r.Protocols {
+		protos = append(protos, proto)
+	}
+	sort.Strings(protos)
+	return protos
+}
+
+func (r *Report) writeText(w io.Writer) error {
+	fmt.Fprintf(w, "%d requests, %d failed in %.3fs, %.1f req/s\n", r.Requests, r.Failed, r.Duration, r.Throughput)
+	if len(r.Protocols) > 0 {
+		fmt.Fprint(w, "protocols:")
+		for _, proto := range r.protocols() {
+			fmt.Fprintf(w, " %s %d", proto, r.Protocols[proto])
+		}
+		fmt.Fprintln(w)
+	}
+	if r.Attestations > 0 {
+		fmt.Fprintf(w, "%d RA-TLS certificates verified\n", r.Attestations)
+	}
+	if len(r.latencies) == 0 {
+		return nil
+	}
+	l := r.Latency
+	fmt.Fprintf(w, "latency ms: min %.3f mean %.3f p50 %.3f p90 %.3f p99 %.3f max %.3f\n", l.Min, l.Mean, l.P50, l.P90, l.P99, l.Max)
+	max := 0
+	for _, b := range r.Histogram {
+		if b.Count > max {
+			max = b.Count
+		}
+	}
+	for _, b := range r.Histogram {
+		bar := make([]byte, b.Count*40/max)
+		for i := range bar {
+			bar[i] = '#'
+		}
+		fmt.Fprintf(w, "  <= %9.1fms %8d %s\n", b.LE, b.Count, bar)
+	}
+	return nil
+}
+
+var csvHeader = []string{"requests", "failed", "attestations", "protocols", "duration_s", "throughput_rps", "min_ms", "mean_ms", "p50_ms", "p90_ms", "p99_ms", "max_ms"}
+
+// writeCSV prints r as a header and a single record. The protocols column
+// lists the response protocols as "proto=count" separated by ';'.
+func (r *Report) writeCSV(w io.Writer) error {
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+	l := r.Latency
+	var protos []string
+	for _, proto := range r.protocols() {
+		protos = append(protos, proto+"="+strconv.FormatInt(r.Protocols[proto], 10))
+	}
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader)
+	cw.Write([]string{
+		strconv.FormatInt(r.Requests, 10), strconv.FormatInt(r.Failed, 10),
+		strconv.FormatInt(r.Attestations, 10), strings.Join(protos, ";"),
+		f(r.Duration), f(r.Throughput),
+		f(l.Min), f(l.Mean), f(l.P50), f(l.P90), f(l.P99), f(l.Max),
+	})
+	cw.Flush()
+	return cw.Error()
+}
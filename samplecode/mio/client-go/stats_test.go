@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	ten := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	tests := []struct {
+		name   string
+		sorted []float64
+		p      float64
+		want   float64
+	}{
+		{name: "median", sorted: ten, p: 50, want: 5},
+		{name: "p90", sorted: ten, p: 90, want: 9},
+		{name: "p99 rounds up", sorted: ten, p: 99, want: 10},
+		{name: "p91 rounds up", sorted: ten, p: 91, want: 10},
+		{name: "p0", sorted: ten, p: 0, want: 1},
+		{name: "single sample", sorted: []float64{7}, p: 99, want: 7},
+		{name: "p99 of 3", sorted: []float64{1, 2, 3}, p: 99, want: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentile(tt.sorted, tt.p); got != tt.want {
+				t.Errorf("percentile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	tests := []struct {
+		name   string
+		sorted []float64
+		want   []Bucket
+	}{
+		{name: "first bucket", sorted: []float64{0.1, 0.5}, want: []Bucket{{LE: 0.5, Count: 2}}},
+		{name: "doubling bounds", sorted: []float64{0.2, 0.7, 1.5, 1.9}, want: []Bucket{{0.5, 1}, {1, 1}, {2, 2}}},
+		{name: "empty buckets", sorted: []float64{0.1, 3}, want: []Bucket{{0.5, 1}, {1, 0}, {2, 0}, {4, 1}}},
+		{name: "no samples"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := histogram(tt.sorted); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("histogram() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewReport(t *testing.T) {
+	ms := func(v ...int) []time.Duration {
+		var d []time.Duration
+		for _, n := range v {
+			d = append(d, time.Duration(n)*time.Millisecond)
+		}
+		return d
+	}
+	tests := []struct {
+		name           string
+		latencies      []time.Duration
+		failed         int64
+		elapsed        time.Duration
+		wantLatency    Latency
+		wantThroughput float64
+	}{
+		{
+			name:           "unsorted",
+			latencies:      ms(4, 1, 3, 2),
+			elapsed:        2 * time.Second,
+			wantLatency:    Latency{Min: 1, Mean: 2.5, P50: 2, P90: 4, P99: 4, Max: 4},
+			wantThroughput: 2,
+		},
+		{name: "all failed", failed: 3, elapsed: time.Second},
+		{name: "no time elapsed", latencies: ms(1), wantLatency: Latency{Min: 1, Mean: 1, P50: 1, P90: 1, P99: 1, Max: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newReport(tt.latencies, int64(len(tt.latencies))+tt.failed, tt.failed, tt.elapsed)
+			if r.Latency != tt.wantLatency || r.Throughput != tt.wantThroughput {
+				t.Errorf("newReport() = %+v at %v req/s, want %+v at %v req/s", r.Latency, r.Throughput, tt.wantLatency, tt.wantThroughput)
+			}
+			if r.Histogram == nil {
+				t.Error("newReport() histogram is nil, want it encoded as a list")
+			}
+		})
+	}
+}
+
+func TestReportWrite(t *testing.T) {
+	r := newReport([]time.Duration{time.Millisecond, 3 * time.Millisecond}, 3, 1, time.Second)
+	r.Protocols = map[string]int64{"HTTP/2.0": 1, "HTTP/1.1": 1}
+	tests := []struct {
+		format  string
+		want    []string
+		wantErr bool
+	}{
+		{format: "text", want: []string{"3 requests, 1 failed", "protocols: HTTP/1.1 1 HTTP/2.0 1", "p50 1.000"}},
+		{format: "json", want: []string{`"failed": 1`, `"HTTP/2.0": 1`, `"p99": 3`}},
+		{format: "csv", want: []string{"3,1,0,HTTP/1.1=1;HTTP/2.0=1,1.000,2.000,1.000,2.000,1.000,3.000,3.000,3.000"}},
+		{format: "xml", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := r.write(&buf, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("write() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("write() = %q, want it to contain %q", buf.String(), want)
+				}
+			}
+		})
+	}
+}
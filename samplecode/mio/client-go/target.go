@@ -0,0 +1,80 @@
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Defaults of the request target.
+const (
+	DEFAULTURL = "https://localhost:8443"
+	DEFAULTCA  = "./ca.cert"
+)
+
+// envOr returns the value of the environment variable name if it is set,
+// def otherwise.
+func envOr(name, def string) string {
+	if v, ok := os.LookupEnv(name); ok {
+		return v
+	}
+	return def
+}
+
+// rootCAs returns the certificates of the -ca bundle.
+func rootCAs() (*x509.CertPool, error) {
+	pem, err := os.ReadFile(*caFile)
+	if err != nil {
+		return nil, fmt.Errorf("ReadFile err: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in %s", *caFile)
+	}
+	return pool, nil
+}
+
+// headerFlags collects repeated -H "Name: value" flags.
+type headerFlags http.Header
+
+func (h headerFlags) String() string {
+	var s []string
+	for name, values := range h {
+		for _, v := range values {
+			s = append(s, name+": "+v)
+		}
+	}
+	return strings.Join(s, ", ")
+}
+
+func (h headerFlags) Set(s string) error {
+	name, value, ok := strings.Cut(s, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return fmt.Errorf("header %q is not of the form \"Name: value\"", s)
+	}
+	http.Header(h).Add(name, strings.TrimSpace(value))
+	return nil
+}
+
+// newRequest returns a GET request of the target URL with the -host and
+// -H headers set.
+func newRequest() (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, *targetURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	// Go sends req.Host, not a Host header
+	if host := http.Header(headers).Get("Host"); host != "" {
+		req.Host = host
+	}
+	if *hostHeader != "" {
+		req.Host = *hostHeader
+	}
+	return req, nil
+}
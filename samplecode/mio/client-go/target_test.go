@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRootCAs(t *testing.T) {
+	ca := *caFile
+	defer func() { *caFile = ca }()
+	dir := t.TempDir()
+	bundle, _ := writeKeyPair(t, dir, "ca")
+	empty := filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(empty, []byte("not PEM"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{name: "bundle", path: bundle},
+		{name: "sample CA", path: DEFAULTCA},
+		{name: "no certificate", path: empty, wantErr: true},
+		{name: "missing", path: filepath.Join(dir, "missing.pem"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*caFile = tt.path
+			got, err := rootCAs()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("rootCAs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got == nil {
+				t.Error("rootCAs() = nil, want a pool")
+			}
+		})
+	}
+}
+
+func TestHeaderFlagsSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    http.Header
+		wantErr bool
+	}{
+		{name: "header", s: "X-Token: abc", want: http.Header{"X-Token": {"abc"}}},
+		{name: "canonical name", s: "x-token:abc", want: http.Header{"X-Token": {"abc"}}},
+		{name: "value with colon", s: "Authorization: Basic a:b", want: http.Header{"Authorization": {"Basic a:b"}}},
+		{name: "empty value", s: "X-Empty:", want: http.Header{"X-Empty": {""}}},
+		{name: "no colon", s: "X-Token", wantErr: true},
+		{name: "no name", s: " : abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := headerFlags{}
+			err := h.Set(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Set() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(http.Header(h), tt.want) {
+				t.Errorf("Set() headers = %v, want %v", http.Header(h), tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRequest(t *testing.T) {
+	url, host := *targetURL, *hostHeader
+	defer func() {
+		*targetURL, *hostHeader = url, host
+		for name := range headers {
+			delete(headers, name)
+		}
+	}()
+	tests := []struct {
+		name     string
+		url      string
+		host     string
+		headers  []string
+		wantHost string
+		wantErr  bool
+	}{
+		{name: "URL host", url: "https://localhost:8443/", wantHost: "localhost:8443"},
+		{name: "host flag", url: "https://127.0.0.1:8443/", host: "enclave.example.com", wantHost: "enclave.example.com"},
+		{name: "Host header", url: "https://127.0.0.1:8443/", headers: []string{"Host: a.example.com", "X-Token: abc"}, wantHost: "a.example.com"},
+		{name: "host flag over Host header", url: "https://127.0.0.1:8443/", host: "b.example.com", headers: []string{"Host: a.example.com"}, wantHost: "b.example.com"},
+		{name: "malformed URL", url: "https://[::1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*targetURL, *hostHeader = tt.url, tt.host
+			for name := range headers {
+				delete(headers, name)
+			}
+			for _, h := range tt.headers {
+				if err := headers.Set(h); err != nil {
+					t.Fatal(err)
+				}
+			}
+			req, err := newRequest()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if req.Host != tt.wantHost {
+				t.Errorf("newRequest() host = %q, want %q", req.Host, tt.wantHost)
+			}
+			for name, values := range headers {
+				if !reflect.DeepEqual(req.Header[name], values) {
+					t.Errorf("newRequest() header %s = %q, want %q", name, req.Header[name], values)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"io"
+	"log"
+
+	"github.com/apache/incubator-teaclave-sgx-sdk/samplecode/ue-ra/ratls"
+)
+
+const SERVERADDR = "localhost:3443"
+
+var (
+	certFile = flag.String("cert", "", "PEM RA-TLS certificate presented to the server (required)")
+	keyFile  = flag.String("key", "", "PEM private key of -cert (required)")
+	status   = flag.String("quote-status", "permissive", "accepted IAS quote statuses: strict, permissive or a comma separated list")
+	policy   = flag.String("policy", "", "JSON file of allowed mr_enclave/mr_signer values")
+	debug    = flag.Bool("allow-debug", true, "accept enclaves running in debug mode, as built by this sample")
+)
+
+func main() {
+	flag.Parse()
+	log.SetFlags(log.Lshortfile)
+	println("Starting mutual-ra-client-go")
+
+	if *certFile == "" || *keyFile == "" {
+		log.Fatalln("-cert and -key are required: the mutual-ra server only accepts RA-TLS client certificates")
+	}
+	cert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	statuses, err := ratls.ParseQuoteStatusPolicy(*status)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	verifier := &ratls.Verifier{
+		Mode:          ratls.ModeEPID,
+		QuoteStatuses: statuses,
+		AllowDebug:    *debug,
+	}
+	if *policy != "" {
+		if verifier.Measurements, err = ratls.LoadMeasurementPolicy(*policy); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	println("Connecting to ", SERVERADDR)
+
+	conn, err := tls.Dial("tcp", SERVERADDR, make_config(cert, verifier))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer conn.Close()
+
+	// Same exchange as run_client in the enclave: say hello, then read
+	// until the server closes the connection.
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		log.Fatalln(err)
+	}
+	reply, err := io.ReadAll(conn)
+	if err != nil && len(reply) == 0 {
+		log.Fatalln(err)
+	}
+
+	println("Server replied: ", string(reply))
+}
+
+func make_config(cert tls.Certificate, verifier *ratls.Verifier) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		// The enclave certificate is self-signed, its trust comes from the
+		// attestation report checked in VerifyPeerCertificate.
+		InsecureSkipVerify: true,
+		// The enclave client pins TLS 1.2, keep the sample symmetric.
+		MaxVersion: tls.VersionTLS12,
+		VerifyPeerCertificate: func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+			return verify_mra_cert(verifier, rawCerts)
+		},
+	}
+}
+
+func verify_mra_cert(verifier *ratls.Verifier, rawCerts [][]byte) error {
+	if len(rawCerts) == 0 {
+		return errors.New("server presented no certificate")
+	}
+	res, err := verifier.Verify(rawCerts[0])
+	if err != nil {
+		return err
+	}
+	println("isvEnclaveQuoteStatus = ", res.QuoteStatus)
+	println("sgx quote mr_enclave = ", res.MrEnclave)
+	println("sgx quote mr_signer = ", res.MrSigner)
+	if !res.KeyBound {
+		return errors.New("certificate key " + hex.EncodeToString(res.PublicKey) + " is not bound to the attestation report")
+	}
+	println("mutual RA done!")
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/apache/incubator-teaclave-sgx-sdk/samplecode/ue-ra/ratls"
+)
+
+// newEnclaveCert returns a self-signed certificate carrying, as Teaclave
+// enclaves do, the quote of a simulation mode enclave whose report_data
+// starts with the SHA-256 of bound, or of the certificate key if nil.
+func newEnclaveCert(t *testing.T, bound []byte) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bound == nil {
+		if bound, err = x509.MarshalPKIXPublicKey(&key.PublicKey); err != nil {
+			t.Fatal(err)
+		}
+	}
+	header := ratls.EPIDQuoteHeader{Version: 2, SignType: ratls.EPIDLinkable}
+	var body ratls.ReportBody
+	digest := sha256.Sum256(bound)
+	copy(body.ReportData[:], digest[:])
+	var quote bytes.Buffer
+	binary.Write(&quote, binary.LittleEndian, header)
+	binary.Write(&quote, binary.LittleEndian, body)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Teaclave"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{{
+			Id:    asn1.ObjectIdentifier{2, 16, 840, 1, 113730, 1, 13},
+			Value: quote.Bytes(),
+		}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestVerifyMRACert(t *testing.T) {
+	simulation := &ratls.Verifier{Mode: ratls.ModeEPID, AllowSimulation: true}
+	tests := []struct {
+		name     string
+		verifier *ratls.Verifier
+		rawCerts [][]byte
+		wantErr  bool
+	}{
+		{name: "simulated enclave", verifier: simulation, rawCerts: [][]byte{newEnclaveCert(t, nil)}},
+		{name: "simulation not allowed", verifier: &ratls.Verifier{Mode: ratls.ModeEPID}, rawCerts: [][]byte{newEnclaveCert(t, nil)}, wantErr: true},
+		{name: "other key bound", verifier: simulation, rawCerts: [][]byte{newEnclaveCert(t, []byte("other key"))}, wantErr: true},
+		{name: "no certificate", verifier: simulation, wantErr: true},
+		{name: "not a certificate", verifier: simulation, rawCerts: [][]byte{[]byte("certificate")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := verify_mra_cert(tt.verifier, tt.rawCerts); (err != nil) != tt.wantErr {
+				t.Errorf("verify_mra_cert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package ratls
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strings"
+)
+
+// AdvisoryPolicy lists the Intel security advisory IDs (e.g. "INTEL-SA-00334")
+// that may appear in an IAS report. A report carrying any other advisory is
+// rejected.
+type AdvisoryPolicy struct {
+	Allowed []string
+}
+
+// Check returns an *AdvisoryError naming the advisories of ids that are not
+// allowed.
+func (p *AdvisoryPolicy) Check(ids []string) error {
+	var rejected []string
+	for _, id := range ids {
+		if !p.allows(id) {
+			rejected = append(rejected, id)
+		}
+	}
+	if len(rejected) > 0 {
+		return &AdvisoryError{IDs: rejected}
+	}
+	return nil
+}
+
+func (p *AdvisoryPolicy) allows(id string) bool {
+	for _, allowed := range p.Allowed {
+		if strings.EqualFold(id, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// AdvisoryError reports advisories found in an IAS report that the policy
+// does not allow. Status is set when the advisories came with a status
+// accepted under AdvisoryExceptions.
+type AdvisoryError struct {
+	Status string
+	IDs    []string
+}
+
+func (e *AdvisoryError) Error() string {
+	if e.Status != "" {
+		return "status " + e.Status + " carries advisories without an exception: " + strings.Join(e.IDs, ", ")
+	}
+	return "report carries advisories not in the allowlist: " + strings.Join(e.IDs, ", ")
+}
+
+// DefaultExceptionStatuses are the statuses AdvisoryExceptions applies to
+// when its Statuses are empty: platforms missing a TCB recovery or needing
+// software hardening, as reported by IAS and by DCAP TCB info.
+var DefaultExceptionStatuses = []string{
+	"GROUP_OUT_OF_DATE",
+	"SW_HARDENING_NEEDED",
+	"OutOfDate",
+	"SWHardeningNeeded",
+}
+
+// AdvisoryExceptions accepts out of date platforms case by case: evidence
+// with one of Statuses is accepted only if the operator has granted an
+// exception for every advisory it carries, whatever the quote or TCB status
+// policy says. Evidence with other statuses, or that names no advisory, is
+// checked by the status policy as usual.
+type AdvisoryExceptions struct {
+	// Statuses are the IAS quote and DCAP/TDX TCB statuses accepted under
+	// exception, DefaultExceptionStatuses if empty.
+	Statuses []string
+	// IDs are the advisory IDs the operator has reviewed and accepts.
+	IDs []string
+}
+
+// LoadAdvisoryExceptions reads the advisory IDs of an exceptions file, one
+// per line. Blank lines and lines starting with '#' are ignored, as is
+// anything after the ID, which leaves room for the reason of the exception.
+func LoadAdvisoryExceptions(path string) (*AdvisoryExceptions, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	e := &AdvisoryExceptions{IDs: []string{}}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		e.IDs = append(e.IDs, fields[0])
+	}
+	return e, sc.Err()
+}
+
+// applies reports whether evidence with status and the advisories ids is
+// accepted under exception. Without advisories there is nothing to grant an
+// exception for.
+func (e *AdvisoryExceptions) applies(status string, ids []string) bool {
+	if len(ids) == 0 {
+		return false
+	}
+	statuses := e.Statuses
+	if len(statuses) == 0 {
+		statuses = DefaultExceptionStatuses
+	}
+	for _, s := range statuses {
+		if status == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns an *AdvisoryError naming the advisories of ids that have no
+// exception.
+func (e *AdvisoryExceptions) Check(status string, ids []string) error {
+	if err := (&AdvisoryPolicy{Allowed: e.IDs}).Check(ids); err != nil {
+		err.(*AdvisoryError).Status = status
+		return err
+	}
+	return nil
+}
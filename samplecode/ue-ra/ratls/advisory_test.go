@@ -0,0 +1,97 @@
+package ratls
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestAdvisoryPolicy(t *testing.T) {
+	p := &AdvisoryPolicy{Allowed: []string{"INTEL-SA-00334", "INTEL-SA-00615"}}
+	tests := []struct {
+		name string
+		ids  []string
+		want []string
+	}{
+		{name: "none", ids: nil},
+		{name: "allowed", ids: []string{"INTEL-SA-00334"}},
+		{name: "case insensitive", ids: []string{"intel-sa-00615"}},
+		{name: "rejected", ids: []string{"INTEL-SA-00334", "INTEL-SA-00161", "INTEL-SA-00219"}, want: []string{"INTEL-SA-00161", "INTEL-SA-00219"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Check(tt.ids)
+			if (err != nil) != (tt.want != nil) {
+				t.Fatalf("Check(%v) error = %v", tt.ids, err)
+			}
+			var advErr *AdvisoryError
+			if err != nil && (!errors.As(err, &advErr) || !reflect.DeepEqual(advErr.IDs, tt.want)) {
+				t.Errorf("Check(%v) error = %v, want advisories %v", tt.ids, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdvisoryExceptions(t *testing.T) {
+	exceptions := &AdvisoryExceptions{IDs: []string{"INTEL-SA-00586", "INTEL-SA-00614"}}
+	tests := []struct {
+		name       string
+		exceptions *AdvisoryExceptions
+		statuses   QuoteStatusPolicy
+		status     string
+		ids        []string
+		ok         bool
+	}{
+		{name: "every advisory excepted", exceptions: exceptions, statuses: StrictTCBStatus, status: "OutOfDate", ids: []string{"INTEL-SA-00586", "INTEL-SA-00614"}, ok: true},
+		{name: "advisory without exception", exceptions: exceptions, statuses: PermissiveTCBStatus, status: "OutOfDate", ids: []string{"INTEL-SA-00586", "INTEL-SA-00615"}},
+		{name: "no advisory, strict policy", exceptions: exceptions, statuses: StrictTCBStatus, status: "OutOfDate"},
+		{name: "no advisory, permissive policy", exceptions: exceptions, statuses: PermissiveTCBStatus, status: "OutOfDate", ok: true},
+		{name: "status not concerned", exceptions: exceptions, statuses: StrictTCBStatus, status: "ConfigurationNeeded", ids: []string{"INTEL-SA-00586"}},
+		{name: "up to date", exceptions: exceptions, statuses: StrictTCBStatus, status: "UpToDate", ok: true},
+		{name: "revoked", exceptions: exceptions, statuses: PermissiveTCBStatus, status: "Revoked", ids: []string{"INTEL-SA-00586"}},
+		{name: "custom statuses", exceptions: &AdvisoryExceptions{Statuses: []string{"SWHardeningNeeded"}, IDs: exceptions.IDs}, statuses: StrictTCBStatus, status: "OutOfDate", ids: []string{"INTEL-SA-00586"}},
+		{name: "no exceptions", statuses: StrictTCBStatus, status: "OutOfDate", ids: []string{"INTEL-SA-00586"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Verifier{AdvisoryExceptions: tt.exceptions, TCBStatuses: tt.statuses}
+			err := v.checkTCBStatus(tt.status, tt.ids)
+			if (err == nil) != tt.ok {
+				t.Fatalf("checkTCBStatus(%q, %v) error = %v, want ok %v", tt.status, tt.ids, err, tt.ok)
+			}
+			if err != nil && !errors.Is(err, ErrTCBOutOfDate) {
+				t.Errorf("checkTCBStatus(%q, %v) error = %v, want %v", tt.status, tt.ids, err, ErrTCBOutOfDate)
+			}
+		})
+	}
+}
+
+func TestReportAdvisories(t *testing.T) {
+	tests := []struct {
+		name       string
+		advisories *AdvisoryPolicy
+		ids        []string
+		wantErr    bool
+	}{
+		{name: "no policy", ids: []string{"INTEL-SA-00161"}},
+		{name: "allowed", advisories: &AdvisoryPolicy{Allowed: []string{"INTEL-SA-00161"}}, ids: []string{"INTEL-SA-00161"}},
+		{name: "rejected", advisories: &AdvisoryPolicy{}, ids: []string{"INTEL-SA-00161"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Verifier{Clock: FixedClock(testNow), Advisories: tt.advisories}
+			raw := testIASReport(t, EPIDLinkable, func(qr *QuoteReport) {
+				qr.AdvisoryIDs = tt.ids
+				qr.AdvisoryURL = "https://security-center.intel.com"
+			})
+			res := &VerificationResult{}
+			err := v.verifyAttReport(raw, res)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyAttReport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(res.AdvisoryIDs, tt.ids) || res.AdvisoryURL != "https://security-center.intel.com" {
+				t.Errorf("advisories = %v, %q", res.AdvisoryIDs, res.AdvisoryURL)
+			}
+		})
+	}
+}
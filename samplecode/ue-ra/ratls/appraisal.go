@@ -0,0 +1,212 @@
+package ratls
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Appraisal is the decision of an Appraiser and the reasons for it.
+type Appraisal struct {
+	Allow   bool
+	Reasons []string
+}
+
+// Appraiser decides whether an enclave that passed the built-in checks is
+// accepted, from the claims of its verified evidence. RulePolicy evaluates
+// JSON rules; other engines, such as an OPA query over the JSON encoded
+// result, can be plugged in by implementing it. It must be safe for
+// concurrent use.
+type Appraiser interface {
+	Appraise(res *VerificationResult) Appraisal
+}
+
+// Rule effects.
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// PolicyMatch selects the enclaves a rule applies to. Empty fields match
+// any value; values within a field are alternatives.
+type PolicyMatch struct {
+	Mode      []string `json:"mode,omitempty"`
+	MrEnclave []string `json:"mr_enclave,omitempty"`
+	MrSigner  []string `json:"mr_signer,omitempty"`
+	ISVProdID []uint16 `json:"isv_prod_id,omitempty"`
+}
+
+// PolicyRequirements must all hold for an allow rule to accept an enclave.
+type PolicyRequirements struct {
+	MinISVSVN  uint16 `json:"min_isv_svn,omitempty"`
+	AllowDebug bool   `json:"allow_debug,omitempty"`
+	KeyBound   bool   `json:"key_bound,omitempty"`
+	// QuoteStatuses and TCBStatuses list the accepted IAS quote and
+	// platform TCB statuses. An enclave whose status is unknown, e.g.
+	// without collateral, fails them.
+	QuoteStatuses []string `json:"quote_status,omitempty"`
+	TCBStatuses   []string `json:"tcb_status,omitempty"`
+	// Advisories, if present, lists the advisory IDs the evidence may
+	// carry; an empty list admits none.
+	Advisories []string `json:"allowed_advisories"`
+}
+
+// PolicyRule is one rule of a RulePolicy.
+type PolicyRule struct {
+	Name string `json:"name"`
+	// Effect is EffectAllow (the default) or EffectDeny.
+	Effect  string             `json:"effect,omitempty"`
+	Match   PolicyMatch        `json:"match"`
+	Require PolicyRequirements `json:"require"`
+}
+
+// RulePolicy appraises results with rules evaluated in order, of the form
+//
+//	{
+//	  "default": "deny",
+//	  "rules": [
+//	    {"name": "revoked build", "effect": "deny", "match": {"mr_enclave": ["<hex>"]}},
+//	    {"name": "production", "match": {"mr_signer": ["<hex>"]},
+//	     "require": {"min_isv_svn": 3, "tcb_status": ["UpToDate", "SWHardeningNeeded"],
+//	                 "allowed_advisories": ["INTEL-SA-00334"]}}
+//	  ]
+//	}
+//
+// The first rule matching the enclave decides: a deny rule rejects it, an
+// allow rule accepts it if all its requirements hold. If no rule matches,
+// Default applies ("deny" unless set to "allow").
+type RulePolicy struct {
+	Default string       `json:"default,omitempty"`
+	Rules   []PolicyRule `json:"rules"`
+}
+
+// ParseRulePolicy parses a JSON rule policy.
+func ParseRulePolicy(data []byte) (*RulePolicy, error) {
+	var p RulePolicy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parse rule policy: %v", err)
+	}
+	if p.Default != "" && p.Default != EffectAllow && p.Default != EffectDeny {
+		return nil, fmt.Errorf("unknown default effect %q", p.Default)
+	}
+	for i, r := range p.Rules {
+		if r.Effect != "" && r.Effect != EffectAllow && r.Effect != EffectDeny {
+			return nil, fmt.Errorf("rule %d: unknown effect %q", i, r.Effect)
+		}
+	}
+	return &p, nil
+}
+
+// LoadRulePolicy reads a JSON rule policy from path.
+func LoadRulePolicy(path string) (*RulePolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseRulePolicy(data)
+}
+
+// Appraise evaluates the rules against res.
+func (p *RulePolicy) Appraise(res *VerificationResult) Appraisal {
+	for i, r := range p.Rules {
+		if !r.Match.matches(res) {
+			continue
+		}
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i)
+		}
+		if r.Effect == EffectDeny {
+			return Appraisal{Reasons: []string{fmt.Sprintf("denied by rule %q", name)}}
+		}
+		failed := r.Require.check(res)
+		if len(failed) > 0 {
+			for j := range failed {
+				failed[j] = fmt.Sprintf("rule %q: %s", name, failed[j])
+			}
+			return Appraisal{Reasons: failed}
+		}
+		return Appraisal{Allow: true, Reasons: []string{fmt.Sprintf("allowed by rule %q", name)}}
+	}
+	if p.Default == EffectAllow {
+		return Appraisal{Allow: true, Reasons: []string{"allowed by default"}}
+	}
+	return Appraisal{Reasons: []string{"no rule matches the enclave"}}
+}
+
+func (m *PolicyMatch) matches(res *VerificationResult) bool {
+	if len(m.Mode) > 0 && !containsFold(m.Mode, res.Mode.String()) {
+		return false
+	}
+	if len(m.MrEnclave) > 0 && !containsFold(m.MrEnclave, res.MrEnclave) {
+		return false
+	}
+	if len(m.MrSigner) > 0 && !containsFold(m.MrSigner, res.MrSigner) {
+		return false
+	}
+	if len(m.ISVProdID) > 0 {
+		found := false
+		for _, id := range m.ISVProdID {
+			found = found || id == res.ISVProdID
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// check returns the requirements res fails.
+func (r *PolicyRequirements) check(res *VerificationResult) []string {
+	var failed []string
+	if res.ISVSVN < r.MinISVSVN {
+		failed = append(failed, fmt.Sprintf("ISV SVN %d is below %d", res.ISVSVN, r.MinISVSVN))
+	}
+	if res.Debug && !r.AllowDebug {
+		failed = append(failed, "debug enclaves are not allowed")
+	}
+	if r.KeyBound && !res.KeyBound {
+		failed = append(failed, "report_data does not bind the certificate key")
+	}
+	if len(r.QuoteStatuses) > 0 && !containsFold(r.QuoteStatuses, res.QuoteStatus) {
+		failed = append(failed, fmt.Sprintf("quote status %q is not allowed", res.QuoteStatus))
+	}
+	if len(r.TCBStatuses) > 0 && !containsFold(r.TCBStatuses, res.TCBStatus) {
+		failed = append(failed, fmt.Sprintf("TCB status %q is not allowed", res.TCBStatus))
+	}
+	if r.Advisories != nil {
+		for _, id := range res.AdvisoryIDs {
+			if !containsFold(r.Advisories, id) {
+				failed = append(failed, fmt.Sprintf("advisory %s is not allowed", id))
+			}
+		}
+	}
+	return failed
+}
+
+func containsFold(list []string, s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// appraise applies v.Policy to an otherwise accepted result.
+func (v *Verifier) appraise(res *VerificationResult) error {
+	a := v.Policy.Appraise(res)
+	res.PolicyReasons = a.Reasons
+	if !a.Allow {
+		if len(a.Reasons) == 0 {
+			return failure(ErrPolicyDenied, errors.New("enclave denied by policy"))
+		}
+		return failuref(ErrPolicyDenied, "enclave denied by policy: %s", strings.Join(a.Reasons, "; "))
+	}
+	return nil
+}
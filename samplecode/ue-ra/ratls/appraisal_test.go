@@ -0,0 +1,178 @@
+package ratls
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseRulePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "rules", data: `{"default": "deny", "rules": [{"name": "old", "effect": "deny", "match": {"isv_prod_id": [1]}}, {"match": {"mr_signer": ["bb"]}, "require": {"min_isv_svn": 2}}]}`},
+		{name: "default allow", data: `{"default": "allow"}`},
+		{name: "unknown default", data: `{"default": "permit"}`, wantErr: true},
+		{name: "unknown effect", data: `{"rules": [{"effect": "audit"}]}`, wantErr: true},
+		{name: "wrong field type", data: `{"rules": [{"match": {"isv_prod_id": ["1"]}}]}`, wantErr: true},
+		{name: "not JSON", data: `default: deny`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseRulePolicy([]byte(tt.data)); (err != nil) != tt.wantErr {
+				t.Errorf("ParseRulePolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRulePolicyAppraise(t *testing.T) {
+	policy, err := ParseRulePolicy([]byte(`{
+		"rules": [
+			{"name": "revoked build", "effect": "deny", "match": {"mr_enclave": ["DEAD"]}},
+			{"name": "production", "match": {"mode": ["dcap"], "mr_signer": ["bb"], "isv_prod_id": [1, 2]},
+			 "require": {"min_isv_svn": 3, "key_bound": true, "tcb_status": ["UpToDate", "SWHardeningNeeded"],
+			             "allowed_advisories": ["INTEL-SA-00334"]}},
+			{"name": "staging", "match": {"mr_signer": ["cc"]}, "require": {"allow_debug": true, "allowed_advisories": []}}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	production := func(edit func(*VerificationResult)) *VerificationResult {
+		res := &VerificationResult{
+			Mode:      ModeDCAP,
+			MrEnclave: "aa",
+			MrSigner:  "BB",
+			ISVProdID: 1,
+			ISVSVN:    3,
+			KeyBound:  true,
+			TCBStatus: "SWHardeningNeeded",
+		}
+		if edit != nil {
+			edit(res)
+		}
+		return res
+	}
+	tests := []struct {
+		name        string
+		policy      *RulePolicy
+		res         *VerificationResult
+		wantAllow   bool
+		wantReasons []string
+	}{
+		{name: "allowed", policy: policy, res: production(nil), wantAllow: true, wantReasons: []string{`allowed by rule "production"`}},
+		{
+			name:        "allowed advisory",
+			policy:      policy,
+			res:         production(func(r *VerificationResult) { r.AdvisoryIDs = []string{"intel-sa-00334"} }),
+			wantAllow:   true,
+			wantReasons: []string{`allowed by rule "production"`},
+		},
+		{
+			name:        "denied build",
+			policy:      policy,
+			res:         production(func(r *VerificationResult) { r.MrEnclave = "dead" }),
+			wantReasons: []string{`denied by rule "revoked build"`},
+		},
+		{
+			name:   "requirements failed",
+			policy: policy,
+			res: production(func(r *VerificationResult) {
+				r.ISVSVN = 2
+				r.Debug = true
+				r.KeyBound = false
+				r.TCBStatus = "OutOfDate"
+				r.AdvisoryIDs = []string{"INTEL-SA-00615"}
+			}),
+			wantReasons: []string{
+				`rule "production": ISV SVN 2 is below 3`,
+				`rule "production": debug enclaves are not allowed`,
+				`rule "production": report_data does not bind the certificate key`,
+				`rule "production": TCB status "OutOfDate" is not allowed`,
+				`rule "production": advisory INTEL-SA-00615 is not allowed`,
+			},
+		},
+		{
+			name:        "unknown TCB status",
+			policy:      policy,
+			res:         production(func(r *VerificationResult) { r.TCBStatus = "" }),
+			wantReasons: []string{`rule "production": TCB status "" is not allowed`},
+		},
+		{
+			name:        "debug staging enclave",
+			policy:      policy,
+			res:         &VerificationResult{Mode: ModeSim, MrSigner: "cc", Debug: true},
+			wantAllow:   true,
+			wantReasons: []string{`allowed by rule "staging"`},
+		},
+		{
+			name:        "no advisories admitted",
+			policy:      policy,
+			res:         &VerificationResult{MrSigner: "cc", AdvisoryIDs: []string{"INTEL-SA-00334"}},
+			wantReasons: []string{`rule "staging": advisory INTEL-SA-00334 is not allowed`},
+		},
+		{
+			name:        "other mode",
+			policy:      policy,
+			res:         production(func(r *VerificationResult) { r.Mode = ModeEPID }),
+			wantReasons: []string{"no rule matches the enclave"},
+		},
+		{
+			name:        "other product",
+			policy:      policy,
+			res:         production(func(r *VerificationResult) { r.ISVProdID = 3 }),
+			wantReasons: []string{"no rule matches the enclave"},
+		},
+		{
+			name:        "default allow",
+			policy:      &RulePolicy{Default: EffectAllow, Rules: policy.Rules},
+			res:         &VerificationResult{MrSigner: "dd"},
+			wantAllow:   true,
+			wantReasons: []string{"allowed by default"},
+		},
+		{
+			name:        "unnamed rule",
+			policy:      &RulePolicy{Rules: []PolicyRule{{Effect: EffectDeny}}},
+			res:         production(nil),
+			wantReasons: []string{`denied by rule "#0"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.policy.Appraise(tt.res)
+			if got.Allow != tt.wantAllow || !reflect.DeepEqual(got.Reasons, tt.wantReasons) {
+				t.Errorf("Appraise() = %v %q, want %v %q", got.Allow, got.Reasons, tt.wantAllow, tt.wantReasons)
+			}
+		})
+	}
+}
+
+func TestVerifierPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  *RulePolicy
+		wantErr bool
+	}{
+		{name: "allowed", policy: &RulePolicy{Rules: []PolicyRule{{Match: PolicyMatch{Mode: []string{"sim"}}}}}},
+		{name: "denied", policy: &RulePolicy{Rules: []PolicyRule{{Effect: EffectDeny}}}, wantErr: true},
+		{name: "no rule matches", policy: &RulePolicy{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Verifier{AllowSimulation: true, Policy: tt.policy}
+			res, err := v.Verify(newSimulatedCert(t, 1))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, ErrPolicyDenied) {
+				t.Errorf("Verify() error = %v, want %v", err, ErrPolicyDenied)
+			}
+			if res == nil || len(res.PolicyReasons) != 1 {
+				t.Errorf("Verify() policy reasons = %+v", res)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package ratls
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Evidence that exceeds practical certificate sizes, such as TDX quotes with
+// their certification data or attestation tokens, can be sent after the
+// handshake instead. The attested peer then presents an ordinary
+// certificate and, as its first application data, an attest message: the
+// evidence payload, laid out as in a certificate, preceded by its length as
+// a 4 byte big-endian integer. Its report_data, or the runtime data of a
+// token, must start with ChannelBinding of the connection.
+
+// EvidenceExporterLabel is the TLS exporter label of the keying material
+// ChannelBinding is derived from.
+const EvidenceExporterLabel = "EXPORTER-Teaclave-RA-TLS-Evidence"
+
+// MaxEvidenceSize is the largest attest message ReadEvidence accepts.
+const MaxEvidenceSize = 1 << 20
+
+// ChannelBinding returns the value the report_data of evidence sent after
+// the handshake of cs must start with: the SHA-256 of 32 bytes of keying
+// material exported with EvidenceExporterLabel. It is unique to the TLS
+// session, so the evidence can neither be replayed on another connection
+// nor relayed by a peer that does not hold the session keys.
+//
+// TLS 1.2 connections must have negotiated the extended master secret.
+func ChannelBinding(cs tls.ConnectionState) ([]byte, error) {
+	if !cs.HandshakeComplete {
+		return nil, errors.New("TLS handshake is not complete")
+	}
+	ekm, err := cs.ExportKeyingMaterial(EvidenceExporterLabel, nil, 32)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(ekm)
+	return digest[:], nil
+}
+
+// WriteEvidence writes an attest message carrying payload to w.
+func WriteEvidence(w io.Writer, payload []byte) error {
+	if len(payload) > MaxEvidenceSize {
+		return fmt.Errorf("evidence of %d bytes exceeds %d", len(payload), MaxEvidenceSize)
+	}
+	msg := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(msg, uint32(len(payload)))
+	copy(msg[4:], payload)
+	_, err := w.Write(msg)
+	return err
+}
+
+// ReadEvidence reads an attest message from r and returns its payload.
+func ReadEvidence(r io.Reader) ([]byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, fmt.Errorf("read attest message: %v", err)
+	}
+	n := binary.BigEndian.Uint32(hdr[:])
+	if n == 0 || n > MaxEvidenceSize {
+		return nil, fmt.Errorf("attest message of %d bytes", n)
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, fmt.Errorf("read attest message: %v", err)
+	}
+	return payload, nil
+}
+
+// SendEvidence completes the handshake of conn if needed and sends the
+// evidence returned by attest, which is given the ChannelBinding to put in
+// report_data. It is the attester side of a PostHandshakeClient or
+// PostHandshakeServer peer.
+func SendEvidence(conn *tls.Conn, attest func(binding []byte) ([]byte, error)) error {
+	if err := conn.Handshake(); err != nil {
+		return err
+	}
+	binding, err := ChannelBinding(conn.ConnectionState())
+	if err != nil {
+		return err
+	}
+	payload, err := attest(binding)
+	if err != nil {
+		return err
+	}
+	return WriteEvidence(conn, payload)
+}
+
+// ChannelVerifier verifies evidence received after the handshake of a
+// connection. Verifier implements it.
+type ChannelVerifier interface {
+	VerifyChannelEvidence(peer string, cs tls.ConnectionState, payload []byte) (*VerificationResult, error)
+}
+
+// VerifyChannelEvidence verifies the payload of an attest message received
+// from peer on the connection cs, which must bind the connection: KeyBound
+// reports that report_data starts with ChannelBinding(cs), which
+// PublicKey holds, and evidence that does not bind it is rejected.
+// Verifier.Binding does not apply. With PKIRoots, the certificate chain of
+// the peer is checked as well, and Evidence archives it with the payload.
+func (v *Verifier) VerifyChannelEvidence(peer string, cs tls.ConnectionState, payload []byte) (*VerificationResult, error) {
+	start := time.Now()
+	binding, err := ChannelBinding(cs)
+	if err != nil {
+		return nil, err
+	}
+	ev := &evidence{pubKey: binding, channel: true, comment: payload}
+	res, err := v.verifyEvidence(ev)
+	rawCerts := make([][]byte, len(cs.PeerCertificates))
+	for i, cert := range cs.PeerCertificates {
+		rawCerts[i] = cert.Raw
+	}
+	if err == nil && v.PKIRoots != nil {
+		if len(rawCerts) == 0 {
+			err = errors.New("no certificate presented by peer")
+		} else {
+			err = v.verifyPKI(rawCerts)
+		}
+		res.PKIVerified = err == nil
+	}
+	if err == nil && v.Evidence != nil {
+		err = v.archive(peer, res, ev, rawCerts)
+	}
+	v.observe(peer, res, err, start)
+	return res, err
+}
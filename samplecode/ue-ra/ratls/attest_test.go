@@ -0,0 +1,117 @@
+package ratls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// testSimulatedEvidence returns a simulated EPID quote body whose
+// report_data starts with reportData.
+func testSimulatedEvidence(reportData []byte) []byte {
+	quote := EPIDQuote{Header: EPIDQuoteHeader{Version: 2, SignType: EPIDLinkable}}
+	copy(quote.ReportBody.ReportData[:], reportData)
+	var raw bytes.Buffer
+	binary.Write(&raw, binary.LittleEndian, quote.Header)
+	binary.Write(&raw, binary.LittleEndian, quote.ReportBody)
+	return raw.Bytes()
+}
+
+func TestReadEvidence(t *testing.T) {
+	msg := func(n uint32, payload []byte) []byte {
+		return append(binary.BigEndian.AppendUint32(nil, n), payload...)
+	}
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []byte
+		wantErr bool
+	}{
+		{name: "payload", data: msg(7, []byte("payload")), want: []byte("payload")},
+		{name: "followed by data", data: msg(7, []byte("payload and more")), want: []byte("payload")},
+		{name: "empty", data: msg(0, nil), wantErr: true},
+		{name: "too large", data: msg(MaxEvidenceSize+1, []byte("payload")), wantErr: true},
+		{name: "truncated payload", data: msg(8, []byte("payload")), wantErr: true},
+		{name: "truncated length", data: []byte{0, 0, 7}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadEvidence(bytes.NewReader(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadEvidence() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("ReadEvidence() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteEvidence(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		wantErr bool
+	}{
+		{name: "payload", payload: []byte("payload")},
+		{name: "largest", payload: make([]byte, MaxEvidenceSize)},
+		{name: "too large", payload: make([]byte, MaxEvidenceSize+1), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := WriteEvidence(&buf, tt.payload)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WriteEvidence() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got, err := ReadEvidence(&buf); err != nil || !bytes.Equal(got, tt.payload) {
+				t.Errorf("ReadEvidence(WriteEvidence()) = %d bytes, %v, want %d bytes", len(got), err, len(tt.payload))
+			}
+		})
+	}
+}
+
+func TestVerifyChannelEvidence(t *testing.T) {
+	client, server := testHandshake(t, 0)
+	binding, err := ChannelBinding(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := testHandshake(t, 0)
+	otherBinding, err := ChannelBinding(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		verifier *Verifier
+		payload  []byte
+		wantErr  bool
+		wantIs   error
+	}{
+		{name: "bound", verifier: &Verifier{AllowSimulation: true}, payload: testSimulatedEvidence(binding)},
+		{name: "binding ignored", verifier: &Verifier{AllowSimulation: true, Binding: BindingSPKIHash}, payload: testSimulatedEvidence(binding)},
+		{name: "other connection", verifier: &Verifier{AllowSimulation: true}, payload: testSimulatedEvidence(otherBinding), wantErr: true, wantIs: ErrKeyNotBound},
+		{name: "not bound", verifier: &Verifier{AllowSimulation: true}, payload: testSimulatedEvidence(nil), wantErr: true, wantIs: ErrKeyNotBound},
+		{name: "simulation not allowed", verifier: &Verifier{}, payload: testSimulatedEvidence(binding), wantErr: true},
+		{name: "malformed", verifier: &Verifier{AllowSimulation: true}, payload: []byte("evidence"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := tt.verifier.VerifyChannelEvidence("peer", client, tt.payload)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyChannelEvidence() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Errorf("VerifyChannelEvidence() error = %v, want %v", err, tt.wantIs)
+			}
+			if err == nil && (!res.KeyBound || !bytes.Equal(res.PublicKey, binding)) {
+				t.Errorf("VerifyChannelEvidence() KeyBound = %v, PublicKey = %x", res.KeyBound, res.PublicKey)
+			}
+		})
+	}
+}
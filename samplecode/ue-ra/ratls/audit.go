@@ -0,0 +1,106 @@
+package ratls
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// AuditRecord describes one verification and its outcome.
+type AuditRecord struct {
+	Time          time.Time `json:"time"`
+	Peer          string    `json:"peer,omitempty"`
+	Mode          string    `json:"mode"`
+	MrEnclave     string    `json:"mr_enclave,omitempty"`
+	MrSigner      string    `json:"mr_signer,omitempty"`
+	ISVProdID     uint16    `json:"isv_prod_id"`
+	ISVSVN        uint16    `json:"isv_svn"`
+	Debug         bool      `json:"debug"`
+	QuoteStatus   string    `json:"quote_status,omitempty"`
+	TCBStatus     string    `json:"tcb_status,omitempty"`
+	AdvisoryIDs   []string  `json:"advisory_ids,omitempty"`
+	PolicyVersion string    `json:"policy_version,omitempty"`
+	// TestRoots marks verifications against insecure test roots.
+	TestRoots bool `json:"insecure_test_roots,omitempty"`
+	// Simulated marks enclaves accepted in simulation mode.
+	Simulated bool `json:"simulated,omitempty"`
+	// Decision is "accept" or "reject"; Error gives the reason of a
+	// rejection.
+	Decision string `json:"decision"`
+	Error    string `json:"error,omitempty"`
+}
+
+// AuditSink receives an AuditRecord for every verification. It must be
+// safe for concurrent use.
+type AuditSink interface {
+	Audit(rec *AuditRecord)
+}
+
+// JSONAuditSink writes audit records as JSON lines, e.g. to a file tailed
+// by a SIEM agent.
+type JSONAuditSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONAuditSink returns a sink writing to w.
+func NewJSONAuditSink(w io.Writer) *JSONAuditSink {
+	return &JSONAuditSink{enc: json.NewEncoder(w)}
+}
+
+// Audit writes rec as one line. Write errors are dropped, auditing must not
+// change the verification outcome.
+func (s *JSONAuditSink) Audit(rec *AuditRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enc.Encode(rec)
+}
+
+func (m Mode) String() string {
+	switch m {
+	case ModeEPID:
+		return "epid"
+	case ModeDCAP:
+		return "dcap"
+	case ModeTDX:
+		return "tdx"
+	case ModeAuto:
+		return "auto"
+	case ModeMAA:
+		return "maa"
+	case ModeITA:
+		return "ita"
+	case ModeSim:
+		return "sim"
+	}
+	return "unknown"
+}
+
+func (v *Verifier) audit(peer string, res *VerificationResult, err error) {
+	rec := &AuditRecord{
+		Time:          v.now(),
+		Peer:          peer,
+		Mode:          v.Mode.String(),
+		PolicyVersion: v.PolicyVersion,
+		Decision:      "accept",
+	}
+	if res != nil {
+		rec.Mode = res.Mode.String()
+		rec.MrEnclave = res.MrEnclave
+		rec.MrSigner = res.MrSigner
+		rec.ISVProdID = res.ISVProdID
+		rec.ISVSVN = res.ISVSVN
+		rec.Debug = res.Debug
+		rec.QuoteStatus = res.QuoteStatus
+		rec.TCBStatus = res.TCBStatus
+		rec.AdvisoryIDs = res.AdvisoryIDs
+		rec.TestRoots = res.InsecureTestRoots
+		rec.Simulated = res.Simulated
+	}
+	if err != nil {
+		rec.Decision = "reject"
+		rec.Error = err.Error()
+	}
+	v.Audit.Audit(rec)
+}
@@ -0,0 +1,55 @@
+package ratls
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	tests := []struct {
+		name         string
+		raw          []byte
+		wantDecision string
+		wantMode     string
+		wantDebug    bool
+		wantError    bool
+	}{
+		{name: "accepted", raw: newSimulatedCert(t, 1), wantDecision: "accept", wantMode: "sim"},
+		{
+			name:         "rejected enclave",
+			raw:          newSimulatedCertWith(t, func(body *ReportBody) { body.Attributes.Flags = sgxFlagsDebug }),
+			wantDecision: "reject",
+			wantMode:     "sim",
+			wantDebug:    true,
+			wantError:    true,
+		},
+		{name: "no evidence", raw: []byte("certificate"), wantDecision: "reject", wantMode: "epid", wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			v := &Verifier{
+				AllowSimulation: true,
+				Audit:           NewJSONAuditSink(&out),
+				PolicyVersion:   "v1",
+				Clock:           FixedClock(testNow),
+			}
+			v.VerifyPeerChain("10.0.0.1:443", [][]byte{tt.raw})
+
+			var rec AuditRecord
+			if err := json.Unmarshal(out.Bytes(), &rec); err != nil {
+				t.Fatalf("audit record %q: %v", out.Bytes(), err)
+			}
+			if rec.Decision != tt.wantDecision || rec.Mode != tt.wantMode || rec.Debug != tt.wantDebug || (rec.Error != "") != tt.wantError {
+				t.Errorf("record = %+v", rec)
+			}
+			if rec.Peer != "10.0.0.1:443" || rec.PolicyVersion != "v1" || !rec.Time.Equal(testNow) {
+				t.Errorf("record peer, policy version, time = %q, %q, %v", rec.Peer, rec.PolicyVersion, rec.Time)
+			}
+			if tt.wantMode == "sim" && (!rec.Simulated || rec.MrEnclave == "") {
+				t.Errorf("record of a simulated enclave = %+v", rec)
+			}
+		})
+	}
+}
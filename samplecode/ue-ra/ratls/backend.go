@@ -0,0 +1,45 @@
+package ratls
+
+import (
+	"errors"
+	"time"
+)
+
+// QuoteBackend verifies ECDSA quotes in place of the built-in verifier, for
+// instance QVLBackend which delegates to the Intel quote verification
+// library (build tag qvl).
+type QuoteBackend interface {
+	// VerifyQuote checks the signature, certificate chain and collateral of
+	// a v3 or v4 quote as of now and returns the platform TCB status
+	// ("UpToDate", "OutOfDate", ...).
+	VerifyQuote(quote []byte, now time.Time) (tcbStatus string, err error)
+}
+
+// verifyWithBackend verifies a quote with v.Backend and decodes the enclave
+// or TD identity from it.
+func (v *Verifier) verifyWithBackend(rawQuote []byte, now time.Time, res *VerificationResult) error {
+	status, err := v.Backend.VerifyQuote(rawQuote, now)
+	if err != nil {
+		return err
+	}
+	res.TCBStatus = status
+
+	quote, err := parseECDSAQuote(rawQuote)
+	if err != nil {
+		return err
+	}
+	res.QuoteVersion = int(quote.Header.Version)
+	if quote.TDReport != nil {
+		res.setTDReport(quote.TDReport)
+	} else {
+		res.setReportBody(quote.ReportBody.quoteReportBody())
+	}
+	if (res.Mode == ModeTDX) != (res.TDReport != nil) {
+		return errors.New("quote TEE type does not match the attestation mode")
+	}
+	// The backend reports no advisories to apply AdvisoryExceptions to
+	if err := v.tcbStatusPolicy().Check(status); err != nil {
+		return failure(ErrTCBOutOfDate, err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package ratls
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeBackend returns status or err for every quote.
+type fakeBackend struct {
+	status string
+	err    error
+}
+
+func (b fakeBackend) VerifyQuote([]byte, time.Time) (string, error) {
+	return b.status, b.err
+}
+
+func TestVerifyWithBackend(t *testing.T) {
+	sgxQuote := newTestQuoteV3().raw()
+	tdQuote := testQuoteV4(quoteVersionV4, TEETypeTDX, make([]byte, tdReportLen), testSignatureDataV4())
+	tests := []struct {
+		name     string
+		verifier Verifier
+		mode     Mode
+		quote    []byte
+		wantErr  bool
+		is       error
+	}{
+		{name: "SGX up to date", verifier: Verifier{Backend: fakeBackend{status: "UpToDate"}}, mode: ModeDCAP, quote: sgxQuote},
+		{name: "TD up to date", verifier: Verifier{Backend: fakeBackend{status: "UpToDate"}}, mode: ModeTDX, quote: tdQuote},
+		{name: "out of date, permissive", verifier: Verifier{Backend: fakeBackend{status: "OutOfDate"}}, mode: ModeDCAP, quote: sgxQuote},
+		{
+			name:     "out of date, strict",
+			verifier: Verifier{Backend: fakeBackend{status: "OutOfDate"}, TCBStatuses: StrictTCBStatus},
+			mode:     ModeDCAP,
+			quote:    sgxQuote,
+			wantErr:  true,
+			is:       ErrTCBOutOfDate,
+		},
+		{name: "revoked", verifier: Verifier{Backend: fakeBackend{status: "Revoked"}}, mode: ModeDCAP, quote: sgxQuote, wantErr: true, is: ErrTCBOutOfDate},
+		{name: "backend failure", verifier: Verifier{Backend: fakeBackend{err: errors.New("bad signature")}}, mode: ModeDCAP, quote: sgxQuote, wantErr: true},
+		{name: "TD quote in DCAP mode", verifier: Verifier{Backend: fakeBackend{status: "UpToDate"}}, mode: ModeDCAP, quote: tdQuote, wantErr: true},
+		{name: "SGX quote in TDX mode", verifier: Verifier{Backend: fakeBackend{status: "UpToDate"}}, mode: ModeTDX, quote: sgxQuote, wantErr: true},
+		{name: "malformed quote", verifier: Verifier{Backend: fakeBackend{status: "UpToDate"}}, mode: ModeDCAP, quote: sgxQuote[:100], wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &VerificationResult{Mode: tt.mode}
+			err := tt.verifier.verifyWithBackend(tt.quote, testNow, res)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyWithBackend() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.is != nil && !errors.Is(err, tt.is) {
+				t.Errorf("verifyWithBackend() error = %v, want %v", err, tt.is)
+			}
+		})
+	}
+}
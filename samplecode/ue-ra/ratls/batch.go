@@ -0,0 +1,66 @@
+package ratls
+
+import (
+	"crypto/x509"
+	"runtime"
+	"sync"
+)
+
+// BatchResult is the outcome of verifying one item of a batch. On failure
+// Result, if not nil, holds what was established before the failing check.
+type BatchResult struct {
+	Result *VerificationResult
+	Err    error
+}
+
+// VerifyAll verifies many items concurrently, e.g. evidence collected when
+// registering a fleet of enclaves. Each item is a DER encoded RA-TLS
+// certificate, verified as by Verify, or saved evidence, verified as by
+// VerifyEvidence. The results are in the order of items.
+//
+// Unless v.Collateral is a CollateralCache already, the collateral is
+// cached for the batch, so platforms sharing an FMSPC fetch it once.
+func (v *Verifier) VerifyAll(items [][]byte) []BatchResult {
+	batch := v
+	if v.Collateral != nil {
+		if _, ok := v.Collateral.(*CollateralCache); !ok {
+			shared := *v
+			shared.Collateral = &CollateralCache{Source: v.Collateral}
+			batch = &shared
+		}
+	}
+
+	results := make([]BatchResult, len(items))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(items) {
+		workers = len(items)
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = batch.verifyItem(items[i])
+			}
+		}()
+	}
+	for i := range items {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
+
+func (v *Verifier) verifyItem(item []byte) BatchResult {
+	var res *VerificationResult
+	var err error
+	if _, perr := x509.ParseCertificate(item); perr == nil {
+		res, err = v.Verify(item)
+	} else {
+		res, err = v.VerifyEvidence(item)
+	}
+	return BatchResult{Result: res, Err: err}
+}
@@ -0,0 +1,44 @@
+package ratls
+
+import "testing"
+
+func TestVerifyAll(t *testing.T) {
+	tests := []struct {
+		name         string
+		items        [][]byte
+		wantEnclaves []string
+		wantErrs     []bool
+	}{
+		{name: "empty"},
+		{
+			name:         "certificates",
+			items:        [][]byte{newSimulatedCert(t, 1), newSimulatedCert(t, 2)},
+			wantEnclaves: []string{"01", "02"},
+			wantErrs:     []bool{false, false},
+		},
+		{
+			name:         "malformed item",
+			items:        [][]byte{newSimulatedCert(t, 1), []byte("not evidence"), newSimulatedCert(t, 3)},
+			wantEnclaves: []string{"01", "", "03"},
+			wantErrs:     []bool{false, true, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Verifier{AllowSimulation: true}
+			got := v.VerifyAll(tt.items)
+			if len(got) != len(tt.items) {
+				t.Fatalf("VerifyAll() = %d results, want %d", len(got), len(tt.items))
+			}
+			for i, r := range got {
+				if (r.Err != nil) != tt.wantErrs[i] {
+					t.Errorf("item %d: error = %v, wantErr %v", i, r.Err, tt.wantErrs[i])
+					continue
+				}
+				if r.Err == nil && r.Result.MrEnclave[:2] != tt.wantEnclaves[i] {
+					t.Errorf("item %d: MrEnclave = %s, want %s...", i, r.Result.MrEnclave, tt.wantEnclaves[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package ratls
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+// KeyBinding selects what report_data must start with for the evidence to
+// bind the certificate key. Evidence that does not bind it is rejected.
+type KeyBinding int
+
+const (
+	// BindingKeyOrSPKIHash, the default, accepts either BindingSPKIHash,
+	// the RA-TLS convention of most other stacks, or BindingKey, so that
+	// Teaclave enclaves and those stacks are verified alike. PublicKey
+	// holds the value report_data was found to start with.
+	BindingKeyOrSPKIHash KeyBinding = iota
+	// BindingKey expects the key encoding itself: the uncompressed P-256
+	// point without its 0x04 prefix (as Teaclave enclaves produce), the raw
+	// Ed25519 key, or the SHA-256 of the SubjectPublicKeyInfo for P-384 and
+	// RSA keys, which do not fit.
+	BindingKey
+	// BindingSPKIHash expects the SHA-256 of the DER SubjectPublicKeyInfo
+	// for every key type.
+	BindingSPKIHash
+	// BindingKeyNonceHash expects SHA-256(key encoding || Verifier.Nonce),
+	// which binds the key and the challenge of the connection at once.
+	// TCG DICE tagged evidence, which binds its claims instead, is
+	// rejected.
+	BindingKeyNonceHash
+	// BindingCustom expects the value returned by Verifier.BindingData.
+	BindingCustom
+)
+
+// ParseKeyBinding accepts "key", "spki-sha256", "key-nonce-sha256" or
+// "auto" (BindingKeyOrSPKIHash).
+func ParseKeyBinding(s string) (KeyBinding, error) {
+	switch s {
+	case "key":
+		return BindingKey, nil
+	case "spki-sha256":
+		return BindingSPKIHash, nil
+	case "key-nonce-sha256":
+		return BindingKeyNonceHash, nil
+	case "auto":
+		return BindingKeyOrSPKIHash, nil
+	}
+	return 0, fmt.Errorf("unknown key binding %q", s)
+}
+
+// bindingValue returns the value report_data must start with for the key
+// of ev.cert. TCG DICE tagged evidence always binds the hash of its
+// claims, which carry the key hash themselves but no nonce, so it cannot
+// satisfy BindingKeyNonceHash.
+func (v *Verifier) bindingValue(ev *evidence) ([]byte, error) {
+	if ev.claimsBound {
+		if v.Binding == BindingKeyNonceHash {
+			return nil, failure(ErrKeyNotBound, errors.New("TCG DICE tagged evidence binds its claims, not the nonce; use another key binding"))
+		}
+		return ev.pubKey, nil
+	}
+	switch v.Binding {
+	case BindingKey:
+		return ev.pubKey, nil
+	case BindingKeyOrSPKIHash:
+		digest := sha256.Sum256(ev.cert.RawSubjectPublicKeyInfo)
+		ev.altPubKey = digest[:]
+		return ev.pubKey, nil
+	case BindingSPKIHash:
+		digest := sha256.Sum256(ev.cert.RawSubjectPublicKeyInfo)
+		return digest[:], nil
+	case BindingKeyNonceHash:
+		if len(v.Nonce) == 0 {
+			return nil, errors.New("nonce key binding requires a nonce")
+		}
+		h := sha256.New()
+		h.Write(ev.pubKey)
+		h.Write(v.Nonce)
+		ev.nonceBound = true
+		return h.Sum(nil), nil
+	case BindingCustom:
+		if v.BindingData == nil {
+			return nil, errors.New("custom key binding requires BindingData")
+		}
+		return v.BindingData(ev.cert, v.Nonce)
+	}
+	return nil, errors.New("unknown key binding")
+}
+
+// equalBytes compares values taken from evidence, such as report_data, in
+// constant time, so a forger learns nothing from how long a mismatch takes.
+func equalBytes(a, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// hasPrefixBytes is equalBytes for the start of b.
+func hasPrefixBytes(b, prefix []byte) bool {
+	return len(b) >= len(prefix) && equalBytes(b[:len(prefix)], prefix)
+}
+
+// BindingDataFunc computes the report_data prefix of BindingCustom for a
+// certificate and the nonce of the connection, if any.
+type BindingDataFunc func(cert *x509.Certificate, nonce []byte) ([]byte, error)
@@ -0,0 +1,98 @@
+package ratls
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"testing"
+)
+
+func TestBindingValue(t *testing.T) {
+	nonce := bytes.Repeat([]byte{0xab}, NonceSize)
+	key := bytes.Repeat([]byte{0x02}, 64)
+	claimsHash := bytes.Repeat([]byte{0x03}, 32)
+	keyNonce := sha256.Sum256(append(append([]byte{}, key...), nonce...))
+	cert := &x509.Certificate{RawSubjectPublicKeyInfo: []byte("subject public key info")}
+	spkiHash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	tests := []struct {
+		name       string
+		verifier   Verifier
+		ev         evidence
+		want       []byte
+		alt        []byte
+		nonceBound bool
+		wantErr    bool
+		// is is the sentinel the error must match, if any.
+		is error
+	}{
+		{name: "zero value", ev: evidence{pubKey: key, cert: cert}, want: key, alt: spkiHash[:]},
+		{name: "key", verifier: Verifier{Binding: BindingKey}, ev: evidence{pubKey: key}, want: key},
+		{name: "SPKI hash", verifier: Verifier{Binding: BindingSPKIHash}, ev: evidence{pubKey: key, cert: cert}, want: spkiHash[:]},
+		{name: "key and nonce", verifier: Verifier{Binding: BindingKeyNonceHash, Nonce: nonce}, ev: evidence{pubKey: key}, want: keyNonce[:], nonceBound: true},
+		{name: "key and no nonce", verifier: Verifier{Binding: BindingKeyNonceHash}, ev: evidence{pubKey: key}, wantErr: true},
+		{name: "claims", verifier: Verifier{Binding: BindingKey}, ev: evidence{pubKey: claimsHash, claimsBound: true}, want: claimsHash},
+		{name: "claims and nonce", verifier: Verifier{Binding: BindingKeyNonceHash, Nonce: nonce}, ev: evidence{pubKey: claimsHash, claimsBound: true}, wantErr: true, is: ErrKeyNotBound},
+		{name: "custom without BindingData", verifier: Verifier{Binding: BindingCustom}, ev: evidence{pubKey: key}, wantErr: true},
+		{
+			name: "custom",
+			verifier: Verifier{Binding: BindingCustom, Nonce: nonce, BindingData: func(c *x509.Certificate, n []byte) ([]byte, error) {
+				return append(append([]byte{}, c.RawSubjectPublicKeyInfo...), n...), nil
+			}},
+			ev:   evidence{pubKey: key, cert: cert},
+			want: append(append([]byte{}, cert.RawSubjectPublicKeyInfo...), nonce...),
+		},
+		{name: "unknown binding", verifier: Verifier{Binding: BindingCustom + 1}, ev: evidence{pubKey: key}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := tt.ev
+			got, err := tt.verifier.bindingValue(&ev)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("bindingValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if tt.is != nil && !errors.Is(err, tt.is) {
+					t.Errorf("bindingValue() error = %v, want %v", err, tt.is)
+				}
+				return
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("bindingValue() = %x, want %x", got, tt.want)
+			}
+			if !bytes.Equal(ev.altPubKey, tt.alt) {
+				t.Errorf("altPubKey = %x, want %x", ev.altPubKey, tt.alt)
+			}
+			if ev.nonceBound != tt.nonceBound {
+				t.Errorf("nonceBound = %v, want %v", ev.nonceBound, tt.nonceBound)
+			}
+		})
+	}
+}
+
+func TestParseKeyBinding(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    KeyBinding
+		wantErr bool
+	}{
+		{s: "auto", want: BindingKeyOrSPKIHash},
+		{s: "key", want: BindingKey},
+		{s: "spki-sha256", want: BindingSPKIHash},
+		{s: "key-nonce-sha256", want: BindingKeyNonceHash},
+		{s: "custom", wantErr: true},
+		{s: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseKeyBinding(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseKeyBinding() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseKeyBinding() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package ratls
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultCollateralTTL is the lifetime of a cached collateral entry when
+// CollateralCache.TTL is zero.
+const DefaultCollateralTTL = time.Hour
+
+// CollateralCache is a CollateralSource caching the collateral of another
+// source in memory and, if Dir is set, on disk, keyed by FMSPC and PCK CA.
+// An entry expires after TTL or at the earliest nextUpdate of its TCB info,
+// QE identity and CRLs, whichever comes first. Cached collateral is
+// verified on every use like fetched collateral, so the cache directory
+// does not need to be trusted.
+type CollateralCache struct {
+	Source CollateralSource
+	TTL    time.Duration
+	Dir    string
+	// Metrics, if set, counts cache hits and misses.
+	Metrics Metrics
+
+	mu      sync.Mutex
+	entries map[string]*cachedCollateral
+}
+
+type cachedCollateral struct {
+	col     *Collateral
+	expires time.Time
+}
+
+// diskCollateral is the on-disk form of a cache entry.
+type diskCollateral struct {
+	Expires               time.Time `json:"expires"`
+	TCBInfo               []byte    `json:"tcb_info"`
+	TCBInfoIssuerChain    [][]byte  `json:"tcb_info_issuer_chain"`
+	QEIdentity            []byte    `json:"qe_identity"`
+	QEIdentityIssuerChain [][]byte  `json:"qe_identity_issuer_chain"`
+	PCKCRL                []byte    `json:"pck_crl"`
+	PCKCRLIssuerChain     [][]byte  `json:"pck_crl_issuer_chain"`
+	RootCACRL             []byte    `json:"root_ca_crl"`
+}
+
+// GetCollateral returns the cached collateral of a platform, fetching it
+// from Source if it is missing or expired.
+func (c *CollateralCache) GetCollateral(ctx context.Context, fmspc, ca string) (*Collateral, error) {
+	key := fmspc + "-" + ca
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*cachedCollateral)
+	}
+	if e, ok := c.entries[key]; ok && now.Before(e.expires) {
+		c.observe(true)
+		return e.col, nil
+	}
+	if e := c.load(key); e != nil && now.Before(e.expires) {
+		c.entries[key] = e
+		c.observe(true)
+		return e.col, nil
+	}
+	c.observe(false)
+
+	col, err := c.Source.GetCollateral(ctx, fmspc, ca)
+	if err != nil {
+		return nil, err
+	}
+	e := &cachedCollateral{col: col, expires: c.expiry(col, now)}
+	c.entries[key] = e
+	c.store(key, e)
+	return col, nil
+}
+
+func (c *CollateralCache) observe(hit bool) {
+	if c.Metrics != nil {
+		c.Metrics.ObserveCollateralLookup(hit)
+	}
+}
+
+func (c *CollateralCache) expiry(col *Collateral, now time.Time) time.Time {
+	ttl := c.TTL
+	if ttl == 0 {
+		ttl = DefaultCollateralTTL
+	}
+	expires := now.Add(ttl)
+	earlier := func(t time.Time) {
+		if !t.IsZero() && t.Before(expires) {
+			expires = t
+		}
+	}
+	earlier(jsonNextUpdate(col.TCBInfo, "tcbInfo"))
+	earlier(jsonNextUpdate(col.QEIdentity, "enclaveIdentity"))
+	for _, raw := range [][]byte{col.PCKCRL, col.RootCACRL} {
+		if crl, err := ParseCRL(raw); err == nil {
+			earlier(crl.NextUpdate)
+		}
+	}
+	return expires
+}
+
+// jsonNextUpdate returns the nextUpdate of a signed collateral document
+// without verifying it, or the zero time.
+func jsonNextUpdate(doc []byte, field string) time.Time {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &fields); err != nil {
+		return time.Time{}
+	}
+	var body struct {
+		NextUpdate time.Time `json:"nextUpdate"`
+	}
+	if err := json.Unmarshal(fields[field], &body); err != nil {
+		return time.Time{}
+	}
+	return body.NextUpdate
+}
+
+func (c *CollateralCache) path(key string) string {
+	return filepath.Join(c.Dir, "collateral-"+key+".json")
+}
+
+// OfflineCollateral is a CollateralSource reading collateral saved by a
+// CollateralCache in Dir, never accessing the network. Copying the cache
+// directory of an online verifier to an air-gapped machine allows evidence
+// to be re-checked there; expired entries are still served, their validity
+// is checked against the Verifier clock like any collateral.
+type OfflineCollateral struct {
+	Dir string
+}
+
+// GetCollateral implements CollateralSource.
+func (o OfflineCollateral) GetCollateral(ctx context.Context, fmspc, ca string) (*Collateral, error) {
+	c := &CollateralCache{Dir: o.Dir}
+	e := c.load(fmspc + "-" + ca)
+	if e == nil {
+		return nil, fmt.Errorf("no saved collateral for FMSPC %s in %s", fmspc, o.Dir)
+	}
+	return e.col, nil
+}
+
+// load reads an entry from disk. Unreadable entries are ignored and
+// fetched again.
+func (c *CollateralCache) load(key string) *cachedCollateral {
+	if c.Dir == "" {
+		return nil
+	}
+	raw, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil
+	}
+	var d diskCollateral
+	if err := json.Unmarshal(raw, &d); err != nil {
+		return nil
+	}
+	col := &Collateral{
+		TCBInfo:    d.TCBInfo,
+		QEIdentity: d.QEIdentity,
+		PCKCRL:     d.PCKCRL,
+		RootCACRL:  d.RootCACRL,
+	}
+	if col.TCBInfoIssuerChain, err = parseDERCerts(d.TCBInfoIssuerChain); err != nil {
+		return nil
+	}
+	if col.QEIdentityIssuerChain, err = parseDERCerts(d.QEIdentityIssuerChain); err != nil {
+		return nil
+	}
+	if col.PCKCRLIssuerChain, err = parseDERCerts(d.PCKCRLIssuerChain); err != nil {
+		return nil
+	}
+	return &cachedCollateral{col: col, expires: d.Expires}
+}
+
+// store writes an entry to disk. Failures only cost a later refetch.
+func (c *CollateralCache) store(key string, e *cachedCollateral) {
+	if c.Dir == "" {
+		return
+	}
+	raw, err := json.Marshal(&diskCollateral{
+		Expires:               e.expires,
+		TCBInfo:               e.col.TCBInfo,
+		TCBInfoIssuerChain:    rawCerts(e.col.TCBInfoIssuerChain),
+		QEIdentity:            e.col.QEIdentity,
+		QEIdentityIssuerChain: rawCerts(e.col.QEIdentityIssuerChain),
+		PCKCRL:                e.col.PCKCRL,
+		PCKCRLIssuerChain:     rawCerts(e.col.PCKCRLIssuerChain),
+		RootCACRL:             e.col.RootCACRL,
+	})
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(c.Dir, 0o700); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(c.Dir, "collateral-*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(raw)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path(key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+func rawCerts(chain []*x509.Certificate) [][]byte {
+	raws := make([][]byte, len(chain))
+	for i, c := range chain {
+		raws[i] = c.Raw
+	}
+	return raws
+}
+
+func parseDERCerts(raws [][]byte) ([]*x509.Certificate, error) {
+	chain := make([]*x509.Certificate, len(raws))
+	for i, raw := range raws {
+		c, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return nil, err
+		}
+		chain[i] = c
+	}
+	return chain, nil
+}
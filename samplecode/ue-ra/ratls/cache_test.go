@@ -0,0 +1,147 @@
+package ratls
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// countingSource serves col and counts the requests.
+type countingSource struct {
+	col      *Collateral
+	requests int
+}
+
+func (s *countingSource) GetCollateral(ctx context.Context, fmspc, ca string) (*Collateral, error) {
+	s.requests++
+	return s.col, nil
+}
+
+func TestCollateralCacheExpiry(t *testing.T) {
+	pki := newTestCollateralPKI(t)
+	doc := func(field string, nextUpdate time.Time) []byte {
+		return []byte(fmt.Sprintf(`{"%s":{"nextUpdate":"%s"},"signature":""}`, field, nextUpdate.Format(time.RFC3339)))
+	}
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		col  *Collateral
+		want time.Time
+	}{
+		{name: "default TTL", col: &Collateral{}, want: testNow.Add(DefaultCollateralTTL)},
+		{name: "custom TTL", ttl: time.Minute, col: &Collateral{}, want: testNow.Add(time.Minute)},
+		{name: "TCB info update", col: &Collateral{TCBInfo: doc("tcbInfo", testNow.Add(time.Minute))}, want: testNow.Add(time.Minute)},
+		{name: "QE identity update", col: &Collateral{QEIdentity: doc("enclaveIdentity", testNow.Add(time.Minute))}, want: testNow.Add(time.Minute)},
+		{name: "later update", col: &Collateral{TCBInfo: doc("tcbInfo", testNow.Add(48*time.Hour))}, want: testNow.Add(DefaultCollateralTTL)},
+		{
+			name: "CRL update",
+			col:  &Collateral{RootCACRL: newTestCRL(t, pki.root, pki.rootKey, testNow.Add(-time.Hour).AddDate(0, -1, 0))},
+			want: testNow.Add(-time.Hour),
+		},
+		{name: "malformed documents", col: &Collateral{TCBInfo: []byte("{"), PCKCRL: []byte("CRL")}, want: testNow.Add(DefaultCollateralTTL)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &CollateralCache{TTL: tt.ttl}
+			if got := c.expiry(tt.col, testNow); !got.Equal(tt.want) {
+				t.Errorf("expiry() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollateralCache(t *testing.T) {
+	pki := newTestCollateralPKI(t)
+	col := &Collateral{TCBInfo: []byte(`{"tcbInfo":{}}`), TCBInfoIssuerChain: []*x509.Certificate{pki.signer, pki.root}}
+	const key = "00906ed50000-" + PCKCAPlatform
+	tests := []struct {
+		name string
+		// second returns the cache of the second lookup, after one by c
+		// caching in dir.
+		second       func(t *testing.T, c *CollateralCache, dir string) *CollateralCache
+		wantRequests int
+	}{
+		{
+			name:         "in memory",
+			second:       func(_ *testing.T, c *CollateralCache, _ string) *CollateralCache { return c },
+			wantRequests: 1,
+		},
+		{
+			name: "on disk",
+			second: func(_ *testing.T, c *CollateralCache, dir string) *CollateralCache {
+				return &CollateralCache{Source: c.Source, Dir: dir}
+			},
+			wantRequests: 1,
+		},
+		{
+			name: "corrupt file",
+			second: func(t *testing.T, c *CollateralCache, dir string) *CollateralCache {
+				if err := os.WriteFile(filepath.Join(dir, "collateral-"+key+".json"), []byte("{"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				return &CollateralCache{Source: c.Source, Dir: dir}
+			},
+			wantRequests: 2,
+		},
+		{
+			name: "expired",
+			second: func(_ *testing.T, c *CollateralCache, _ string) *CollateralCache {
+				c.entries[key].expires = time.Now().Add(-time.Second)
+				c.Dir = ""
+				return c
+			},
+			wantRequests: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := &countingSource{col: col}
+			c := &CollateralCache{Source: src, Dir: dir}
+			if _, err := c.GetCollateral(context.Background(), "00906ed50000", PCKCAPlatform); err != nil {
+				t.Fatal(err)
+			}
+			got, err := tt.second(t, c, dir).GetCollateral(context.Background(), "00906ed50000", PCKCAPlatform)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if src.requests != tt.wantRequests {
+				t.Errorf("%d requests, want %d", src.requests, tt.wantRequests)
+			}
+			if string(got.TCBInfo) != string(col.TCBInfo) || len(got.TCBInfoIssuerChain) != 2 || !got.TCBInfoIssuerChain[0].Equal(pki.signer) {
+				t.Errorf("GetCollateral() = %s with %d issuers", got.TCBInfo, len(got.TCBInfoIssuerChain))
+			}
+		})
+	}
+}
+
+func TestOfflineCollateral(t *testing.T) {
+	dir := t.TempDir()
+	c := &CollateralCache{Source: &countingSource{col: &Collateral{TCBInfo: []byte(`{}`)}}, Dir: dir, TTL: -time.Hour}
+	if _, err := c.GetCollateral(context.Background(), "00906ed50000", PCKCAPlatform); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		fmspc   string
+		wantErr bool
+	}{
+		{name: "expired entry", fmspc: "00906ed50000"},
+		{name: "unknown FMSPC", fmspc: "00606a000000", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			col, err := OfflineCollateral{Dir: dir}.GetCollateral(context.Background(), tt.fmspc, PCKCAPlatform)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetCollateral() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && string(col.TCBInfo) != `{}` {
+				t.Errorf("TCBInfo = %s", col.TCBInfo)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package ratls
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"crypto/x509"
+	"sync"
+	"time"
+)
+
+// Defaults of a CachingVerifier.
+const (
+	DefaultResultCacheSize   = 1024
+	DefaultResultCacheMaxAge = 5 * time.Minute
+)
+
+// CachingVerifier is a concurrency-safe front end of a Verifier for
+// gateways that verify the same certificates over and over. Concurrent
+// verifications of one certificate are done once and shared, and accepted
+// certificates are remembered in an LRU cache keyed by their SHA-256
+// fingerprint, so reconnecting enclaves are not verified again until their
+// entry expires. Only accepted results are cached, so transient failures
+// such as unreachable collateral services are retried. Cache hits are not
+// audited or counted in Metrics. Verifiers with a Nonce bypass the cache.
+//
+// Cached results are verified again once the Verifier's MeasurementPolicy
+// is reloaded, and never outlive the MaxReportAge of their report or
+// token. Revocations learnt while results are cached, e.g. a revoked
+// MRENCLAVE or a platform listed in a new CRL, are applied with
+// Invalidate, or checked on every cache hit by Revoked.
+type CachingVerifier struct {
+	Verifier *Verifier
+	// Size is the maximum number of cached results, DefaultResultCacheSize
+	// if zero.
+	Size int
+	// MaxAge is how long an accepted result is reused,
+	// DefaultResultCacheMaxAge if zero. It bounds how late a revoked or
+	// downgraded platform is noticed.
+	MaxAge time.Duration
+	// Revoked, if set, is asked on every cache hit whether the cached
+	// result still holds. If it reports true, the entry is dropped and the
+	// certificate is verified again. It is called with the cache locked
+	// and must not use c.
+	Revoked func(res *VerificationResult) bool
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[[sha256.Size]byte]*list.Element
+	flights map[[sha256.Size]byte]*flight
+}
+
+type cachedResult struct {
+	key     [sha256.Size]byte
+	res     *VerificationResult
+	expires time.Time
+	// policy is the generation of the MeasurementPolicy res was checked
+	// against.
+	policy uint64
+}
+
+// flight is a verification in progress, waited for by callers presenting
+// the same certificate.
+type flight struct {
+	done chan struct{}
+	res  *VerificationResult
+	err  error
+}
+
+// NewCachingVerifier returns a CachingVerifier of v with the default size
+// and maximum age.
+func NewCachingVerifier(v *Verifier) *CachingVerifier {
+	return &CachingVerifier{Verifier: v}
+}
+
+// VerifyPeerCertificate has the signature of tls.Config.VerifyPeerCertificate
+// and can be plugged in directly.
+func (c *CachingVerifier) VerifyPeerCertificate(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	_, err := c.VerifyPeerChain("", rawCerts)
+	return err
+}
+
+// VerifyPeerChain is Verifier.VerifyPeerChain served from the cache when
+// possible. Every caller gets its own copy of the result.
+func (c *CachingVerifier) VerifyPeerChain(peer string, rawCerts [][]byte) (*VerificationResult, error) {
+	v := c.Verifier
+	if len(rawCerts) == 0 || v.Nonce != nil {
+		return v.VerifyPeerChain(peer, rawCerts)
+	}
+	key := c.key(rawCerts)
+	now := v.now()
+	policy := v.Measurements.loaded()
+
+	c.mu.Lock()
+	if c.entries == nil {
+		c.lru = list.New()
+		c.entries = make(map[[sha256.Size]byte]*list.Element)
+		c.flights = make(map[[sha256.Size]byte]*flight)
+	}
+	if e, ok := c.entries[key]; ok {
+		cached := e.Value.(*cachedResult)
+		if now.Before(cached.expires) && cached.policy == policy && (c.Revoked == nil || !c.Revoked(cached.res)) {
+			c.lru.MoveToFront(e)
+			c.mu.Unlock()
+			return cached.res.clone(), nil
+		}
+		c.lru.Remove(e)
+		delete(c.entries, key)
+	}
+	if f, ok := c.flights[key]; ok {
+		c.mu.Unlock()
+		<-f.done
+		return f.res.clone(), f.err
+	}
+	f := &flight{done: make(chan struct{})}
+	c.flights[key] = f
+	c.mu.Unlock()
+
+	f.res, f.err = v.VerifyPeerChain(peer, rawCerts)
+
+	c.mu.Lock()
+	delete(c.flights, key)
+	if f.err == nil {
+		c.add(key, f.res, now, policy)
+	}
+	c.mu.Unlock()
+	close(f.done)
+	return f.res.clone(), f.err
+}
+
+// Invalidate drops the cached results for which revoked returns true and
+// returns their number. The certificates are verified again when next
+// presented.
+func (c *CachingVerifier) Invalidate(revoked func(res *VerificationResult) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for key, e := range c.entries {
+		if revoked(e.Value.(*cachedResult).res) {
+			c.lru.Remove(e)
+			delete(c.entries, key)
+			n++
+		}
+	}
+	return n
+}
+
+// Purge drops every cached result.
+func (c *CachingVerifier) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries != nil {
+		c.lru.Init()
+		c.entries = make(map[[sha256.Size]byte]*list.Element)
+	}
+}
+
+// key is the fingerprint of the leaf certificate or, when the chain is
+// checked against PKIRoots, of the whole chain.
+func (c *CachingVerifier) key(rawCerts [][]byte) [sha256.Size]byte {
+	if c.Verifier.PKIRoots == nil || len(rawCerts) == 1 {
+		return sha256.Sum256(rawCerts[0])
+	}
+	h := sha256.New()
+	for _, raw := range rawCerts {
+		sum := sha256.Sum256(raw)
+		h.Write(sum[:])
+	}
+	var key [sha256.Size]byte
+	h.Sum(key[:0])
+	return key
+}
+
+// add caches an accepted result checked against the given policy
+// generation, evicting the least recently used entries beyond Size. c.mu
+// must be held.
+func (c *CachingVerifier) add(key [sha256.Size]byte, res *VerificationResult, now time.Time, policy uint64) {
+	maxAge := c.MaxAge
+	if maxAge == 0 {
+		maxAge = DefaultResultCacheMaxAge
+	}
+	size := c.Size
+	if size == 0 {
+		size = DefaultResultCacheSize
+	}
+	expires := now.Add(maxAge)
+	// An attestation token is not reused past its own expiry
+	if !res.TokenExpiry.IsZero() && res.TokenExpiry.Before(expires) {
+		expires = res.TokenExpiry
+	}
+	// nor past the age at which its report or token is rejected
+	if reportAge := c.Verifier.maxReportAge(); reportAge > 0 {
+		for _, issued := range []time.Time{res.Timestamp, res.TokenIssuedAt} {
+			if !issued.IsZero() && issued.Add(reportAge).Before(expires) {
+				expires = issued.Add(reportAge)
+			}
+		}
+	}
+	c.entries[key] = c.lru.PushFront(&cachedResult{key: key, res: res, expires: expires, policy: policy})
+	for c.lru.Len() > size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedResult).key)
+	}
+}
+
+// clone returns a shallow copy of r, or nil.
+func (r *VerificationResult) clone() *VerificationResult {
+	if r == nil {
+		return nil
+	}
+	c := *r
+	return &c
+}
@@ -0,0 +1,217 @@
+package ratls
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newSimulatedCert returns a self-signed RA-TLS certificate carrying the
+// bare EPID quote of a simulation mode enclave measured as mrEnclave, whose
+// report_data binds the SHA-256 of the certificate's SubjectPublicKeyInfo.
+func newSimulatedCert(t *testing.T, mrEnclave byte) []byte {
+	t.Helper()
+	return newSimulatedCertWith(t, func(body *ReportBody) { body.MrEnclave[0] = mrEnclave })
+}
+
+// newSimulatedCertWith is like newSimulatedCert, with the report body set
+// by edit.
+func newSimulatedCertWith(t *testing.T, edit func(*ReportBody)) []byte {
+	t.Helper()
+	return newSimulatedKeyPair(t, edit).Certificate[0]
+}
+
+// newSimulatedKeyPair returns the certificate of newSimulatedCertWith with
+// its private key.
+func newSimulatedKeyPair(t *testing.T, edit func(*ReportBody)) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spki, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quote := EPIDQuote{Header: EPIDQuoteHeader{Version: 2, SignType: EPIDLinkable}}
+	edit(&quote.ReportBody)
+	digest := sha256.Sum256(spki)
+	copy(quote.ReportBody.ReportData[:], digest[:])
+	var raw bytes.Buffer
+	binary.Write(&raw, binary.LittleEndian, quote.Header)
+	binary.Write(&raw, binary.LittleEndian, quote.ReportBody)
+
+	der := newTestRATLSCert(t, key, pkix.Extension{Id: oidNetscapeComment, Value: raw.Bytes()})
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// countingMetrics counts the verifications actually run.
+type countingMetrics struct {
+	verifications atomic.Int32
+}
+
+func (m *countingMetrics) ObserveVerification(*VerificationResult, error, time.Duration) {
+	m.verifications.Add(1)
+}
+
+func (m *countingMetrics) ObserveCollateralLookup(bool) {}
+
+// testClock is a Clock moved by hand.
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func TestCachingVerifier(t *testing.T) {
+	writePolicy := func(t *testing.T, path string, allowed ...byte) {
+		t.Helper()
+		content := `{"allowed": [`
+		for i, b := range allowed {
+			if i > 0 {
+				content += ","
+			}
+			mr := make([]byte, 32)
+			mr[0] = b
+			content += `{"mr_enclave": "` + hex.EncodeToString(mr) + `"}`
+		}
+		if err := os.WriteFile(path, []byte(content+"]}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		// between runs between the first and the second verification.
+		between           func(t *testing.T, c *CachingVerifier, clock *testClock, policyPath string)
+		wantVerifications int32
+		wantErr           error
+	}{
+		{name: "cached", between: func(*testing.T, *CachingVerifier, *testClock, string) {}, wantVerifications: 1},
+		{
+			name: "expired",
+			between: func(_ *testing.T, c *CachingVerifier, clock *testClock, _ string) {
+				clock.now = clock.now.Add(DefaultResultCacheMaxAge)
+			},
+			wantVerifications: 2,
+		},
+		{
+			name: "policy reloaded",
+			between: func(t *testing.T, c *CachingVerifier, _ *testClock, path string) {
+				writePolicy(t, path, 1, 2)
+				if err := c.Verifier.Measurements.Reload(); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantVerifications: 2,
+		},
+		{
+			name: "enclave removed from the policy",
+			between: func(t *testing.T, c *CachingVerifier, _ *testClock, path string) {
+				writePolicy(t, path, 2)
+				if err := c.Verifier.Measurements.Reload(); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantVerifications: 2,
+			wantErr:           ErrMeasurementMismatch,
+		},
+		{
+			name: "invalidated",
+			between: func(t *testing.T, c *CachingVerifier, _ *testClock, _ string) {
+				if n := c.Invalidate(func(*VerificationResult) bool { return true }); n != 1 {
+					t.Errorf("Invalidate() = %d, want 1", n)
+				}
+			},
+			wantVerifications: 2,
+		},
+		{
+			name: "revoked on hit",
+			between: func(_ *testing.T, c *CachingVerifier, _ *testClock, _ string) {
+				c.Revoked = func(res *VerificationResult) bool { return res.Simulated }
+			},
+			wantVerifications: 2,
+		},
+		{
+			name:              "purged",
+			between:           func(_ *testing.T, c *CachingVerifier, _ *testClock, _ string) { c.Purge() },
+			wantVerifications: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "policy.json")
+			writePolicy(t, path, 1)
+			policy, err := LoadMeasurementPolicy(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			metrics := &countingMetrics{}
+			clock := &testClock{now: testNow}
+			c := NewCachingVerifier(&Verifier{
+				AllowSimulation: true,
+				Measurements:    policy,
+				Metrics:         metrics,
+				Clock:           clock,
+			})
+			rawCerts := [][]byte{newSimulatedCert(t, 1)}
+
+			if _, err := c.VerifyPeerChain("enclave", rawCerts); err != nil {
+				t.Fatalf("first VerifyPeerChain() error = %v", err)
+			}
+			tt.between(t, c, clock, path)
+			_, err = c.VerifyPeerChain("enclave", rawCerts)
+			if (err != nil) != (tt.wantErr != nil) || err != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("second VerifyPeerChain() error = %v, want %v", err, tt.wantErr)
+			}
+			if n := metrics.verifications.Load(); n != tt.wantVerifications {
+				t.Errorf("%d verifications, want %d", n, tt.wantVerifications)
+			}
+		})
+	}
+}
+
+func TestCachingVerifierExpiry(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxReportAge time.Duration
+		res          VerificationResult
+		want         time.Time
+	}{
+		{name: "no timestamp", res: VerificationResult{}, want: testNow.Add(DefaultResultCacheMaxAge)},
+		{name: "fresh report", res: VerificationResult{Timestamp: testNow.Add(-time.Hour)}, want: testNow.Add(DefaultResultCacheMaxAge)},
+		{name: "report about to go stale", res: VerificationResult{Timestamp: testNow.Add(-DefaultMaxReportAge + time.Minute)}, want: testNow.Add(time.Minute)},
+		{name: "custom report age", maxReportAge: time.Hour, res: VerificationResult{Timestamp: testNow.Add(-58 * time.Minute)}, want: testNow.Add(2 * time.Minute)},
+		{name: "report age unchecked", maxReportAge: -1, res: VerificationResult{Timestamp: testNow.Add(-48 * time.Hour)}, want: testNow.Add(DefaultResultCacheMaxAge)},
+		{name: "token about to go stale", maxReportAge: time.Hour, res: VerificationResult{TokenIssuedAt: testNow.Add(-59 * time.Minute)}, want: testNow.Add(time.Minute)},
+		{name: "token about to expire", res: VerificationResult{TokenExpiry: testNow.Add(time.Second)}, want: testNow.Add(time.Second)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &CachingVerifier{
+				Verifier: &Verifier{MaxReportAge: tt.maxReportAge},
+				lru:      list.New(),
+				entries:  make(map[[sha256.Size]byte]*list.Element),
+			}
+			var key [sha256.Size]byte
+			c.add(key, &tt.res, testNow, 0)
+			if got := c.entries[key].Value.(*cachedResult).expires; !got.Equal(tt.want) {
+				t.Errorf("expires = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,390 @@
+package ratls
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// oidNetscapeComment is the extension Teaclave enclaves use to carry their
+// attestation evidence.
+var oidNetscapeComment = asn1.ObjectIdentifier{2, 16, 840, 1, 113730, 1, 13}
+
+// Extensions defined by Intel's RA-TLS whitepaper and sgx-ra-tls.
+var (
+	oidIASResponseBody    = asn1.ObjectIdentifier{1, 2, 840, 113741, 1337, 2}
+	oidIASRootCert        = asn1.ObjectIdentifier{1, 2, 840, 113741, 1337, 3}
+	oidIASLeafCert        = asn1.ObjectIdentifier{1, 2, 840, 113741, 1337, 4}
+	oidIASReportSignature = asn1.ObjectIdentifier{1, 2, 840, 113741, 1337, 5}
+	oidSGXQuote           = asn1.ObjectIdentifier{1, 2, 840, 113741, 1337, 6}
+)
+
+// evidence is the attestation material extracted from an RA certificate.
+type evidence struct {
+	// pubKey is the encoding of the certificate public key report_data must
+	// bind, see keyBinding, or the hash of the claims carrying the key hash
+	// if claimsBound.
+	pubKey []byte
+	// altPubKey is the SHA-256 of the SubjectPublicKeyInfo report_data may
+	// start with instead of pubKey, see BindingKeyOrSPKIHash.
+	altPubKey   []byte
+	keyType     string
+	claimsBound bool
+	// nonceBound is set when pubKey hashes Verifier.Nonce in, see
+	// BindingKeyNonceHash.
+	nonceBound bool
+	// cert is the certificate the evidence was read from, nil for evidence
+	// saved on its own or sent after the handshake.
+	cert *x509.Certificate
+	// channel is set for evidence sent after the handshake, whose pubKey
+	// is the ChannelBinding of the connection.
+	channel bool
+	// comment is the raw Netscape comment payload, whose layout depends on
+	// the attestation mode.
+	comment []byte
+
+	// IAS report bundle, set from the Intel RA-TLS extensions.
+	report      []byte
+	signature   []byte
+	signingCert []byte
+	// claims are the application claims of a fourth payload segment.
+	claims []byte
+	// quote is a raw SGX quote, set from the Intel RA-TLS extensions.
+	quote []byte
+}
+
+// unmarshalCert extracts the evidence of a certificate from the extensions
+// of the layouts in formats.
+func unmarshalCert(rawbyte []byte, formats CertFormat) (*evidence, error) {
+	cert, err := x509.ParseCertificate(rawbyte)
+	if err != nil {
+		return nil, err
+	}
+
+	// Obtain the public key encoding bound in report_data
+	pubKey, keyType, err := keyBinding(cert)
+	if err != nil {
+		return nil, err
+	}
+	ev := &evidence{pubKey: pubKey, keyType: keyType, cert: cert}
+
+	accepts := func(f CertFormat) bool { return formats&f != 0 }
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidNetscapeComment) && accepts(FormatTeaclave):
+			// Teaclave enclaves store the payload as the raw extension value,
+			// other tools wrap it in an IA5String as RFC 5280 style comments.
+			var comment string
+			if rest, err := asn1.UnmarshalWithParams(ext.Value, &comment, "ia5"); err == nil && len(rest) == 0 {
+				ev.comment = []byte(comment)
+			} else {
+				ev.comment = ext.Value
+			}
+		case ext.Id.Equal(oidIASResponseBody) && accepts(FormatIntel):
+			ev.report = ext.Value
+		case ext.Id.Equal(oidIASReportSignature) && accepts(FormatIntel):
+			sig, err := base64.StdEncoding.DecodeString(string(ext.Value))
+			if err != nil {
+				return nil, err
+			}
+			ev.signature = sig
+		case ext.Id.Equal(oidIASLeafCert) && accepts(FormatIntel):
+			ev.signingCert = decodeCertBytes(ext.Value)
+		case ext.Id.Equal(oidIASRootCert) && accepts(FormatIntel):
+			// The IAS root is never trusted from the peer, the verifier's
+			// own IASRoots are used instead.
+		case ext.Id.Equal(oidSGXQuote) && accepts(FormatIntel),
+			ext.Id.Equal(oidGramineQuote) && accepts(FormatGramine):
+			ev.quote = ext.Value
+		case ext.Id.Equal(oidTCGDiceTaggedEvidence) && accepts(FormatGramine|FormatLibrats):
+			quote, claimsHash, format, err := parseTaggedEvidence(ext.Value, cert)
+			if err != nil {
+				return nil, err
+			}
+			if !accepts(format) {
+				continue
+			}
+			// report_data binds the claims, which bind the key
+			ev.quote = quote
+			ev.pubKey = claimsHash
+			ev.claimsBound = true
+		}
+	}
+
+	if ev.comment == nil && ev.report == nil && ev.quote == nil {
+		return nil, errors.New("certificate carries no attestation payload")
+	}
+	return ev, nil
+}
+
+// keyBinding returns the encoding of the certificate public key an enclave
+// places at the start of report_data, and the key type. Keys small enough
+// to fit are bound directly: the uncompressed P-256 point without its 0x04
+// prefix (as Teaclave enclaves do) or the raw Ed25519 key. P-384 and RSA
+// keys are bound by the SHA-256 digest of their SubjectPublicKeyInfo, as in
+// Intel's RA-TLS.
+func keyBinding(cert *x509.Certificate) ([]byte, string, error) {
+	switch pub := cert.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			ecdhPub, err := pub.ECDH()
+			if err != nil {
+				return nil, "", err
+			}
+			return ecdhPub.Bytes()[1:], "P-256", nil
+		case elliptic.P384():
+			digest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			return digest[:], "P-384", nil
+		}
+		return nil, "", fmt.Errorf("unsupported certificate curve %s", pub.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return []byte(pub), "Ed25519", nil
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		return digest[:], "RSA", nil
+	}
+	return nil, "", fmt.Errorf("unsupported certificate public key %T", cert.PublicKey)
+}
+
+// decodeCertBytes accepts a certificate either PEM or DER encoded and
+// returns its DER form.
+func decodeCertBytes(raw []byte) []byte {
+	if block, _ := pem.Decode(raw); block != nil {
+		return block.Bytes
+	}
+	return raw
+}
+
+// splitIASPayload splits a Teaclave "report|sig|cert" payload into the
+// report, decoded signature and DER signing certificate, and the decoded
+// claims of an optional fourth segment.
+func splitIASPayload(payload []byte) ([]byte, []byte, []byte, []byte, error) {
+	// Extract each field
+	pl_split := bytes.Split(payload, []byte{0x7C})
+	if len(pl_split) != 3 && len(pl_split) != 4 {
+		return nil, nil, nil, nil, errors.New("malformed attestation payload")
+	}
+	attn_report_raw := pl_split[0]
+	sig_raw := pl_split[1]
+
+	sig, err := base64.StdEncoding.DecodeString(string(sig_raw))
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	sig_cert_raw := pl_split[2]
+	sig_cert_dec, err := base64.StdEncoding.DecodeString(string(sig_cert_raw))
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	var claims []byte
+	if len(pl_split) == 4 {
+		if claims, err = decodeClaimsSegment(pl_split[3]); err != nil {
+			return nil, nil, nil, nil, err
+		}
+	}
+	return attn_report_raw, sig, sig_cert_dec, claims, nil
+}
+
+// verifyCert checks the IAS report signature with one of the allowed
+// algorithms and returns the verified chain of the report signing
+// certificate and the algorithm of the signature.
+func verifyCert(attn_report_raw, sig, sig_cert_dec []byte, roots *x509.CertPool, now time.Time, allowed []x509.SignatureAlgorithm) ([]*x509.Certificate, x509.SignatureAlgorithm, error) {
+	certServer, err := x509.ParseCertificate(sig_cert_dec)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:       roots,
+		CurrentTime: now,
+	}
+
+	chains, err := certServer.Verify(opts)
+	if err != nil {
+		return nil, 0, failure(ErrBadSignature, err)
+	}
+
+	// Verify the signature against the signing cert
+	alg, err := checkReportSignature(certServer, attn_report_raw, sig, allowed)
+	if err != nil {
+		return nil, 0, err
+	}
+	return chains[0], alg, nil
+}
+
+func (v *Verifier) verifyAttReport(attn_report_raw []byte, res *VerificationResult) error {
+	var qr QuoteReport
+	err := json.Unmarshal(attn_report_raw, &qr)
+	if err != nil {
+		return err
+	}
+	res.ReportID = qr.ID
+	res.AdvisoryIDs = qr.AdvisoryIDs
+	res.AdvisoryURL = qr.AdvisoryURL
+
+	// 1. Check timestamp is within the allowed report age
+	if qr.Timestamp != "" {
+		//timeFixed := qr.Timestamp + "+0000"
+		timeFixed := qr.Timestamp + "Z"
+		ts, err := time.Parse(time.RFC3339, timeFixed)
+		if err != nil {
+			return err
+		}
+		res.Timestamp = ts
+		age := v.now().Sub(ts)
+		if maxAge := v.maxReportAge(); maxAge > 0 && age > maxAge {
+			return failuref(ErrStaleReport, "attestation report is %v old, exceeding %v", age.Round(time.Second), maxAge)
+		}
+	} else {
+		return errors.New("Failed to fetch timestamp from attestation report")
+	}
+
+	// 2. Verify quote status (mandatory field)
+	if qr.IsvEnclaveQuoteStatus != "" {
+		res.QuoteStatus = qr.IsvEnclaveQuoteStatus
+		switch qr.IsvEnclaveQuoteStatus {
+		case "OK":
+			break
+		case "GROUP_OUT_OF_DATE", "GROUP_REVOKED", "CONFIGURATION_NEEDED":
+			// Verify platformInfoBlob for further info if status not OK
+			if qr.PlatformInfoBlob != "" {
+				blob, err := hex.DecodeString(qr.PlatformInfoBlob)
+				if err != nil {
+					return errors.New("illegal PlatformInfoBlob")
+				}
+				platInfo, err := decodePlatformInfo(blob)
+				if err != nil {
+					return err
+				}
+				if v.PlatformInfoKey != nil {
+					if err := verifyPlatformInfo(platInfo, v.PlatformInfoKey); err != nil {
+						return err
+					}
+					res.PlatformInfoVerified = true
+				}
+
+				if res.PlatformInfo, err = parsePlatform(platInfo); err != nil {
+					return err
+				}
+				if qb, err := base64.StdEncoding.DecodeString(qr.IsvEnclaveQuoteBody); err == nil {
+					if quote, err := ParseEPIDQuote(qb); err == nil {
+						if res.PlatformUpdate, err = platformUpdate(platInfo, quote); err != nil {
+							return err
+						}
+					}
+				}
+			} else {
+				return errors.New("Failed to fetch platformInfoBlob from attestation report")
+			}
+		}
+		if err := v.checkQuoteStatus(qr.IsvEnclaveQuoteStatus, qr.AdvisoryIDs); err != nil {
+			return err
+		}
+	} else {
+		err := errors.New("Failed to fetch isvEnclaveQuoteStatus from attestation report")
+		return err
+	}
+
+	// Check advisories (IAS API v4) against the allowlist
+	if v.Advisories != nil {
+		if err := v.Advisories.Check(qr.AdvisoryIDs); err != nil {
+			return err
+		}
+	}
+
+	// 3. Verify quote body
+	if qr.IsvEnclaveQuoteBody != "" {
+		qb, err := base64.StdEncoding.DecodeString(qr.IsvEnclaveQuoteBody)
+		if err != nil {
+			return err
+		}
+
+		qrData, err := parseReport(qb)
+		if err != nil {
+			return err
+		}
+		res.QuoteVersion = qrData.version
+		res.SignType = qrData.signType
+		res.setReportBody(&qrData.reportBody)
+		res.EPIDGroupID = qrData.reportBody.epidGroupID
+		if v.CheckSignType && res.SignType != v.SignType {
+			return failuref(ErrPolicyDenied, "EPID signature type is %v, %v required", res.SignType, v.SignType)
+		}
+
+		return v.checkNonce(qr.Nonce, res)
+	} else {
+		err := errors.New("Failed to fetch isvEnclaveQuoteBody from attestation report")
+		return err
+	}
+}
+
+// checkQuoteStatus checks an IAS quote status and the advisories of the
+// report against AdvisoryExceptions and QuoteStatuses.
+func (v *Verifier) checkQuoteStatus(status string, advisories []string) error {
+	if e := v.AdvisoryExceptions; e != nil && e.applies(status, advisories) {
+		if err := e.Check(status, advisories); err != nil {
+			return failure(ErrQuoteStatus, err)
+		}
+		return nil
+	}
+	statuses := v.QuoteStatuses
+	if len(statuses.Allowed) == 0 {
+		statuses = PermissiveQuoteStatus
+	}
+	if err := statuses.Check(status); err != nil {
+		return failure(ErrQuoteStatus, err)
+	}
+	return nil
+}
+
+// detectMode tells the evidence format of a certificate: Intel RA-TLS
+// extensions name it, a Teaclave payload is either an IAS report bundle
+// (JSON report, '|' separated), an attestation token or a raw quote, whose
+// header gives the version and TEE type.
+func detectMode(ev *evidence) (Mode, error) {
+	if ev.report != nil {
+		return ModeEPID, nil
+	}
+	payload := ev.quote
+	if payload == nil {
+		payload = ev.comment
+	}
+	if n := bytes.Count(payload, []byte("|")); bytes.HasPrefix(payload, []byte("{")) && (n == 2 || n == 3) {
+		return ModeEPID, nil
+	}
+	if isJWT(payload) {
+		return tokenMode(payload), nil
+	}
+	if len(payload) >= quoteHeaderLen {
+		version := binary.LittleEndian.Uint16(payload[0:2])
+		keyType := binary.LittleEndian.Uint16(payload[2:4])
+		teeType := binary.LittleEndian.Uint32(payload[4:8])
+		switch {
+		case keyType != attKeyTypeP256:
+		case version == quoteVersionV3:
+			return ModeDCAP, nil
+		case version == quoteVersionV4 || version == quoteVersionV5:
+			if teeType == TEETypeTDX {
+				return ModeTDX, nil
+			}
+			return ModeDCAP, nil
+		}
+	}
+	return 0, errors.New("cannot detect the attestation evidence format")
+}
@@ -0,0 +1,420 @@
+package ratls
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// newTestRATLSCert returns a certificate self-signed by key carrying exts,
+// with a fresh P-256 key if key is nil.
+func newTestRATLSCert(t *testing.T, key crypto.Signer, exts ...pkix.Extension) []byte {
+	t.Helper()
+	if key == nil {
+		var err error
+		if key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		Subject:         pkix.Name{CommonName: "enclave"},
+		NotBefore:       time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:        time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC),
+		ExtraExtensions: exts,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+// testIASReport returns the JSON of an IAS attestation report with status
+// OK, issued at testNow, over an EPID quote of the given signature type,
+// after applying edit to it if not nil.
+func testIASReport(t *testing.T, signType EPIDSignType, edit func(*QuoteReport)) []byte {
+	t.Helper()
+	quote := EPIDQuote{Header: EPIDQuoteHeader{Version: 2, SignType: signType}}
+	var body bytes.Buffer
+	binary.Write(&body, binary.LittleEndian, quote.Header)
+	binary.Write(&body, binary.LittleEndian, quote.ReportBody)
+	report := QuoteReport{
+		ID:                    "1",
+		Timestamp:             testNow.Format("2006-01-02T15:04:05.000000"),
+		Version:               4,
+		IsvEnclaveQuoteStatus: "OK",
+		IsvEnclaveQuoteBody:   base64.StdEncoding.EncodeToString(body.Bytes()),
+	}
+	if edit != nil {
+		edit(&report)
+	}
+	raw, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// testIASPayload returns the Teaclave "report|sig|cert" payload of report
+// signed by the signer of pki.
+func testIASPayload(t *testing.T, pki *testCollateralPKI, report []byte) []byte {
+	t.Helper()
+	digest := sha256.Sum256(report)
+	sig, err := ecdsa.SignASN1(rand.Reader, pki.signerKey, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Join([][]byte{
+		report,
+		[]byte(base64.StdEncoding.EncodeToString(sig)),
+		[]byte(base64.StdEncoding.EncodeToString(pki.signer.Raw)),
+	}, []byte("|"))
+}
+
+func TestEPIDSignType(t *testing.T) {
+	tests := []struct {
+		name     string
+		verifier Verifier
+		signType EPIDSignType
+		wantErr  bool
+	}{
+		{name: "any type", verifier: Verifier{}, signType: EPIDLinkable},
+		{name: "linkable required", verifier: Verifier{CheckSignType: true, SignType: EPIDLinkable}, signType: EPIDLinkable},
+		{name: "unlinkable required", verifier: Verifier{CheckSignType: true, SignType: EPIDUnlinkable}, signType: EPIDUnlinkable},
+		{name: "linkable instead of unlinkable", verifier: Verifier{CheckSignType: true, SignType: EPIDUnlinkable}, signType: EPIDLinkable, wantErr: true},
+		{name: "unlinkable instead of linkable", verifier: Verifier{CheckSignType: true, SignType: EPIDLinkable}, signType: EPIDUnlinkable, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := tt.verifier
+			v.Clock = FixedClock(testNow)
+			res := &VerificationResult{}
+			err := v.verifyAttReport(testIASReport(t, tt.signType, nil), res)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyAttReport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrPolicyDenied) {
+				t.Errorf("verifyAttReport() error = %v, want %v", err, ErrPolicyDenied)
+			}
+			if res.SignType != tt.signType {
+				t.Errorf("SignType = %v, want %v", res.SignType, tt.signType)
+			}
+		})
+	}
+}
+
+func TestUnmarshalCertComment(t *testing.T) {
+	payload := []byte("report|signature|cert")
+	ia5, err := asn1.MarshalWithParams(string(payload), "ia5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		raw     []byte
+		want    []byte
+		wantErr bool
+	}{
+		{name: "raw comment", raw: newTestRATLSCert(t, nil, pkix.Extension{Id: oidNetscapeComment, Value: payload}), want: payload},
+		{name: "IA5String comment", raw: newTestRATLSCert(t, nil, pkix.Extension{Id: oidNetscapeComment, Value: ia5}), want: payload},
+		{name: "IA5String with trailing data", raw: newTestRATLSCert(t, nil, pkix.Extension{Id: oidNetscapeComment, Value: append(ia5, 0)}), want: append(ia5, 0)},
+		{name: "no payload", raw: newTestRATLSCert(t, nil), wantErr: true},
+		{name: "not a certificate", raw: []byte("not a certificate"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev, err := unmarshalCert(tt.raw, FormatAll)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unmarshalCert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !bytes.Equal(ev.comment, tt.want) {
+				t.Errorf("comment = %q, want %q", ev.comment, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalCertIntelExtensions(t *testing.T) {
+	leaf := newTestRATLSCert(t, nil)
+	leafPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf})
+	report := []byte(`{"id":"1"}`)
+	sig := []byte("signature")
+	bundle := []pkix.Extension{
+		{Id: oidIASResponseBody, Value: report},
+		{Id: oidIASReportSignature, Value: []byte(base64.StdEncoding.EncodeToString(sig))},
+		{Id: oidIASLeafCert, Value: leafPEM},
+		{Id: oidIASRootCert, Value: leafPEM},
+	}
+	quote := []byte("quote")
+	tests := []struct {
+		name       string
+		exts       []pkix.Extension
+		formats    CertFormat
+		wantReport bool
+		wantQuote  bool
+		wantErr    bool
+	}{
+		{name: "IAS report bundle", exts: bundle, formats: FormatAll, wantReport: true},
+		{name: "raw quote", exts: []pkix.Extension{{Id: oidSGXQuote, Value: quote}}, formats: FormatAll, wantQuote: true},
+		{name: "Intel format disabled", exts: bundle, formats: FormatTeaclave, wantErr: true},
+		{name: "IAS root only", exts: []pkix.Extension{{Id: oidIASRootCert, Value: leafPEM}}, formats: FormatAll, wantErr: true},
+		{
+			name:    "signature not base64",
+			exts:    []pkix.Extension{{Id: oidIASResponseBody, Value: report}, {Id: oidIASReportSignature, Value: []byte("!!")}},
+			formats: FormatAll,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev, err := unmarshalCert(newTestRATLSCert(t, nil, tt.exts...), tt.formats)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unmarshalCert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if tt.wantReport {
+				if !bytes.Equal(ev.report, report) || !bytes.Equal(ev.signature, sig) || !bytes.Equal(ev.signingCert, leaf) {
+					t.Errorf("report bundle = %q, %q, %x", ev.report, ev.signature, ev.signingCert)
+				}
+			}
+			if got := ev.quote != nil; got != tt.wantQuote || tt.wantQuote && !bytes.Equal(ev.quote, quote) {
+				t.Errorf("quote = %q, want %q", ev.quote, quote)
+			}
+		})
+	}
+}
+
+func TestReportFreshness(t *testing.T) {
+	at := func(ts string) func(*QuoteReport) {
+		return func(qr *QuoteReport) { qr.Timestamp = ts }
+	}
+	tests := []struct {
+		name         string
+		maxReportAge time.Duration
+		edit         func(*QuoteReport)
+		wantErr      bool
+		wantStale    bool
+	}{
+		{name: "fresh", edit: at("2023-01-14T12:00:00.000000")},
+		{name: "default age exceeded", edit: at("2023-01-13T23:00:00.000000"), wantErr: true, wantStale: true},
+		{name: "custom age", maxReportAge: time.Hour, edit: at("2023-01-14T23:30:00.000000")},
+		{name: "custom age exceeded", maxReportAge: time.Hour, edit: at("2023-01-14T12:00:00.000000"), wantErr: true, wantStale: true},
+		{name: "age unchecked", maxReportAge: -1, edit: at("2020-01-01T00:00:00.000000")},
+		{name: "no timestamp", edit: at(""), wantErr: true},
+		{name: "malformed timestamp", edit: at("yesterday"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Verifier{Clock: FixedClock(testNow), MaxReportAge: tt.maxReportAge}
+			err := v.verifyAttReport(testIASReport(t, EPIDLinkable, tt.edit), &VerificationResult{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyAttReport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if stale := errors.Is(err, ErrStaleReport); stale != tt.wantStale {
+				t.Errorf("verifyAttReport() error = %v, want stale %v", err, tt.wantStale)
+			}
+		})
+	}
+}
+
+func TestVerifyCertRoots(t *testing.T) {
+	pki := newTestCollateralPKI(t)
+	other := newTestCollateralPKI(t)
+	report := testIASReport(t, EPIDLinkable, nil)
+	digest := sha256.Sum256(report)
+	sig, err := ecdsa.SignASN1(rand.Reader, pki.signerKey, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		report  []byte
+		roots   *x509.CertPool
+		wantErr bool
+	}{
+		{name: "custom root", report: report, roots: pki.roots},
+		{name: "embedded IAS root", report: report, roots: IntelIASRoots(), wantErr: true},
+		{name: "other root", report: report, roots: other.roots, wantErr: true},
+		{name: "tampered report", report: append(bytes.Clone(report), ' '), roots: pki.roots, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, alg, err := verifyCert(tt.report, sig, pki.signer.Raw, tt.roots, testNow, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyCert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrBadSignature) {
+					t.Errorf("verifyCert() error = %v, want %v", err, ErrBadSignature)
+				}
+				return
+			}
+			if len(chain) != 2 || alg != x509.ECDSAWithSHA256 {
+				t.Errorf("verifyCert() = %d certificates, %v", len(chain), alg)
+			}
+		})
+	}
+}
+
+func TestKeyBinding(t *testing.T) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p521, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spkiHash := func(key crypto.Signer) []byte {
+		spki, err := x509.MarshalPKIXPublicKey(key.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		digest := sha256.Sum256(spki)
+		return digest[:]
+	}
+	p256Point := elliptic.Marshal(elliptic.P256(), p256.X, p256.Y)[1:]
+
+	tests := []struct {
+		name     string
+		key      crypto.Signer
+		want     []byte
+		wantType string
+		wantErr  bool
+	}{
+		{name: "P-256", key: p256, want: p256Point, wantType: "P-256"},
+		{name: "P-384", key: p384, want: spkiHash(p384), wantType: "P-384"},
+		{name: "Ed25519", key: edKey, want: edPub, wantType: "Ed25519"},
+		{name: "RSA", key: rsaKey, want: spkiHash(rsaKey), wantType: "RSA"},
+		{name: "P-521", key: p521, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert, err := x509.ParseCertificate(newTestRATLSCert(t, tt.key))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, keyType, err := keyBinding(cert)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("keyBinding() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(got, tt.want) || keyType != tt.wantType {
+				t.Errorf("keyBinding() = %x, %q, want %x, %q", got, keyType, tt.want, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestDetectMode(t *testing.T) {
+	token := func(claims string) []byte {
+		enc := base64.RawURLEncoding.EncodeToString
+		return []byte(enc([]byte(`{"alg":"RS256"}`)) + "." + enc([]byte(claims)) + "." + enc([]byte("sig")))
+	}
+	header := func(version, keyType uint16, teeType uint32) []byte {
+		var b bytes.Buffer
+		binary.Write(&b, binary.LittleEndian, QuoteHeader{Version: version, AttestationKeyType: keyType, TEEType: teeType})
+		return b.Bytes()
+	}
+	tests := []struct {
+		name    string
+		ev      evidence
+		want    Mode
+		wantErr bool
+	}{
+		{name: "Intel report extension", ev: evidence{report: []byte("{}")}, want: ModeEPID},
+		{name: "IAS report bundle", ev: evidence{comment: []byte(`{"id":"1"}|sig|cert`)}, want: ModeEPID},
+		{name: "IAS report bundle with claims", ev: evidence{comment: []byte(`{"id":"1"}|sig|cert|claims`)}, want: ModeEPID},
+		{name: "MAA token", ev: evidence{comment: token(`{"x-ms-attestation-type":"sgx"}`)}, want: ModeMAA},
+		{name: "ITA token", ev: evidence{comment: token(`{"attester_type":"SGX"}`)}, want: ModeITA},
+		{name: "quote v3", ev: evidence{comment: header(quoteVersionV3, attKeyTypeP256, 0)}, want: ModeDCAP},
+		{name: "SGX quote v4", ev: evidence{quote: header(quoteVersionV4, attKeyTypeP256, TEETypeSGX)}, want: ModeDCAP},
+		{name: "TDX quote v4", ev: evidence{quote: header(quoteVersionV4, attKeyTypeP256, TEETypeTDX)}, want: ModeTDX},
+		{name: "TDX quote v5", ev: evidence{quote: header(quoteVersionV5, attKeyTypeP256, TEETypeTDX)}, want: ModeTDX},
+		{name: "P-384 attestation key", ev: evidence{quote: header(quoteVersionV4, 3, TEETypeSGX)}, wantErr: true},
+		{name: "EPID quote", ev: evidence{comment: header(2, 0, 0)}, wantErr: true},
+		{name: "truncated header", ev: evidence{quote: header(quoteVersionV3, attKeyTypeP256, 0)[:quoteHeaderLen-1]}, wantErr: true},
+		{name: "JSON without signature", ev: evidence{comment: []byte(`{"id":"1"}`)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := detectMode(&tt.ev)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("detectMode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("detectMode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReportPlatformInfo(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := testPlatformInfo(t, key, nil)
+	blob := func(info []byte) string {
+		return hex.EncodeToString(append([]byte{platformInfoTLVType, 2, 0, platformInfoSize}, info...))
+	}
+	tests := []struct {
+		name    string
+		blob    string
+		wantErr bool
+	}{
+		{name: "platform info", blob: blob(info)},
+		{name: "truncated", blob: blob(info[:platformInfoSize-1]), wantErr: true},
+		{name: "header only", blob: blob(nil), wantErr: true},
+		{name: "not hex", blob: "zz", wantErr: true},
+		{name: "missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := testIASReport(t, EPIDLinkable, func(r *QuoteReport) {
+				r.IsvEnclaveQuoteStatus = "GROUP_OUT_OF_DATE"
+				r.PlatformInfoBlob = tt.blob
+			})
+			v := &Verifier{PlatformInfoKey: &key.PublicKey, QuoteStatuses: QuoteStatusPolicy{Allowed: []string{"OK", "GROUP_OUT_OF_DATE"}}, Clock: FixedClock(testNow)}
+			res := &VerificationResult{}
+			err := v.verifyAttReport(report, res)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyAttReport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (!res.PlatformInfoVerified || res.PlatformInfo == nil) {
+				t.Errorf("PlatformInfoVerified = %v, PlatformInfo = %+v", res.PlatformInfoVerified, res.PlatformInfo)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package ratls
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+// OrderChain returns the certificate chain presented by a peer leaf first.
+// The leaf is the one certificate that issues none of the others, which
+// need not be presented first. The others must follow it in issuing order,
+// each issuing its predecessor, and the chain must not carry unrelated
+// certificates.
+func OrderChain(rawCerts [][]byte) ([][]byte, error) {
+	if len(rawCerts) == 0 {
+		return nil, errors.New("no certificate presented by peer")
+	}
+	if len(rawCerts) == 1 {
+		return rawCerts, nil
+	}
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return nil, fmt.Errorf("certificate %d of the chain: %v", i, err)
+		}
+		certs[i] = cert
+	}
+
+	leaf := -1
+	for i, cert := range certs {
+		isIssuer := false
+		for j, other := range certs {
+			if i != j && issues(cert, other) {
+				isIssuer = true
+				break
+			}
+		}
+		if isIssuer {
+			continue
+		}
+		if leaf >= 0 {
+			return nil, fmt.Errorf("certificates %d and %d of the chain are both leaves", leaf, i)
+		}
+		leaf = i
+	}
+	if leaf < 0 {
+		return nil, errors.New("certificate chain has no leaf")
+	}
+
+	ordered := [][]byte{rawCerts[leaf]}
+	prev := leaf
+	for i := range certs {
+		if i == leaf {
+			continue
+		}
+		if !issues(certs[i], certs[prev]) {
+			return nil, fmt.Errorf("certificate %d of the chain does not issue certificate %d", i, prev)
+		}
+		ordered = append(ordered, rawCerts[i])
+		prev = i
+	}
+	return ordered, nil
+}
+
+// issues reports whether child is signed by parent, which must be a CA.
+func issues(parent, child *x509.Certificate) bool {
+	return bytes.Equal(child.RawIssuer, parent.RawSubject) && child.CheckSignatureFrom(parent) == nil
+}
+
+// checkLeaf checks the structure of the leaf of an ordered chain: it must
+// be valid now and must not be a CA. A leaf presented on its own, as
+// enclaves do without PKIRoots, must be self-signed by its key.
+func (v *Verifier) checkLeaf(rawCerts [][]byte) error {
+	cert, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return err
+	}
+	now := v.now()
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return failuref(ErrStaleReport, "certificate is not valid at %v (valid from %v to %v)", now, cert.NotBefore, cert.NotAfter)
+	}
+	if cert.BasicConstraintsValid && cert.IsCA {
+		return errors.New("leaf certificate is a CA")
+	}
+	if len(rawCerts) == 1 {
+		if !bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+			return errors.New("certificate is not self-issued")
+		}
+		if err := cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature); err != nil {
+			return failuref(ErrBadSignature, "certificate is not signed by its key: %v", err)
+		}
+	}
+	return nil
+}
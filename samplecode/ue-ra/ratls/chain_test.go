@@ -0,0 +1,86 @@
+package ratls
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOrderChain(t *testing.T) {
+	root, rootKey := newTestCert(t, "Root CA", true, nil, nil)
+	ca, caKey := newTestCert(t, "Intermediate CA", true, root, rootKey)
+	leaf, _ := newTestCert(t, "enclave", false, ca, caKey)
+	other, _ := newTestCert(t, "other", false, ca, caKey)
+	unrelated, _ := newTestCert(t, "Other CA", true, nil, nil)
+
+	tests := []struct {
+		name    string
+		chain   [][]byte
+		want    [][]byte
+		wantErr bool
+	}{
+		{name: "leaf only", chain: [][]byte{leaf.Raw}, want: [][]byte{leaf.Raw}},
+		{name: "ordered", chain: [][]byte{leaf.Raw, ca.Raw, root.Raw}, want: [][]byte{leaf.Raw, ca.Raw, root.Raw}},
+		{name: "leaf last", chain: [][]byte{ca.Raw, root.Raw, leaf.Raw}, want: [][]byte{leaf.Raw, ca.Raw, root.Raw}},
+		{name: "issuers out of order", chain: [][]byte{leaf.Raw, root.Raw, ca.Raw}, wantErr: true},
+		{name: "two leaves", chain: [][]byte{leaf.Raw, other.Raw, ca.Raw}, wantErr: true},
+		{name: "unrelated certificate", chain: [][]byte{leaf.Raw, ca.Raw, unrelated.Raw}, wantErr: true},
+		{name: "malformed certificate", chain: [][]byte{leaf.Raw, []byte("certificate")}, wantErr: true},
+		{name: "empty", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := OrderChain(tt.chain)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OrderChain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("OrderChain() = %d certificates, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if !bytes.Equal(got[i], tt.want[i]) {
+					t.Errorf("OrderChain()[%d] is not the expected certificate", i)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckLeaf(t *testing.T) {
+	root, rootKey := newTestCert(t, "Root CA", true, nil, nil)
+	leaf, _ := newTestCert(t, "enclave", false, root, rootKey)
+	selfCA, _ := newTestCert(t, "enclave", true, nil, nil)
+	// impostor names itself as its issuer but is signed by another key
+	impostorIssuer, impostorKey := newTestCert(t, "enclave", true, nil, nil)
+	impostor, _ := newTestCert(t, "enclave", false, impostorIssuer, impostorKey)
+
+	tests := []struct {
+		name    string
+		chain   [][]byte
+		now     time.Time
+		wantErr bool
+		wantIs  error
+	}{
+		{name: "self-signed", chain: [][]byte{newTestRATLSCert(t, nil)}, now: testNow},
+		{name: "issued", chain: [][]byte{leaf.Raw, root.Raw}, now: testNow},
+		{name: "not yet valid", chain: [][]byte{newTestRATLSCert(t, nil)}, now: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), wantErr: true, wantIs: ErrStaleReport},
+		{name: "expired", chain: [][]byte{leaf.Raw, root.Raw}, now: time.Date(2051, 1, 1, 0, 0, 0, 0, time.UTC), wantErr: true, wantIs: ErrStaleReport},
+		{name: "CA", chain: [][]byte{selfCA.Raw}, now: testNow, wantErr: true},
+		{name: "not self-issued", chain: [][]byte{leaf.Raw}, now: testNow, wantErr: true},
+		{name: "not signed by its key", chain: [][]byte{impostor.Raw}, now: testNow, wantErr: true, wantIs: ErrBadSignature},
+		{name: "malformed", chain: [][]byte{[]byte("certificate")}, now: testNow, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Verifier{Clock: FixedClock(tt.now)}
+			err := v.checkLeaf(tt.chain)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkLeaf() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Errorf("checkLeaf() error = %v, want %v", err, tt.wantIs)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package ratls
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// An IAS report bundle may carry application-defined claims, e.g. the
+// configuration the enclave was started with, as a fourth segment:
+// "report|sig|cert|claims", where claims is the base64 of a JSON object or
+// a CBOR map. report_data does not cover them; they are attested by the
+// key it binds instead. In a certificate, the certificate must be signed
+// by its own attested key. Evidence sent after the handshake is
+// authenticated by the attested channel. Evidence saved without its
+// certificate cannot authenticate claims and is rejected if it has any.
+
+// MaxClaimsSize is the largest decoded claims segment accepted.
+const MaxClaimsSize = 64 << 10
+
+// decodeClaimsSegment decodes the base64 claims segment of a payload.
+func decodeClaimsSegment(seg []byte) ([]byte, error) {
+	claims, err := base64.StdEncoding.DecodeString(string(seg))
+	if err != nil {
+		return nil, fmt.Errorf("claims segment: %v", err)
+	}
+	if len(claims) == 0 {
+		return nil, errors.New("claims segment is empty")
+	}
+	if len(claims) > MaxClaimsSize {
+		return nil, fmt.Errorf("claims of %d bytes exceed %d", len(claims), MaxClaimsSize)
+	}
+	return claims, nil
+}
+
+// checkClaims authenticates the claims of ev, see above, and decodes
+// them into res.
+func checkClaims(ev *evidence, res *VerificationResult) error {
+	switch {
+	case ev.channel:
+	case ev.cert == nil:
+		return errors.New("claims cannot be authenticated without the certificate")
+	default:
+		cert := ev.cert
+		if err := cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature); err != nil {
+			return failuref(ErrBadSignature, "claims: certificate is not signed by its attested key: %v", err)
+		}
+	}
+	claims, err := decodeClaims(ev.claims)
+	if err != nil {
+		return err
+	}
+	res.RawClaims = ev.claims
+	res.Claims = claims
+	return nil
+}
+
+// decodeClaims decodes a JSON object or, if it does not start with '{', a
+// CBOR map. CBOR map keys must be strings; byte strings decode as []byte,
+// integers as int64 or uint64 and floats as float64.
+func decodeClaims(data []byte) (map[string]any, error) {
+	if trimmed := bytes.TrimLeft(data, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '{' {
+		var claims map[string]any
+		if err := json.Unmarshal(trimmed, &claims); err != nil {
+			return nil, fmt.Errorf("claims: %v", err)
+		}
+		return claims, nil
+	}
+	v, rest, err := cborValue(data, 0)
+	if err != nil {
+		return nil, fmt.Errorf("claims: %v", err)
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("claims: trailing data after CBOR map")
+	}
+	claims, ok := v.(map[string]any)
+	if !ok {
+		return nil, errors.New("claims: not a CBOR map")
+	}
+	return claims, nil
+}
+
+// cborMajorNegInt completes the major types of dice.go needed to decode
+// arbitrary items.
+const cborMajorNegInt = 1
+
+// cborValue decodes the first item of data.
+func cborValue(data []byte, depth int) (any, []byte, error) {
+	if depth > cborMaxDepth {
+		return nil, nil, errors.New("CBOR data is nested too deeply")
+	}
+	if len(data) > 0 && data[0] == 0xf9 {
+		return nil, nil, errors.New("half-precision CBOR floats are not supported")
+	}
+	major, n, rest, err := cborHead(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch major {
+	case cborMajorUint:
+		if n <= math.MaxInt64 {
+			return int64(n), rest, nil
+		}
+		return n, rest, nil
+	case cborMajorNegInt:
+		if n > math.MaxInt64 {
+			return nil, nil, errors.New("CBOR negative integer out of range")
+		}
+		return -1 - int64(n), rest, nil
+	case cborMajorBytes, cborMajorText:
+		s, rest, err := cborString(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		if major == cborMajorText {
+			return string(s), rest, nil
+		}
+		return append([]byte(nil), s...), rest, nil
+	case cborMajorArray:
+		if n > uint64(len(rest)) {
+			return nil, nil, errors.New("truncated CBOR data")
+		}
+		items := make([]any, 0, n)
+		for i := uint64(0); i < n; i++ {
+			var item any
+			if item, rest, err = cborValue(rest, depth+1); err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+		return items, rest, nil
+	case cborMajorMap:
+		if n > uint64(len(rest)) {
+			return nil, nil, errors.New("truncated CBOR data")
+		}
+		m := make(map[string]any, n)
+		for i := uint64(0); i < n; i++ {
+			if len(rest) == 0 || int(rest[0]>>5) != cborMajorText {
+				return nil, nil, errors.New("CBOR map key is not a text string")
+			}
+			var key []byte
+			if key, rest, err = cborString(rest); err != nil {
+				return nil, nil, err
+			}
+			var item any
+			if item, rest, err = cborValue(rest, depth+1); err != nil {
+				return nil, nil, err
+			}
+			m[string(key)] = item
+		}
+		return m, rest, nil
+	case cborMajorTag:
+		// Tags only qualify the item, e.g. as a date
+		return cborValue(rest, depth+1)
+	}
+	// Major type 7: simple values and floats
+	switch info := data[0] & 0x1f; {
+	case info == 20:
+		return false, rest, nil
+	case info == 21:
+		return true, rest, nil
+	case info == 22 || info == 23:
+		return nil, rest, nil
+	case info == 26:
+		return float64(math.Float32frombits(uint32(n))), rest, nil
+	case info == 27:
+		return math.Float64frombits(n), rest, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported CBOR simple value %d", n)
+}
@@ -0,0 +1,157 @@
+package ratls
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeClaimsSegment(t *testing.T) {
+	b64 := base64.StdEncoding.EncodeToString
+	tests := []struct {
+		name    string
+		seg     string
+		want    []byte
+		wantErr bool
+	}{
+		{name: "claims", seg: b64([]byte(`{"a":1}`)), want: []byte(`{"a":1}`)},
+		{name: "largest", seg: b64(bytes.Repeat([]byte{' '}, MaxClaimsSize)), want: bytes.Repeat([]byte{' '}, MaxClaimsSize)},
+		{name: "too large", seg: b64(bytes.Repeat([]byte{' '}, MaxClaimsSize+1)), wantErr: true},
+		{name: "not base64", seg: "{}", wantErr: true},
+		{name: "empty", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeClaimsSegment([]byte(tt.seg))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeClaimsSegment() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("decodeClaimsSegment() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeClaims(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    map[string]any
+		wantErr bool
+	}{
+		{name: "JSON", data: []byte(` {"config": "prod", "workers": 4}`), want: map[string]any{"config": "prod", "workers": 4.0}},
+		{name: "malformed JSON", data: []byte(`{"config"}`), wantErr: true},
+		{
+			// {"a": 1, "b": -2, "c": h'01', "d": true, "e": [1], "f": 1.5}
+			name: "CBOR",
+			data: []byte{
+				0xa6,
+				0x61, 'a', 0x01,
+				0x61, 'b', 0x21,
+				0x61, 'c', 0x41, 0x01,
+				0x61, 'd', 0xf5,
+				0x61, 'e', 0x81, 0x01,
+				0x61, 'f', 0xfb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0,
+			},
+			want: map[string]any{"a": int64(1), "b": int64(-2), "c": []byte{1}, "d": true, "e": []any{int64(1)}, "f": 1.5},
+		},
+		{name: "CBOR tag and null", data: []byte{0xa2, 0x61, 't', 0xc1, 0x01, 0x61, 'n', 0xf6}, want: map[string]any{"t": int64(1), "n": nil}},
+		{name: "CBOR large uint", data: []byte{0xa1, 0x61, 'u', 0x1b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, want: map[string]any{"u": uint64(1<<64 - 1)}},
+		{name: "CBOR negative out of range", data: []byte{0xa1, 0x61, 'n', 0x3b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, wantErr: true},
+		{name: "CBOR half float", data: []byte{0xa1, 0x61, 'h', 0xf9, 0x3e, 0x00}, wantErr: true},
+		{name: "CBOR integer key", data: []byte{0xa1, 0x01, 0x01}, wantErr: true},
+		{name: "CBOR array", data: []byte{0x81, 0x01}, wantErr: true},
+		{name: "CBOR trailing data", data: []byte{0xa0, 0x00}, wantErr: true},
+		{name: "CBOR truncated", data: []byte{0xa2, 0x61, 'a', 0x01}, wantErr: true},
+		{name: "CBOR nested too deeply", data: append(bytes.Repeat([]byte{0x81}, cborMaxDepth+1), 0x01), wantErr: true},
+		{name: "empty", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeClaims(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeClaims() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeClaims() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckClaims(t *testing.T) {
+	selfSigned, err := x509.ParseCertificate(newTestRATLSCert(t, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, rootKey := newTestCert(t, "Root CA", true, nil, nil)
+	issued, _ := newTestCert(t, "enclave", false, root, rootKey)
+	claims := []byte(`{"config": "prod"}`)
+	tests := []struct {
+		name    string
+		ev      *evidence
+		wantErr bool
+		wantIs  error
+	}{
+		{name: "self-signed certificate", ev: &evidence{cert: selfSigned, claims: claims}},
+		{name: "attested channel", ev: &evidence{channel: true, claims: claims}},
+		{name: "certificate signed by another key", ev: &evidence{cert: issued, claims: claims}, wantErr: true, wantIs: ErrBadSignature},
+		{name: "saved evidence", ev: &evidence{claims: claims}, wantErr: true},
+		{name: "malformed claims", ev: &evidence{cert: selfSigned, claims: []byte("{")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &VerificationResult{}
+			err := checkClaims(tt.ev, res)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkClaims() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Errorf("checkClaims() error = %v, want %v", err, tt.wantIs)
+			}
+			if err == nil && (res.Claims["config"] != "prod" || !bytes.Equal(res.RawClaims, claims)) {
+				t.Errorf("checkClaims() claims = %v, raw %q", res.Claims, res.RawClaims)
+			}
+		})
+	}
+}
+
+func TestSplitIASPayload(t *testing.T) {
+	b64 := base64.StdEncoding.EncodeToString
+	sig, cert, claims := b64([]byte("sig")), b64([]byte("cert")), b64([]byte(`{"a":1}`))
+	tests := []struct {
+		name       string
+		payload    string
+		wantClaims []byte
+		wantErr    bool
+	}{
+		{name: "three segments", payload: `{"id":"1"}|` + sig + "|" + cert},
+		{name: "claims", payload: `{"id":"1"}|` + sig + "|" + cert + "|" + claims, wantClaims: []byte(`{"a":1}`)},
+		{name: "empty claims", payload: `{"id":"1"}|` + sig + "|" + cert + "|", wantErr: true},
+		{name: "malformed claims", payload: `{"id":"1"}|` + sig + "|" + cert + "|{}", wantErr: true},
+		{name: "five segments", payload: `{"id":"1"}|` + sig + "|" + cert + "|" + claims + "|" + claims, wantErr: true},
+		{name: "malformed signature", payload: `{"id":"1"}|!|` + cert, wantErr: true},
+		{name: "malformed certificate", payload: `{"id":"1"}|` + sig + "|!", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, gotSig, gotCert, gotClaims, err := splitIASPayload([]byte(tt.payload))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitIASPayload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if string(report) != `{"id":"1"}` || string(gotSig) != "sig" || string(gotCert) != "cert" {
+				t.Errorf("splitIASPayload() = %q, %q, %q", report, gotSig, gotCert)
+			}
+			if !bytes.Equal(gotClaims, tt.wantClaims) {
+				t.Errorf("splitIASPayload() claims = %q, want %q", gotClaims, tt.wantClaims)
+			}
+		})
+	}
+}
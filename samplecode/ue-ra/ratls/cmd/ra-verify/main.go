@@ -0,0 +1,419 @@
+// Command ra-verify verifies saved attestation evidence and prints the
+// verdict, so RA-TLS certificates, quotes and IAS reports can be checked
+// from scripts and during incident response.
+//
+// Usage:
+//
+//	ra-verify [flags] <file>...
+//
+// The file is an RA-TLS certificate (PEM or DER), a raw payload (IAS report
+// bundle or quote), or an IAS report JSON given with -report-sig and
+// -report-cert. Several files are verified concurrently, sharing fetched
+// collateral, and a verdict is printed for each. The exit status is 0 if
+// all evidence is accepted, 1 if any is rejected and 2 on usage errors.
+//
+// The dump subcommand prints every field of a quote without verifying it:
+//
+//	ra-verify dump [-json] <file>
+//
+// The file holds a raw EPID or ECDSA quote, its base64 encoding (such as an
+// isvEnclaveQuoteBody) or an IAS report JSON.
+package main
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/apache/incubator-teaclave-sgx-sdk/samplecode/ue-ra/ratls"
+)
+
+var (
+	mode        = flag.String("mode", "epid", "evidence format: epid, dcap, tdx, maa, ita or auto")
+	testRoots   = flag.String("insecure-test-roots", "", "INSECURE: test trust bundle directory (roots.pem, saved collateral) replacing the Intel roots")
+	iasRoot     = flag.String("ias-root", "", "PEM file overriding the embedded IAS report signing root")
+	sgxRoot     = flag.String("sgx-root", "", "PEM file overriding the embedded Intel SGX Root CA (dcap, tdx, auto)")
+	reportSig   = flag.String("report-sig", "", "file holding the X-IASReport-Signature header of a saved IAS report")
+	reportCert  = flag.String("report-cert", "", "file holding the X-IASReport-Signing-Certificate header (URL encoded or PEM) of a saved IAS report")
+	collateral  = flag.String("collateral-url", "", "PCS or PCCS certification API to fetch DCAP collateral from")
+	cacheDir    = flag.String("collateral-cache", "", "directory of saved collateral; used offline unless -collateral-url is set")
+	crls        = flag.String("crl", "", "comma separated CRL files of the PCK hierarchy")
+	maaURL      = flag.String("maa-url", "", "Azure Attestation provider whose tokens are trusted (maa, auto)")
+	maaJWKS     = flag.String("maa-jwks", "", "file of the provider's saved signing keys (JWKS), used instead of fetching them")
+	itaURL      = flag.String("ita-url", "", "Intel Trust Authority portal serving the token signing keys (ita, auto), "+ratls.DefaultITAURL+" if empty in ita mode")
+	itaJWKS     = flag.String("ita-jwks", "", "file of Intel Trust Authority's saved signing keys (JWKS), used instead of fetching them")
+	quoteStatus = flag.String("quote-status", "permissive", "accepted IAS quote statuses")
+	tcbStatus   = flag.String("tcb-status", "permissive", "accepted DCAP/TDX TCB statuses")
+	exceptions  = flag.String("advisory-exceptions", "", "file of advisory IDs accepted for GROUP_OUT_OF_DATE/SW_HARDENING_NEEDED (OutOfDate/SWHardeningNeeded) platforms")
+	binding     = flag.String("binding", "auto", "what report_data binds in certificates: auto (spki-sha256 or key), key or spki-sha256")
+	rules       = flag.String("rules", "", "JSON appraisal rules the enclave must also satisfy")
+	signType    = flag.String("sign-type", "", "required EPID signature type: linkable or unlinkable")
+	allowDebug  = flag.Bool("allow-debug", false, "accept debug enclaves")
+	allowSim    = flag.Bool("allow-sim", false, "accept the bare quotes of simulation mode enclaves (development only, INSECURE)")
+	at          = flag.String("at", "", "verify as of this RFC 3339 time")
+	jsonOut     = flag.Bool("json", false, "print the result as JSON")
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "dump" {
+		dump(os.Args[2:])
+		return
+	}
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] <file>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if flag.NArg() > 1 && (*reportSig != "" || *reportCert != "") {
+		fatalUsage(errors.New("-report-sig and -report-cert take a single report"))
+	}
+
+	verifier, err := newVerifier()
+	if err != nil {
+		fatalUsage(err)
+	}
+	items := make([][]byte, flag.NArg())
+	for i, path := range flag.Args() {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			fatalUsage(err)
+		}
+		if items[i], err = evidence(raw); err != nil {
+			fatalUsage(err)
+		}
+	}
+
+	rejected := false
+	for i, r := range verifier.VerifyAll(items) {
+		if flag.NArg() > 1 {
+			if i > 0 && !*jsonOut {
+				fmt.Println()
+			}
+			report(flag.Arg(i), r.Result, r.Err)
+		} else {
+			report("", r.Result, r.Err)
+		}
+		rejected = rejected || r.Err != nil
+	}
+	if rejected {
+		os.Exit(1)
+	}
+}
+
+func dump(args []string) {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the quote as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s dump [-json] <file>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	raw, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fatalUsage(err)
+	}
+	d, err := ratls.DumpQuote(raw)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ra-verify:", err)
+		os.Exit(1)
+	}
+	if *asJSON {
+		out, _ := json.MarshalIndent(d, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	fmt.Print(d)
+}
+
+func newVerifier() (*ratls.Verifier, error) {
+	v := &ratls.Verifier{AllowDebug: *allowDebug, AllowSimulation: *allowSim}
+	var err error
+	switch *mode {
+	case "epid":
+		v.Mode = ratls.ModeEPID
+	case "dcap":
+		v.Mode = ratls.ModeDCAP
+	case "tdx":
+		v.Mode = ratls.ModeTDX
+	case "maa":
+		v.Mode = ratls.ModeMAA
+	case "ita":
+		v.Mode = ratls.ModeITA
+	case "auto":
+		v.Mode = ratls.ModeAuto
+	default:
+		return nil, fmt.Errorf("unknown mode %q", *mode)
+	}
+
+	if v.Mode == ratls.ModeEPID || v.Mode == ratls.ModeAuto {
+		if *iasRoot != "" {
+			if v.IASRoots, err = loadPool(*iasRoot); err != nil {
+				return nil, err
+			}
+		}
+		if v.QuoteStatuses, err = ratls.ParseQuoteStatusPolicy(*quoteStatus); err != nil {
+			return nil, err
+		}
+		// Saved reports are typically older than a live handshake allows
+		v.MaxReportAge = -1
+	}
+	if v.Mode == ratls.ModeMAA || v.Mode == ratls.ModeAuto && *maaURL != "" {
+		if *maaURL == "" {
+			return nil, errors.New("-maa-url is required in maa mode")
+		}
+		v.MAA = ratls.NewMAAIssuer(*maaURL)
+		if *maaJWKS != "" {
+			if v.MAA.Keys, err = loadJWKS(*maaJWKS); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if v.Mode == ratls.ModeITA || v.Mode == ratls.ModeAuto && *itaURL != "" {
+		v.ITA = ratls.NewITAIssuer(*itaURL)
+		if *itaJWKS != "" {
+			if v.ITA.Keys, err = loadJWKS(*itaJWKS); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if v.MAA != nil || v.ITA != nil {
+		// Saved tokens are typically older than a live handshake allows
+		v.MaxReportAge = -1
+		if v.TCBStatuses, err = ratls.ParseTCBStatusPolicy(*tcbStatus); err != nil {
+			return nil, err
+		}
+	}
+	if v.Mode == ratls.ModeDCAP || v.Mode == ratls.ModeTDX || v.Mode == ratls.ModeAuto {
+		if *sgxRoot != "" {
+			if v.SGXRoots, err = loadPool(*sgxRoot); err != nil {
+				return nil, err
+			}
+		}
+		if v.TCBStatuses, err = ratls.ParseTCBStatusPolicy(*tcbStatus); err != nil {
+			return nil, err
+		}
+		switch {
+		case *collateral != "":
+			client := &ratls.CollateralClient{BaseURL: *collateral}
+			if v.Mode == ratls.ModeTDX {
+				client.TCBBaseURL = strings.Replace(*collateral, "/sgx/", "/tdx/", 1)
+			}
+			v.Collateral = &ratls.CollateralCache{Source: client, Dir: *cacheDir}
+			v.PCKCerts = client
+		case *cacheDir != "":
+			v.Collateral = ratls.OfflineCollateral{Dir: *cacheDir}
+		}
+		if *crls != "" {
+			for _, path := range strings.Split(*crls, ",") {
+				crl, err := os.ReadFile(path)
+				if err != nil {
+					return nil, err
+				}
+				v.CRLs = append(v.CRLs, crl)
+			}
+		}
+	}
+	if v.Binding, err = ratls.ParseKeyBinding(*binding); err != nil {
+		return nil, err
+	}
+	if *signType != "" {
+		if v.SignType, err = ratls.ParseEPIDSignType(*signType); err != nil {
+			return nil, err
+		}
+		v.CheckSignType = true
+	}
+	if *rules != "" {
+		if v.Policy, err = ratls.LoadRulePolicy(*rules); err != nil {
+			return nil, err
+		}
+	}
+	if *exceptions != "" {
+		if v.AdvisoryExceptions, err = ratls.LoadAdvisoryExceptions(*exceptions); err != nil {
+			return nil, err
+		}
+	}
+	if *testRoots != "" {
+		roots, err := ratls.LoadTestRoots(*testRoots)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintln(os.Stderr, "ra-verify: WARNING: trusting insecure test roots from", *testRoots)
+		ratls.WithInsecureTestRoots(roots)(v)
+	}
+	if *at != "" {
+		t, err := time.Parse(time.RFC3339, *at)
+		if err != nil {
+			return nil, err
+		}
+		v.Clock = ratls.FixedClock(t)
+	}
+	return v, nil
+}
+
+// evidence returns the DER certificate or the evidence payload in raw, as
+// taken by Verifier.VerifyAll.
+func evidence(raw []byte) ([]byte, error) {
+	if block, _ := pem.Decode(raw); block != nil && block.Type == "CERTIFICATE" {
+		return block.Bytes, nil
+	}
+	if _, err := x509.ParseCertificate(raw); err == nil {
+		return raw, nil
+	}
+	if *reportSig != "" || *reportCert != "" {
+		return iasBundle(raw)
+	}
+	return raw, nil
+}
+
+// iasBundle builds the "report|sig|cert" payload of a saved IAS response
+// from its body and signature headers.
+func iasBundle(report []byte) ([]byte, error) {
+	if *reportSig == "" || *reportCert == "" {
+		return nil, fmt.Errorf("-report-sig and -report-cert must be given together")
+	}
+	sig, err := os.ReadFile(*reportSig)
+	if err != nil {
+		return nil, err
+	}
+	certHeader, err := os.ReadFile(*reportCert)
+	if err != nil {
+		return nil, err
+	}
+	// The header is URL encoded, a PEM file is taken as is: unescaping
+	// would turn the '+' of its base64 into spaces
+	block, _ := pem.Decode(certHeader)
+	if block == nil {
+		certPEM, err := url.QueryUnescape(strings.TrimSpace(string(certHeader)))
+		if err != nil {
+			return nil, err
+		}
+		// The header carries the signing certificate followed by the root
+		block, _ = pem.Decode([]byte(certPEM))
+	}
+	if block == nil {
+		return nil, fmt.Errorf("%s holds no PEM certificate", *reportCert)
+	}
+	bundle := strings.Join([]string{
+		strings.TrimSpace(string(report)),
+		strings.TrimSpace(string(sig)),
+		base64.StdEncoding.EncodeToString(block.Bytes),
+	}, "|")
+	return []byte(bundle), nil
+}
+
+// report prints the verdict on the evidence in file, which is only named
+// when several files are verified.
+func report(file string, res *ratls.VerificationResult, err error) {
+	verdict := "ACCEPTED"
+	if err != nil {
+		verdict = "REJECTED"
+	}
+	if *jsonOut {
+		out := struct {
+			File    string                    `json:"file,omitempty"`
+			Verdict string                    `json:"verdict"`
+			Error   string                    `json:"error,omitempty"`
+			Cause   string                    `json:"cause,omitempty"`
+			Result  *ratls.VerificationResult `json:"result,omitempty"`
+		}{File: file, Verdict: verdict, Cause: ratls.Reason(err), Result: res}
+		if err != nil {
+			out.Error = err.Error()
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(out)
+		return
+	}
+
+	if file != "" {
+		fmt.Println("file:         ", file)
+	}
+	fmt.Println("verdict:      ", verdict)
+	if err != nil {
+		fmt.Println("reason:       ", err)
+		fmt.Println("cause:        ", ratls.Reason(err))
+	}
+	if res == nil {
+		return
+	}
+	fmt.Println("mode:         ", res.Mode)
+	fmt.Println("mr_enclave:   ", res.MrEnclave)
+	fmt.Println("mr_signer:    ", res.MrSigner)
+	fmt.Println("isv_prod_id:  ", res.ISVProdID)
+	fmt.Println("isv_svn:      ", res.ISVSVN)
+	fmt.Println("debug:        ", res.Debug)
+	fmt.Println("report_data:  ", res.ReportData)
+	fmt.Println("key bound:    ", res.KeyBound)
+	if res.InsecureTestRoots {
+		fmt.Println("test roots:   ", "INSECURE")
+	}
+	if res.Simulated {
+		fmt.Println("simulated:    ", "INSECURE, not attested by SGX hardware")
+	}
+	if res.QuoteStatus != "" {
+		fmt.Println("quote status: ", res.QuoteStatus)
+		fmt.Println("timestamp:    ", res.Timestamp.Format(time.RFC3339))
+	}
+	if res.TokenIssuer != "" {
+		fmt.Println("issuer:       ", res.TokenIssuer)
+		fmt.Println("issued at:    ", res.TokenIssuedAt.Format(time.RFC3339))
+		fmt.Println("expires:      ", res.TokenExpiry.Format(time.RFC3339))
+	}
+	if res.TCBStatus != "" {
+		fmt.Println("fmspc:        ", res.FMSPC)
+		fmt.Println("tcb status:   ", res.TCBStatus)
+		fmt.Println("qe status:    ", res.QEStatus)
+	}
+	if len(res.AdvisoryIDs) > 0 {
+		fmt.Println("advisories:   ", strings.Join(res.AdvisoryIDs, ", "))
+	}
+	if len(res.PolicyReasons) > 0 {
+		fmt.Println("policy:       ", strings.Join(res.PolicyReasons, "; "))
+	}
+	if res.Claims != nil {
+		if claims, err := json.Marshal(res.Claims); err == nil {
+			fmt.Println("claims:       ", string(claims))
+		}
+	}
+}
+
+func loadJWKS(path string) (ratls.JWKSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ratls.ParseJWKS(data)
+}
+
+func loadPool(path string) (*x509.CertPool, error) {
+	pemBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemBytes) {
+		return nil, fmt.Errorf("no certificate found in %s", path)
+	}
+	return pool, nil
+}
+
+func fatalUsage(err error) {
+	fmt.Fprintln(os.Stderr, "ra-verify:", err)
+	os.Exit(2)
+}
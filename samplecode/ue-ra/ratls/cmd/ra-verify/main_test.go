@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvidence(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Intel SGX Attestation Report Signing"},
+		NotBefore:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	dir := t.TempDir()
+	write := func(name string, content []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, content, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	sigFile := write("sig", []byte("c2lnbmF0dXJl\n"))
+	headerFile := write("header", []byte(url.QueryEscape(string(certPEM))))
+	// IAS escapes spaces as %20
+	iasHeaderFile := write("ias-header", []byte(strings.ReplaceAll(url.QueryEscape(string(certPEM)), "+", "%20")))
+	pemFile := write("cert.pem", certPEM)
+	bogusFile := write("bogus", []byte("not a certificate"))
+	bundle := []byte(`{"id":"1"}|c2lnbmF0dXJl|` + base64.StdEncoding.EncodeToString(der))
+
+	tests := []struct {
+		name       string
+		raw        []byte
+		reportSig  string
+		reportCert string
+		want       []byte
+		wantErr    bool
+	}{
+		{name: "PEM certificate", raw: certPEM, want: der},
+		{name: "DER certificate", raw: der, want: der},
+		{name: "payload", raw: []byte("report|sig|cert"), want: []byte("report|sig|cert")},
+		{name: "saved IAS response", raw: []byte("{\"id\":\"1\"}\n"), reportSig: sigFile, reportCert: headerFile, want: bundle},
+		{name: "IAS header", raw: []byte(`{"id":"1"}`), reportSig: sigFile, reportCert: iasHeaderFile, want: bundle},
+		{name: "PEM signing certificate", raw: []byte(`{"id":"1"}`), reportSig: sigFile, reportCert: pemFile, want: bundle},
+		{name: "signature only", raw: []byte(`{"id":"1"}`), reportSig: sigFile, wantErr: true},
+		{name: "no signing certificate", raw: []byte(`{"id":"1"}`), reportSig: sigFile, reportCert: bogusFile, wantErr: true},
+		{name: "missing signature", raw: []byte(`{"id":"1"}`), reportSig: filepath.Join(dir, "missing"), reportCert: pemFile, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*reportSig, *reportCert = tt.reportSig, tt.reportCert
+			defer func() { *reportSig, *reportCert = "", "" }()
+			got, err := evidence(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("evidence() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("evidence() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package ratls
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// DefaultPCSURL is the Intel Provisioning Certification Service.
+	DefaultPCSURL = "https://api.trustedservices.intel.com/sgx/certification/v4"
+	// DefaultTDXPCSURL serves the TCB info and QE identity of TDX platforms.
+	DefaultTDXPCSURL = "https://api.trustedservices.intel.com/tdx/certification/v4"
+	// DefaultPCCSURL is a PCCS deployed with its default settings.
+	DefaultPCCSURL = "https://localhost:8081/sgx/certification/v4"
+	// DefaultRootCACRLURL is where Intel publishes the SGX Root CA CRL.
+	DefaultRootCACRLURL = "https://certificates.trustedservices.intel.com/IntelSGXRootCA.der"
+)
+
+// PCK CA types accepted by GetPCKCRL.
+const (
+	PCKCAPlatform  = "platform"
+	PCKCAProcessor = "processor"
+)
+
+// maxCollateralSize bounds the size of a collateral response.
+const maxCollateralSize = 1 << 20
+
+// Collateral is the signed data needed to appraise a DCAP quote. The JSON
+// bodies are kept as returned by the service so their signatures can be
+// checked over the exact bytes.
+type Collateral struct {
+	// TCBInfo is the {"tcbInfo": ..., "signature": ...} document for the
+	// platform FMSPC.
+	TCBInfo            []byte
+	TCBInfoIssuerChain []*x509.Certificate
+
+	// QEIdentity is the {"enclaveIdentity": ..., "signature": ...} document
+	// of the quoting enclave.
+	QEIdentity            []byte
+	QEIdentityIssuerChain []*x509.Certificate
+
+	// PCKCRL is the DER CRL of the PCK Platform or Processor CA.
+	PCKCRL            []byte
+	PCKCRLIssuerChain []*x509.Certificate
+
+	// RootCACRL is the DER CRL of the Intel SGX Root CA.
+	RootCACRL []byte
+}
+
+// CollateralSource provides collateral for a platform, identified by its
+// FMSPC (hex) and the type of PCK CA that issued its PCK certificate.
+type CollateralSource interface {
+	GetCollateral(ctx context.Context, fmspc, ca string) (*Collateral, error)
+}
+
+// CollateralClient fetches collateral from the Intel PCS or from a PCCS.
+// The zero value talks to the Intel PCS.
+type CollateralClient struct {
+	// BaseURL of the v4 certification API, DefaultPCSURL if empty.
+	BaseURL string
+	// TCBBaseURL, if set, is used instead of BaseURL for TCB info and QE
+	// identity. Set it to DefaultTDXPCSURL, or the tdx path of a PCCS, to
+	// fetch TDX collateral; the PCK CRLs are shared with SGX.
+	TCBBaseURL string
+	// RootCACRLURL is used when the service does not serve the root CA CRL
+	// itself. DefaultRootCACRLURL if empty.
+	RootCACRLURL string
+	// APIKey is sent as Ocp-Apim-Subscription-Key, if set.
+	APIKey string
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// GetCollateral fetches the TCB info, QE identity and CRLs for a platform.
+func (c *CollateralClient) GetCollateral(ctx context.Context, fmspc, ca string) (*Collateral, error) {
+	col := &Collateral{}
+	var err error
+	if col.TCBInfo, col.TCBInfoIssuerChain, err = c.GetTCBInfo(ctx, fmspc); err != nil {
+		return nil, err
+	}
+	if col.QEIdentity, col.QEIdentityIssuerChain, err = c.GetQEIdentity(ctx); err != nil {
+		return nil, err
+	}
+	if col.PCKCRL, col.PCKCRLIssuerChain, err = c.GetPCKCRL(ctx, ca); err != nil {
+		return nil, err
+	}
+	if col.RootCACRL, err = c.GetRootCACRL(ctx); err != nil {
+		return nil, err
+	}
+	return col, nil
+}
+
+// GetTCBInfo fetches the signed TCB info of an FMSPC and its issuer chain.
+func (c *CollateralClient) GetTCBInfo(ctx context.Context, fmspc string) ([]byte, []*x509.Certificate, error) {
+	return c.getSigned(ctx, c.tcbBaseURL(), "/tcb?fmspc="+url.QueryEscape(fmspc), "TCB-Info-Issuer-Chain", "SGX-TCB-Info-Issuer-Chain")
+}
+
+// GetQEIdentity fetches the signed quoting enclave identity and its issuer
+// chain.
+func (c *CollateralClient) GetQEIdentity(ctx context.Context) ([]byte, []*x509.Certificate, error) {
+	return c.getSigned(ctx, c.tcbBaseURL(), "/qe/identity", "SGX-Enclave-Identity-Issuer-Chain")
+}
+
+// GetPCKCRL fetches the DER CRL of the given PCK CA (PCKCAPlatform or
+// PCKCAProcessor) and its issuer chain.
+func (c *CollateralClient) GetPCKCRL(ctx context.Context, ca string) ([]byte, []*x509.Certificate, error) {
+	return c.getSigned(ctx, c.baseURL(), "/pckcrl?ca="+url.QueryEscape(ca)+"&encoding=der", "SGX-PCK-CRL-Issuer-Chain")
+}
+
+// GetRootCACRL fetches the DER CRL of the Intel SGX Root CA. A PCCS serves
+// it under /rootcacrl; otherwise RootCACRLURL is used.
+func (c *CollateralClient) GetRootCACRL(ctx context.Context) ([]byte, error) {
+	if c.BaseURL != "" && c.BaseURL != DefaultPCSURL {
+		body, _, err := c.get(ctx, c.BaseURL+"/rootcacrl")
+		if err == nil {
+			// Older PCCS releases return the DER CRL hex encoded
+			if der, herr := hex.DecodeString(strings.TrimSpace(string(body))); herr == nil {
+				return der, nil
+			}
+			return body, nil
+		}
+	}
+	rootURL := c.RootCACRLURL
+	if rootURL == "" {
+		rootURL = DefaultRootCACRLURL
+	}
+	body, _, err := c.get(ctx, rootURL)
+	return body, err
+}
+
+func (c *CollateralClient) baseURL() string {
+	if c.BaseURL == "" {
+		return DefaultPCSURL
+	}
+	return c.BaseURL
+}
+
+func (c *CollateralClient) tcbBaseURL() string {
+	if c.TCBBaseURL == "" {
+		return c.baseURL()
+	}
+	return c.TCBBaseURL
+}
+
+func (c *CollateralClient) getSigned(ctx context.Context, base, path string, chainHeaders ...string) ([]byte, []*x509.Certificate, error) {
+	body, header, err := c.get(ctx, base+path)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, name := range chainHeaders {
+		if raw := header.Get(name); raw != "" {
+			pemChain, err := url.QueryUnescape(raw)
+			if err != nil {
+				return nil, nil, fmt.Errorf("decode %s: %v", name, err)
+			}
+			chain, err := parsePEMCerts([]byte(pemChain))
+			if err != nil {
+				return nil, nil, err
+			}
+			return body, chain, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("%s: response carries no %s header", path, chainHeaders[0])
+}
+
+func (c *CollateralClient) get(ctx context.Context, u string) ([]byte, http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.APIKey != "" {
+		req.Header.Set("Ocp-Apim-Subscription-Key", c.APIKey)
+	}
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCollateralSize))
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("GET %s: %s: %s", u, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, resp.Header, nil
+}
@@ -0,0 +1,101 @@
+package ratls
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestCollateralClient(t *testing.T) {
+	pki := newTestCollateralPKI(t)
+	chain := url.QueryEscape(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: pki.signer.Raw})) +
+		string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: pki.root.Raw})))
+	crl := []byte{0x30, 0x03, 0x02, 0x01, 0x01}
+
+	// pccs serves the collateral of FMSPC 00906ed50000 from the Platform CA,
+	// with the TCB info chain under tcbHeader and the root CA CRL encoded by
+	// rootCACRL.
+	pccs := func(tcbHeader string, rootCACRL func([]byte) []byte) http.Handler {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/tcb", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("fmspc") != "00906ed50000" {
+				http.NotFound(w, r)
+				return
+			}
+			if tcbHeader != "" {
+				w.Header().Set(tcbHeader, chain)
+			}
+			w.Write([]byte(`{"tcbInfo":{}}`))
+		})
+		mux.HandleFunc("/qe/identity", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("SGX-Enclave-Identity-Issuer-Chain", chain)
+			w.Write([]byte(`{"enclaveIdentity":{}}`))
+		})
+		mux.HandleFunc("/pckcrl", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("ca") != PCKCAPlatform || r.URL.Query().Get("encoding") != "der" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("SGX-PCK-CRL-Issuer-Chain", chain)
+			w.Write(crl)
+		})
+		mux.HandleFunc("/rootcacrl", func(w http.ResponseWriter, r *http.Request) {
+			w.Write(rootCACRL(crl))
+		})
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Ocp-Apim-Subscription-Key") != "key" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			mux.ServeHTTP(w, r)
+		})
+	}
+	der := func(b []byte) []byte { return b }
+	hexCRL := func(b []byte) []byte { return []byte(hex.EncodeToString(b) + "\n") }
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		fmspc   string
+		apiKey  string
+		wantErr bool
+	}{
+		{name: "DER root CA CRL", handler: pccs("TCB-Info-Issuer-Chain", der), fmspc: "00906ed50000", apiKey: "key"},
+		{name: "hex root CA CRL", handler: pccs("TCB-Info-Issuer-Chain", hexCRL), fmspc: "00906ed50000", apiKey: "key"},
+		{name: "v3 TCB info header", handler: pccs("SGX-TCB-Info-Issuer-Chain", der), fmspc: "00906ed50000", apiKey: "key"},
+		{name: "no issuer chain", handler: pccs("", der), fmspc: "00906ed50000", apiKey: "key", wantErr: true},
+		{name: "unknown FMSPC", handler: pccs("TCB-Info-Issuer-Chain", der), fmspc: "00606a000000", apiKey: "key", wantErr: true},
+		{name: "no API key", handler: pccs("TCB-Info-Issuer-Chain", der), fmspc: "00906ed50000", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+			c := &CollateralClient{BaseURL: srv.URL, APIKey: tt.apiKey}
+			col, err := c.GetCollateral(context.Background(), tt.fmspc, PCKCAPlatform)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetCollateral() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if string(col.TCBInfo) != `{"tcbInfo":{}}` || string(col.QEIdentity) != `{"enclaveIdentity":{}}` {
+				t.Errorf("TCB info, QE identity = %s, %s", col.TCBInfo, col.QEIdentity)
+			}
+			for _, got := range [][]*x509.Certificate{col.TCBInfoIssuerChain, col.QEIdentityIssuerChain, col.PCKCRLIssuerChain} {
+				if len(got) != 2 || !got[0].Equal(pki.signer) || !got[1].Equal(pki.root) {
+					t.Errorf("issuer chain of %d certificates, want the signer and the root", len(got))
+				}
+			}
+			if !bytes.Equal(col.PCKCRL, crl) || !bytes.Equal(col.RootCACRL, crl) {
+				t.Errorf("PCK CRL, root CA CRL = %x, %x, want %x", col.PCKCRL, col.RootCACRL, crl)
+			}
+		})
+	}
+}
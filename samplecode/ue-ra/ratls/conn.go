@@ -0,0 +1,215 @@
+package ratls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// PeerVerifier verifies the certificate chain presented by a peer. Verifier
+// and CachingVerifier implement it.
+type PeerVerifier interface {
+	VerifyPeerChain(peer string, rawCerts [][]byte) (*VerificationResult, error)
+}
+
+// Conn is a TLS connection whose peer is verified as an RA-TLS enclave. Its
+// Identity method exposes the attested enclave to the application, e.g. to
+// authorize requests per MRENCLAVE or MRSIGNER.
+type Conn struct {
+	*tls.Conn
+
+	verifier PeerVerifier
+	channel  ChannelVerifier
+	peer     string
+
+	mu  sync.Mutex
+	res *VerificationResult
+}
+
+// Client returns an RA-TLS client connection over conn, which verifies the
+// server with v during the handshake. config is cloned; its own
+// VerifyPeerCertificate, if set, must pass as well and runs first.
+func Client(conn net.Conn, config *tls.Config, v PeerVerifier) *Conn {
+	c := &Conn{verifier: v, peer: conn.RemoteAddr().String()}
+	c.Conn = tls.Client(conn, c.config(config))
+	return c
+}
+
+// Server returns an RA-TLS server connection over conn, which requires a
+// client certificate and verifies it with v during the handshake. config is
+// cloned as for Client.
+func Server(conn net.Conn, config *tls.Config, v PeerVerifier) *Conn {
+	c := &Conn{verifier: v, peer: conn.RemoteAddr().String()}
+	conf := c.config(config)
+	conf.ClientAuth = tls.RequireAnyClientCert
+	// The verification is bound to c, not to a per-client configuration
+	conf.GetConfigForClient = nil
+	c.Conn = tls.Server(conn, conf)
+	return c
+}
+
+// PostHandshakeClient returns a client connection over conn whose server
+// sends its evidence in an attest message after the handshake, see
+// ChannelBinding. The certificate of the server is not verified, except by
+// config's own VerifyPeerCertificate; Identity reads and verifies the
+// evidence with v before any application data.
+func PostHandshakeClient(conn net.Conn, config *tls.Config, v ChannelVerifier) *Conn {
+	c := &Conn{channel: v, peer: conn.RemoteAddr().String()}
+	c.Conn = tls.Client(conn, c.config(config))
+	return c
+}
+
+// PostHandshakeServer is PostHandshakeClient for servers whose clients send
+// their evidence after the handshake. A client certificate is still
+// required, as the channel binding covers it.
+func PostHandshakeServer(conn net.Conn, config *tls.Config, v ChannelVerifier) *Conn {
+	c := &Conn{channel: v, peer: conn.RemoteAddr().String()}
+	conf := c.config(config)
+	conf.ClientAuth = tls.RequireAnyClientCert
+	conf.GetConfigForClient = nil
+	c.Conn = tls.Server(conn, conf)
+	return c
+}
+
+func (c *Conn) config(config *tls.Config) *tls.Config {
+	conf := config.Clone()
+	if conf == nil {
+		conf = &tls.Config{}
+	}
+	// RA-TLS certificates are self-signed; trust comes from the evidence
+	conf.InsecureSkipVerify = true
+	next := conf.VerifyPeerCertificate
+	conf.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if next != nil {
+			if err := next(rawCerts, verifiedChains); err != nil {
+				return err
+			}
+		}
+		if c.channel != nil {
+			// The evidence follows the handshake
+			return nil
+		}
+		res, err := c.verifier.VerifyPeerChain(c.peer, rawCerts)
+		if err != nil {
+			return err
+		}
+		c.mu.Lock()
+		c.res = res
+		c.mu.Unlock()
+		return nil
+	}
+	if cache, ok := conf.ClientSessionCache.(*SessionCache); ok {
+		// crypto/tls stores client sessions under the server name, or the
+		// address of the server if there is none
+		key := conf.ServerName
+		if key == "" {
+			key = c.peer
+		}
+		nextConn := conf.VerifyConnection
+		conf.VerifyConnection = func(cs tls.ConnectionState) error {
+			if nextConn != nil {
+				if err := nextConn(cs); err != nil {
+					return err
+				}
+			}
+			if cs.DidResume {
+				cache.resumed(key)
+			}
+			return nil
+		}
+	}
+	return conf
+}
+
+// Identity completes the handshake if needed and returns the verification
+// result of the peer: its MRENCLAVE, MRSIGNER, ISV SVN, TCB status and the
+// rest of the attested claims. The result must not be modified. After a
+// renegotiation, whose certificate is verified as well, it is the result of
+// the certificate presented then.
+//
+// Resumed sessions do not present the certificate again. For them, the
+// certificate of the original handshake, which crypto/tls keeps with the
+// session, is verified once more; a CachingVerifier avoids repeating the
+// work. Verifiers with a Nonce reject it, as the evidence answers an older
+// challenge. A SessionCache bounds how long such evidence is reused.
+//
+// For PostHandshakeClient and PostHandshakeServer connections, Identity
+// reads the attest message of the peer, which follows every handshake,
+// resumed or not, and verifies it. It must be called before reading
+// application data.
+func (c *Conn) Identity() (*VerificationResult, error) {
+	return c.IdentityContext(context.Background())
+}
+
+// IdentityContext is Identity with a context bounding the handshake.
+func (c *Conn) IdentityContext(ctx context.Context) (*VerificationResult, error) {
+	if err := c.HandshakeContext(ctx); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.res != nil {
+		return c.res, nil
+	}
+	if c.channel != nil {
+		return c.readEvidence(ctx)
+	}
+	certs := c.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, errors.New("peer presented no certificate")
+	}
+	rawCerts := make([][]byte, len(certs))
+	for i, cert := range certs {
+		rawCerts[i] = cert.Raw
+	}
+	res, err := c.verifier.VerifyPeerChain(c.peer, rawCerts)
+	if err != nil {
+		return nil, err
+	}
+	c.res = res
+	return res, nil
+}
+
+// readEvidence reads and verifies the attest message of the peer. c.mu
+// must be held.
+func (c *Conn) readEvidence(ctx context.Context) (*VerificationResult, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetReadDeadline(deadline)
+		defer c.SetReadDeadline(time.Time{})
+	}
+	payload, err := ReadEvidence(c.Conn)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.channel.VerifyChannelEvidence(c.peer, c.ConnectionState(), payload)
+	if err != nil {
+		return nil, err
+	}
+	c.res = res
+	return res, nil
+}
+
+// listener wraps accepted connections in Server.
+type listener struct {
+	net.Listener
+	config   *tls.Config
+	verifier PeerVerifier
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return Server(conn, l.config, l.verifier), nil
+}
+
+// NewListener returns a listener accepting RA-TLS server connections over
+// the connections of inner. Accepted connections are of type *Conn.
+func NewListener(inner net.Listener, config *tls.Config, v PeerVerifier) net.Listener {
+	return &listener{Listener: inner, config: config, verifier: v}
+}
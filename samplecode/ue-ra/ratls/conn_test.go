@@ -0,0 +1,81 @@
+package ratls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestConnIdentity(t *testing.T) {
+	cert := newSimulatedKeyPair(t, func(body *ReportBody) { body.MrEnclave[0] = 0xaa })
+	sim := &Verifier{AllowSimulation: true}
+	tests := []struct {
+		name string
+		// server makes the RA-TLS side the server of the handshake.
+		server     bool
+		verifier   PeerVerifier
+		verifyPeer func([][]byte, [][]*x509.Certificate) error
+		noPeerCert bool
+		wantErr    bool
+	}{
+		{name: "client", verifier: sim},
+		{name: "server", server: true, verifier: sim},
+		{name: "simulation not allowed", verifier: &Verifier{}, wantErr: true},
+		{
+			name:     "own check fails",
+			verifier: sim,
+			verifyPeer: func([][]byte, [][]*x509.Certificate) error {
+				return errors.New("rejected")
+			},
+			wantErr: true,
+		},
+		{name: "no client certificate", server: true, verifier: sim, noPeerCert: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, s := net.Pipe()
+			conf := &tls.Config{VerifyPeerCertificate: tt.verifyPeer}
+			peerConf := &tls.Config{InsecureSkipVerify: true}
+			if !tt.noPeerCert {
+				peerConf.Certificates = []tls.Certificate{cert}
+			}
+			var conn *Conn
+			var peer *tls.Conn
+			if tt.server {
+				conf.Certificates = []tls.Certificate{cert}
+				conn = Server(s, conf, tt.verifier)
+				peer = tls.Client(c, peerConf)
+			} else {
+				conn = Client(c, conf, tt.verifier)
+				peer = tls.Server(s, peerConf)
+			}
+			// The peer reads until the pipe closes, so that alerts sent
+			// after its side of the handshake completes do not block
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				if peer.Handshake() == nil {
+					peer.Read(make([]byte, 1))
+				}
+			}()
+			res, err := conn.Identity()
+			c.Close()
+			s.Close()
+			<-done
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Identity() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if res.Mode != ModeSim || res.MrEnclave[:2] != "aa" {
+				t.Errorf("Identity() = %s %s, want a simulated enclave aa...", res.Mode, res.MrEnclave)
+			}
+			if again, err := conn.Identity(); err != nil || again != res {
+				t.Errorf("second Identity() = %p, %v, want %p", again, err, res)
+			}
+		})
+	}
+}
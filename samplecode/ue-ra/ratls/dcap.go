@@ -0,0 +1,169 @@
+package ratls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+func (v *Verifier) verifyDCAPQuote(rawQuote []byte, res *VerificationResult) error {
+	now := v.now()
+	quote, err := parseECDSAQuote(rawQuote)
+	if err != nil {
+		return err
+	}
+	if quote.ReportBody == nil {
+		return errors.New("quote does not carry an SGX report body")
+	}
+	res.QuoteVersion = int(quote.Header.Version)
+
+	pck, col, err := v.verifyECDSAQuote(quote.signed, &quote.SignatureData, now, res)
+	if err != nil {
+		return err
+	}
+	res.setReportBody(quote.ReportBody.quoteReportBody())
+	if col == nil {
+		return nil
+	}
+
+	// 7. Evaluate the platform TCB level against the signed TCB info
+	return v.evaluateTCB(pck, col, nil, now, res)
+}
+
+// verifyECDSAQuote runs the checks shared by SGX and TDX quotes, up to the
+// QE identity. It returns the platform PCK extensions and collateral, or
+// nil ones if the Verifier has no collateral source.
+func (v *Verifier) verifyECDSAQuote(signed []byte, sig *ECDSASignatureData, now time.Time, res *VerificationResult) (*PCKExtensions, *Collateral, error) {
+	// 1. Verify the PCK certificate chain of the quote's platform
+	chain, col, err := v.pckChain(&sig.CertificationData)
+	if err != nil {
+		return nil, nil, err
+	}
+	path, err := verifyPCKChain(chain, v.sgxRoots(), now)
+	if err != nil {
+		return nil, nil, failure(ErrBadSignature, err)
+	}
+	pckCert := chain[0]
+
+	// 2. Verify the QE report is signed by the PCK key
+	pckKey, ok := pckCert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, nil, errors.New("PCK certificate does not carry an ECDSA key")
+	}
+	if !verifyRawECDSA(pckKey, sig.qeReportRaw, sig.QEReportSignature[:]) {
+		return nil, nil, failure(ErrBadSignature, errors.New("QE report signature is invalid"))
+	}
+
+	// 3. Verify the attestation key is bound to the QE report
+	h := sha256.New()
+	h.Write(sig.AttestationKey[:])
+	h.Write(sig.QEAuthData)
+	if !hasPrefixBytes(sig.QEReport.ReportData[:], h.Sum(nil)) {
+		return nil, nil, failure(ErrBadSignature, errors.New("attestation key is not bound to QE report"))
+	}
+
+	// 4. Verify the quote is signed by the attestation key
+	attKey, err := rawP256PublicKey(sig.AttestationKey[:])
+	if err != nil {
+		return nil, nil, err
+	}
+	if !verifyRawECDSA(attKey, signed, sig.Signature[:]) {
+		return nil, nil, failure(ErrBadSignature, errors.New("DCAP quote signature is invalid"))
+	}
+
+	var pck *PCKExtensions
+	if v.Collateral != nil {
+		if pck, err = ParsePCKExtensions(pckCert); err != nil {
+			return nil, nil, err
+		}
+		res.FMSPC = pck.FMSPC
+		if col == nil {
+			col, err = v.Collateral.GetCollateral(context.Background(), pck.FMSPC, pck.CA)
+			if err != nil {
+				return nil, nil, fmt.Errorf("fetch collateral: %v", err)
+			}
+		}
+	}
+
+	// 5. Check the PCK chain against the Intel CRLs
+	if err := v.checkRevocation(path, col, now); err != nil {
+		return nil, nil, err
+	}
+	if col == nil {
+		return nil, nil, nil
+	}
+
+	// 6. Verify the QE report matches the signed QE identity
+	if err := v.checkQEIdentity(col, &sig.QEReport, now, res); err != nil {
+		return nil, nil, err
+	}
+	return pck, col, nil
+}
+
+// evaluateTCB finds the TCB level of the platform and, for TDX quotes
+// (teeTCBSVN not nil), of its TDX module.
+func (v *Verifier) evaluateTCB(pck *PCKExtensions, col *Collateral, teeTCBSVN []byte, now time.Time, res *VerificationResult) error {
+	info, err := VerifyTCBInfo(col.TCBInfo, col.TCBInfoIssuerChain, v.sgxRoots(), now)
+	if err != nil {
+		return err
+	}
+	if err := v.checkSignerRevocation(col.TCBInfoIssuerChain, col, now); err != nil {
+		return fmt.Errorf("TCB info: %w", err)
+	}
+	var level *TCBLevel
+	if teeTCBSVN != nil {
+		level, err = info.EvaluateTDX(pck, teeTCBSVN)
+	} else {
+		level, err = info.Evaluate(pck)
+	}
+	if err != nil {
+		return err
+	}
+	res.TCBStatus = level.TCBStatus
+	res.TCBDate = level.TCBDate
+	res.AdvisoryIDs = level.AdvisoryIDs
+	return v.checkTCBStatus(level.TCBStatus, level.AdvisoryIDs)
+}
+
+// checkTCBStatus checks a platform or QE TCB status and the advisories that
+// came with it against AdvisoryExceptions and TCBStatuses.
+func (v *Verifier) checkTCBStatus(status string, advisories []string) error {
+	if e := v.AdvisoryExceptions; e != nil && e.applies(status, advisories) {
+		if err := e.Check(status, advisories); err != nil {
+			return failure(ErrTCBOutOfDate, err)
+		}
+		return nil
+	}
+	if err := v.tcbStatusPolicy().Check(status); err != nil {
+		return failure(ErrTCBOutOfDate, err)
+	}
+	return nil
+}
+
+func (v *Verifier) tcbStatusPolicy() QuoteStatusPolicy {
+	if len(v.TCBStatuses.Allowed) == 0 {
+		return PermissiveTCBStatus
+	}
+	return v.TCBStatuses
+}
+
+func rawP256PublicKey(raw []byte) (*ecdsa.PublicKey, error) {
+	x := new(big.Int).SetBytes(raw[:32])
+	y := new(big.Int).SetBytes(raw[32:64])
+	if !elliptic.P256().IsOnCurve(x, y) {
+		return nil, errors.New("attestation key is not on P-256")
+	}
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+}
+
+func verifyRawECDSA(pub *ecdsa.PublicKey, data []byte, sig []byte) bool {
+	digest := sha256.Sum256(data)
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:64])
+	return ecdsa.Verify(pub, digest[:], r, s)
+}
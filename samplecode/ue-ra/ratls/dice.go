@@ -0,0 +1,261 @@
+package ratls
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+)
+
+// Gramine RA-TLS certificates carry a DCAP quote in one of two extensions.
+// Before Gramine 1.6 the OID was written with its own DER tag and length
+// included, so parsers read it as 0.6.9.42.840.113741.1337.6; report_data
+// starts with the SHA-256 of the certificate SubjectPublicKeyInfo. Newer
+// releases, like librats and rats-tls, use the TCG DICE tagged evidence
+// extension described at parseTaggedEvidence. Gramine EPID certificates,
+// whose quote the verifier has to submit to IAS itself, are not supported.
+var (
+	oidGramineQuote          = asn1.ObjectIdentifier{0, 6, 9, 42, 840, 113741, 1337, 6}
+	oidTCGDiceTaggedEvidence = asn1.ObjectIdentifier{2, 23, 133, 5, 4, 9}
+)
+
+// cborTagTEEQuote is the CBOR tag of tagged evidence holding an Intel SGX or
+// TDX quote.
+const cborTagTEEQuote = 0x1A75FA
+
+// hashAlgSHA256 is the named information hash algorithm ID (RFC 6920) of
+// SHA-256.
+const hashAlgSHA256 = 1
+
+// parseTaggedEvidence decodes a TCG DICE tagged evidence extension:
+//
+//	tag(0x1A75FA, [quote: bstr, claims])
+//
+// Gramine encodes the claims as a map, librats as a byte string holding the
+// encoded map; either holds a "pubkey-hash" claim, [1, bstr] or the bare
+// SHA-256 digest, which must be the hash of the SubjectPublicKeyInfo of
+// cert. It returns the quote, the SHA-256 of the encoded claims, which the
+// quote binds in report_data, and the layout found.
+func parseTaggedEvidence(value []byte, cert *x509.Certificate) ([]byte, []byte, CertFormat, error) {
+	var raw []byte
+	if _, err := asn1.Unmarshal(value, &raw); err != nil {
+		// Not wrapped in an OCTET STRING
+		raw = value
+	}
+
+	major, tag, rest, err := cborHead(raw)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if major != cborMajorTag || tag != cborTagTEEQuote {
+		return nil, nil, 0, errors.New("tagged evidence does not hold a TEE quote")
+	}
+	major, n, rest, err := cborHead(rest)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if major != cborMajorArray || n != 2 {
+		return nil, nil, 0, errors.New("malformed tagged evidence")
+	}
+	quote, rest, err := cborBytes(rest)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	claims, format := rest, FormatGramine
+	if len(rest) > 0 && int(rest[0]>>5) == cborMajorBytes {
+		if claims, rest, err = cborBytes(rest); err != nil {
+			return nil, nil, 0, err
+		}
+		format = FormatLibrats
+	}
+	pubKeyHash, after, err := cborPubKeyHash(claims)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if format == FormatGramine {
+		rest = after
+	} else if len(after) != 0 {
+		return nil, nil, 0, errors.New("trailing data after tagged evidence claims")
+	}
+	if len(rest) != 0 {
+		return nil, nil, 0, errors.New("trailing data after tagged evidence")
+	}
+
+	spkiHash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	if !equalBytes(pubKeyHash, spkiHash[:]) {
+		return nil, nil, 0, errors.New("pubkey-hash claim does not match the certificate key")
+	}
+	claimsHash := sha256.Sum256(claims)
+	return quote, claimsHash[:], format, nil
+}
+
+// cborPubKeyHash reads the claims map and returns its SHA-256 pubkey-hash
+// claim and the data following the map.
+func cborPubKeyHash(data []byte) ([]byte, []byte, error) {
+	major, n, rest, err := cborHead(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if major != cborMajorMap {
+		return nil, nil, errors.New("malformed tagged evidence claims")
+	}
+	var hash []byte
+	for i := uint64(0); i < n; i++ {
+		var key []byte
+		if major, _, _, err = cborHead(rest); err != nil {
+			return nil, nil, err
+		}
+		if major != cborMajorText {
+			return nil, nil, errors.New("malformed tagged evidence claims")
+		}
+		if key, rest, err = cborString(rest); err != nil {
+			return nil, nil, err
+		}
+		if string(key) != "pubkey-hash" {
+			if rest, err = cborSkip(rest); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		if hash, rest, err = cborHashClaim(rest); err != nil {
+			return nil, nil, err
+		}
+	}
+	if hash == nil {
+		return nil, nil, errors.New("tagged evidence has no pubkey-hash claim")
+	}
+	return hash, rest, nil
+}
+
+// cborHashClaim reads a pubkey-hash claim, either [1, bstr] or a bare
+// SHA-256 digest.
+func cborHashClaim(data []byte) ([]byte, []byte, error) {
+	if len(data) > 0 && int(data[0]>>5) == cborMajorBytes {
+		hash, rest, err := cborBytes(data)
+		if err == nil && len(hash) != sha256.Size {
+			err = errors.New("pubkey-hash claim is not SHA-256")
+		}
+		return hash, rest, err
+	}
+	major, count, rest, err := cborHead(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if major != cborMajorArray || count != 2 {
+		return nil, nil, errors.New("malformed pubkey-hash claim")
+	}
+	major, alg, rest, err := cborHead(rest)
+	if err != nil {
+		return nil, nil, err
+	}
+	if major != cborMajorUint || alg != hashAlgSHA256 {
+		return nil, nil, errors.New("pubkey-hash claim is not SHA-256")
+	}
+	return cborBytes(rest)
+}
+
+// CBOR major types (RFC 8949).
+const (
+	cborMajorUint  = 0
+	cborMajorBytes = 2
+	cborMajorText  = 3
+	cborMajorArray = 4
+	cborMajorMap   = 5
+	cborMajorTag   = 6
+)
+
+// cborHead decodes the head of a data item into its major type and
+// argument. Indefinite lengths are not supported.
+func cborHead(data []byte) (major int, arg uint64, rest []byte, err error) {
+	if len(data) == 0 {
+		return 0, 0, nil, errors.New("truncated CBOR data")
+	}
+	major, info := int(data[0]>>5), data[0]&0x1f
+	data = data[1:]
+	var size int
+	switch {
+	case info < 24:
+		return major, uint64(info), data, nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, 0, nil, errors.New("unsupported CBOR encoding")
+	}
+	if len(data) < size {
+		return 0, 0, nil, errors.New("truncated CBOR data")
+	}
+	var buf [8]byte
+	copy(buf[8-size:], data[:size])
+	return major, binary.BigEndian.Uint64(buf[:]), data[size:], nil
+}
+
+// cborString reads a byte or text string.
+func cborString(data []byte) ([]byte, []byte, error) {
+	major, n, rest, err := cborHead(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if major != cborMajorBytes && major != cborMajorText {
+		return nil, nil, errors.New("CBOR item is not a string")
+	}
+	if uint64(len(rest)) < n {
+		return nil, nil, errors.New("truncated CBOR data")
+	}
+	return rest[:n], rest[n:], nil
+}
+
+// cborBytes reads a byte string.
+func cborBytes(data []byte) ([]byte, []byte, error) {
+	if len(data) == 0 || int(data[0]>>5) != cborMajorBytes {
+		return nil, nil, errors.New("CBOR item is not a byte string")
+	}
+	return cborString(data)
+}
+
+// cborMaxDepth bounds the nesting of skipped items, so hostile evidence
+// cannot exhaust the stack.
+const cborMaxDepth = 16
+
+// cborSkip returns the data following the first item.
+func cborSkip(data []byte) ([]byte, error) {
+	return cborSkipDepth(data, 0)
+}
+
+func cborSkipDepth(data []byte, depth int) ([]byte, error) {
+	if depth > cborMaxDepth {
+		return nil, errors.New("CBOR data is nested too deeply")
+	}
+	major, n, rest, err := cborHead(data)
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case cborMajorBytes, cborMajorText:
+		_, rest, err = cborString(data)
+		return rest, err
+	case cborMajorArray, cborMajorMap:
+		items := n
+		if major == cborMajorMap {
+			items *= 2
+		}
+		for i := uint64(0); i < items; i++ {
+			if rest, err = cborSkipDepth(rest, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return rest, nil
+	case cborMajorTag:
+		return cborSkipDepth(rest, depth+1)
+	}
+	// Integers and simple values have no content
+	return rest, nil
+}
@@ -0,0 +1,268 @@
+package ratls
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"testing"
+)
+
+// cborHeadOf encodes the head of a CBOR data item in its shortest form.
+func cborHeadOf(major int, arg uint64) []byte {
+	m := byte(major << 5)
+	switch {
+	case arg < 24:
+		return []byte{m | byte(arg)}
+	case arg <= 0xff:
+		return []byte{m | 24, byte(arg)}
+	case arg <= 0xffff:
+		return []byte{m | 25, byte(arg >> 8), byte(arg)}
+	case arg <= 0xffffffff:
+		return []byte{m | 26, byte(arg >> 24), byte(arg >> 16), byte(arg >> 8), byte(arg)}
+	}
+	return []byte{m | 27, byte(arg >> 56), byte(arg >> 48), byte(arg >> 40), byte(arg >> 32), byte(arg >> 24), byte(arg >> 16), byte(arg >> 8), byte(arg)}
+}
+
+// cborJoin concatenates encoded CBOR items.
+func cborJoin(items ...[]byte) []byte {
+	return bytes.Join(items, nil)
+}
+
+func cborBstr(b []byte) []byte { return cborJoin(cborHeadOf(cborMajorBytes, uint64(len(b))), b) }
+
+func cborTstr(s string) []byte { return cborJoin(cborHeadOf(cborMajorText, uint64(len(s))), []byte(s)) }
+
+// testClaims returns a claims map of the given pubkey-hash claim and other
+// claims, each a key followed by its value.
+func testClaims(hashClaim []byte, other ...[]byte) []byte {
+	n := uint64(len(other) / 2)
+	if hashClaim != nil {
+		n++
+	}
+	claims := cborJoin(cborHeadOf(cborMajorMap, n), cborJoin(other...))
+	if hashClaim != nil {
+		claims = cborJoin(claims, cborTstr("pubkey-hash"), hashClaim)
+	}
+	return claims
+}
+
+// testTaggedEvidence returns the tagged evidence of quote and claims.
+func testTaggedEvidence(quote, claims []byte) []byte {
+	return cborJoin(cborHeadOf(cborMajorTag, cborTagTEEQuote), cborHeadOf(cborMajorArray, 2), cborBstr(quote), claims)
+}
+
+func TestParseTaggedEvidence(t *testing.T) {
+	cert, err := x509.ParseCertificate(newTestRATLSCert(t, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spkiHash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	quote := []byte("quote")
+	hashClaim := cborJoin(cborHeadOf(cborMajorArray, 2), cborHeadOf(cborMajorUint, hashAlgSHA256), cborBstr(spkiHash[:]))
+	claims := testClaims(hashClaim)
+	wrapped, err := asn1.Marshal(testTaggedEvidence(quote, claims))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := make([]byte, sha256.Size)
+
+	tests := []struct {
+		name       string
+		value      []byte
+		wantClaims []byte
+		wantFormat CertFormat
+		wantErr    bool
+	}{
+		{name: "algorithm and digest", value: testTaggedEvidence(quote, claims), wantClaims: claims, wantFormat: FormatGramine},
+		{name: "OCTET STRING", value: wrapped, wantClaims: claims, wantFormat: FormatGramine},
+		{
+			name:       "bare digest",
+			value:      testTaggedEvidence(quote, testClaims(cborBstr(spkiHash[:]))),
+			wantClaims: testClaims(cborBstr(spkiHash[:])),
+			wantFormat: FormatGramine,
+		},
+		{
+			name:       "other claims",
+			value:      testTaggedEvidence(quote, testClaims(hashClaim, cborTstr("nonce"), cborBstr([]byte{1, 2}), cborTstr("tcb"), cborJoin(cborHeadOf(cborMajorMap, 1), cborTstr("svn"), cborHeadOf(cborMajorUint, 300)))),
+			wantClaims: testClaims(hashClaim, cborTstr("nonce"), cborBstr([]byte{1, 2}), cborTstr("tcb"), cborJoin(cborHeadOf(cborMajorMap, 1), cborTstr("svn"), cborHeadOf(cborMajorUint, 300))),
+			wantFormat: FormatGramine,
+		},
+		{name: "librats", value: testTaggedEvidence(quote, cborBstr(claims)), wantClaims: claims, wantFormat: FormatLibrats},
+		{name: "librats trailing claims data", value: testTaggedEvidence(quote, cborBstr(cborJoin(claims, []byte{0}))), wantErr: true},
+		{name: "librats trailing data", value: cborJoin(testTaggedEvidence(quote, cborBstr(claims)), []byte{0}), wantErr: true},
+		{name: "librats other key", value: testTaggedEvidence(quote, cborBstr(testClaims(cborBstr(other)))), wantErr: true},
+		{name: "librats claims truncated", value: testTaggedEvidence(quote, cborBstr(claims)[:len(claims)]), wantErr: true},
+		{name: "other key", value: testTaggedEvidence(quote, testClaims(cborBstr(other))), wantErr: true},
+		{name: "no pubkey-hash", value: testTaggedEvidence(quote, testClaims(nil, cborTstr("nonce"), cborBstr(nil))), wantErr: true},
+		{name: "SHA-384", value: testTaggedEvidence(quote, testClaims(cborJoin(cborHeadOf(cborMajorArray, 2), cborHeadOf(cborMajorUint, 7), cborBstr(spkiHash[:])))), wantErr: true},
+		{name: "short digest", value: testTaggedEvidence(quote, testClaims(cborBstr(spkiHash[:20]))), wantErr: true},
+		{name: "claim key not text", value: testTaggedEvidence(quote, cborJoin(cborHeadOf(cborMajorMap, 1), cborHeadOf(cborMajorUint, 1), hashClaim)), wantErr: true},
+		{name: "claims not a map", value: testTaggedEvidence(quote, cborJoin(cborHeadOf(cborMajorArray, 1), hashClaim)), wantErr: true},
+		{name: "other tag", value: cborJoin(cborHeadOf(cborMajorTag, 1), cborHeadOf(cborMajorArray, 2), cborBstr(quote), claims), wantErr: true},
+		{name: "not an array of two", value: cborJoin(cborHeadOf(cborMajorTag, cborTagTEEQuote), cborHeadOf(cborMajorArray, 3), cborBstr(quote), claims), wantErr: true},
+		{name: "quote not bytes", value: cborJoin(cborHeadOf(cborMajorTag, cborTagTEEQuote), cborHeadOf(cborMajorArray, 2), cborTstr("quote"), claims), wantErr: true},
+		{name: "trailing data", value: cborJoin(testTaggedEvidence(quote, claims), []byte{0}), wantErr: true},
+		{name: "truncated", value: testTaggedEvidence(quote, claims[:len(claims)-1]), wantErr: true},
+		{name: "quote length overflows", value: cborJoin(cborHeadOf(cborMajorTag, cborTagTEEQuote), cborHeadOf(cborMajorArray, 2), cborHeadOf(cborMajorBytes, 1<<63)), wantErr: true},
+		{name: "indefinite length", value: cborJoin(cborHeadOf(cborMajorTag, cborTagTEEQuote), []byte{0x9f}), wantErr: true},
+		{name: "empty", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotQuote, gotHash, format, err := parseTaggedEvidence(tt.value, cert)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTaggedEvidence() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			wantHash := sha256.Sum256(tt.wantClaims)
+			if !bytes.Equal(gotQuote, quote) || !bytes.Equal(gotHash, wantHash[:]) || format != tt.wantFormat {
+				t.Errorf("parseTaggedEvidence() = %q, %x, %v, want %q, %x, %v", gotQuote, gotHash, format, quote, wantHash, tt.wantFormat)
+			}
+		})
+	}
+}
+
+func TestCBORSkip(t *testing.T) {
+	nested := func(depth int) []byte {
+		var b []byte
+		for i := 0; i < depth; i++ {
+			b = append(b, cborHeadOf(cborMajorArray, 1)...)
+		}
+		return append(b, 0)
+	}
+	tests := []struct {
+		name     string
+		data     []byte
+		wantRest int
+		wantErr  bool
+	}{
+		{name: "integer", data: cborJoin(cborHeadOf(cborMajorUint, 1000), []byte{1, 2}), wantRest: 2},
+		{name: "text", data: cborJoin(cborTstr("claim"), []byte{1}), wantRest: 1},
+		{name: "map", data: cborJoin(cborHeadOf(cborMajorMap, 1), cborTstr("a"), cborBstr([]byte{1})), wantRest: 0},
+		{name: "tagged", data: cborJoin(cborHeadOf(cborMajorTag, 24), cborBstr([]byte{1}), []byte{1}), wantRest: 1},
+		{name: "nested", data: nested(cborMaxDepth)},
+		{name: "nested too deeply", data: nested(cborMaxDepth + 2), wantErr: true},
+		{name: "short map", data: cborJoin(cborHeadOf(cborMajorMap, 2), cborTstr("a"), cborBstr(nil)), wantErr: true},
+		{name: "huge array", data: cborHeadOf(cborMajorArray, 1<<62), wantErr: true},
+		{name: "truncated head", data: []byte{0x1a, 0}, wantErr: true},
+		{name: "reserved encoding", data: []byte{0x1c}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rest, err := cborSkip(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("cborSkip() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && len(rest) != tt.wantRest {
+				t.Errorf("cborSkip() left %d bytes, want %d", len(rest), tt.wantRest)
+			}
+		})
+	}
+}
+
+func TestUnmarshalCertGramine(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spki, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spkiHash := sha256.Sum256(spki)
+	quote := []byte("quote")
+	claims := testClaims(cborBstr(spkiHash[:]))
+	claimsHash := sha256.Sum256(claims)
+	point, err := key.PublicKey.ECDH()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		ext             pkix.Extension
+		wantPubKey      []byte
+		wantClaimsBound bool
+		wantErr         bool
+	}{
+		{name: "legacy quote", ext: pkix.Extension{Id: oidGramineQuote, Value: quote}, wantPubKey: point.Bytes()[1:]},
+		{
+			name:            "tagged evidence",
+			ext:             pkix.Extension{Id: oidTCGDiceTaggedEvidence, Value: testTaggedEvidence(quote, claims)},
+			wantPubKey:      claimsHash[:],
+			wantClaimsBound: true,
+		},
+		{
+			name:    "tagged evidence of another key",
+			ext:     pkix.Extension{Id: oidTCGDiceTaggedEvidence, Value: testTaggedEvidence(quote, testClaims(cborBstr(make([]byte, sha256.Size))))},
+			wantErr: true,
+		},
+		{name: "malformed tagged evidence", ext: pkix.Extension{Id: oidTCGDiceTaggedEvidence, Value: []byte{0xff}}, wantErr: true},
+		{name: "other extension", ext: pkix.Extension{Id: asn1.ObjectIdentifier{1, 2, 3}, Value: quote}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev, err := unmarshalCert(newTestRATLSCert(t, key, tt.ext), FormatAll)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unmarshalCert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !bytes.Equal(ev.quote, quote) || !bytes.Equal(ev.pubKey, tt.wantPubKey) || ev.claimsBound != tt.wantClaimsBound {
+				t.Errorf("unmarshalCert() quote, pubKey, claimsBound = %q, %x, %v", ev.quote, ev.pubKey, ev.claimsBound)
+			}
+		})
+	}
+}
+
+func TestUnmarshalCertFormats(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spki, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spkiHash := sha256.Sum256(spki)
+	claims := testClaims(cborBstr(spkiHash[:]))
+	gramine := pkix.Extension{Id: oidTCGDiceTaggedEvidence, Value: testTaggedEvidence([]byte("quote"), claims)}
+	librats := pkix.Extension{Id: oidTCGDiceTaggedEvidence, Value: testTaggedEvidence([]byte("quote"), cborBstr(claims))}
+	legacy := pkix.Extension{Id: oidGramineQuote, Value: []byte("quote")}
+	intel := pkix.Extension{Id: oidSGXQuote, Value: []byte("quote")}
+	comment := pkix.Extension{Id: oidNetscapeComment, Value: []byte("report|sig|cert")}
+
+	tests := []struct {
+		name    string
+		ext     pkix.Extension
+		formats CertFormat
+		wantErr bool
+	}{
+		{name: "librats", ext: librats, formats: FormatAll},
+		{name: "librats only", ext: librats, formats: FormatLibrats},
+		{name: "librats not accepted", ext: librats, formats: FormatGramine, wantErr: true},
+		{name: "Gramine tagged evidence", ext: gramine, formats: FormatGramine},
+		{name: "Gramine tagged evidence not accepted", ext: gramine, formats: FormatLibrats, wantErr: true},
+		{name: "Gramine legacy quote", ext: legacy, formats: FormatGramine},
+		{name: "Gramine legacy quote not accepted", ext: legacy, formats: FormatIntel | FormatLibrats, wantErr: true},
+		{name: "Intel quote", ext: intel, formats: FormatIntel},
+		{name: "Intel quote not accepted", ext: intel, formats: FormatGramine, wantErr: true},
+		{name: "Teaclave comment", ext: comment, formats: FormatTeaclave},
+		{name: "Teaclave comment not accepted", ext: comment, formats: FormatAll &^ FormatTeaclave, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := unmarshalCert(newTestRATLSCert(t, key, tt.ext), tt.formats); (err != nil) != tt.wantErr {
+				t.Errorf("unmarshalCert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
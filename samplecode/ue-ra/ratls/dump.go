@@ -0,0 +1,240 @@
+package ratls
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// EPIDQuote is a parsed EPID quote, layout of sgx_quote_t. IAS reports
+// carry it without the signature as isvEnclaveQuoteBody.
+type EPIDQuote struct {
+	Header     EPIDQuoteHeader
+	ReportBody ReportBody
+	Signature  []byte
+}
+
+// ParseEPIDQuote decodes an EPID quote or an isvEnclaveQuoteBody.
+func ParseEPIDQuote(raw []byte) (*EPIDQuote, error) {
+	q := &EPIDQuote{}
+	r := bytes.NewReader(raw)
+	if err := binary.Read(r, binary.LittleEndian, &q.Header); err != nil {
+		return nil, errors.New("EPID quote is truncated")
+	}
+	if q.Header.SignType != EPIDUnlinkable && q.Header.SignType != EPIDLinkable {
+		return nil, fmt.Errorf("unknown EPID signature type %d", q.Header.SignType)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &q.ReportBody); err != nil {
+		return nil, errors.New("EPID quote is truncated")
+	}
+	var sigLen uint32
+	if binary.Read(r, binary.LittleEndian, &sigLen) == nil {
+		if uint64(r.Len()) < uint64(sigLen) {
+			return nil, errors.New("EPID quote signature is truncated")
+		}
+		q.Signature = make([]byte, sigLen)
+		r.Read(q.Signature)
+	}
+	return q, nil
+}
+
+// QuoteDump is a decoded quote for display, with every field in order and
+// byte arrays hex encoded. String gives an indented text form and
+// json.Marshal an object keeping the field order.
+type QuoteDump struct {
+	// Kind names the quote format, e.g. "EPID quote".
+	Kind   string
+	Fields []DumpField
+}
+
+// DumpField is a field of a QuoteDump. Value is a string, an unsigned
+// integer, a []DumpField for nested structures or a []any for arrays.
+type DumpField struct {
+	Name  string
+	Value any
+}
+
+// DumpQuote decodes a quote of any supported version for display. data is
+// the raw quote, its base64 encoding (such as an isvEnclaveQuoteBody) or an
+// IAS report JSON, whose quote body is taken.
+func DumpQuote(data []byte) (*QuoteDump, error) {
+	raw := data
+	trimmed := bytes.TrimSpace(data)
+	var report QuoteReport
+	if bytes.HasPrefix(trimmed, []byte("{")) && json.Unmarshal(trimmed, &report) == nil && report.IsvEnclaveQuoteBody != "" {
+		trimmed = []byte(report.IsvEnclaveQuoteBody)
+	}
+	if dec, err := base64.StdEncoding.DecodeString(string(trimmed)); err == nil && len(dec) >= quoteHeaderLen {
+		raw = dec
+	}
+	if len(raw) < 2 {
+		return nil, errors.New("quote is too short")
+	}
+
+	var quote any
+	var kind string
+	var err error
+	switch version := binary.LittleEndian.Uint16(raw); version {
+	case 1, 2:
+		kind = "EPID quote"
+		quote, err = ParseEPIDQuote(raw)
+	case quoteVersionV3:
+		kind = "ECDSA quote v3"
+		quote, err = ParseQuoteV3(raw)
+	case quoteVersionV4:
+		var q *QuoteV4
+		q, err = ParseQuoteV4(raw)
+		kind, quote = "ECDSA quote v4 (SGX)", q
+		if err == nil && q.TDReport != nil {
+			kind = "ECDSA quote v4 (TDX)"
+		}
+	case quoteVersionV5:
+		var q *QuoteV5
+		q, err = ParseQuoteV5(raw)
+		kind, quote = "ECDSA quote v5 (SGX)", q
+		if err == nil && q.TDReport != nil {
+			kind = "ECDSA quote v5 (TDX)"
+		}
+	default:
+		return nil, fmt.Errorf("unsupported quote version %d", version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &QuoteDump{Kind: kind, Fields: dumpStruct(reflect.ValueOf(quote).Elem())}, nil
+}
+
+// dumpStruct lists the exported fields of a struct, skipping reserved
+// ones, nil pointers and empty slices.
+func dumpStruct(v reflect.Value) []DumpField {
+	var fields []DumpField
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() || strings.HasPrefix(f.Name, "Reserved") {
+			continue
+		}
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Slice && fv.Len() == 0 {
+			continue
+		}
+		fields = append(fields, DumpField{Name: f.Name, Value: dumpValue(fv)})
+	}
+	return fields
+}
+
+func dumpValue(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.Struct:
+		return dumpStruct(v)
+	case reflect.Array, reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return hex.EncodeToString(b)
+		}
+		items := make([]any, v.Len())
+		for i := range items {
+			items[i] = dumpValue(v.Index(i))
+		}
+		return items
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// String formats the dump as indented "name: value" lines.
+func (d *QuoteDump) String() string {
+	var b strings.Builder
+	b.WriteString(d.Kind + "\n")
+	writeDumpFields(&b, d.Fields, "  ")
+	return b.String()
+}
+
+func writeDumpFields(b *strings.Builder, fields []DumpField, indent string) {
+	for _, f := range fields {
+		writeDumpValue(b, f.Name, f.Value, indent)
+	}
+}
+
+func writeDumpValue(b *strings.Builder, name string, value any, indent string) {
+	switch v := value.(type) {
+	case []DumpField:
+		fmt.Fprintf(b, "%s%s:\n", indent, name)
+		writeDumpFields(b, v, indent+"  ")
+	case []any:
+		fmt.Fprintf(b, "%s%s:\n", indent, name)
+		for i, item := range v {
+			writeDumpValue(b, fmt.Sprintf("[%d]", i), item, indent+"  ")
+		}
+	case uint64:
+		fmt.Fprintf(b, "%s%s: %d (%#x)\n", indent, name, v, v)
+	default:
+		fmt.Fprintf(b, "%s%s: %v\n", indent, name, v)
+	}
+}
+
+// MarshalJSON encodes the dump as {"kind": ..., "fields": {...}}, keeping
+// the field order of the quote layout.
+func (d *QuoteDump) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	kind, _ := json.Marshal(d.Kind)
+	b.WriteString(`{"kind":`)
+	b.Write(kind)
+	b.WriteString(`,"fields":`)
+	if err := marshalDumpValue(&b, d.Fields); err != nil {
+		return nil, err
+	}
+	b.WriteString("}")
+	return b.Bytes(), nil
+}
+
+func marshalDumpValue(b *bytes.Buffer, value any) error {
+	switch v := value.(type) {
+	case []DumpField:
+		b.WriteString("{")
+		for i, f := range v {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			name, _ := json.Marshal(f.Name)
+			b.Write(name)
+			b.WriteString(":")
+			if err := marshalDumpValue(b, f.Value); err != nil {
+				return err
+			}
+		}
+		b.WriteString("}")
+	case []any:
+		b.WriteString("[")
+		for i, item := range v {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			if err := marshalDumpValue(b, item); err != nil {
+				return err
+			}
+		}
+		b.WriteString("]")
+	default:
+		enc, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		b.Write(enc)
+	}
+	return nil
+}
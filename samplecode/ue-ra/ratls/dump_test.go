@@ -0,0 +1,97 @@
+package ratls
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseEPIDQuote(t *testing.T) {
+	body := testEPIDQuote(func(q *EPIDQuote) { q.ReportBody.ISVSVN = 7 })
+	signed := testEPIDQuote(func(q *EPIDQuote) { q.Signature = []byte("signature") })
+	tests := []struct {
+		name    string
+		raw     []byte
+		wantSig []byte
+		wantErr bool
+	}{
+		{name: "quote body", raw: body},
+		{name: "signed quote", raw: signed, wantSig: []byte("signature")},
+		{name: "linkable", raw: testEPIDQuote(func(q *EPIDQuote) { q.Header.SignType = EPIDLinkable })},
+		{name: "signature truncated", raw: signed[:len(signed)-1], wantErr: true},
+		{name: "signature length overflows", raw: append(bytes.Clone(body), 0xff, 0xff, 0xff, 0xff), wantErr: true},
+		{name: "unknown signature type", raw: testEPIDQuote(func(q *EPIDQuote) { q.Header.SignType = 2 }), wantErr: true},
+		{name: "body truncated", raw: body[:len(body)-1], wantErr: true},
+		{name: "header truncated", raw: body[:10], wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := ParseEPIDQuote(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseEPIDQuote() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !bytes.Equal(q.Signature, tt.wantSig) {
+				t.Errorf("ParseEPIDQuote() signature = %q, want %q", q.Signature, tt.wantSig)
+			}
+		})
+	}
+}
+
+func TestDumpQuote(t *testing.T) {
+	epid := testEPIDQuote(func(q *EPIDQuote) { q.ReportBody.ISVSVN = 7 })
+	report, err := json.Marshal(QuoteReport{ID: "1", IsvEnclaveQuoteBody: base64.StdEncoding.EncodeToString(epid)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tdBody := make([]byte, tdReportLen)
+	tdx := testQuoteV4(quoteVersionV4, TEETypeTDX, tdBody, testSignatureDataV4())
+
+	tests := []struct {
+		name      string
+		data      []byte
+		wantKind  string
+		wantField string
+		wantErr   bool
+	}{
+		{name: "raw EPID quote", data: epid, wantKind: "EPID quote", wantField: "ISVSVN: 7 (0x7)"},
+		{name: "base64", data: []byte(base64.StdEncoding.EncodeToString(epid) + "\n"), wantKind: "EPID quote", wantField: "ISVSVN: 7 (0x7)"},
+		{name: "IAS report", data: report, wantKind: "EPID quote", wantField: "ISVSVN: 7 (0x7)"},
+		{name: "quote v3", data: newTestQuoteV3().raw(), wantKind: "ECDSA quote v3", wantField: "QEAuthData: " + hex.EncodeToString([]byte("auth"))},
+		{name: "TDX quote v4", data: tdx, wantKind: "ECDSA quote v4 (TDX)", wantField: "MrTD: " + strings.Repeat("00", 48)},
+		{name: "unsupported version", data: []byte{9, 0, 0, 0}, wantErr: true},
+		{name: "truncated", data: epid[:100], wantErr: true},
+		{name: "too short", data: []byte{2}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := DumpQuote(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DumpQuote() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			text := d.String()
+			if d.Kind != tt.wantKind || !strings.Contains(text, tt.wantField) {
+				t.Errorf("DumpQuote() = %s, want %s with %q", text, tt.wantKind, tt.wantField)
+			}
+			if strings.Contains(text, "Reserved") {
+				t.Errorf("DumpQuote() lists reserved fields:\n%s", text)
+			}
+			raw, err := json.Marshal(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var doc struct {
+				Kind   string         `json:"kind"`
+				Fields map[string]any `json:"fields"`
+			}
+			if err := json.Unmarshal(raw, &doc); err != nil || doc.Kind != tt.wantKind || len(doc.Fields) != len(d.Fields) {
+				t.Errorf("json.Marshal(DumpQuote()) = %s, %v", raw, err)
+			}
+		})
+	}
+}
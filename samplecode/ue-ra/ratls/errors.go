@@ -0,0 +1,88 @@
+package ratls
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Causes of verification failures. Errors returned by a Verifier match at
+// most one of them with errors.Is, so callers can branch on the cause and
+// label metrics, see Reason. The messages of the errors themselves are
+// unchanged; typed errors such as *QuoteStatusError are still available
+// with errors.As.
+var (
+	// ErrQuoteStatus: the IAS quote status is not accepted.
+	ErrQuoteStatus = errors.New("quote status not accepted")
+	// ErrTCBOutOfDate: the DCAP, TDX or token TCB status of the platform
+	// or quoting enclave is not accepted.
+	ErrTCBOutOfDate = errors.New("TCB status not accepted")
+	// ErrMeasurementMismatch: the enclave identity (MRENCLAVE, MRSIGNER,
+	// ISV product ID or SVN) is not accepted.
+	ErrMeasurementMismatch = errors.New("enclave measurement not accepted")
+	// ErrStaleReport: the evidence or its collateral is too old or
+	// expired, or does not answer the challenge of the connection.
+	ErrStaleReport = errors.New("attestation evidence is stale")
+	// ErrBadSignature: a signature over the evidence or its collateral
+	// does not verify, or the signer does not chain to a trusted root.
+	ErrBadSignature = errors.New("signature verification failed")
+	// ErrKeyNotBound: report_data does not bind the certificate key.
+	ErrKeyNotBound = errors.New("certificate key not bound")
+	// ErrDebugEnclave: the enclave runs in debug mode.
+	ErrDebugEnclave = errors.New("debug enclave not accepted")
+	// ErrPolicyDenied: Verifier.Policy rejected the enclave, or its EPID
+	// signature type is not the one required.
+	ErrPolicyDenied = errors.New("denied by policy")
+	// ErrRevoked: a certificate of the PCK chain is listed in a CRL, or
+	// one of the IAS report signing chain is revoked by OCSP or CRL.
+	ErrRevoked = errors.New("certificate revoked")
+)
+
+// verifyError attributes err to one of the failure causes.
+type verifyError struct {
+	cause error
+	err   error
+}
+
+func (e *verifyError) Error() string   { return e.err.Error() }
+func (e *verifyError) Unwrap() []error { return []error{e.cause, e.err} }
+
+// failure attributes err to cause.
+func failure(cause, err error) error {
+	return &verifyError{cause: cause, err: err}
+}
+
+// failuref is failure with an error formatted as by fmt.Errorf.
+func failuref(cause error, format string, args ...any) error {
+	return &verifyError{cause: cause, err: fmt.Errorf(format, args...)}
+}
+
+// Reason returns a short label for the cause of a verification error, e.g.
+// for metrics: "quote_status", "tcb_status", "measurement", "stale",
+// "signature", "key_binding", "debug", "policy", "revoked", "other" for
+// failures of another cause such as malformed evidence, or "" for a nil
+// error.
+func Reason(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrQuoteStatus):
+		return "quote_status"
+	case errors.Is(err, ErrTCBOutOfDate):
+		return "tcb_status"
+	case errors.Is(err, ErrMeasurementMismatch):
+		return "measurement"
+	case errors.Is(err, ErrStaleReport):
+		return "stale"
+	case errors.Is(err, ErrBadSignature):
+		return "signature"
+	case errors.Is(err, ErrKeyNotBound):
+		return "key_binding"
+	case errors.Is(err, ErrDebugEnclave):
+		return "debug"
+	case errors.Is(err, ErrPolicyDenied):
+		return "policy"
+	case errors.Is(err, ErrRevoked):
+		return "revoked"
+	}
+	return "other"
+}
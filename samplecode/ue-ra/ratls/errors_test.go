@@ -0,0 +1,46 @@
+package ratls
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestReason(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{err: nil, want: ""},
+		{err: errors.New("malformed evidence"), want: "other"},
+		{err: failure(ErrQuoteStatus, errors.New("GROUP_REVOKED")), want: "quote_status"},
+		{err: failure(ErrTCBOutOfDate, errors.New("OutOfDate")), want: "tcb_status"},
+		{err: failuref(ErrMeasurementMismatch, "ISV SVN %d", 1), want: "measurement"},
+		{err: failure(ErrStaleReport, errors.New("expired")), want: "stale"},
+		{err: failure(ErrBadSignature, errors.New("bad signature")), want: "signature"},
+		{err: failure(ErrKeyNotBound, errors.New("not bound")), want: "key_binding"},
+		{err: failure(ErrDebugEnclave, errors.New("debug")), want: "debug"},
+		{err: failure(ErrPolicyDenied, errors.New("denied")), want: "policy"},
+		{err: failure(ErrRevoked, errors.New("revoked")), want: "revoked"},
+		{err: fmt.Errorf("verify server: %w", failure(ErrRevoked, errors.New("revoked"))), want: "revoked"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := Reason(tt.err); got != tt.want {
+				t.Errorf("Reason(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFailureMessage(t *testing.T) {
+	err := failuref(ErrStaleReport, "report is %s old", "2h")
+	if err.Error() != "report is 2h old" {
+		t.Errorf("Error() = %q, the cause must not change the message", err.Error())
+	}
+	var target *QuoteStatusError
+	wrapped := failure(ErrQuoteStatus, &QuoteStatusError{Status: "GROUP_REVOKED"})
+	if !errors.As(wrapped, &target) || target.Status != "GROUP_REVOKED" {
+		t.Errorf("errors.As() does not find the wrapped *QuoteStatusError in %v", wrapped)
+	}
+}
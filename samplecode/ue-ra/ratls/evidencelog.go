@@ -0,0 +1,147 @@
+package ratls
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// EvidenceRecord is the complete evidence of an accepted verification,
+// kept so that attested access can be proven to auditors later. Together
+// with the roots of trust it allows the verification to be repeated.
+type EvidenceRecord struct {
+	Time          time.Time `json:"time"`
+	Peer          string    `json:"peer,omitempty"`
+	Mode          string    `json:"mode"`
+	MrEnclave     string    `json:"mr_enclave"`
+	MrSigner      string    `json:"mr_signer,omitempty"`
+	PolicyVersion string    `json:"policy_version,omitempty"`
+
+	// Certificates is the DER certificate chain of the peer, leaf first.
+	Certificates [][]byte `json:"certificates,omitempty"`
+	// IAS report bundle, set in ModeEPID.
+	Report            []byte `json:"ias_report,omitempty"`
+	ReportSignature   []byte `json:"ias_report_signature,omitempty"`
+	ReportSigningCert []byte `json:"ias_report_signing_cert,omitempty"`
+	// Quote is the raw quote, set in ModeDCAP, ModeTDX and ModeSim.
+	Quote []byte `json:"quote,omitempty"`
+	// Token is the attestation token, set in ModeMAA and ModeITA.
+	Token string `json:"token,omitempty"`
+
+	// Identifiers of the report and collateral the evidence was
+	// appraised with.
+	ReportID    string   `json:"ias_report_id,omitempty"`
+	QuoteStatus string   `json:"quote_status,omitempty"`
+	FMSPC       string   `json:"fmspc,omitempty"`
+	TCBStatus   string   `json:"tcb_status,omitempty"`
+	TCBDate     string   `json:"tcb_date,omitempty"`
+	QEStatus    string   `json:"qe_status,omitempty"`
+	TokenIssuer string   `json:"token_issuer,omitempty"`
+	AdvisoryIDs []string `json:"advisory_ids,omitempty"`
+	// TestRoots marks evidence verified against insecure test roots.
+	TestRoots bool `json:"insecure_test_roots,omitempty"`
+}
+
+// EvidenceSink persists an EvidenceRecord for every accepted verification.
+// It must be safe for concurrent use. Unlike auditing, archiving is part
+// of the decision: evidence that cannot be archived is rejected.
+type EvidenceSink interface {
+	ArchiveEvidence(rec *EvidenceRecord) error
+}
+
+// EvidenceDir archives every record to a new read-only JSON file in Dir,
+// which must exist. Existing files are never written to, so the directory
+// can be write-once storage.
+type EvidenceDir struct {
+	Dir string
+}
+
+// ArchiveEvidence writes rec to a file named after its time and synced to
+// disk before it returns.
+func (d EvidenceDir) ArchiveEvidence(rec *EvidenceRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.json", rec.Time.UTC().Format("20060102T150405.000000000Z"), hex.EncodeToString(id[:]))
+	f, err := os.OpenFile(filepath.Join(d.Dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o444)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// JSONEvidenceSink writes records as JSON lines, e.g. to syslog.
+type JSONEvidenceSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONEvidenceSink returns a sink writing to w.
+func NewJSONEvidenceSink(w io.Writer) *JSONEvidenceSink {
+	return &JSONEvidenceSink{enc: json.NewEncoder(w)}
+}
+
+// ArchiveEvidence writes rec as one line.
+func (s *JSONEvidenceSink) ArchiveEvidence(rec *EvidenceRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(rec)
+}
+
+// archive hands the evidence ev of the accepted result res, presented by
+// peer with the certificate chain rawCerts, to the Evidence sink.
+func (v *Verifier) archive(peer string, res *VerificationResult, ev *evidence, rawCerts [][]byte) error {
+	rec := &EvidenceRecord{
+		Time:              v.now(),
+		Peer:              peer,
+		Mode:              res.Mode.String(),
+		MrEnclave:         res.MrEnclave,
+		MrSigner:          res.MrSigner,
+		PolicyVersion:     v.PolicyVersion,
+		Certificates:      rawCerts,
+		Report:            ev.report,
+		ReportSignature:   ev.signature,
+		ReportSigningCert: ev.signingCert,
+		ReportID:          res.ReportID,
+		QuoteStatus:       res.QuoteStatus,
+		FMSPC:             res.FMSPC,
+		TCBStatus:         res.TCBStatus,
+		TCBDate:           res.TCBDate,
+		QEStatus:          res.QEStatus,
+		TokenIssuer:       res.TokenIssuer,
+		AdvisoryIDs:       res.AdvisoryIDs,
+		TestRoots:         res.InsecureTestRoots,
+	}
+	switch res.Mode {
+	case ModeDCAP, ModeTDX, ModeSim:
+		rec.Quote = ev.quote
+		if rec.Quote == nil {
+			rec.Quote = ev.comment
+		}
+	case ModeMAA, ModeITA:
+		rec.Token = string(ev.comment)
+	}
+	if err := v.Evidence.ArchiveEvidence(rec); err != nil {
+		return fmt.Errorf("archive evidence: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package ratls
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// failingSink is an EvidenceSink that cannot archive.
+type failingSink struct{}
+
+func (failingSink) ArchiveEvidence(*EvidenceRecord) error {
+	return errors.New("disk full")
+}
+
+func TestArchiveEvidence(t *testing.T) {
+	var lines bytes.Buffer
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		sink    EvidenceSink
+		read    func(t *testing.T) []byte
+		wantErr bool
+	}{
+		{
+			name: "JSON lines",
+			sink: NewJSONEvidenceSink(&lines),
+			read: func(*testing.T) []byte { return lines.Bytes() },
+		},
+		{
+			name: "directory",
+			sink: EvidenceDir{Dir: dir},
+			read: func(t *testing.T) []byte {
+				files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+				if err != nil || len(files) != 1 {
+					t.Fatalf("archived files = %v, %v", files, err)
+				}
+				fi, err := os.Stat(files[0])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if fi.Mode().Perm()&0o222 != 0 {
+					t.Errorf("archived file mode = %v, want read-only", fi.Mode())
+				}
+				data, err := os.ReadFile(files[0])
+				if err != nil {
+					t.Fatal(err)
+				}
+				return data
+			},
+		},
+		{name: "missing directory", sink: EvidenceDir{Dir: filepath.Join(dir, "missing")}, wantErr: true},
+		{name: "sink fails", sink: failingSink{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert := newSimulatedCert(t, 0xaa)
+			v := &Verifier{AllowSimulation: true, Evidence: tt.sink, PolicyVersion: "v1", Clock: FixedClock(testNow)}
+			_, err := v.VerifyPeerChain("peer", [][]byte{cert})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyPeerChain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var rec EvidenceRecord
+			if err := json.Unmarshal(tt.read(t), &rec); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Peer != "peer" || rec.Mode != "sim" || rec.MrEnclave[:2] != "aa" || rec.PolicyVersion != "v1" || !rec.Time.Equal(testNow) {
+				t.Errorf("archived record = %+v", rec)
+			}
+			if len(rec.Certificates) != 1 || !bytes.Equal(rec.Certificates[0], cert) || len(rec.Quote) == 0 {
+				t.Errorf("archived %d certificates and a %d byte quote", len(rec.Certificates), len(rec.Quote))
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package ratls
+
+import (
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+)
+
+// ExporterLabel is the TLS exporter label (RFC 5705, RFC 8446 section 7.5)
+// of keying material bound to an attested channel.
+const ExporterLabel = "EXPORTER-Teaclave-RA-TLS"
+
+// ExportKeyingMaterial derives length bytes of keying material from the
+// TLS connection cs whose peer certificate was verified with result res.
+// The exporter context is the enclave identity, MRENCLAVE followed by
+// MRSIGNER (MRTD alone for TDX). It is public and adds no secret: the
+// material is secret, and bound to the attested enclave, only because it
+// comes from the TLS session whose certificate carried the verified
+// evidence. The context merely keeps both ends from agreeing on material
+// for different enclave identities. Upper-layer protocols can use it to
+// key payload encryption or to sign application messages for the attested
+// channel.
+//
+// TLS 1.2 connections must have negotiated the extended master secret.
+func ExportKeyingMaterial(cs tls.ConnectionState, res *VerificationResult, length int) ([]byte, error) {
+	if !cs.HandshakeComplete {
+		return nil, errors.New("TLS handshake is not complete")
+	}
+	if res == nil || res.MrEnclave == "" {
+		return nil, errors.New("no verified enclave identity")
+	}
+	context, err := hex.DecodeString(res.MrEnclave + res.MrSigner)
+	if err != nil {
+		return nil, err
+	}
+	return cs.ExportKeyingMaterial(ExporterLabel, context, length)
+}
@@ -0,0 +1,110 @@
+package ratls
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net"
+	"testing"
+)
+
+// testHandshake completes a TLS handshake of at most version maxVersion
+// over a pipe and returns the client and server connection states.
+func testHandshake(t *testing.T, maxVersion uint16) (client, server tls.ConnectionState) {
+	t.Helper()
+	c, s := net.Pipe()
+	defer c.Close()
+	defer s.Close()
+	cert := newSimulatedKeyPair(t, func(*ReportBody) {})
+	tlsServer := tls.Server(s, &tls.Config{Certificates: []tls.Certificate{cert}, MaxVersion: maxVersion})
+	tlsClient := tls.Client(c, &tls.Config{InsecureSkipVerify: true, MaxVersion: maxVersion})
+	done := make(chan error, 1)
+	go func() { done <- tlsServer.Handshake() }()
+	if err := tlsClient.Handshake(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	return tlsClient.ConnectionState(), tlsServer.ConnectionState()
+}
+
+func TestExportKeyingMaterial(t *testing.T) {
+	enclave := &VerificationResult{MrEnclave: "aa00", MrSigner: "bb00"}
+	tests := []struct {
+		name       string
+		maxVersion uint16
+		clientRes  *VerificationResult
+		serverRes  *VerificationResult
+		incomplete bool
+		wantErr    bool
+		wantEqual  bool
+	}{
+		{name: "TLS 1.3", maxVersion: tls.VersionTLS13, clientRes: enclave, serverRes: enclave, wantEqual: true},
+		{name: "TLS 1.2", maxVersion: tls.VersionTLS12, clientRes: enclave, serverRes: enclave, wantEqual: true},
+		{name: "TD identity", maxVersion: tls.VersionTLS13, clientRes: &VerificationResult{MrEnclave: "cc00"}, serverRes: &VerificationResult{MrEnclave: "cc00"}, wantEqual: true},
+		{name: "other enclave", maxVersion: tls.VersionTLS13, clientRes: enclave, serverRes: &VerificationResult{MrEnclave: "aa01", MrSigner: "bb00"}},
+		{name: "other signer", maxVersion: tls.VersionTLS13, clientRes: enclave, serverRes: &VerificationResult{MrEnclave: "aa00", MrSigner: "bb01"}},
+		{name: "no identity", maxVersion: tls.VersionTLS13, clientRes: &VerificationResult{}, wantErr: true},
+		{name: "no result", maxVersion: tls.VersionTLS13, wantErr: true},
+		{name: "malformed identity", maxVersion: tls.VersionTLS13, clientRes: &VerificationResult{MrEnclave: "zz"}, wantErr: true},
+		{name: "handshake not complete", maxVersion: tls.VersionTLS13, clientRes: enclave, incomplete: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := testHandshake(t, tt.maxVersion)
+			if tt.incomplete {
+				client = tls.ConnectionState{}
+			}
+			got, err := ExportKeyingMaterial(client, tt.clientRes, 32)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExportKeyingMaterial() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(got) != 32 {
+				t.Fatalf("ExportKeyingMaterial() = %d bytes, want 32", len(got))
+			}
+			peer, err := ExportKeyingMaterial(server, tt.serverRes, 32)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Equal(got, peer) != tt.wantEqual {
+				t.Errorf("client and server material equal = %v, want %v", !tt.wantEqual, tt.wantEqual)
+			}
+		})
+	}
+}
+
+func TestChannelBinding(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxVersion uint16
+	}{
+		{name: "TLS 1.3", maxVersion: tls.VersionTLS13},
+		{name: "TLS 1.2", maxVersion: tls.VersionTLS12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := testHandshake(t, tt.maxVersion)
+			got, err := ChannelBinding(client)
+			if err != nil {
+				t.Fatalf("ChannelBinding() error = %v", err)
+			}
+			peer, err := ChannelBinding(server)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, peer) {
+				t.Error("client and server channel bindings differ")
+			}
+			other, _ := testHandshake(t, tt.maxVersion)
+			if again, err := ChannelBinding(other); err != nil || bytes.Equal(got, again) {
+				t.Errorf("ChannelBinding() of another connection = %x, %v", again, err)
+			}
+		})
+	}
+	if _, err := ChannelBinding(tls.ConnectionState{}); err == nil {
+		t.Error("ChannelBinding() of an incomplete handshake succeeded")
+	}
+}
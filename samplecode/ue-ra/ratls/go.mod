@@ -0,0 +1,3 @@
+module github.com/apache/incubator-teaclave-sgx-sdk/samplecode/ue-ra/ratls
+
+go 1.21
@@ -0,0 +1,138 @@
+package ratls
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Enclaves behind a TLS-terminating load balancer cannot present RA-TLS
+// certificates to their clients. They can instead deliver their evidence
+// over HTTP, where it answers a nonce sent by the client: in the
+// EvidenceHeader of their responses to requests carrying a NonceHeader, or
+// as the body of WellKnownEvidencePath. The nonce is echoed as for RA-TLS,
+// in the IAS nonce field or in report_data starting at byte 32. The
+// evidence is not bound to the TLS connection, which the load balancer
+// terminates.
+const (
+	// NonceHeader carries the hex encoded nonce of a request.
+	NonceHeader = "Ratls-Nonce"
+	// EvidenceHeader carries the base64 encoded evidence payload of a
+	// response, laid out as in a certificate.
+	EvidenceHeader = "Ratls-Evidence"
+	// WellKnownEvidencePath serves the evidence payload, answering the
+	// nonce query parameter.
+	WellKnownEvidencePath = "/.well-known/ratls-evidence"
+)
+
+// withNonce returns a copy of v checking that evidence answers nonce.
+func (v *Verifier) withNonce(nonce []byte) *Verifier {
+	c := *v
+	c.Nonce = nonce
+	// The nonce is echoed on its own, there is no certificate key
+	c.Binding = BindingKey
+	return &c
+}
+
+// VerifyResponse verifies the evidence in the EvidenceHeader of resp, which
+// must answer nonce, the one sent in the NonceHeader of the request.
+func (v *Verifier) VerifyResponse(resp *http.Response, nonce []byte) (*VerificationResult, error) {
+	value := resp.Header.Get(EvidenceHeader)
+	if value == "" {
+		return nil, fmt.Errorf("response carries no %s header", EvidenceHeader)
+	}
+	payload, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s header: %v", EvidenceHeader, err)
+	}
+	return v.withNonce(nonce).VerifyEvidence(payload)
+}
+
+// FetchEvidence gets the evidence served at WellKnownEvidencePath of
+// baseURL with a fresh nonce and verifies it. client is
+// http.DefaultClient if nil.
+func (v *Verifier) FetchEvidence(ctx context.Context, client *http.Client, baseURL string) (*VerificationResult, error) {
+	nonce, err := NewNonce()
+	if err != nil {
+		return nil, err
+	}
+	url := strings.TrimRight(baseURL, "/") + WellKnownEvidencePath + "?nonce=" + hex.EncodeToString(nonce)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	payload, err := io.ReadAll(io.LimitReader(resp.Body, MaxEvidenceSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return v.withNonce(nonce).VerifyEvidence(payload)
+}
+
+// Transport is an http.RoundTripper for clients of enclaves behind a load
+// balancer. It sends a fresh nonce with every request and fails the round
+// trip unless the response carries evidence that answers it and passes
+// Verifier. ResponseIdentity returns the verified result of a response.
+type Transport struct {
+	// Base performs the requests, http.DefaultTransport if nil.
+	Base http.RoundTripper
+	// Verifier verifies the evidence of every response.
+	Verifier *Verifier
+}
+
+// resultKey keys the verified result in the context of a response request.
+type resultKey struct{}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Verifier == nil {
+		return nil, errors.New("ratls: Transport has no Verifier")
+	}
+	nonce, err := NewNonce()
+	if err != nil {
+		return nil, err
+	}
+	// RoundTrippers must not modify the request
+	req = req.Clone(req.Context())
+	req.Header.Set(NonceHeader, hex.EncodeToString(nonce))
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	res, err := t.Verifier.VerifyResponse(resp, nonce)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	resp.Request = req.WithContext(context.WithValue(req.Context(), resultKey{}, res))
+	return resp, nil
+}
+
+// ResponseIdentity returns the verification result of a response received
+// through a Transport, or nil.
+func ResponseIdentity(resp *http.Response) *VerificationResult {
+	if resp == nil || resp.Request == nil {
+		return nil
+	}
+	res, _ := resp.Request.Context().Value(resultKey{}).(*VerificationResult)
+	return res
+}
@@ -0,0 +1,91 @@
+package ratls
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testNonceEvidence returns simulated evidence echoing nonce in
+// report_data[32:].
+func testNonceEvidence(nonce []byte) []byte {
+	reportData := make([]byte, 64)
+	copy(reportData[32:], nonce)
+	return testSimulatedEvidence(reportData)
+}
+
+func TestVerifyResponse(t *testing.T) {
+	nonce := bytes.Repeat([]byte{0xab}, NonceSize)
+	header := func(evidence string) http.Header {
+		h := http.Header{}
+		if evidence != "" {
+			h.Set(EvidenceHeader, evidence)
+		}
+		return h
+	}
+	tests := []struct {
+		name    string
+		header  http.Header
+		wantErr bool
+	}{
+		{name: "answers the nonce", header: header(base64.StdEncoding.EncodeToString(testNonceEvidence(nonce)))},
+		{name: "other nonce", header: header(base64.StdEncoding.EncodeToString(testNonceEvidence(make([]byte, NonceSize)))), wantErr: true},
+		{name: "no evidence", header: header(""), wantErr: true},
+		{name: "malformed evidence", header: header("!"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Verifier{AllowSimulation: true, Binding: BindingSPKIHash}
+			res, err := v.VerifyResponse(&http.Response{Header: tt.header}, nonce)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyResponse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && res.Mode != ModeSim {
+				t.Errorf("VerifyResponse() mode = %v, want %v", res.Mode, ModeSim)
+			}
+		})
+	}
+}
+
+func TestTransport(t *testing.T) {
+	tests := []struct {
+		name string
+		// echo makes the server answer the nonce of the request.
+		echo     bool
+		verifier *Verifier
+		wantErr  bool
+	}{
+		{name: "verified", echo: true, verifier: &Verifier{AllowSimulation: true}},
+		{name: "stale evidence", verifier: &Verifier{AllowSimulation: true}, wantErr: true},
+		{name: "simulation not allowed", echo: true, verifier: &Verifier{}, wantErr: true},
+		{name: "no verifier", echo: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				nonce := make([]byte, NonceSize)
+				if tt.echo {
+					nonce, _ = hex.DecodeString(r.Header.Get(NonceHeader))
+				}
+				w.Header().Set(EvidenceHeader, base64.StdEncoding.EncodeToString(testNonceEvidence(nonce)))
+			}))
+			defer srv.Close()
+
+			client := &http.Client{Transport: &Transport{Verifier: tt.verifier}}
+			resp, err := client.Get(srv.URL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			resp.Body.Close()
+			if res := ResponseIdentity(resp); res == nil || res.Mode != ModeSim {
+				t.Errorf("ResponseIdentity() = %+v, want a simulated enclave", res)
+			}
+		})
+	}
+}
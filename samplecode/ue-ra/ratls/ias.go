@@ -0,0 +1,126 @@
+package ratls
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultIASURL is the Intel Attestation Service v4 API.
+const DefaultIASURL = "https://api.trustedservices.intel.com/sgx/attestation/v4"
+
+// IASClient retrieves attestation verification reports from IAS by their
+// ID. Verifier.IAS uses it to confirm online that a report presented by an
+// enclave was issued by IAS and that IAS still gives the same verdict for
+// it, as a second factor besides the report signature.
+type IASClient struct {
+	// BaseURL of the attestation API, DefaultIASURL if empty. Reports are
+	// retrieved from BaseURL/report/<id>.
+	BaseURL string
+	// APIKey is the IAS subscription key, sent as
+	// Ocp-Apim-Subscription-Key.
+	APIKey string
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// IASReport is a report as returned by IAS: the JSON body, its signature
+// and the signing certificate chain, leaf first.
+type IASReport struct {
+	Body      []byte
+	Signature []byte
+	Chain     []*x509.Certificate
+}
+
+// GetReport retrieves the report with the given ID.
+func (c *IASClient) GetReport(ctx context.Context, id string) (*IASReport, error) {
+	base := c.BaseURL
+	if base == "" {
+		base = DefaultIASURL
+	}
+	u := base + "/report/" + url.PathEscape(id)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.APIKey != "" {
+		req.Header.Set("Ocp-Apim-Subscription-Key", c.APIKey)
+	}
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCollateralSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s: %s", u, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(resp.Header.Get("X-IASReport-Signature"))
+	if err != nil || len(sig) == 0 {
+		return nil, fmt.Errorf("GET %s: missing or malformed X-IASReport-Signature", u)
+	}
+	pemChain, err := url.QueryUnescape(resp.Header.Get("X-IASReport-Signing-Certificate"))
+	if err != nil {
+		return nil, fmt.Errorf("decode X-IASReport-Signing-Certificate: %v", err)
+	}
+	chain, err := parsePEMCerts([]byte(pemChain))
+	if err != nil {
+		return nil, err
+	}
+	return &IASReport{Body: body, Signature: sig, Chain: chain}, nil
+}
+
+// recheckReport retrieves the report presented by the enclave from IAS and
+// requires it to be genuine and to match: same quote and same verdict. A
+// report IAS does not know, or whose quote status changed since it was
+// issued (e.g. to GROUP_REVOKED), is rejected.
+func (v *Verifier) recheckReport(presented []byte, roots *x509.CertPool, res *VerificationResult) error {
+	online, err := v.IAS.GetReport(context.Background(), res.ReportID)
+	if err != nil {
+		return fmt.Errorf("re-query IAS report: %v", err)
+	}
+	if len(online.Chain) == 0 {
+		return errors.New("re-query IAS report: no signing certificate")
+	}
+	if _, _, err := verifyCert(online.Body, online.Signature, online.Chain[0].Raw, roots, v.now(), v.ReportSignatureAlgorithms); err != nil {
+		return failuref(ErrBadSignature, "re-query IAS report: %v", err)
+	}
+	if bytes.Equal(online.Body, presented) {
+		res.IASConfirmed = true
+		return nil
+	}
+
+	var got, want QuoteReport
+	if err := json.Unmarshal(online.Body, &got); err != nil {
+		return fmt.Errorf("re-query IAS report: %v", err)
+	}
+	if err := json.Unmarshal(presented, &want); err != nil {
+		return err
+	}
+	switch {
+	case got.ID != want.ID:
+		return fmt.Errorf("IAS returned report %q for ID %q", got.ID, want.ID)
+	case got.IsvEnclaveQuoteBody != want.IsvEnclaveQuoteBody:
+		return fmt.Errorf("IAS report %s is for a different quote", want.ID)
+	case got.IsvEnclaveQuoteStatus != want.IsvEnclaveQuoteStatus:
+		return failuref(ErrQuoteStatus, "IAS now reports status %s for report %s", got.IsvEnclaveQuoteStatus, want.ID)
+	}
+	res.IASConfirmed = true
+	return nil
+}
@@ -0,0 +1,83 @@
+package ratls
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestRecheckReport(t *testing.T) {
+	pki := newTestCollateralPKI(t)
+	other := newTestCollateralPKI(t)
+	presented := testIASReport(t, EPIDUnlinkable, nil)
+	tests := []struct {
+		name    string
+		online  []byte
+		signer  *testCollateralPKI
+		status  int
+		noSig   bool
+		wantErr bool
+		wantIs  error
+	}{
+		{name: "same report", online: presented, signer: pki},
+		{name: "same verdict", online: append(bytes.Clone(presented), '\n'), signer: pki},
+		{
+			name:    "status changed",
+			online:  testIASReport(t, EPIDUnlinkable, func(r *QuoteReport) { r.IsvEnclaveQuoteStatus = "GROUP_REVOKED" }),
+			signer:  pki,
+			wantErr: true,
+			wantIs:  ErrQuoteStatus,
+		},
+		{name: "other quote", online: testIASReport(t, EPIDLinkable, nil), signer: pki, wantErr: true},
+		{name: "other report", online: testIASReport(t, EPIDUnlinkable, func(r *QuoteReport) { r.ID = "2" }), signer: pki, wantErr: true},
+		{name: "other signer", online: presented, signer: other, wantErr: true, wantIs: ErrBadSignature},
+		{name: "no signature", online: presented, signer: pki, noSig: true, wantErr: true},
+		{name: "unknown report", online: []byte("not found"), signer: pki, status: http.StatusNotFound, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/report/1" || r.Header.Get("Ocp-Apim-Subscription-Key") != "key" {
+					http.NotFound(w, r)
+					return
+				}
+				digest := sha256.Sum256(tt.online)
+				sig, err := ecdsa.SignASN1(rand.Reader, tt.signer.signerKey, digest[:])
+				if err != nil {
+					t.Error(err)
+				}
+				if !tt.noSig {
+					w.Header().Set("X-IASReport-Signature", base64.StdEncoding.EncodeToString(sig))
+				}
+				cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tt.signer.signer.Raw})
+				w.Header().Set("X-IASReport-Signing-Certificate", url.QueryEscape(string(cert)))
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+				w.Write(tt.online)
+			}))
+			defer srv.Close()
+
+			v := &Verifier{IAS: &IASClient{BaseURL: srv.URL, APIKey: "key"}, Clock: FixedClock(testNow)}
+			res := &VerificationResult{ReportID: "1"}
+			err := v.recheckReport(presented, pki.roots, res)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("recheckReport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Errorf("recheckReport() error = %v, want %v", err, tt.wantIs)
+			}
+			if res.IASConfirmed != !tt.wantErr {
+				t.Errorf("IASConfirmed = %v, want %v", res.IASConfirmed, !tt.wantErr)
+			}
+		})
+	}
+}
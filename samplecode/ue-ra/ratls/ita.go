@@ -0,0 +1,171 @@
+package ratls
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// DefaultITAURL is the Intel Trust Authority portal serving the token
+	// signing keys; the EU region is https://portal.eu.trustauthority.intel.com.
+	DefaultITAURL = "https://portal.trustauthority.intel.com"
+	// ITAIssuer is the iss claim of Intel Trust Authority tokens.
+	ITAIssuer = "Intel Trust Authority"
+)
+
+// NewITAIssuer returns the TokenIssuer of Intel Trust Authority, whose
+// signing keys are fetched from the /certs endpoint of portalURL
+// (DefaultITAURL if empty).
+func NewITAIssuer(portalURL string) *TokenIssuer {
+	if portalURL == "" {
+		portalURL = DefaultITAURL
+	}
+	return &TokenIssuer{
+		Issuer: ITAIssuer,
+		Keys:   &JWKSClient{URL: strings.TrimSuffix(portalURL, "/") + "/certs"},
+	}
+}
+
+// itaClaims are the claims of an Intel Trust Authority token. SGX tokens
+// carry the sgx_ claims, TDX tokens the tdx_ claims.
+type itaClaims struct {
+	AttesterType string   `json:"attester_type"`
+	TCBStatus    string   `json:"attester_tcb_status"`
+	TCBDate      string   `json:"attester_tcb_date"`
+	AdvisoryIDs  []string `json:"attester_advisory_ids"`
+	// HeldData is the data the attester submitted, bound by report_data.
+	HeldData string `json:"attester_held_data"`
+
+	SGXMrEnclave  string `json:"sgx_mrenclave"`
+	SGXMrSigner   string `json:"sgx_mrsigner"`
+	SGXISVProdID  uint16 `json:"sgx_isvprodid"`
+	SGXISVSVN     uint16 `json:"sgx_isvsvn"`
+	SGXDebuggable *bool  `json:"sgx_is_debuggable"`
+	SGXReportData string `json:"sgx_report_data"`
+
+	TDXMrTD          string `json:"tdx_mrtd"`
+	TDXMrSeam        string `json:"tdx_mrseam"`
+	TDXMrSignerSeam  string `json:"tdx_mrsignerseam"`
+	TDXMrConfigID    string `json:"tdx_mrconfigid"`
+	TDXMrOwner       string `json:"tdx_mrowner"`
+	TDXMrOwnerConfig string `json:"tdx_mrownerconfig"`
+	TDXRTMR0         string `json:"tdx_rtmr0"`
+	TDXRTMR1         string `json:"tdx_rtmr1"`
+	TDXRTMR2         string `json:"tdx_rtmr2"`
+	TDXRTMR3         string `json:"tdx_rtmr3"`
+	TDXTEETCBSVN     string `json:"tdx_tee_tcb_svn"`
+	TDXTDAttributes  string `json:"tdx_td_attributes"`
+	TDXXFAM          string `json:"tdx_xfam"`
+	TDXDebuggable    *bool  `json:"tdx_is_debuggable"`
+	TDXReportData    string `json:"tdx_report_data"`
+}
+
+// tokenMode tells the service that issued an attestation token from its
+// claims: Intel Trust Authority tokens name the attester type.
+func tokenMode(token []byte) Mode {
+	if t, err := parseJWT(string(token)); err == nil {
+		var claims struct {
+			AttesterType string `json:"attester_type"`
+		}
+		if json.Unmarshal(t.payload, &claims) == nil && claims.AttesterType != "" {
+			return ModeITA
+		}
+	}
+	return ModeMAA
+}
+
+// verifyITAToken checks an Intel Trust Authority token, takes the enclave or
+// TD identity and the platform TCB level from its claims, and applies the
+// TCB status policy. Trust Authority has verified the quote against Intel's
+// collateral, so no local collateral is needed.
+func (v *Verifier) verifyITAToken(token []byte, res *VerificationResult) error {
+	if v.ITA == nil {
+		return errors.New("no Intel Trust Authority service configured")
+	}
+	var claims itaClaims
+	if err := v.verifyToken(string(token), v.ITA, &claims, res); err != nil {
+		return err
+	}
+	res.TCBStatus = claims.TCBStatus
+	res.TCBDate = claims.TCBDate
+	res.AdvisoryIDs = claims.AdvisoryIDs
+
+	switch strings.ToUpper(claims.AttesterType) {
+	case "SGX":
+		if claims.SGXMrEnclave == "" || claims.SGXMrSigner == "" || claims.SGXDebuggable == nil {
+			return errors.New("attestation token lacks SGX enclave claims")
+		}
+		res.MrEnclave = strings.ToLower(claims.SGXMrEnclave)
+		res.MrSigner = strings.ToLower(claims.SGXMrSigner)
+		res.ISVProdID = claims.SGXISVProdID
+		res.ISVSVN = claims.SGXISVSVN
+		res.Debug = *claims.SGXDebuggable
+		res.ReportData = strings.ToLower(claims.SGXReportData)
+	case "TDX":
+		if claims.TDXMrTD == "" || claims.TDXDebuggable == nil {
+			return errors.New("attestation token lacks TDX claims")
+		}
+		td, err := claims.tdReport()
+		if err != nil {
+			return err
+		}
+		res.setTDReport(td)
+		res.Debug = *claims.TDXDebuggable
+	default:
+		return fmt.Errorf("unsupported attester type %q", claims.AttesterType)
+	}
+
+	// The enclave submits the key encoding as held data
+	held, err := base64.StdEncoding.DecodeString(claims.HeldData)
+	if err != nil {
+		return errors.New("malformed attester held data in attestation token")
+	}
+	res.KeyBound = res.binds(held, true)
+
+	if claims.TCBStatus == "" {
+		return errors.New("attestation token carries no TCB status")
+	}
+	if err := v.checkTCBStatus(claims.TCBStatus, claims.AdvisoryIDs); err != nil {
+		return err
+	}
+	return v.checkNonce("", res)
+}
+
+// tdReport rebuilds the TD report from the tdx_ claims.
+func (c *itaClaims) tdReport() (*TDReport, error) {
+	td := &TDReport{}
+	fields := []struct {
+		name, claim string
+		dst         []byte
+	}{
+		{"tdx_mrtd", c.TDXMrTD, td.MrTD[:]},
+		{"tdx_mrseam", c.TDXMrSeam, td.MrSeam[:]},
+		{"tdx_mrsignerseam", c.TDXMrSignerSeam, td.MrSignerSeam[:]},
+		{"tdx_mrconfigid", c.TDXMrConfigID, td.MrConfigID[:]},
+		{"tdx_mrowner", c.TDXMrOwner, td.MrOwner[:]},
+		{"tdx_mrownerconfig", c.TDXMrOwnerConfig, td.MrOwnerConfig[:]},
+		{"tdx_rtmr0", c.TDXRTMR0, td.RTMR[0][:]},
+		{"tdx_rtmr1", c.TDXRTMR1, td.RTMR[1][:]},
+		{"tdx_rtmr2", c.TDXRTMR2, td.RTMR[2][:]},
+		{"tdx_rtmr3", c.TDXRTMR3, td.RTMR[3][:]},
+		{"tdx_tee_tcb_svn", c.TDXTEETCBSVN, td.TEETCBSVN[:]},
+		{"tdx_td_attributes", c.TDXTDAttributes, td.TDAttributes[:]},
+		{"tdx_xfam", c.TDXXFAM, td.XFAM[:]},
+		{"tdx_report_data", c.TDXReportData, td.ReportData[:]},
+	}
+	for _, f := range fields {
+		if f.claim == "" {
+			continue
+		}
+		raw, err := hex.DecodeString(f.claim)
+		if err != nil || len(raw) != len(f.dst) {
+			return nil, fmt.Errorf("malformed %s claim in attestation token", f.name)
+		}
+		copy(f.dst, raw)
+	}
+	return td, nil
+}
@@ -0,0 +1,126 @@
+package ratls
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyITAToken(t *testing.T) {
+	keys := newTestTokenKeys(t)
+	ita := &TokenIssuer{Issuer: ITAIssuer, Keys: keys.set()}
+	pubKey := []byte("certificate key")
+	sgx := func(edit func(map[string]any)) []byte {
+		c := map[string]any{
+			"iss":                 ITAIssuer,
+			"iat":                 testNow.Unix(),
+			"exp":                 testNow.Add(time.Hour).Unix(),
+			"attester_type":       "SGX",
+			"attester_tcb_status": "UpToDate",
+			"attester_tcb_date":   "2023-02-15T00:00:00Z",
+			"attester_held_data":  base64.StdEncoding.EncodeToString(pubKey),
+			"sgx_mrenclave":       strings.Repeat("AA", 32),
+			"sgx_mrsigner":        strings.Repeat("BB", 32),
+			"sgx_isvprodid":       1,
+			"sgx_isvsvn":          2,
+			"sgx_is_debuggable":   false,
+		}
+		if edit != nil {
+			edit(c)
+		}
+		return []byte(testJWT(t, map[string]any{"alg": "PS384", "kid": "rsa"}, c, keys.rsa))
+	}
+	tdx := func(edit func(map[string]any)) []byte {
+		return sgx(func(c map[string]any) {
+			for k := range c {
+				if strings.HasPrefix(k, "sgx_") {
+					delete(c, k)
+				}
+			}
+			c["attester_type"] = "TDX"
+			c["tdx_mrtd"] = strings.Repeat("cc", 48)
+			c["tdx_rtmr0"] = strings.Repeat("dd", 48)
+			c["tdx_report_data"] = strings.Repeat("ee", 64)
+			c["tdx_is_debuggable"] = false
+			if edit != nil {
+				edit(c)
+			}
+		})
+	}
+
+	tests := []struct {
+		name          string
+		issuer        *TokenIssuer
+		statuses      []string
+		token         []byte
+		wantMrEnclave string
+		wantKeyBound  bool
+		wantErr       bool
+		wantIs        error
+	}{
+		{name: "SGX", issuer: ita, token: sgx(nil), wantMrEnclave: strings.Repeat("aa", 32), wantKeyBound: true},
+		{name: "TDX", issuer: ita, token: tdx(nil), wantMrEnclave: strings.Repeat("cc", 48), wantKeyBound: true},
+		{name: "lower case attester type", issuer: ita, token: sgx(func(c map[string]any) { c["attester_type"] = "sgx" }), wantMrEnclave: strings.Repeat("aa", 32), wantKeyBound: true},
+		{
+			name:   "other key",
+			issuer: ita,
+			token: sgx(func(c map[string]any) {
+				c["attester_held_data"] = base64.StdEncoding.EncodeToString([]byte("other key"))
+			}),
+			wantMrEnclave: strings.Repeat("aa", 32),
+		},
+		{name: "TCB status allowed", issuer: ita, statuses: []string{"UpToDate", "SWHardeningNeeded"}, token: sgx(func(c map[string]any) { c["attester_tcb_status"] = "SWHardeningNeeded" }), wantMrEnclave: strings.Repeat("aa", 32), wantKeyBound: true},
+		{name: "TCB status not allowed", issuer: ita, statuses: []string{"UpToDate"}, token: sgx(func(c map[string]any) { c["attester_tcb_status"] = "OutOfDate" }), wantErr: true, wantIs: ErrTCBOutOfDate},
+		{name: "no TCB status", issuer: ita, token: sgx(func(c map[string]any) { delete(c, "attester_tcb_status") }), wantErr: true},
+		{name: "malformed held data", issuer: ita, token: sgx(func(c map[string]any) { c["attester_held_data"] = "!" }), wantErr: true},
+		{name: "no SGX claims", issuer: ita, token: sgx(func(c map[string]any) { delete(c, "sgx_is_debuggable") }), wantErr: true},
+		{name: "no MRTD", issuer: ita, token: tdx(func(c map[string]any) { delete(c, "tdx_mrtd") }), wantErr: true},
+		{name: "short RTMR", issuer: ita, token: tdx(func(c map[string]any) { c["tdx_rtmr0"] = "dd" }), wantErr: true},
+		{name: "malformed report data", issuer: ita, token: tdx(func(c map[string]any) { c["tdx_report_data"] = strings.Repeat("zz", 64) }), wantErr: true},
+		{name: "SEV-SNP", issuer: ita, token: sgx(func(c map[string]any) { c["attester_type"] = "SEV-SNP" }), wantErr: true},
+		{name: "other issuer", issuer: ita, token: sgx(func(c map[string]any) { c["iss"] = "https://shared.eus.attest.azure.net" }), wantErr: true},
+		{name: "no service", token: sgx(nil), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Verifier{Mode: ModeITA, ITA: tt.issuer, TCBStatuses: QuoteStatusPolicy{Allowed: tt.statuses}, Clock: FixedClock(testNow)}
+			res := &VerificationResult{Mode: ModeITA, PublicKey: pubKey}
+			err := v.verifyITAToken(tt.token, res)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyITAToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Errorf("verifyITAToken() error = %v, want %v", err, tt.wantIs)
+			}
+			if err != nil {
+				return
+			}
+			if res.MrEnclave != tt.wantMrEnclave || res.KeyBound != tt.wantKeyBound || res.Debug {
+				t.Errorf("MrEnclave, KeyBound, Debug = %s, %v, %v", res.MrEnclave, res.KeyBound, res.Debug)
+			}
+		})
+	}
+}
+
+func TestTokenMode(t *testing.T) {
+	keys := newTestTokenKeys(t)
+	header := map[string]any{"alg": "ES256", "kid": "ec"}
+	tests := []struct {
+		name  string
+		token []byte
+		want  Mode
+	}{
+		{name: "Intel Trust Authority", token: []byte(testJWT(t, header, map[string]any{"attester_type": "SGX"}, keys.ec)), want: ModeITA},
+		{name: "Azure Attestation", token: []byte(testJWT(t, header, map[string]any{"x-ms-attestation-type": "sgx"}, keys.ec)), want: ModeMAA},
+		{name: "not a token", token: []byte("report|sig|cert"), want: ModeMAA},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tokenMode(tt.token); got != tt.want {
+				t.Errorf("tokenMode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
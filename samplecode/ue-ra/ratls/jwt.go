@@ -0,0 +1,370 @@
+package ratls
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultJWKSMaxAge is how long a JWKSClient reuses a fetched key set when
+// its MaxAge is zero.
+const DefaultJWKSMaxAge = time.Hour
+
+// jwksRefreshInterval bounds how often a token signed by an unknown key
+// causes the key set to be fetched again.
+const jwksRefreshInterval = time.Minute
+
+// tokenLeeway is the clock skew tolerated on token validity.
+const tokenLeeway = time.Minute
+
+// TokenIssuer is an attestation service whose tokens a Verifier accepts.
+type TokenIssuer struct {
+	// Issuer is the iss claim tokens must carry.
+	Issuer string
+	// Keys provides the token signing keys, typically a JWKSClient of the
+	// key set the service publishes.
+	Keys KeySource
+}
+
+// KeySource provides the public keys attestation tokens are signed with.
+type KeySource interface {
+	// TokenKey returns the key of the given key ID (the kid header).
+	TokenKey(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// JWKSet is a JSON Web Key Set mapping key IDs to keys. It is a KeySource
+// for keys saved or configured offline.
+type JWKSet map[string]crypto.PublicKey
+
+// TokenKey returns the key of kid.
+func (s JWKSet) TokenKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	key, ok := s[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown token signing key %q", kid)
+	}
+	return key, nil
+}
+
+// jwk is a JSON Web Key (RFC 7517) of a signing key.
+type jwk struct {
+	Kty string   `json:"kty"`
+	Kid string   `json:"kid"`
+	Use string   `json:"use"`
+	N   string   `json:"n"`
+	E   string   `json:"e"`
+	Crv string   `json:"crv"`
+	X   string   `json:"x"`
+	Y   string   `json:"y"`
+	X5c []string `json:"x5c"`
+}
+
+// ParseJWKS parses a JSON Web Key Set. RSA and EC keys are read from their
+// parameters or, as Azure Attestation publishes them, from the first
+// certificate of x5c. Keys not meant for signatures are skipped.
+func ParseJWKS(data []byte) (JWKSet, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	set := make(JWKSet)
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("JWK %q: %v", k.Kid, err)
+		}
+		set[k.Kid] = key
+	}
+	if len(set) == 0 {
+		return nil, errors.New("key set holds no signing key")
+	}
+	return set, nil
+}
+
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	if len(k.X5c) > 0 {
+		der, err := base64.StdEncoding.DecodeString(k.X5c[0])
+		if err != nil {
+			return nil, err
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exp := new(big.Int).SetBytes(e)
+		if len(n) == 0 || !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, errors.New("EC point is not on the curve")
+		}
+		return pub, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// JWKSClient is a KeySource fetching the JSON Web Key Set of an attestation
+// service. The set is cached for MaxAge and fetched again early when a
+// token names an unknown key, as after a key rotation.
+type JWKSClient struct {
+	// URL of the key set, e.g. https://<instance>.attest.azure.net/certs.
+	URL string
+	// MaxAge is how long a fetched set is used, DefaultJWKSMaxAge if zero.
+	MaxAge time.Duration
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	mu      sync.Mutex
+	keys    JWKSet
+	fetched time.Time
+}
+
+// TokenKey returns the key of kid, fetching the key set if needed. When the
+// service cannot be reached, keys already fetched remain in use.
+func (c *JWKSClient) TokenKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	maxAge := c.MaxAge
+	if maxAge == 0 {
+		maxAge = DefaultJWKSMaxAge
+	}
+	age := time.Since(c.fetched)
+	_, known := c.keys[kid]
+	if age > maxAge || (!known && age > jwksRefreshInterval) {
+		keys, err := c.fetch(ctx)
+		if err != nil && !known {
+			return nil, err
+		}
+		if err == nil {
+			c.keys, c.fetched = keys, time.Now()
+		}
+	}
+	return c.keys.TokenKey(ctx, kid)
+}
+
+func (c *JWKSClient) fetch(ctx context.Context) (JWKSet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCollateralSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", c.URL, resp.Status)
+	}
+	return ParseJWKS(body)
+}
+
+// jwt is a JSON Web Token in compact serialization (RFC 7519).
+type jwt struct {
+	alg     string
+	kid     string
+	payload []byte
+	// signed is the signing input, the encoded header and payload.
+	signed    []byte
+	signature []byte
+}
+
+// tokenClaims are the registered claims checked on every token.
+type tokenClaims struct {
+	Issuer    string `json:"iss"`
+	IssuedAt  int64  `json:"iat"`
+	NotBefore int64  `json:"nbf"`
+	Expiry    int64  `json:"exp"`
+}
+
+// isJWT tells whether payload looks like a compact JWT with a JSON header.
+func isJWT(payload []byte) bool {
+	return strings.HasPrefix(string(payload), "eyJ") && strings.Count(string(payload), ".") == 2
+}
+
+func parseJWT(token string) (*jwt, error) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed attestation token")
+	}
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("attestation token header: %v", err)
+	}
+	var header struct {
+		Alg  string   `json:"alg"`
+		Kid  string   `json:"kid"`
+		Crit []string `json:"crit"`
+	}
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return nil, fmt.Errorf("attestation token header: %v", err)
+	}
+	if len(header.Crit) > 0 {
+		return nil, fmt.Errorf("unsupported critical token headers %v", header.Crit)
+	}
+	t := &jwt{alg: header.Alg, kid: header.Kid, signed: []byte(parts[0] + "." + parts[1])}
+	if t.payload, err = base64.RawURLEncoding.DecodeString(parts[1]); err != nil {
+		return nil, fmt.Errorf("attestation token payload: %v", err)
+	}
+	if t.signature, err = base64.RawURLEncoding.DecodeString(parts[2]); err != nil {
+		return nil, fmt.Errorf("attestation token signature: %v", err)
+	}
+	return t, nil
+}
+
+// verifySignature checks the token signature with key. Only the RSA and
+// ECDSA algorithms used by attestation services are accepted, never "none"
+// or HMAC.
+func (t *jwt) verifySignature(key crypto.PublicKey) error {
+	var hash crypto.Hash
+	var curve elliptic.Curve
+	switch t.alg {
+	case "RS256", "PS256":
+		hash = crypto.SHA256
+	case "RS384", "PS384":
+		hash = crypto.SHA384
+	case "RS512", "PS512":
+		hash = crypto.SHA512
+	case "ES256":
+		hash, curve = crypto.SHA256, elliptic.P256()
+	case "ES384":
+		hash, curve = crypto.SHA384, elliptic.P384()
+	case "ES512":
+		hash, curve = crypto.SHA512, elliptic.P521()
+	default:
+		return fmt.Errorf("unsupported token signature algorithm %q", t.alg)
+	}
+	h := hash.New()
+	h.Write(t.signed)
+	digest := h.Sum(nil)
+
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		if curve != nil {
+			break
+		}
+		if t.alg[0] == 'P' {
+			return rsa.VerifyPSS(pub, hash, digest, t.signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		return rsa.VerifyPKCS1v15(pub, hash, digest, t.signature)
+	case *ecdsa.PublicKey:
+		if pub.Curve != curve {
+			break
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(t.signature) != 2*size {
+			return errors.New("malformed token signature")
+		}
+		r := new(big.Int).SetBytes(t.signature[:size])
+		s := new(big.Int).SetBytes(t.signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("invalid token signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("token signing key %T does not match algorithm %s", key, t.alg)
+}
+
+// verifyToken checks the signature, issuer and validity of an attestation
+// token of iss and decodes its claims into claims.
+func (v *Verifier) verifyToken(token string, iss *TokenIssuer, claims any, res *VerificationResult) error {
+	t, err := parseJWT(token)
+	if err != nil {
+		return err
+	}
+	key, err := iss.Keys.TokenKey(context.Background(), t.kid)
+	if err != nil {
+		return err
+	}
+	if err := t.verifySignature(key); err != nil {
+		return failure(ErrBadSignature, err)
+	}
+
+	var reg tokenClaims
+	if err := json.Unmarshal(t.payload, &reg); err != nil {
+		return fmt.Errorf("attestation token claims: %v", err)
+	}
+	res.TokenIssuer = reg.Issuer
+	if reg.Issuer != iss.Issuer {
+		return fmt.Errorf("attestation token issued by %q, want %q", reg.Issuer, iss.Issuer)
+	}
+	now := v.now()
+	if reg.Expiry == 0 {
+		return errors.New("attestation token has no expiry")
+	}
+	res.TokenExpiry = time.Unix(reg.Expiry, 0)
+	if now.After(res.TokenExpiry.Add(tokenLeeway)) {
+		return failuref(ErrStaleReport, "attestation token expired at %v", res.TokenExpiry)
+	}
+	if reg.NotBefore != 0 && now.Add(tokenLeeway).Before(time.Unix(reg.NotBefore, 0)) {
+		return errors.New("attestation token is not valid yet")
+	}
+	if reg.IssuedAt != 0 {
+		res.TokenIssuedAt = time.Unix(reg.IssuedAt, 0)
+		if age, maxAge := now.Sub(res.TokenIssuedAt), v.maxReportAge(); maxAge > 0 && age > maxAge {
+			return failuref(ErrStaleReport, "attestation token is %v old, exceeding %v", age.Round(time.Second), maxAge)
+		}
+	}
+	if err := json.Unmarshal(t.payload, claims); err != nil {
+		return fmt.Errorf("attestation token claims: %v", err)
+	}
+	return nil
+}
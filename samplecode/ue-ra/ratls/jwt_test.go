@@ -0,0 +1,299 @@
+package ratls
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testJWT returns a compact JWT of claims with the given header, signed
+// with key as alg requires. Tokens of other algorithms get a dummy
+// signature.
+func testJWT(t *testing.T, header map[string]any, claims any, key crypto.Signer) string {
+	t.Helper()
+	enc := func(v any) string {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(raw)
+	}
+	signed := enc(header) + "." + enc(claims)
+	alg, _ := header["alg"].(string)
+	hash := map[string]crypto.Hash{"256": crypto.SHA256, "384": crypto.SHA384, "512": crypto.SHA512}[alg[len(alg)-min(len(alg), 3):]]
+	var sig []byte
+	switch {
+	case strings.HasPrefix(alg, "ES"):
+		h := hash.New()
+		h.Write([]byte(signed))
+		ecKey := key.(*ecdsa.PrivateKey)
+		r, s, err := ecdsa.Sign(rand.Reader, ecKey, h.Sum(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := (ecKey.Curve.Params().BitSize + 7) / 8
+		sig = make([]byte, 2*size)
+		r.FillBytes(sig[:size])
+		s.FillBytes(sig[size:])
+	case strings.HasPrefix(alg, "RS"), strings.HasPrefix(alg, "PS"):
+		h := hash.New()
+		h.Write([]byte(signed))
+		var err error
+		if alg[0] == 'P' {
+			sig, err = rsa.SignPSS(rand.Reader, key.(*rsa.PrivateKey), hash, h.Sum(nil), &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			sig, err = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), hash, h.Sum(nil))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+		sig = []byte("signature")
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// testTokenKeys holds the token signing keys of the tests.
+type testTokenKeys struct {
+	ec, p384, other *ecdsa.PrivateKey
+	rsa             *rsa.PrivateKey
+}
+
+func newTestTokenKeys(t *testing.T) *testTokenKeys {
+	t.Helper()
+	var k testTokenKeys
+	var err error
+	for _, gen := range []struct {
+		key   **ecdsa.PrivateKey
+		curve elliptic.Curve
+	}{{&k.ec, elliptic.P256()}, {&k.p384, elliptic.P384()}, {&k.other, elliptic.P256()}} {
+		if *gen.key, err = ecdsa.GenerateKey(gen.curve, rand.Reader); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if k.rsa, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatal(err)
+	}
+	return &k
+}
+
+func (k *testTokenKeys) set() JWKSet {
+	return JWKSet{"ec": &k.ec.PublicKey, "p384": &k.p384.PublicKey, "rsa": &k.rsa.PublicKey}
+}
+
+// testJWK returns the JWK of an EC key.
+func testJWK(kid string, pub *ecdsa.PublicKey) map[string]any {
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	return map[string]any{
+		"kty": "EC",
+		"kid": kid,
+		"crv": pub.Curve.Params().Name,
+		"x":   base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size))),
+		"y":   base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size))),
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	keys := newTestTokenKeys(t)
+	b64 := base64.RawURLEncoding.EncodeToString
+	rsaJWK := map[string]any{"kty": "RSA", "kid": "rsa", "n": b64(keys.rsa.N.Bytes()), "e": "AQAB"}
+	cert := newTestRATLSCert(t, keys.other)
+	x5c := map[string]any{"kty": "EC", "kid": "x5c", "x5c": []string{base64.StdEncoding.EncodeToString(cert)}}
+	offCurve := testJWK("ec", &keys.ec.PublicKey)
+	offCurve["y"] = b64(make([]byte, 32))
+	with := func(jwk map[string]any, k string, v any) map[string]any {
+		c := make(map[string]any)
+		for key, val := range jwk {
+			c[key] = val
+		}
+		c[k] = v
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		keys     []map[string]any
+		raw      string
+		wantKids []string
+		wantErr  bool
+	}{
+		{name: "EC", keys: []map[string]any{testJWK("ec", &keys.ec.PublicKey)}, wantKids: []string{"ec"}},
+		{name: "P-384", keys: []map[string]any{testJWK("p384", &keys.p384.PublicKey)}, wantKids: []string{"p384"}},
+		{name: "RSA", keys: []map[string]any{rsaJWK}, wantKids: []string{"rsa"}},
+		{name: "certificate", keys: []map[string]any{x5c}, wantKids: []string{"x5c"}},
+		{
+			name:     "encryption keys skipped",
+			keys:     []map[string]any{with(rsaJWK, "use", "enc"), with(testJWK("ec", &keys.ec.PublicKey), "use", "sig")},
+			wantKids: []string{"ec"},
+		},
+		{name: "only encryption keys", keys: []map[string]any{with(rsaJWK, "use", "enc")}, wantErr: true},
+		{name: "no keys", keys: []map[string]any{}, wantErr: true},
+		{name: "point not on curve", keys: []map[string]any{offCurve}, wantErr: true},
+		{name: "unsupported curve", keys: []map[string]any{with(testJWK("ec", &keys.ec.PublicKey), "crv", "secp256k1")}, wantErr: true},
+		{name: "RSA exponent 1", keys: []map[string]any{with(rsaJWK, "e", "AQ")}, wantErr: true},
+		{name: "RSA without modulus", keys: []map[string]any{with(rsaJWK, "n", "")}, wantErr: true},
+		{name: "symmetric key", keys: []map[string]any{{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"}}, wantErr: true},
+		{name: "malformed certificate", keys: []map[string]any{with(x5c, "x5c", []string{"Y2VydA=="})}, wantErr: true},
+		{name: "malformed coordinate", keys: []map[string]any{with(testJWK("ec", &keys.ec.PublicKey), "x", "!")}, wantErr: true},
+		{name: "not JSON", raw: "keys", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := []byte(tt.raw)
+			if tt.keys != nil {
+				var err error
+				if raw, err = json.Marshal(map[string]any{"keys": tt.keys}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			set, err := ParseJWKS(raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseJWKS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(set) != len(tt.wantKids) {
+				t.Errorf("ParseJWKS() = %d keys, want %v", len(set), tt.wantKids)
+			}
+			for _, kid := range tt.wantKids {
+				if _, ok := set[kid]; !ok {
+					t.Errorf("ParseJWKS() lacks key %q", kid)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyToken(t *testing.T) {
+	keys := newTestTokenKeys(t)
+	iss := &TokenIssuer{Issuer: "https://attest.example", Keys: keys.set()}
+	claims := func(edit func(map[string]any)) map[string]any {
+		c := map[string]any{
+			"iss": iss.Issuer,
+			"iat": testNow.Add(-time.Minute).Unix(),
+			"nbf": testNow.Add(-time.Minute).Unix(),
+			"exp": testNow.Add(time.Hour).Unix(),
+		}
+		if edit != nil {
+			edit(c)
+		}
+		return c
+	}
+	header := func(alg, kid string) map[string]any { return map[string]any{"alg": alg, "kid": kid, "typ": "JWT"} }
+
+	tests := []struct {
+		name    string
+		token   string
+		maxAge  time.Duration
+		wantErr bool
+		wantIs  error
+	}{
+		{name: "ES256", token: testJWT(t, header("ES256", "ec"), claims(nil), keys.ec)},
+		{name: "ES384", token: testJWT(t, header("ES384", "p384"), claims(nil), keys.p384)},
+		{name: "RS256", token: testJWT(t, header("RS256", "rsa"), claims(nil), keys.rsa)},
+		{name: "PS384", token: testJWT(t, header("PS384", "rsa"), claims(nil), keys.rsa)},
+		{name: "expired within leeway", token: testJWT(t, header("ES256", "ec"), claims(func(c map[string]any) { c["exp"] = testNow.Add(-30 * time.Second).Unix() }), keys.ec)},
+		{name: "other signer", token: testJWT(t, header("ES256", "ec"), claims(nil), keys.other), wantErr: true, wantIs: ErrBadSignature},
+		{name: "algorithm of another key type", token: testJWT(t, header("ES256", "rsa"), claims(nil), keys.ec), wantErr: true, wantIs: ErrBadSignature},
+		{name: "algorithm of another curve", token: testJWT(t, header("ES384", "ec"), claims(nil), keys.p384), wantErr: true, wantIs: ErrBadSignature},
+		{name: "none", token: testJWT(t, header("none", "ec"), claims(nil), nil), wantErr: true, wantIs: ErrBadSignature},
+		{name: "HMAC", token: testJWT(t, header("HS256", "ec"), claims(nil), nil), wantErr: true, wantIs: ErrBadSignature},
+		{name: "unknown key", token: testJWT(t, header("ES256", "gone"), claims(nil), keys.ec), wantErr: true},
+		{
+			name:    "critical header",
+			token:   testJWT(t, map[string]any{"alg": "ES256", "kid": "ec", "crit": []string{"b64"}, "b64": false}, claims(nil), keys.ec),
+			wantErr: true,
+		},
+		{name: "other issuer", token: testJWT(t, header("ES256", "ec"), claims(func(c map[string]any) { c["iss"] = "https://evil.example" }), keys.ec), wantErr: true},
+		{name: "expired", token: testJWT(t, header("ES256", "ec"), claims(func(c map[string]any) { c["exp"] = testNow.Add(-2 * time.Minute).Unix() }), keys.ec), wantErr: true, wantIs: ErrStaleReport},
+		{name: "no expiry", token: testJWT(t, header("ES256", "ec"), claims(func(c map[string]any) { delete(c, "exp") }), keys.ec), wantErr: true},
+		{name: "not yet valid", token: testJWT(t, header("ES256", "ec"), claims(func(c map[string]any) { c["nbf"] = testNow.Add(2 * time.Minute).Unix() }), keys.ec), wantErr: true},
+		{name: "issued too long ago", token: testJWT(t, header("ES256", "ec"), claims(nil), keys.ec), maxAge: 30 * time.Second, wantErr: true, wantIs: ErrStaleReport},
+		{name: "claims not an object", token: testJWT(t, header("ES256", "ec"), []string{"iss"}, keys.ec), wantErr: true},
+		{name: "two segments", token: "eyJhbGciOiJFUzI1NiJ9.e30", wantErr: true},
+		{name: "malformed header", token: "e30x.e30.c2ln", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Verifier{Clock: FixedClock(testNow), MaxReportAge: tt.maxAge}
+			var got map[string]any
+			res := &VerificationResult{}
+			err := v.verifyToken(tt.token, iss, &got, res)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Errorf("verifyToken() error = %v, want %v", err, tt.wantIs)
+			}
+			if err == nil && (got["iss"] != iss.Issuer || res.TokenIssuer != iss.Issuer || res.TokenExpiry.IsZero()) {
+				t.Errorf("verifyToken() claims = %v, result = %+v", got, res)
+			}
+		})
+	}
+}
+
+func TestJWKSClient(t *testing.T) {
+	keys := newTestTokenKeys(t)
+	var fetches atomic.Int32
+	var down atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if down.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"keys": []any{testJWK("ec", &keys.ec.PublicKey)}})
+	}))
+	defer srv.Close()
+	old := JWKSet{"old": &keys.other.PublicKey}
+	known := JWKSet{"ec": &keys.ec.PublicKey}
+
+	tests := []struct {
+		name        string
+		keys        JWKSet
+		age         time.Duration
+		down        bool
+		kid         string
+		wantFetches int32
+		wantErr     bool
+	}{
+		{name: "first use", kid: "ec", wantFetches: 1},
+		{name: "cached", keys: known, age: time.Minute, kid: "ec"},
+		{name: "set too old", keys: known, age: 2 * time.Hour, kid: "ec", wantFetches: 1},
+		{name: "key rotated", keys: old, age: 2 * time.Minute, kid: "ec", wantFetches: 1},
+		{name: "unknown key refetched at most every minute", keys: old, age: 10 * time.Second, kid: "ec", wantErr: true},
+		{name: "key not published", kid: "gone", wantFetches: 1, wantErr: true},
+		{name: "service down keeps known keys", keys: known, age: 2 * time.Hour, down: true, kid: "ec", wantFetches: 1},
+		{name: "service down", down: true, kid: "ec", wantFetches: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetches.Store(0)
+			down.Store(tt.down)
+			c := &JWKSClient{URL: srv.URL, keys: tt.keys}
+			if tt.keys != nil {
+				c.fetched = time.Now().Add(-tt.age)
+			}
+			key, err := c.TokenKey(context.Background(), tt.kid)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TokenKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !keys.ec.PublicKey.Equal(key) {
+				t.Errorf("TokenKey() = %v", key)
+			}
+			if got := fetches.Load(); got != tt.wantFetches {
+				t.Errorf("key set fetched %d times, want %d", got, tt.wantFetches)
+			}
+		})
+	}
+}
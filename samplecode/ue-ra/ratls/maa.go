@@ -0,0 +1,65 @@
+package ratls
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// NewMAAIssuer returns the TokenIssuer of a Microsoft Azure Attestation
+// provider, e.g. "https://sharedeus.eus.attest.azure.net", whose signing
+// keys are fetched from its /certs endpoint.
+func NewMAAIssuer(instanceURL string) *TokenIssuer {
+	instanceURL = strings.TrimSuffix(instanceURL, "/")
+	return &TokenIssuer{
+		Issuer: instanceURL,
+		Keys:   &JWKSClient{URL: instanceURL + "/certs"},
+	}
+}
+
+// maaClaims are the SGX claims of an Azure Attestation token.
+type maaClaims struct {
+	AttestationType string `json:"x-ms-attestation-type"`
+	MrEnclave       string `json:"x-ms-sgx-mrenclave"`
+	MrSigner        string `json:"x-ms-sgx-mrsigner"`
+	ProductID       uint16 `json:"x-ms-sgx-product-id"`
+	SVN             uint16 `json:"x-ms-sgx-svn"`
+	Debuggable      *bool  `json:"x-ms-sgx-is-debuggable"`
+	ReportData      string `json:"x-ms-sgx-report-data"`
+	// EnclaveHeldData is the runtime data the enclave submitted, whose
+	// SHA-256 Azure Attestation checked against report_data.
+	EnclaveHeldData string `json:"x-ms-sgx-ehd"`
+}
+
+// verifyMAAToken checks an Azure Attestation token and takes the enclave
+// identity from its claims. The provider has verified the quote, so the
+// token is trusted as far as v.MAA is.
+func (v *Verifier) verifyMAAToken(token []byte, res *VerificationResult) error {
+	if v.MAA == nil {
+		return errors.New("no Azure Attestation provider configured")
+	}
+	var claims maaClaims
+	if err := v.verifyToken(string(token), v.MAA, &claims, res); err != nil {
+		return err
+	}
+	if claims.AttestationType != "sgx" {
+		return errors.New("attestation token does not describe an SGX enclave")
+	}
+	if claims.MrEnclave == "" || claims.MrSigner == "" || claims.Debuggable == nil {
+		return errors.New("attestation token lacks SGX enclave claims")
+	}
+	res.MrEnclave = strings.ToLower(claims.MrEnclave)
+	res.MrSigner = strings.ToLower(claims.MrSigner)
+	res.ISVProdID = claims.ProductID
+	res.ISVSVN = claims.SVN
+	res.Debug = *claims.Debuggable
+	res.ReportData = strings.ToLower(claims.ReportData)
+
+	// The enclave submits the key encoding as runtime data
+	ehd, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(claims.EnclaveHeldData, "="))
+	if err != nil {
+		return errors.New("malformed enclave held data in attestation token")
+	}
+	res.KeyBound = res.binds(ehd, true)
+	return v.checkNonce("", res)
+}
@@ -0,0 +1,78 @@
+package ratls
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testMAAClaims returns the claims of an Azure Attestation token for an
+// enclave that submitted ehd as runtime data.
+func testMAAClaims(iss string, ehd []byte) map[string]any {
+	return map[string]any{
+		"iss":                    iss,
+		"iat":                    testNow.Unix(),
+		"exp":                    testNow.Add(time.Hour).Unix(),
+		"x-ms-attestation-type":  "sgx",
+		"x-ms-sgx-mrenclave":     strings.Repeat("AA", 32),
+		"x-ms-sgx-mrsigner":      strings.Repeat("BB", 32),
+		"x-ms-sgx-product-id":    1,
+		"x-ms-sgx-svn":           2,
+		"x-ms-sgx-is-debuggable": false,
+		"x-ms-sgx-report-data":   strings.Repeat("CC", 64),
+		"x-ms-sgx-ehd":           base64.RawURLEncoding.EncodeToString(ehd),
+	}
+}
+
+func TestVerifyMAAToken(t *testing.T) {
+	keys := newTestTokenKeys(t)
+	maa := &TokenIssuer{Issuer: "https://shared.eus.attest.azure.net", Keys: keys.set()}
+	pubKey := []byte("certificate key")
+	token := func(edit func(map[string]any)) []byte {
+		c := testMAAClaims(maa.Issuer, pubKey)
+		if edit != nil {
+			edit(c)
+		}
+		return []byte(testJWT(t, map[string]any{"alg": "RS256", "kid": "rsa"}, c, keys.rsa))
+	}
+	tests := []struct {
+		name         string
+		issuer       *TokenIssuer
+		token        []byte
+		wantKeyBound bool
+		wantErr      bool
+	}{
+		{name: "bound key", issuer: maa, token: token(nil), wantKeyBound: true},
+		{name: "padded enclave held data", issuer: maa, token: token(func(c map[string]any) { c["x-ms-sgx-ehd"] = base64.URLEncoding.EncodeToString(pubKey) }), wantKeyBound: true},
+		{name: "other key", issuer: maa, token: token(func(c map[string]any) { c["x-ms-sgx-ehd"] = base64.RawURLEncoding.EncodeToString([]byte("other key")) })},
+		{name: "key prefix", issuer: maa, token: token(func(c map[string]any) { c["x-ms-sgx-ehd"] = base64.RawURLEncoding.EncodeToString(append(pubKey, 0)) })},
+		{name: "malformed enclave held data", issuer: maa, token: token(func(c map[string]any) { c["x-ms-sgx-ehd"] = "!" }), wantErr: true},
+		{name: "not SGX", issuer: maa, token: token(func(c map[string]any) { c["x-ms-attestation-type"] = "sevsnpvm" }), wantErr: true},
+		{name: "no MRSIGNER", issuer: maa, token: token(func(c map[string]any) { delete(c, "x-ms-sgx-mrsigner") }), wantErr: true},
+		{name: "no debug claim", issuer: maa, token: token(func(c map[string]any) { delete(c, "x-ms-sgx-is-debuggable") }), wantErr: true},
+		{name: "other provider", issuer: &TokenIssuer{Issuer: "https://other.attest.azure.net", Keys: keys.set()}, token: token(nil), wantErr: true},
+		{name: "no provider", token: token(nil), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Verifier{Mode: ModeMAA, MAA: tt.issuer, Clock: FixedClock(testNow)}
+			res := &VerificationResult{Mode: ModeMAA, PublicKey: pubKey}
+			err := v.verifyMAAToken(tt.token, res)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyMAAToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if res.KeyBound != tt.wantKeyBound {
+				t.Errorf("KeyBound = %v, want %v", res.KeyBound, tt.wantKeyBound)
+			}
+			want := fmt.Sprintf("%s %s %d %d", strings.Repeat("aa", 32), strings.Repeat("bb", 32), 1, 2)
+			if got := fmt.Sprintf("%s %s %d %d", res.MrEnclave, res.MrSigner, res.ISVProdID, res.ISVSVN); got != want || res.Debug {
+				t.Errorf("identity = %s debug %v, want %s", got, res.Debug, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package ratls
+
+import "time"
+
+// Metrics receives instrumentation events from a Verifier and a
+// CollateralCache, e.g. to export them to a monitoring system; the ratlsprom
+// module provides a Prometheus collector. It must be safe for concurrent
+// use.
+type Metrics interface {
+	// ObserveVerification is called after every verification with its
+	// result, which may be nil, its error and how long it took.
+	ObserveVerification(res *VerificationResult, err error, elapsed time.Duration)
+	// ObserveCollateralLookup is called for every collateral request served
+	// by a CollateralCache, hit telling whether it was cached.
+	ObserveCollateralLookup(hit bool)
+}
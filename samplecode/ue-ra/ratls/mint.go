@@ -0,0 +1,223 @@
+package ratls
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// DefaultMintedTokenLifetime is the lifetime of tokens issued by a
+// TokenMinter whose Lifetime is zero.
+const DefaultMintedTokenLifetime = 5 * time.Minute
+
+// MintedClaims are the claims of a token issued by a TokenMinter.
+type MintedClaims struct {
+	Issuer      string   `json:"iss"`
+	Audience    string   `json:"aud,omitempty"`
+	ID          string   `json:"jti"`
+	IssuedAt    int64    `json:"iat"`
+	Expiry      int64    `json:"exp"`
+	Mode        string   `json:"ratls_mode"`
+	MrEnclave   string   `json:"mr_enclave"`
+	MrSigner    string   `json:"mr_signer,omitempty"`
+	ISVProdID   uint16   `json:"isv_prod_id"`
+	ISVSVN      uint16   `json:"isv_svn"`
+	Debug       bool     `json:"debug"`
+	QuoteStatus string   `json:"quote_status,omitempty"`
+	TCBStatus   string   `json:"tcb_status,omitempty"`
+	AdvisoryIDs []string `json:"advisory_ids,omitempty"`
+	// TestRoots marks results verified against insecure test roots.
+	TestRoots bool `json:"insecure_test_roots,omitempty"`
+	// Simulated marks enclaves accepted in simulation mode.
+	Simulated bool `json:"simulated,omitempty"`
+}
+
+// TokenMinter issues short-lived JWTs asserting the verified identity of an
+// enclave, so that services behind an RA-TLS gateway can authorize requests
+// on it without verifying quotes themselves. They verify the tokens with
+// the key set returned by JWKS, e.g. with ParseMintedToken.
+type TokenMinter struct {
+	// Issuer is the iss claim of the tokens.
+	Issuer string
+	// Audience, if set, is the aud claim of the tokens.
+	Audience string
+	// Key signs the tokens: an *ecdsa.PrivateKey on P-256 (ES256) or P-384
+	// (ES384), or an *rsa.PrivateKey (RS256).
+	Key crypto.Signer
+	// KeyID is the kid header of the tokens.
+	KeyID string
+	// Lifetime is how long a token is valid, DefaultMintedTokenLifetime if
+	// zero. Tokens do not outlive the attestation token a result was
+	// verified from.
+	Lifetime time.Duration
+	// Clock, if set, is used instead of the system time.
+	Clock Clock
+}
+
+func (m *TokenMinter) now() time.Time {
+	if m.Clock != nil {
+		return m.Clock.Now()
+	}
+	return time.Now()
+}
+
+// alg returns the JWS algorithm of the key.
+func (m *TokenMinter) alg() (string, error) {
+	switch key := m.Key.(type) {
+	case *ecdsa.PrivateKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return "ES256", nil
+		case elliptic.P384():
+			return "ES384", nil
+		}
+	case *rsa.PrivateKey:
+		return "RS256", nil
+	}
+	return "", fmt.Errorf("unsupported token signing key %T", m.Key)
+}
+
+// Mint returns a signed token carrying the enclave identity and platform
+// status of res, which must be an accepted verification result.
+func (m *TokenMinter) Mint(res *VerificationResult) (string, error) {
+	if res == nil || res.MrEnclave == "" {
+		return "", errors.New("no verified enclave identity")
+	}
+	alg, err := m.alg()
+	if err != nil {
+		return "", err
+	}
+	var jti [16]byte
+	if _, err := rand.Read(jti[:]); err != nil {
+		return "", err
+	}
+	now := m.now()
+	lifetime := m.Lifetime
+	if lifetime == 0 {
+		lifetime = DefaultMintedTokenLifetime
+	}
+	expiry := now.Add(lifetime)
+	if !res.TokenExpiry.IsZero() && res.TokenExpiry.Before(expiry) {
+		expiry = res.TokenExpiry
+	}
+	claims := MintedClaims{
+		Issuer:      m.Issuer,
+		Audience:    m.Audience,
+		ID:          hex.EncodeToString(jti[:]),
+		IssuedAt:    now.Unix(),
+		Expiry:      expiry.Unix(),
+		Mode:        res.Mode.String(),
+		MrEnclave:   res.MrEnclave,
+		MrSigner:    res.MrSigner,
+		ISVProdID:   res.ISVProdID,
+		ISVSVN:      res.ISVSVN,
+		Debug:       res.Debug,
+		QuoteStatus: res.QuoteStatus,
+		TCBStatus:   res.TCBStatus,
+		AdvisoryIDs: res.AdvisoryIDs,
+		TestRoots:   res.InsecureTestRoots,
+		Simulated:   res.Simulated,
+	}
+	header, err := json.Marshal(map[string]string{"alg": alg, "typ": "JWT", "kid": m.KeyID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(&claims)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sig, err := m.sign(alg, []byte(signed))
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// sign signs the signing input of a token. ECDSA signatures are encoded as
+// the fixed size concatenation of r and s (RFC 7518 section 3.4).
+func (m *TokenMinter) sign(alg string, signed []byte) ([]byte, error) {
+	hash := crypto.SHA256
+	if alg == "ES384" {
+		hash = crypto.SHA384
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+	key, ok := m.Key.(*ecdsa.PrivateKey)
+	if !ok {
+		return m.Key.Sign(rand.Reader, digest, hash)
+	}
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+	if err != nil {
+		return nil, err
+	}
+	size := (key.Curve.Params().BitSize + 7) / 8
+	sig := make([]byte, 2*size)
+	r.FillBytes(sig[:size])
+	s.FillBytes(sig[size:])
+	return sig, nil
+}
+
+// JWKS returns the JSON Web Key Set publishing the public key of m, to be
+// served to the services consuming its tokens.
+func (m *TokenMinter) JWKS() ([]byte, error) {
+	alg, err := m.alg()
+	if err != nil {
+		return nil, err
+	}
+	k := map[string]string{"kid": m.KeyID, "use": "sig", "alg": alg}
+	switch pub := m.Key.Public().(type) {
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		k["kty"], k["crv"] = "EC", pub.Curve.Params().Name
+		k["x"] = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+		k["y"] = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+	case *rsa.PublicKey:
+		k["kty"] = "RSA"
+		k["n"] = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		k["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	}
+	return json.Marshal(map[string]any{"keys": []any{k}})
+}
+
+// ParseMintedToken verifies the signature, issuer and expiry of a token
+// issued by a TokenMinter against iss, whose Keys are typically the minter's
+// published JWKS, and returns its claims.
+func ParseMintedToken(token string, iss *TokenIssuer, now time.Time) (*MintedClaims, error) {
+	t, err := parseJWT(token)
+	if err != nil {
+		return nil, err
+	}
+	key, err := iss.Keys.TokenKey(context.Background(), t.kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.verifySignature(key); err != nil {
+		return nil, failure(ErrBadSignature, err)
+	}
+	var claims MintedClaims
+	if err := json.Unmarshal(t.payload, &claims); err != nil {
+		return nil, fmt.Errorf("attestation token claims: %v", err)
+	}
+	if claims.Issuer != iss.Issuer {
+		return nil, fmt.Errorf("attestation token issued by %q, want %q", claims.Issuer, iss.Issuer)
+	}
+	if claims.MrEnclave == "" {
+		return nil, errors.New("attestation token carries no enclave identity")
+	}
+	if now.After(time.Unix(claims.Expiry, 0).Add(tokenLeeway)) {
+		return nil, failuref(ErrStaleReport, "attestation token expired at %v", time.Unix(claims.Expiry, 0))
+	}
+	return &claims, nil
+}
@@ -0,0 +1,126 @@
+package ratls
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMint(t *testing.T) {
+	keys := newTestTokenKeys(t)
+	p521, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enclave := &VerificationResult{Mode: ModeDCAP, MrEnclave: "aa", MrSigner: "bb", ISVSVN: 2, TCBStatus: "UpToDate"}
+	tests := []struct {
+		name       string
+		key        crypto.Signer
+		lifetime   time.Duration
+		res        *VerificationResult
+		wantExpiry time.Time
+		wantErr    bool
+	}{
+		{name: "ES256", key: keys.ec, res: enclave, wantExpiry: testNow.Add(DefaultMintedTokenLifetime)},
+		{name: "ES384", key: keys.p384, res: enclave, wantExpiry: testNow.Add(DefaultMintedTokenLifetime)},
+		{name: "RS256", key: keys.rsa, res: enclave, wantExpiry: testNow.Add(DefaultMintedTokenLifetime)},
+		{name: "lifetime", key: keys.ec, lifetime: time.Hour, res: enclave, wantExpiry: testNow.Add(time.Hour)},
+		{
+			name:       "attestation token expires first",
+			key:        keys.ec,
+			res:        &VerificationResult{Mode: ModeMAA, MrEnclave: "aa", TokenExpiry: testNow.Add(time.Minute)},
+			wantExpiry: testNow.Add(time.Minute),
+		},
+		{name: "P-521", key: p521, res: enclave, wantErr: true},
+		{name: "Ed25519", key: edKey, res: enclave, wantErr: true},
+		{name: "no enclave identity", key: keys.ec, res: &VerificationResult{Mode: ModeDCAP}, wantErr: true},
+		{name: "no result", key: keys.ec, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &TokenMinter{Issuer: "gateway", Audience: "backend", Key: tt.key, KeyID: "minter", Lifetime: tt.lifetime, Clock: FixedClock(testNow)}
+			token, err := m.Mint(tt.res)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Mint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			jwks, err := m.JWKS()
+			if err != nil {
+				t.Fatal(err)
+			}
+			set, err := ParseJWKS(jwks)
+			if err != nil {
+				t.Fatalf("ParseJWKS(JWKS()) error = %v", err)
+			}
+			claims, err := ParseMintedToken(token, &TokenIssuer{Issuer: "gateway", Keys: set}, testNow)
+			if err != nil {
+				t.Fatalf("ParseMintedToken() error = %v", err)
+			}
+			if claims.MrEnclave != tt.res.MrEnclave || claims.Mode != tt.res.Mode.String() || claims.Audience != "backend" || len(claims.ID) != 32 {
+				t.Errorf("ParseMintedToken() = %+v", claims)
+			}
+			if claims.IssuedAt != testNow.Unix() || claims.Expiry != tt.wantExpiry.Unix() {
+				t.Errorf("iat, exp = %d, %d, want %d, %d", claims.IssuedAt, claims.Expiry, testNow.Unix(), tt.wantExpiry.Unix())
+			}
+		})
+	}
+}
+
+func TestParseMintedToken(t *testing.T) {
+	keys := newTestTokenKeys(t)
+	m := &TokenMinter{Issuer: "gateway", Key: keys.ec, KeyID: "ec", Clock: FixedClock(testNow)}
+	token, err := m.Mint(&VerificationResult{Mode: ModeDCAP, MrEnclave: "aa"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gateway := &TokenIssuer{Issuer: "gateway", Keys: keys.set()}
+	header := map[string]any{"alg": "ES256", "kid": "ec"}
+	// tampered carries the signature of token over other claims
+	other := strings.Split(testJWT(t, header, map[string]any{"iss": "gateway", "mr_enclave": "bb", "exp": testNow.Unix()}, keys.ec), ".")
+	tampered := other[0] + "." + other[1] + "." + strings.Split(token, ".")[2]
+	tests := []struct {
+		name    string
+		token   string
+		issuer  *TokenIssuer
+		now     time.Time
+		wantErr bool
+		wantIs  error
+	}{
+		{name: "minted", token: token, issuer: gateway, now: testNow},
+		{name: "within leeway", token: token, issuer: gateway, now: testNow.Add(DefaultMintedTokenLifetime + tokenLeeway)},
+		{name: "expired", token: token, issuer: gateway, now: testNow.Add(DefaultMintedTokenLifetime + tokenLeeway + time.Second), wantErr: true, wantIs: ErrStaleReport},
+		{name: "other issuer", token: token, issuer: &TokenIssuer{Issuer: "other", Keys: keys.set()}, now: testNow, wantErr: true},
+		{name: "other key", token: token, issuer: &TokenIssuer{Issuer: "gateway", Keys: JWKSet{"ec": &keys.other.PublicKey}}, now: testNow, wantErr: true, wantIs: ErrBadSignature},
+		{name: "unknown key ID", token: token, issuer: &TokenIssuer{Issuer: "gateway", Keys: JWKSet{}}, now: testNow, wantErr: true},
+		{name: "tampered claims", token: tampered, issuer: gateway, now: testNow, wantErr: true, wantIs: ErrBadSignature},
+		{name: "no enclave identity", token: testJWT(t, header, map[string]any{"iss": "gateway", "exp": testNow.Unix()}, keys.ec), issuer: gateway, now: testNow, wantErr: true},
+		{name: "malformed claims", token: testJWT(t, header, []string{"iss"}, keys.ec), issuer: gateway, now: testNow, wantErr: true},
+		{name: "not a token", token: "token", issuer: gateway, now: testNow, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := ParseMintedToken(tt.token, tt.issuer, tt.now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMintedToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Errorf("ParseMintedToken() error = %v, want %v", err, tt.wantIs)
+			}
+			if err == nil && claims.MrEnclave != "aa" {
+				t.Errorf("ParseMintedToken() MrEnclave = %s, want aa", claims.MrEnclave)
+			}
+		})
+	}
+}
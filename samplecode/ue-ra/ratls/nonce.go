@@ -0,0 +1,72 @@
+package ratls
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// NonceSize is the length of nonces returned by NewNonce. It fits both the
+// 32 character IAS nonce field (hex encoded) and the upper half of
+// report_data.
+const NonceSize = 16
+
+// nonceProtocolPrefix marks the ALPN protocol carrying the client challenge.
+const nonceProtocolPrefix = "ratls-nonce/"
+
+// NewNonce returns a fresh random challenge.
+func NewNonce() ([]byte, error) {
+	nonce := make([]byte, NonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return nonce, nil
+}
+
+// NonceProtocol encodes nonce as an ALPN protocol name. Adding it to
+// tls.Config.NextProtos delivers the challenge to the enclave in the
+// ClientHello, before it generates the certificate for the connection.
+func NonceProtocol(nonce []byte) string {
+	return nonceProtocolPrefix + hex.EncodeToString(nonce)
+}
+
+// NonceFromProtocols returns the nonce carried in a list of ALPN protocols
+// offered by a client, or nil if there is none.
+func NonceFromProtocols(protos []string) []byte {
+	for _, p := range protos {
+		if !strings.HasPrefix(p, nonceProtocolPrefix) {
+			continue
+		}
+		if nonce, err := hex.DecodeString(p[len(nonceProtocolPrefix):]); err == nil {
+			return nonce
+		}
+	}
+	return nil
+}
+
+// checkNonce accepts the evidence if the expected nonce is echoed either in
+// the IAS nonce field or in report_data starting at byte 32. The latter
+// needs a key binding of at most 32 bytes: the raw P-256 key of
+// BindingKey fills report_data, leaving no room for a nonce. Attestation
+// tokens bind the key in their runtime data instead.
+func (v *Verifier) checkNonce(iasNonce string, res *VerificationResult) error {
+	// BindingKeyNonceHash covers the nonce with the key binding, unless
+	// the evidence bound something else, such as a TLS channel
+	if len(v.Nonce) == 0 || res.nonceBound {
+		return nil
+	}
+	if iasNonce != "" && equalBytes([]byte(iasNonce), []byte(hex.EncodeToString(v.Nonce))) {
+		return nil
+	}
+	bindsReportData := res.Mode != ModeMAA && res.Mode != ModeITA
+	if bindsReportData && res.KeyBound && len(res.PublicKey) > 32 {
+		return failure(ErrStaleReport, errors.New("the key binding fills report_data, leaving no room for the nonce; use BindingKeyNonceHash"))
+	}
+	reportData, err := hex.DecodeString(res.ReportData)
+	if err == nil && len(v.Nonce) <= 32 && len(reportData) == 64 &&
+		hasPrefixBytes(reportData[32:], v.Nonce) {
+		return nil
+	}
+	return failure(ErrStaleReport, errors.New("attestation evidence does not reflect the nonce"))
+}
@@ -0,0 +1,90 @@
+package ratls
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestCheckNonce(t *testing.T) {
+	nonce := bytes.Repeat([]byte{0xab}, NonceSize)
+	keyHash := bytes.Repeat([]byte{0x01}, 32)
+	rawKey := bytes.Repeat([]byte{0x02}, 64)
+	reportData := func(parts ...[]byte) string {
+		data := make([]byte, 64)
+		offset := 0
+		for _, p := range parts {
+			offset += copy(data[offset:], p)
+		}
+		return hex.EncodeToString(data)
+	}
+
+	tests := []struct {
+		name     string
+		verifier Verifier
+		iasNonce string
+		res      VerificationResult
+		wantErr  bool
+	}{
+		{name: "no challenge", verifier: Verifier{}, res: VerificationResult{Mode: ModeDCAP}},
+		{name: "IAS nonce", verifier: Verifier{Nonce: nonce}, iasNonce: hex.EncodeToString(nonce), res: VerificationResult{Mode: ModeEPID}},
+		{name: "other IAS nonce", verifier: Verifier{Nonce: nonce}, iasNonce: hex.EncodeToString(keyHash[:NonceSize]), res: VerificationResult{Mode: ModeEPID, ReportData: reportData(keyHash)}, wantErr: true},
+		{
+			name:     "nonce after a 32 byte binding",
+			verifier: Verifier{Nonce: nonce, Binding: BindingSPKIHash},
+			res:      VerificationResult{Mode: ModeDCAP, KeyBound: true, PublicKey: keyHash, ReportData: reportData(keyHash, nonce)},
+		},
+		{
+			name:     "nonce missing",
+			verifier: Verifier{Nonce: nonce, Binding: BindingSPKIHash},
+			res:      VerificationResult{Mode: ModeDCAP, KeyBound: true, PublicKey: keyHash, ReportData: reportData(keyHash)},
+			wantErr:  true,
+		},
+		{
+			name:     "raw key fills report_data",
+			verifier: Verifier{Nonce: nonce, Binding: BindingKey},
+			res:      VerificationResult{Mode: ModeDCAP, KeyBound: true, PublicKey: rawKey, ReportData: reportData(rawKey)},
+			wantErr:  true,
+		},
+		{
+			name:     "raw key fills TDX report_data",
+			verifier: Verifier{Nonce: nonce, Binding: BindingKey},
+			res:      VerificationResult{Mode: ModeTDX, KeyBound: true, PublicKey: rawKey, ReportData: reportData(rawKey)},
+			wantErr:  true,
+		},
+		{
+			name:     "raw key in a token",
+			verifier: Verifier{Nonce: nonce, Binding: BindingKey},
+			res:      VerificationResult{Mode: ModeMAA, KeyBound: true, PublicKey: rawKey, ReportData: reportData(keyHash, nonce)},
+		},
+		{
+			name:     "key and nonce hashed together",
+			verifier: Verifier{Nonce: nonce, Binding: BindingKeyNonceHash},
+			res:      VerificationResult{Mode: ModeDCAP, KeyBound: true, PublicKey: keyHash, ReportData: reportData(keyHash), nonceBound: true},
+		},
+		{
+			name:     "nonce hash binding without the nonce hashed in",
+			verifier: Verifier{Nonce: nonce, Binding: BindingKeyNonceHash},
+			res:      VerificationResult{Mode: ModeDCAP, KeyBound: true, PublicKey: keyHash, ReportData: reportData(keyHash)},
+			wantErr:  true,
+		},
+		{
+			name:     "nonce too long for report_data",
+			verifier: Verifier{Nonce: bytes.Repeat([]byte{0xab}, 33), Binding: BindingSPKIHash},
+			res:      VerificationResult{Mode: ModeDCAP, KeyBound: true, PublicKey: keyHash, ReportData: reportData(keyHash, nonce)},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.verifier.checkNonce(tt.iasNonce, &tt.res)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkNonce() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrStaleReport) {
+				t.Errorf("checkNonce() error = %v, want %v", err, ErrStaleReport)
+			}
+		})
+	}
+}
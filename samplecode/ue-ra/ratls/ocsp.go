@@ -0,0 +1,337 @@
+package ratls
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+// OCSPChecker checks the IAS report signing certificate chain for
+// revocation with OCSP (RFC 6960), falling back to the CRLs of the
+// distribution points of a certificate when no OCSP status can be
+// obtained.
+type OCSPChecker struct {
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// Stapled holds DER OCSP responses obtained in advance, e.g. stapled
+	// by the server or fetched by a cron job for offline verification. A
+	// current response covering a certificate is used instead of querying
+	// its responder.
+	Stapled [][]byte
+	// Policy selects whether a certificate whose status can be established
+	// neither by OCSP nor by CRL is rejected. The default is soft-fail.
+	Policy RevocationPolicy
+}
+
+var (
+	oidOCSPBasic   = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+	oidSHA1        = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	errNoOCSPState = errors.New("no OCSP status available")
+	errNoStatus    = errors.New("no valid OCSP response or CRL available")
+)
+
+type ocspCertID struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	NameHash      []byte
+	IssuerKeyHash []byte
+	SerialNumber  *big.Int
+}
+
+type ocspRequest struct {
+	TBSRequest ocspTBSRequest
+}
+
+type ocspTBSRequest struct {
+	Version     int `asn1:"explicit,tag:0,default:0,optional"`
+	RequestList []ocspSingleRequest
+}
+
+type ocspSingleRequest struct {
+	Cert ocspCertID
+}
+
+type ocspResponse struct {
+	Status   asn1.Enumerated
+	Response ocspResponseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type ocspBasicResponse struct {
+	TBSResponseData    ocspResponseData
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseData struct {
+	Raw                asn1.RawContent
+	Version            int `asn1:"optional,default:0,explicit,tag:0"`
+	RawResponderID     asn1.RawValue
+	ProducedAt         time.Time `asn1:"generalized"`
+	Responses          []ocspSingleResponse
+	ResponseExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type ocspSingleResponse struct {
+	CertID           ocspCertID
+	Good             asn1.Flag        `asn1:"tag:0,optional"`
+	Revoked          ocspRevokedInfo  `asn1:"tag:1,optional"`
+	Unknown          asn1.Flag        `asn1:"tag:2,optional"`
+	ThisUpdate       time.Time        `asn1:"generalized"`
+	NextUpdate       time.Time        `asn1:"generalized,explicit,tag:0,optional"`
+	SingleExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type ocspRevokedInfo struct {
+	RevocationTime time.Time       `asn1:"generalized"`
+	Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+}
+
+// newOCSPCertID identifies cert, issued by issuer, with SHA-1 hashes as
+// responders universally support.
+func newOCSPCertID(cert, issuer *x509.Certificate) (ocspCertID, error) {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return ocspCertID{}, err
+	}
+	nameHash := sha1.Sum(issuer.RawSubject)
+	keyHash := sha1.Sum(spki.PublicKey.RightAlign())
+	return ocspCertID{
+		HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA1, Parameters: asn1.NullRawValue},
+		NameHash:      nameHash[:],
+		IssuerKeyHash: keyHash[:],
+		SerialNumber:  cert.SerialNumber,
+	}, nil
+}
+
+func (id *ocspCertID) matches(other *ocspCertID) bool {
+	return id.HashAlgorithm.Algorithm.Equal(other.HashAlgorithm.Algorithm) &&
+		bytes.Equal(id.NameHash, other.NameHash) &&
+		bytes.Equal(id.IssuerKeyHash, other.IssuerKeyHash) &&
+		id.SerialNumber.Cmp(other.SerialNumber) == 0
+}
+
+// check checks every certificate of the verified chain, leaf first,
+// against its issuer.
+func (c *OCSPChecker) check(chain []*x509.Certificate, now time.Time) error {
+	for i := 0; i+1 < len(chain); i++ {
+		cert, issuer := chain[i], chain[i+1]
+		err := c.checkCert(cert, issuer, now)
+		if err == errNoStatus && c.Policy == RevocationSoftFail {
+			continue
+		}
+		if errors.Is(err, ErrRevoked) {
+			return fmt.Errorf("report signing certificate %q: %w", cert.Subject.CommonName, err)
+		}
+		if err != nil {
+			return failuref(ErrBadSignature, "report signing certificate %q: %v", cert.Subject.CommonName, err)
+		}
+	}
+	return nil
+}
+
+// checkCert establishes the status of cert from a stapled response, its
+// OCSP responders and then its CRL distribution points. errNoStatus means
+// that none of them gave one.
+func (c *OCSPChecker) checkCert(cert, issuer *x509.Certificate, now time.Time) error {
+	id, err := newOCSPCertID(cert, issuer)
+	if err != nil {
+		return err
+	}
+	for _, raw := range c.Stapled {
+		if err := checkOCSPResponse(raw, &id, issuer, now); err != errNoOCSPState {
+			return err
+		}
+	}
+	for _, url := range cert.OCSPServer {
+		raw, err := c.queryOCSP(url, &id)
+		if err != nil {
+			continue
+		}
+		if err := checkOCSPResponse(raw, &id, issuer, now); err != errNoOCSPState {
+			return err
+		}
+	}
+	var crls []*x509.RevocationList
+	for _, url := range cert.CRLDistributionPoints {
+		raw, err := c.get(url)
+		if err != nil {
+			continue
+		}
+		if crl, err := ParseCRL(raw); err == nil {
+			crls = append(crls, crl)
+		}
+	}
+	if err := checkCRL(cert, issuer, crls, now); err != errCRLUnavailable {
+		return err
+	}
+	return errNoStatus
+}
+
+// queryOCSP posts a request for id to the responder at url.
+func (c *OCSPChecker) queryOCSP(url string, id *ocspCertID) ([]byte, error) {
+	body, err := asn1.Marshal(ocspRequest{TBSRequest: ocspTBSRequest{RequestList: []ocspSingleRequest{{Cert: *id}}}})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+	return c.do(req)
+}
+
+func (c *OCSPChecker) get(url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(req)
+}
+
+func (c *OCSPChecker) do(req *http.Request) ([]byte, error) {
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s: %s", req.Method, req.URL, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// checkOCSPResponse returns nil if the DER OCSP response raw reports the
+// certificate id as good, an error if it reports it revoked, and
+// errNoOCSPState if it does not establish its current status: it is
+// malformed, unsuccessful, not signed for issuer, out of date or about
+// another certificate.
+func checkOCSPResponse(raw []byte, id *ocspCertID, issuer *x509.Certificate, now time.Time) error {
+	var resp ocspResponse
+	if rest, err := asn1.Unmarshal(raw, &resp); err != nil || len(rest) != 0 {
+		return errNoOCSPState
+	}
+	if resp.Status != 0 || !resp.Response.ResponseType.Equal(oidOCSPBasic) {
+		return errNoOCSPState
+	}
+	var basic ocspBasicResponse
+	if _, err := asn1.Unmarshal(resp.Response.Response, &basic); err != nil {
+		return errNoOCSPState
+	}
+	if verifyOCSPSignature(&basic, issuer, now) != nil {
+		return errNoOCSPState
+	}
+	for i := range basic.TBSResponseData.Responses {
+		single := &basic.TBSResponseData.Responses[i]
+		if !single.CertID.matches(id) {
+			continue
+		}
+		if now.Before(single.ThisUpdate) || !single.NextUpdate.IsZero() && now.After(single.NextUpdate) {
+			return errNoOCSPState
+		}
+		switch {
+		case bool(single.Good):
+			return nil
+		case !single.Revoked.RevocationTime.IsZero():
+			return failuref(ErrRevoked, "revoked at %v", single.Revoked.RevocationTime)
+		}
+		return errNoOCSPState
+	}
+	return errNoOCSPState
+}
+
+// verifyOCSPSignature checks that the response is signed by issuer or by a
+// responder certificate that issuer delegated OCSP signing to.
+func verifyOCSPSignature(basic *ocspBasicResponse, issuer *x509.Certificate, now time.Time) error {
+	algo, ok := signatureAlgorithms[basic.SignatureAlgorithm.Algorithm.String()]
+	if !ok {
+		return fmt.Errorf("unsupported OCSP signature algorithm %v", basic.SignatureAlgorithm.Algorithm)
+	}
+	signer := issuer
+	if !responderIs(basic.TBSResponseData.RawResponderID, issuer) {
+		if len(basic.Certificates) == 0 {
+			return errors.New("OCSP response signed by an unknown responder")
+		}
+		responder, err := x509.ParseCertificate(basic.Certificates[0].FullBytes)
+		if err != nil {
+			return err
+		}
+		if err := responder.CheckSignatureFrom(issuer); err != nil {
+			return err
+		}
+		if now.Before(responder.NotBefore) || now.After(responder.NotAfter) {
+			return errors.New("OCSP responder certificate is not valid")
+		}
+		delegated := false
+		for _, usage := range responder.ExtKeyUsage {
+			delegated = delegated || usage == x509.ExtKeyUsageOCSPSigning
+		}
+		if !delegated {
+			return errors.New("OCSP responder certificate is not authorized")
+		}
+		signer = responder
+	}
+	return signer.CheckSignature(algo, basic.TBSResponseData.Raw, basic.Signature.RightAlign())
+}
+
+// responderIs reports whether the ResponderID id names cert, by name
+// ([1]) or by the SHA-1 hash of its key ([2]).
+func responderIs(id asn1.RawValue, cert *x509.Certificate) bool {
+	if id.Class != asn1.ClassContextSpecific {
+		return false
+	}
+	switch id.Tag {
+	case 1:
+		return bytes.Equal(id.Bytes, cert.RawSubject)
+	case 2:
+		var keyHash []byte
+		if _, err := asn1.Unmarshal(id.Bytes, &keyHash); err != nil {
+			return false
+		}
+		var spki struct {
+			Algorithm pkix.AlgorithmIdentifier
+			PublicKey asn1.BitString
+		}
+		if _, err := asn1.Unmarshal(cert.RawSubjectPublicKeyInfo, &spki); err != nil {
+			return false
+		}
+		digest := sha1.Sum(spki.PublicKey.RightAlign())
+		return bytes.Equal(keyHash, digest[:])
+	}
+	return false
+}
+
+// signatureAlgorithms maps the signature algorithm OIDs of OCSP responses
+// to their x509 counterparts.
+var signatureAlgorithms = map[string]x509.SignatureAlgorithm{
+	"1.2.840.113549.1.1.5":  x509.SHA1WithRSA,
+	"1.2.840.113549.1.1.11": x509.SHA256WithRSA,
+	"1.2.840.113549.1.1.12": x509.SHA384WithRSA,
+	"1.2.840.113549.1.1.13": x509.SHA512WithRSA,
+	"1.2.840.10045.4.3.2":   x509.ECDSAWithSHA256,
+	"1.2.840.10045.4.3.3":   x509.ECDSAWithSHA384,
+	"1.2.840.10045.4.3.4":   x509.ECDSAWithSHA512,
+	"1.3.101.112":           x509.PureEd25519,
+}
@@ -0,0 +1,288 @@
+package ratls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+
+// testOCSP describes an OCSP response of the tests.
+type testOCSP struct {
+	id     ocspCertID
+	single ocspSingleResponse
+	// key signs the response, named by responderID.
+	key         *ecdsa.PrivateKey
+	responderID asn1.RawValue
+	// responder, if set, is sent along as the delegated responder
+	// certificate.
+	responder *x509.Certificate
+	status    asn1.Enumerated
+}
+
+// der returns the DER OCSP response of r.
+func (r *testOCSP) der(t *testing.T) []byte {
+	t.Helper()
+	single := r.single
+	single.CertID = r.id
+	tbs, err := asn1.Marshal(ocspResponseData{
+		RawResponderID: r.responderID,
+		ProducedAt:     testNow.UTC(),
+		Responses:      []ocspSingleResponse{single},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(tbs)
+	sig, err := ecdsa.SignASN1(rand.Reader, r.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	basic := ocspBasicResponse{
+		TBSResponseData:    ocspResponseData{Raw: tbs},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256},
+		Signature:          asn1.BitString{Bytes: sig, BitLength: 8 * len(sig)},
+	}
+	if r.responder != nil {
+		basic.Certificates = []asn1.RawValue{{FullBytes: r.responder.Raw}}
+	}
+	basicDER, err := asn1.Marshal(basic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := ocspResponse{Status: r.status}
+	if r.status == 0 {
+		resp.Response = ocspResponseBytes{ResponseType: oidOCSPBasic, Response: basicDER}
+	}
+	der, err := asn1.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+// byName is the ResponderID naming cert.
+func byName(cert *x509.Certificate) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 1, IsCompound: true, Bytes: cert.RawSubject}
+}
+
+// newTestResponder returns a responder certificate issued by parent, with
+// the OCSP signing extended key usage if ocspSigning is set.
+func newTestResponder(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, ocspSigning bool) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(7),
+		Subject:      pkix.Name{CommonName: "OCSP Responder"},
+		NotBefore:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if ocspSigning {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func TestCheckOCSPResponse(t *testing.T) {
+	ca, caKey := newTestCert(t, "Report Signing CA", true, nil, nil)
+	leaf, _ := newTestCert(t, "Report Signing", false, ca, caKey)
+	other, _ := newTestCert(t, "Report Signing", false, ca, caKey)
+	id, err := newOCSPCertID(leaf, ca)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherID, err := newOCSPCertID(other, ca)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherKey := newTestCert(t, "Report Signing CA", true, nil, nil)
+	delegated, delegatedKey := newTestResponder(t, ca, caKey, true)
+	undelegated, undelegatedKey := newTestResponder(t, ca, caKey, false)
+	spkiHash := func() asn1.RawValue {
+		var spki struct {
+			Algorithm pkix.AlgorithmIdentifier
+			PublicKey asn1.BitString
+		}
+		if _, err := asn1.Unmarshal(ca.RawSubjectPublicKeyInfo, &spki); err != nil {
+			t.Fatal(err)
+		}
+		digest := sha1.Sum(spki.PublicKey.RightAlign())
+		octets, err := asn1.Marshal(digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, IsCompound: true, Bytes: octets}
+	}()
+	good := ocspSingleResponse{Good: true, ThisUpdate: testNow.Add(-time.Hour).UTC(), NextUpdate: testNow.Add(time.Hour).UTC()}
+	response := func(edit func(r *testOCSP)) []byte {
+		r := &testOCSP{id: id, single: good, key: caKey, responderID: byName(ca)}
+		if edit != nil {
+			edit(r)
+		}
+		return r.der(t)
+	}
+
+	tests := []struct {
+		name    string
+		raw     []byte
+		wantErr error
+	}{
+		{name: "good", raw: response(nil)},
+		{name: "responder by key hash", raw: response(func(r *testOCSP) { r.responderID = spkiHash })},
+		{
+			name: "delegated responder",
+			raw: response(func(r *testOCSP) {
+				r.key, r.responderID, r.responder = delegatedKey, byName(delegated), delegated
+			}),
+		},
+		{
+			name: "revoked",
+			raw: response(func(r *testOCSP) {
+				r.single = ocspSingleResponse{Revoked: ocspRevokedInfo{RevocationTime: testNow.Add(-2 * time.Hour).UTC()}, ThisUpdate: good.ThisUpdate}
+			}),
+			wantErr: ErrRevoked,
+		},
+		{name: "unknown", raw: response(func(r *testOCSP) { r.single = ocspSingleResponse{Unknown: true, ThisUpdate: good.ThisUpdate} }), wantErr: errNoOCSPState},
+		{name: "other certificate", raw: response(func(r *testOCSP) { r.id = otherID }), wantErr: errNoOCSPState},
+		{name: "out of date", raw: response(func(r *testOCSP) { r.single.NextUpdate = testNow.Add(-time.Minute).UTC() }), wantErr: errNoOCSPState},
+		{name: "not yet valid", raw: response(func(r *testOCSP) { r.single.ThisUpdate = testNow.Add(time.Minute).UTC() }), wantErr: errNoOCSPState},
+		{name: "other signer", raw: response(func(r *testOCSP) { r.key = otherKey }), wantErr: errNoOCSPState},
+		{
+			name: "responder not authorized",
+			raw: response(func(r *testOCSP) {
+				r.key, r.responderID, r.responder = undelegatedKey, byName(undelegated), undelegated
+			}),
+			wantErr: errNoOCSPState,
+		},
+		{name: "unknown responder", raw: response(func(r *testOCSP) { r.responderID = byName(leaf) }), wantErr: errNoOCSPState},
+		{name: "unsuccessful", raw: response(func(r *testOCSP) { r.status = 3 }), wantErr: errNoOCSPState},
+		{name: "malformed", raw: []byte("response"), wantErr: errNoOCSPState},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkOCSPResponse(tt.raw, &id, ca, testNow)
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkOCSPResponse() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOCSPChecker(t *testing.T) {
+	ca, caKey := newTestCert(t, "Report Signing CA", true, nil, nil)
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// newLeaf issues a report signing certificate pointing to the OCSP
+	// responder and CRL distribution point at path of srv, if set.
+	newLeaf := func(ocspPath, crlPath string) *x509.Certificate {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber: serial,
+			Subject:      pkix.Name{CommonName: "Report Signing"},
+			NotBefore:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+			NotAfter:     time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC),
+		}
+		if ocspPath != "" {
+			tmpl.OCSPServer = []string{srv.URL + ocspPath}
+		}
+		if crlPath != "" {
+			tmpl.CRLDistributionPoints = []string{srv.URL + crlPath}
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	response := func(cert *x509.Certificate, single ocspSingleResponse) []byte {
+		id, err := newOCSPCertID(cert, ca)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := &testOCSP{id: id, single: single, key: caKey, responderID: byName(ca)}
+		return r.der(t)
+	}
+	good := ocspSingleResponse{Good: true, ThisUpdate: testNow.Add(-time.Hour).UTC(), NextUpdate: testNow.Add(time.Hour).UTC()}
+	revoked := ocspSingleResponse{Revoked: ocspRevokedInfo{RevocationTime: testNow.Add(-time.Hour).UTC()}, ThisUpdate: good.ThisUpdate}
+
+	goodByResponder := newLeaf("/ocsp/good", "")
+	mux.HandleFunc("/ocsp/good", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/ocsp-request" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Write(response(goodByResponder, good))
+	})
+	revokedByCRL := newLeaf("/ocsp/down", "/crl")
+	mux.HandleFunc("/ocsp/down", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc("/crl", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(newTestCRL(t, ca, caKey, testNow.Add(-time.Hour), revokedByCRL))
+	})
+	stapled := newLeaf("", "")
+	unchecked := newLeaf("/ocsp/down", "")
+
+	tests := []struct {
+		name    string
+		leaf    *x509.Certificate
+		checker *OCSPChecker
+		wantErr bool
+		wantIs  error
+	}{
+		{name: "stapled good", leaf: stapled, checker: &OCSPChecker{Stapled: [][]byte{response(stapled, good)}}},
+		{name: "stapled revoked", leaf: stapled, checker: &OCSPChecker{Stapled: [][]byte{response(stapled, revoked)}}, wantErr: true, wantIs: ErrRevoked},
+		{name: "stapled for another certificate", leaf: stapled, checker: &OCSPChecker{Stapled: [][]byte{response(unchecked, good)}, Policy: RevocationHardFail}, wantErr: true, wantIs: ErrBadSignature},
+		{name: "responder", leaf: goodByResponder, checker: &OCSPChecker{Policy: RevocationHardFail}},
+		{name: "CRL fallback", leaf: revokedByCRL, checker: &OCSPChecker{}, wantErr: true, wantIs: ErrRevoked},
+		{name: "no status, soft-fail", leaf: unchecked, checker: &OCSPChecker{}},
+		{name: "no status, hard-fail", leaf: unchecked, checker: &OCSPChecker{Policy: RevocationHardFail}, wantErr: true, wantIs: ErrBadSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.checker.check([]*x509.Certificate{tt.leaf, ca}, testNow)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Errorf("check() error = %v, want %v", err, tt.wantIs)
+			}
+		})
+	}
+}
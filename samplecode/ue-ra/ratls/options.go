@@ -0,0 +1,177 @@
+package ratls
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"time"
+)
+
+// Option configures a Verifier built by NewVerifier.
+type Option func(*Verifier)
+
+// NewVerifier returns a Verifier configured by opts. Without options it
+// verifies EPID evidence against the embedded Intel root with the default
+// policies, like the zero Verifier.
+func NewVerifier(opts ...Option) *Verifier {
+	v := &Verifier{}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// WithMode selects the attestation evidence expected from peers.
+func WithMode(mode Mode) Option {
+	return func(v *Verifier) { v.Mode = mode }
+}
+
+// WithFormats restricts the RA-TLS certificate layouts accepted from peers.
+func WithFormats(formats CertFormat) Option {
+	return func(v *Verifier) { v.Formats = formats }
+}
+
+// WithAllowedStatuses sets the accepted IAS quote statuses.
+func WithAllowedStatuses(statuses ...string) Option {
+	return func(v *Verifier) { v.QuoteStatuses = QuoteStatusPolicy{Allowed: statuses} }
+}
+
+// WithAllowedTCBStatuses sets the accepted DCAP and TDX TCB statuses.
+func WithAllowedTCBStatuses(statuses ...string) Option {
+	return func(v *Verifier) { v.TCBStatuses = QuoteStatusPolicy{Allowed: statuses} }
+}
+
+// WithAdvisoryExceptions accepts out of date platforms only for the
+// advisories of e, see Verifier.AdvisoryExceptions.
+func WithAdvisoryExceptions(e *AdvisoryExceptions) Option {
+	return func(v *Verifier) { v.AdvisoryExceptions = e }
+}
+
+// WithMaxReportAge sets the maximum age of IAS reports, see
+// Verifier.MaxReportAge.
+func WithMaxReportAge(d time.Duration) Option {
+	return func(v *Verifier) { v.MaxReportAge = d }
+}
+
+// WithAllowedMeasurements restricts the accepted enclaves to a fixed
+// allowlist.
+func WithAllowedMeasurements(allowed ...Measurement) Option {
+	return func(v *Verifier) { v.Measurements = NewMeasurementPolicy(allowed...) }
+}
+
+// WithMeasurementPolicy restricts the accepted enclaves to a policy that
+// may be reloaded, e.g. one returned by LoadMeasurementPolicy.
+func WithMeasurementPolicy(p *MeasurementPolicy) Option {
+	return func(v *Verifier) { v.Measurements = p }
+}
+
+// WithIASRoot trusts cert as IAS report signing root instead of the
+// embedded Intel root. It may be given several times.
+func WithIASRoot(cert *x509.Certificate) Option {
+	return func(v *Verifier) {
+		if v.IASRoots == nil {
+			v.IASRoots = x509.NewCertPool()
+		}
+		v.IASRoots.AddCert(cert)
+	}
+}
+
+// WithSGXRoot trusts cert as Intel SGX root CA for PCK certificate chains.
+func WithSGXRoot(cert *x509.Certificate) Option {
+	return func(v *Verifier) {
+		if v.SGXRoots == nil {
+			v.SGXRoots = x509.NewCertPool()
+		}
+		v.SGXRoots.AddCert(cert)
+	}
+}
+
+// WithCollateral sets the source of DCAP collateral.
+func WithCollateral(src CollateralSource) Option {
+	return func(v *Verifier) { v.Collateral = src }
+}
+
+// WithKeyBinding selects what report_data must start with to bind the
+// certificate key.
+func WithKeyBinding(b KeyBinding) Option {
+	return func(v *Verifier) { v.Binding = b }
+}
+
+// WithBindingData selects BindingCustom with report_data prefixes computed
+// by f.
+func WithBindingData(f BindingDataFunc) Option {
+	return func(v *Verifier) { v.Binding, v.BindingData = BindingCustom, f }
+}
+
+// WithCertStructureCheck checks the validity window, basic constraints and
+// self-signature of the peer certificate, see Verifier.CheckCertStructure.
+func WithCertStructureCheck() Option {
+	return func(v *Verifier) { v.CheckCertStructure = true }
+}
+
+// WithPolicy appraises accepted enclaves with p, e.g. a RulePolicy.
+func WithPolicy(p Appraiser) Option {
+	return func(v *Verifier) { v.Policy = p }
+}
+
+// WithMAA trusts the tokens of the Azure Attestation provider at
+// instanceURL in ModeMAA.
+func WithMAA(instanceURL string) Option {
+	return func(v *Verifier) { v.MAA = NewMAAIssuer(instanceURL) }
+}
+
+// WithITA trusts Intel Trust Authority tokens in ModeITA, with the signing
+// keys served by portalURL (DefaultITAURL if empty).
+func WithITA(portalURL string) Option {
+	return func(v *Verifier) { v.ITA = NewITAIssuer(portalURL) }
+}
+
+// WithIASRecheck confirms every IAS report online by retrieving it again by
+// its ID with the given subscription key.
+func WithIASRecheck(apiKey string) Option {
+	return func(v *Verifier) { v.IAS = &IASClient{APIKey: apiKey} }
+}
+
+// WithReportRevocation checks the IAS report signing certificate chain for
+// revocation with c.
+func WithReportRevocation(c *OCSPChecker) Option {
+	return func(v *Verifier) { v.ReportRevocation = c }
+}
+
+// WithPlatformInfoKey requires the platformInfoBlob of IAS reports to be
+// signed by key.
+func WithPlatformInfoKey(key *ecdsa.PublicKey) Option {
+	return func(v *Verifier) { v.PlatformInfoKey = key }
+}
+
+// WithPKIRoots enables hybrid trust: peer certificates must also chain to
+// roots and, if name is not empty, be valid for that host name.
+func WithPKIRoots(roots *x509.CertPool, name string) Option {
+	return func(v *Verifier) {
+		v.PKIRoots = roots
+		v.PKIName = name
+	}
+}
+
+// WithEPIDSignType requires EPID quotes to be signed with t.
+func WithEPIDSignType(t EPIDSignType) Option {
+	return func(v *Verifier) {
+		v.CheckSignType = true
+		v.SignType = t
+	}
+}
+
+// WithAllowDebug accepts enclaves running in debug mode.
+func WithAllowDebug() Option {
+	return func(v *Verifier) { v.AllowDebug = true }
+}
+
+// WithAllowSimulation accepts enclaves built in simulation mode, for
+// development only.
+func WithAllowSimulation() Option {
+	return func(v *Verifier) { v.AllowSimulation = true }
+}
+
+// WithClock sets the clock verification time is taken from.
+func WithClock(c Clock) Option {
+	return func(v *Verifier) { v.Clock = c }
+}
@@ -0,0 +1,70 @@
+package ratls
+
+import (
+	"crypto/x509"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewVerifier(t *testing.T) {
+	pki := newTestCollateralPKI(t)
+	pool := func(certs ...*x509.Certificate) *x509.CertPool {
+		p := x509.NewCertPool()
+		for _, c := range certs {
+			p.AddCert(c)
+		}
+		return p
+	}
+	tests := []struct {
+		name string
+		opts []Option
+		want func(v *Verifier) bool
+	}{
+		{name: "defaults", want: func(v *Verifier) bool { return reflect.DeepEqual(v, &Verifier{}) }},
+		{name: "mode", opts: []Option{WithMode(ModeDCAP)}, want: func(v *Verifier) bool { return v.Mode == ModeDCAP }},
+		{name: "later option wins", opts: []Option{WithMode(ModeDCAP), WithMode(ModeTDX)}, want: func(v *Verifier) bool { return v.Mode == ModeTDX }},
+		{
+			name: "statuses",
+			opts: []Option{WithAllowedStatuses("OK"), WithAllowedTCBStatuses("UpToDate")},
+			want: func(v *Verifier) bool {
+				return reflect.DeepEqual(v.QuoteStatuses.Allowed, []string{"OK"}) && reflect.DeepEqual(v.TCBStatuses.Allowed, []string{"UpToDate"})
+			},
+		},
+		{name: "report age", opts: []Option{WithMaxReportAge(time.Hour)}, want: func(v *Verifier) bool { return v.MaxReportAge == time.Hour }},
+		{
+			name: "measurements",
+			opts: []Option{WithAllowedMeasurements(Measurement{MrSigner: "bb"})},
+			want: func(v *Verifier) bool {
+				return v.Measurements.Check("aa", "bb") == nil && v.Measurements.Check("aa", "cc") != nil
+			},
+		},
+		{
+			name: "roots accumulate",
+			opts: []Option{WithSGXRoot(pki.root), WithSGXRoot(pki.signer)},
+			want: func(v *Verifier) bool {
+				_, err := pki.signer.Verify(x509.VerifyOptions{Roots: v.SGXRoots, CurrentTime: testNow})
+				return err == nil && v.SGXRoots.Equal(pool(pki.root, pki.signer)) && v.IASRoots == nil
+			},
+		},
+		{
+			name: "custom binding",
+			opts: []Option{WithBindingData(func(*x509.Certificate, []byte) ([]byte, error) { return nil, nil })},
+			want: func(v *Verifier) bool { return v.Binding == BindingCustom && v.BindingData != nil },
+		},
+		{name: "sign type", opts: []Option{WithEPIDSignType(EPIDUnlinkable)}, want: func(v *Verifier) bool { return v.CheckSignType && v.SignType == EPIDUnlinkable }},
+		{
+			name: "development",
+			opts: []Option{WithAllowDebug(), WithAllowSimulation()},
+			want: func(v *Verifier) bool { return v.AllowDebug && v.AllowSimulation },
+		},
+		{name: "clock", opts: []Option{WithClock(FixedClock(testNow))}, want: func(v *Verifier) bool { return v.now().Equal(testNow) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if v := NewVerifier(tt.opts...); !tt.want(v) {
+				t.Errorf("NewVerifier() = %+v", v)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package ratls
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Subject common names of the Intel SGX PCK hierarchy and of the TCB
+// Signing certificate the root issues for collateral.
+const (
+	pckCertCN       = "Intel SGX PCK Certificate"
+	pckPlatformCACN = "Intel SGX PCK Platform CA"
+	pckProcessorCN  = "Intel SGX PCK Processor CA"
+	sgxRootCACN     = "Intel SGX Root CA"
+	tcbSigningCN    = "Intel SGX TCB Signing"
+)
+
+// ParsePCKChain decodes the PEM certificate chain of certification data
+// type 5: PCK leaf, then the Platform or Processor CA, then the root.
+func ParsePCKChain(certData []byte) ([]*x509.Certificate, error) {
+	chain, err := parsePEMCerts(bytes.TrimRight(certData, "\x00"))
+	if err != nil {
+		return nil, err
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("no PCK certificate found in quote")
+	}
+	return chain, nil
+}
+
+// parsePEMCerts decodes all certificates of a PEM bundle.
+func parsePEMCerts(rest []byte) ([]*x509.Certificate, error) {
+	var chain []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, cert)
+	}
+	return chain, nil
+}
+
+// VerifyPCKChain checks that chain is a well formed PCK certificate chain
+// valid at now and anchored in roots, the Intel SGX Root CA. A root
+// certificate included in chain is only accepted if it is the anchor.
+func VerifyPCKChain(chain []*x509.Certificate, roots *x509.CertPool, now time.Time) error {
+	_, err := verifyPCKChain(chain, roots, now)
+	return err
+}
+
+// verifyPCKChain implements VerifyPCKChain and returns the verified path
+// from the PCK certificate to the anchor.
+func verifyPCKChain(chain []*x509.Certificate, roots *x509.CertPool, now time.Time) ([]*x509.Certificate, error) {
+	if len(chain) < 2 {
+		return nil, errors.New("PCK certificate chain is incomplete")
+	}
+	leaf, ca := chain[0], chain[1]
+
+	for _, c := range chain {
+		if now.Before(c.NotBefore) || now.After(c.NotAfter) {
+			return nil, fmt.Errorf("certificate %q is not valid at %v", c.Subject.CommonName, now.UTC())
+		}
+		if !isP256Key(c) {
+			return nil, fmt.Errorf("certificate %q does not carry a P-256 key", c.Subject.CommonName)
+		}
+	}
+
+	// The leaf signs QE reports only
+	if leaf.Subject.CommonName != pckCertCN {
+		return nil, fmt.Errorf("unexpected PCK certificate subject %q", leaf.Subject.CommonName)
+	}
+	if leaf.IsCA {
+		return nil, errors.New("PCK certificate must not be a CA")
+	}
+	if leaf.KeyUsage != 0 && leaf.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+		return nil, errors.New("PCK certificate key usage does not allow signatures")
+	}
+
+	// The intermediate is the Platform or Processor CA issuing the leaf
+	if ca.Subject.CommonName != pckPlatformCACN && ca.Subject.CommonName != pckProcessorCN {
+		return nil, fmt.Errorf("unexpected PCK CA subject %q", ca.Subject.CommonName)
+	}
+	for _, c := range chain[1:] {
+		if !c.BasicConstraintsValid || !c.IsCA {
+			return nil, fmt.Errorf("certificate %q is not a CA", c.Subject.CommonName)
+		}
+		if c.KeyUsage&x509.KeyUsageCertSign == 0 {
+			return nil, fmt.Errorf("certificate %q key usage does not allow signing certificates", c.Subject.CommonName)
+		}
+	}
+
+	intermediates := x509.NewCertPool()
+	intermediates.AddCert(ca)
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	verified, err := leaf.Verify(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	anchor := verified[0][len(verified[0])-1]
+	if anchor.Subject.CommonName != sgxRootCACN {
+		return nil, fmt.Errorf("PCK chain is anchored in %q, not the Intel SGX Root CA", anchor.Subject.CommonName)
+	}
+	if len(chain) > 2 && !chain[len(chain)-1].Equal(anchor) {
+		return nil, errors.New("root certificate in quote does not match the trusted Intel SGX Root CA")
+	}
+	return verified[0], nil
+}
+
+func isP256Key(c *x509.Certificate) bool {
+	pub, ok := c.PublicKey.(*ecdsa.PublicKey)
+	return ok && pub.Curve == elliptic.P256()
+}
@@ -0,0 +1,52 @@
+package ratls
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+)
+
+func TestVerifyPCKChain(t *testing.T) {
+	pki := newTestCollateralPKI(t)
+	ca, caKey := newTestCert(t, pckPlatformCACN, true, pki.root, pki.rootKey)
+	leaf, _ := newTestCert(t, pckCertCN, false, ca, caKey)
+	caLeaf, _ := newTestCert(t, pckCertCN, true, ca, caKey)
+	otherLeaf, _ := newTestCert(t, "Some Certificate", false, ca, caKey)
+	otherCA, otherCAKey := newTestCert(t, "Some CA", true, pki.root, pki.rootKey)
+	otherCALeaf, _ := newTestCert(t, pckCertCN, false, otherCA, otherCAKey)
+	fakeRoot, _ := newTestCert(t, sgxRootCACN, true, nil, nil)
+
+	otherAnchor, otherAnchorKey := newTestCert(t, "Some Root CA", true, nil, nil)
+	otherAnchorCA, otherAnchorCAKey := newTestCert(t, pckPlatformCACN, true, otherAnchor, otherAnchorKey)
+	otherAnchorLeaf, _ := newTestCert(t, pckCertCN, false, otherAnchorCA, otherAnchorCAKey)
+	otherAnchorRoots := x509.NewCertPool()
+	otherAnchorRoots.AddCert(otherAnchor)
+
+	tests := []struct {
+		name    string
+		chain   []*x509.Certificate
+		roots   *x509.CertPool
+		now     time.Time
+		wantErr bool
+	}{
+		{name: "leaf and CA", chain: []*x509.Certificate{leaf, ca}, roots: pki.roots, now: testNow},
+		{name: "with the root", chain: []*x509.Certificate{leaf, ca, pki.root}, roots: pki.roots, now: testNow},
+		{name: "leaf only", chain: []*x509.Certificate{leaf}, roots: pki.roots, now: testNow, wantErr: true},
+		{name: "other root in quote", chain: []*x509.Certificate{leaf, ca, fakeRoot}, roots: pki.roots, now: testNow, wantErr: true},
+		{name: "untrusted root", chain: []*x509.Certificate{leaf, ca}, roots: newTestCollateralPKI(t).roots, now: testNow, wantErr: true},
+		{name: "anchor is not the SGX root", chain: []*x509.Certificate{otherAnchorLeaf, otherAnchorCA}, roots: otherAnchorRoots, now: testNow, wantErr: true},
+		{name: "leaf subject", chain: []*x509.Certificate{otherLeaf, ca}, roots: pki.roots, now: testNow, wantErr: true},
+		{name: "leaf is a CA", chain: []*x509.Certificate{caLeaf, ca}, roots: pki.roots, now: testNow, wantErr: true},
+		{name: "CA subject", chain: []*x509.Certificate{otherCALeaf, otherCA}, roots: pki.roots, now: testNow, wantErr: true},
+		{name: "CA is not a CA", chain: []*x509.Certificate{leaf, leaf}, roots: pki.roots, now: testNow, wantErr: true},
+		{name: "not yet valid", chain: []*x509.Certificate{leaf, ca}, roots: pki.roots, now: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), wantErr: true},
+		{name: "expired", chain: []*x509.Certificate{leaf, ca}, roots: pki.roots, now: time.Date(2051, 1, 1, 0, 0, 0, 0, time.UTC), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := VerifyPCKChain(tt.chain, tt.roots, tt.now); (err != nil) != tt.wantErr {
+				t.Errorf("VerifyPCKChain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
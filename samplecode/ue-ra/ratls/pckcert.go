@@ -0,0 +1,150 @@
+package ratls
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// PlatformID identifies the platform of a quote whose certification data
+// carries its PPID (types 1 to 3) instead of a PCK certificate.
+type PlatformID struct {
+	// PPID is the platform provisioning ID, in clear text (type 1) or
+	// encrypted with RSA-2048 (type 2) or RSA-3072 (type 3) OAEP under the
+	// PCK retrieval key.
+	PPID      []byte
+	Encrypted bool
+	CPUSVN    [16]byte
+	PCESVN    uint16
+	PCEID     uint16
+}
+
+// ParsePlatformID decodes certification data of types 1 to 3.
+func ParsePlatformID(c *CertificationData) (*PlatformID, error) {
+	var ppidLen int
+	switch c.Type {
+	case CertTypePPIDCleartext:
+		ppidLen = 16
+	case CertTypePPIDRSA2048:
+		ppidLen = 256
+	case CertTypePPIDRSA3072:
+		ppidLen = 384
+	default:
+		return nil, fmt.Errorf("certification data type %d carries no PPID", c.Type)
+	}
+	if len(c.Data) < ppidLen+20 {
+		return nil, errors.New("PPID certification data is truncated")
+	}
+	id := &PlatformID{
+		PPID:      c.Data[:ppidLen],
+		Encrypted: c.Type != CertTypePPIDCleartext,
+	}
+	copy(id.CPUSVN[:], c.Data[ppidLen:])
+	id.PCESVN = binary.LittleEndian.Uint16(c.Data[ppidLen+16:])
+	id.PCEID = binary.LittleEndian.Uint16(c.Data[ppidLen+18:])
+	return id, nil
+}
+
+// PCKCertSource provides the PCK certificate chain of a platform, leaf
+// first, for quotes that do not embed it.
+type PCKCertSource interface {
+	GetPCKCert(ctx context.Context, id *PlatformID) ([]*x509.Certificate, error)
+}
+
+// GetPCKCert fetches the PCK certificate of a platform and its issuer
+// chain. The certification API looks platforms up by encrypted PPID only;
+// quotes with a clear text PPID need a source that knows the platform, such
+// as a registry of the fleet.
+func (c *CollateralClient) GetPCKCert(ctx context.Context, id *PlatformID) ([]*x509.Certificate, error) {
+	if !id.Encrypted {
+		return nil, errors.New("PCK certificates cannot be fetched by clear text PPID")
+	}
+	var svn, pceid [2]byte
+	binary.LittleEndian.PutUint16(svn[:], id.PCESVN)
+	binary.LittleEndian.PutUint16(pceid[:], id.PCEID)
+	path := fmt.Sprintf("/pckcert?encrypted_ppid=%x&cpusvn=%x&pcesvn=%x&pceid=%x", id.PPID, id.CPUSVN[:], svn[:], pceid[:])
+	body, chain, err := c.getSigned(ctx, c.baseURL(), path, "SGX-PCK-Certificate-Issuer-Chain")
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := parsePEMCerts(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(leaf) != 1 {
+		return nil, errors.New("pckcert: response is not a single certificate")
+	}
+	return append(leaf, chain...), nil
+}
+
+// pckChain returns the PCK certificate chain of a quote, leaf first, from
+// its certification data: embedded as a whole (type 5), as the leaf whose
+// issuer chain comes with the collateral (type 4), or fetched from
+// v.PCKCerts by PPID (types 1 to 3). For type 4 it also returns the
+// collateral it fetched.
+func (v *Verifier) pckChain(c *CertificationData) ([]*x509.Certificate, *Collateral, error) {
+	switch c.Type {
+	case CertTypePCKCertChain:
+		chain, err := ParsePCKChain(c.Data)
+		return chain, nil, err
+	case CertTypePCKCleartext:
+		leaf, err := x509.ParseCertificate(decodeCertBytes(bytes.TrimRight(c.Data, "\x00")))
+		if err != nil {
+			return nil, nil, fmt.Errorf("parse PCK certificate: %v", err)
+		}
+		if v.Collateral == nil {
+			return nil, nil, errors.New("quote carries the PCK certificate without its issuer chain, which needs collateral")
+		}
+		pck, err := ParsePCKExtensions(leaf)
+		if err != nil {
+			return nil, nil, err
+		}
+		col, err := v.Collateral.GetCollateral(context.Background(), pck.FMSPC, pck.CA)
+		if err != nil {
+			return nil, nil, fmt.Errorf("fetch collateral: %v", err)
+		}
+		// The PCK CRL is issued by the CA that issued the PCK certificate
+		return append([]*x509.Certificate{leaf}, col.PCKCRLIssuerChain...), col, nil
+	case CertTypePPIDCleartext, CertTypePPIDRSA2048, CertTypePPIDRSA3072:
+		id, err := ParsePlatformID(c)
+		if err != nil {
+			return nil, nil, err
+		}
+		if v.PCKCerts == nil {
+			return nil, nil, errors.New("quote carries a PPID instead of the PCK certificate, which needs a PCK certificate source")
+		}
+		chain, err := v.PCKCerts.GetPCKCert(context.Background(), id)
+		if err != nil {
+			return nil, nil, fmt.Errorf("fetch PCK certificate: %v", err)
+		}
+		if len(chain) == 0 {
+			return nil, nil, errors.New("no PCK certificate found for the platform")
+		}
+		if err := id.matches(chain[0]); err != nil {
+			return nil, nil, err
+		}
+		return chain, nil, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported certification data type %d", c.Type)
+}
+
+// matches checks that a fetched PCK certificate is for the platform.
+func (id *PlatformID) matches(cert *x509.Certificate) error {
+	pck, err := ParsePCKExtensions(cert)
+	if err != nil {
+		return err
+	}
+	var pceid [2]byte
+	binary.LittleEndian.PutUint16(pceid[:], id.PCEID)
+	if pck.PCEID != hex.EncodeToString(pceid[:]) {
+		return fmt.Errorf("PCK certificate is for PCE ID %s, quote has %x", pck.PCEID, pceid)
+	}
+	if !id.Encrypted && !bytes.Equal(pck.PPID, id.PPID) {
+		return errors.New("PCK certificate is for a different PPID")
+	}
+	return nil
+}
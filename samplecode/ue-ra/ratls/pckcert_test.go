@@ -0,0 +1,121 @@
+package ratls
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// testPPIDData returns certification data of type typ: a PPID of ppidLen
+// bytes of 0x11, a CPUSVN of 0x22 bytes, PCESVN 3 and PCE ID 0.
+func testPPIDData(typ uint16, ppidLen int) *CertificationData {
+	data := append(bytes.Repeat([]byte{0x11}, ppidLen), bytes.Repeat([]byte{0x22}, 16)...)
+	data = binary.LittleEndian.AppendUint16(data, 3)
+	data = binary.LittleEndian.AppendUint16(data, 0)
+	return &CertificationData{Type: typ, Data: data}
+}
+
+// testPCKLeaf returns an unsigned certificate carrying the SGX extensions
+// of a PCK certificate for ppid and pceid.
+func testPCKLeaf(t *testing.T, ppid, pceid []byte) *x509.Certificate {
+	t.Helper()
+	octets := func(b []byte) asn1.RawValue {
+		der, err := asn1.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return asn1.RawValue{FullBytes: der}
+	}
+	der, err := asn1.Marshal([]sgxExtension{
+		{Id: oidSGXPPID, Value: octets(ppid)},
+		{Id: oidSGXPCEID, Value: octets(pceid)},
+		{Id: oidSGXFMSPC, Value: octets([]byte{0x00, 0x90, 0x6e, 0xd5, 0x00, 0x00})},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &x509.Certificate{Extensions: []pkix.Extension{{Id: oidSGXExtensions, Value: der}}}
+}
+
+// pckSource is a PCKCertSource returning a fixed chain.
+type pckSource struct {
+	chain []*x509.Certificate
+	err   error
+}
+
+func (s pckSource) GetPCKCert(context.Context, *PlatformID) ([]*x509.Certificate, error) {
+	return s.chain, s.err
+}
+
+func TestParsePlatformID(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          *CertificationData
+		wantPPIDLen   int
+		wantEncrypted bool
+		wantErr       bool
+	}{
+		{name: "clear text", data: testPPIDData(CertTypePPIDCleartext, 16), wantPPIDLen: 16},
+		{name: "RSA-2048", data: testPPIDData(CertTypePPIDRSA2048, 256), wantPPIDLen: 256, wantEncrypted: true},
+		{name: "RSA-3072", data: testPPIDData(CertTypePPIDRSA3072, 384), wantPPIDLen: 384, wantEncrypted: true},
+		{name: "truncated", data: testPPIDData(CertTypePPIDRSA3072, 256), wantErr: true},
+		{name: "PCK certificate chain", data: &CertificationData{Type: CertTypePCKCertChain}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := ParsePlatformID(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePlatformID() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(id.PPID) != tt.wantPPIDLen || id.Encrypted != tt.wantEncrypted {
+				t.Errorf("ParsePlatformID() PPID = %d bytes, encrypted %v", len(id.PPID), id.Encrypted)
+			}
+			if id.CPUSVN != [16]byte(bytes.Repeat([]byte{0x22}, 16)) || id.PCESVN != 3 || id.PCEID != 0 {
+				t.Errorf("ParsePlatformID() = CPUSVN %x, PCESVN %d, PCE ID %d", id.CPUSVN, id.PCESVN, id.PCEID)
+			}
+		})
+	}
+}
+
+func TestPCKChain(t *testing.T) {
+	ppid := bytes.Repeat([]byte{0x11}, 16)
+	leaf := testPCKLeaf(t, ppid, []byte{0, 0})
+	tests := []struct {
+		name    string
+		source  PCKCertSource
+		data    *CertificationData
+		wantErr bool
+	}{
+		{name: "clear text PPID", source: pckSource{chain: []*x509.Certificate{leaf}}, data: testPPIDData(CertTypePPIDCleartext, 16)},
+		{name: "encrypted PPID", source: pckSource{chain: []*x509.Certificate{testPCKLeaf(t, nil, []byte{0, 0})}}, data: testPPIDData(CertTypePPIDRSA2048, 256)},
+		{name: "other PPID", source: pckSource{chain: []*x509.Certificate{testPCKLeaf(t, bytes.Repeat([]byte{0x33}, 16), []byte{0, 0})}}, data: testPPIDData(CertTypePPIDCleartext, 16), wantErr: true},
+		{name: "other PCE ID", source: pckSource{chain: []*x509.Certificate{testPCKLeaf(t, ppid, []byte{1, 0})}}, data: testPPIDData(CertTypePPIDCleartext, 16), wantErr: true},
+		{name: "no SGX extensions", source: pckSource{chain: []*x509.Certificate{{}}}, data: testPPIDData(CertTypePPIDCleartext, 16), wantErr: true},
+		{name: "no certificate", source: pckSource{}, data: testPPIDData(CertTypePPIDCleartext, 16), wantErr: true},
+		{name: "source fails", source: pckSource{err: errors.New("unavailable")}, data: testPPIDData(CertTypePPIDCleartext, 16), wantErr: true},
+		{name: "no source", data: testPPIDData(CertTypePPIDCleartext, 16), wantErr: true},
+		{name: "PCK certificate without collateral", data: &CertificationData{Type: CertTypePCKCleartext, Data: newTestRATLSCert(t, nil)}, wantErr: true},
+		{name: "malformed PCK certificate", data: &CertificationData{Type: CertTypePCKCleartext, Data: []byte("certificate")}, wantErr: true},
+		{name: "platform manifest", data: &CertificationData{Type: CertTypePlatformManifest}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Verifier{PCKCerts: tt.source}
+			chain, _, err := v.pckChain(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("pckChain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && len(chain) != 1 {
+				t.Errorf("pckChain() = %d certificates, want 1", len(chain))
+			}
+		})
+	}
+}
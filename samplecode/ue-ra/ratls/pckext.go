@@ -0,0 +1,124 @@
+package ratls
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// SGX extensions of PCK certificates, see the Intel SGX PCK Certificate and
+// CRL Profile.
+var (
+	oidSGXExtensions = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1}
+	oidSGXPPID       = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1, 1}
+	oidSGXTCB        = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1, 2}
+	oidSGXPCEID      = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1, 3}
+	oidSGXFMSPC      = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1, 4}
+	oidSGXType       = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1, 5}
+)
+
+// PCKExtensions holds the platform data Intel encodes in a PCK certificate.
+type PCKExtensions struct {
+	PPID []byte
+	// CompSVN are the 16 SGX TCB component SVNs.
+	CompSVN [16]int
+	PCESVN  int
+	CPUSVN  []byte
+	PCEID   string
+	FMSPC   string
+	// SGXType is 0 for Standard and 1 for Scalable platforms.
+	SGXType int
+	// CA is PCKCAPlatform or PCKCAProcessor, from the certificate issuer.
+	CA string
+}
+
+type sgxExtension struct {
+	Id    asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+// ParsePCKExtensions extracts the SGX extensions of a PCK certificate.
+func ParsePCKExtensions(cert *x509.Certificate) (*PCKExtensions, error) {
+	var raw []byte
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidSGXExtensions) {
+			raw = ext.Value
+			break
+		}
+	}
+	if raw == nil {
+		return nil, errors.New("PCK certificate has no SGX extensions")
+	}
+
+	var exts []sgxExtension
+	if rest, err := asn1.Unmarshal(raw, &exts); err != nil || len(rest) != 0 {
+		return nil, errors.New("malformed SGX extensions in PCK certificate")
+	}
+
+	p := &PCKExtensions{}
+	switch cert.Issuer.CommonName {
+	case pckPlatformCACN:
+		p.CA = PCKCAPlatform
+	case pckProcessorCN:
+		p.CA = PCKCAProcessor
+	}
+	var err error
+	for _, e := range exts {
+		switch {
+		case e.Id.Equal(oidSGXPPID):
+			_, err = asn1.Unmarshal(e.Value.FullBytes, &p.PPID)
+		case e.Id.Equal(oidSGXTCB):
+			err = p.parseTCB(e.Value.FullBytes)
+		case e.Id.Equal(oidSGXPCEID):
+			var b []byte
+			_, err = asn1.Unmarshal(e.Value.FullBytes, &b)
+			p.PCEID = hex.EncodeToString(b)
+		case e.Id.Equal(oidSGXFMSPC):
+			var b []byte
+			_, err = asn1.Unmarshal(e.Value.FullBytes, &b)
+			p.FMSPC = hex.EncodeToString(b)
+		case e.Id.Equal(oidSGXType):
+			var t asn1.Enumerated
+			_, err = asn1.Unmarshal(e.Value.FullBytes, &t)
+			p.SGXType = int(t)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("malformed SGX extension %v: %v", e.Id, err)
+		}
+	}
+	if p.FMSPC == "" {
+		return nil, errors.New("PCK certificate has no FMSPC")
+	}
+	return p, nil
+}
+
+// parseTCB decodes the TCB sequence: component SVNs 1-16 under .2.1-.2.16,
+// PCESVN under .2.17 and CPUSVN under .2.18.
+func (p *PCKExtensions) parseTCB(raw []byte) error {
+	var comps []sgxExtension
+	if _, err := asn1.Unmarshal(raw, &comps); err != nil {
+		return err
+	}
+	for _, c := range comps {
+		if len(c.Id) != len(oidSGXTCB)+1 || !c.Id[:len(oidSGXTCB)].Equal(oidSGXTCB) {
+			continue
+		}
+		switch n := c.Id[len(oidSGXTCB)]; {
+		case n >= 1 && n <= 16:
+			if _, err := asn1.Unmarshal(c.Value.FullBytes, &p.CompSVN[n-1]); err != nil {
+				return err
+			}
+		case n == 17:
+			if _, err := asn1.Unmarshal(c.Value.FullBytes, &p.PCESVN); err != nil {
+				return err
+			}
+		case n == 18:
+			if _, err := asn1.Unmarshal(c.Value.FullBytes, &p.CPUSVN); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
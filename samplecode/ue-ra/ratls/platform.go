@@ -0,0 +1,142 @@
+package ratls
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"math/bits"
+)
+
+// Layout of the platformInfoBlob of IAS reports: a TLV header followed by
+// the 101 byte sgx_platform_info_t, whose last 64 bytes are an ECDSA P-256
+// signature (r and s, big endian) over the fields before it.
+const (
+	platformInfoTLVType   = 21
+	platformInfoTLVHeader = 4
+	platformInfoSize      = 101
+	platformInfoSigned    = platformInfoSize - 64
+)
+
+// platformInfo mirrors sgx_platform_info_t.
+type platformInfo struct {
+	EPIDGroupFlags          uint8
+	TCBEvaluationFlags      uint16
+	PSEEvaluationFlags      uint16
+	LatestEquivalentTCBPSVN [18]byte
+	LatestPSEISVSVN         [2]byte
+	LatestPSDASVN           [4]byte
+	XEID                    uint32
+	GID                     uint32
+	Signature               [64]byte
+}
+
+// decodePlatformInfo strips the TLV header of a platformInfoBlob and
+// returns the sgx_platform_info_t.
+func decodePlatformInfo(blob []byte) ([]byte, error) {
+	if len(blob) != platformInfoTLVHeader+platformInfoSize {
+		return nil, errors.New("illegal PlatformInfoBlob")
+	}
+	if blob[0] != platformInfoTLVType || int(binary.BigEndian.Uint16(blob[2:4])) != platformInfoSize {
+		return nil, errors.New("illegal PlatformInfoBlob header")
+	}
+	return blob[platformInfoTLVHeader:], nil
+}
+
+// verifyPlatformInfo checks the signature Intel puts on a platform info
+// blob, so the TCB recovery advice it carries can be acted upon outside the
+// IAS report, e.g. when handed to the platform software.
+func verifyPlatformInfo(info []byte, key *ecdsa.PublicKey) error {
+	if len(info) != platformInfoSize {
+		return errors.New("illegal PlatformInfoBlob")
+	}
+	digest := sha256.Sum256(info[:platformInfoSigned])
+	r := new(big.Int).SetBytes(info[platformInfoSigned : platformInfoSigned+32])
+	s := new(big.Int).SetBytes(info[platformInfoSigned+32:])
+	if !ecdsa.Verify(key, digest[:], r, s) {
+		return failure(ErrBadSignature, errors.New("PlatformInfoBlob signature verification failed"))
+	}
+	return nil
+}
+
+// Evaluation flags of sgx_platform_info_t, in network byte order.
+const (
+	groupFlagRevoked    = 0x01
+	groupFlagOutOfDate  = 0x04
+	tcbFlagCPUSVN       = 0x0001
+	tcbFlagQESVN        = 0x0002
+	tcbFlagPCESVN       = 0x0004
+	tcbFlagConfigNeeded = 0x0008
+	pseFlagISVSVN       = 0x0001
+)
+
+// PlatformUpdate is the TCB recovery advice of a platform info blob: how
+// the platform compares with the latest equivalent TCB Intel knows of and
+// what has to be updated to reach it.
+type PlatformUpdate struct {
+	// Required reports that the platform must be updated or
+	// reconfigured.
+	Required bool `json:"required"`
+	// CPUSVN and PCESVN are the current values from the quote.
+	CPUSVN          string `json:"cpu_svn"`
+	LatestCPUSVN    string `json:"latest_cpu_svn"`
+	PCESVN          uint16 `json:"pce_svn"`
+	LatestPCESVN    uint16 `json:"latest_pce_svn"`
+	LatestPSEISVSVN uint16 `json:"latest_pse_isvsvn"`
+	// Actions lists what to update: "cpu_svn" (BIOS and microcode),
+	// "qe_svn" and "pce_svn" (SGX platform software), "pse_isvsvn"
+	// (platform services), "configuration" (BIOS settings) and
+	// "epid_group" (the EPID group is revoked or out of date and the
+	// platform must be provisioned again).
+	Actions []string `json:"actions,omitempty"`
+}
+
+// platformUpdate compares the latest equivalent TCB of the
+// sgx_platform_info_t info with the current one of the quote. A component
+// of CPUSVN lower than the latest one means a microcode or BIOS update is
+// due. The quote does not carry the PSE ISVSVN, whose comparison is
+// reported by the PSE evaluation flags.
+func platformUpdate(info []byte, quote *EPIDQuote) (*PlatformUpdate, error) {
+	var pi platformInfo
+	if err := binary.Read(bytes.NewReader(info), binary.LittleEndian, &pi); err != nil {
+		return nil, errors.New("illegal PlatformInfoBlob")
+	}
+	tcbFlags := bits.ReverseBytes16(pi.TCBEvaluationFlags)
+	pseFlags := bits.ReverseBytes16(pi.PSEEvaluationFlags)
+	latestCPUSVN := pi.LatestEquivalentTCBPSVN[:16]
+	u := &PlatformUpdate{
+		CPUSVN:          hex.EncodeToString(quote.ReportBody.CPUSVN[:]),
+		LatestCPUSVN:    hex.EncodeToString(latestCPUSVN),
+		PCESVN:          quote.Header.PCESVN,
+		LatestPCESVN:    binary.LittleEndian.Uint16(pi.LatestEquivalentTCBPSVN[16:]),
+		LatestPSEISVSVN: binary.BigEndian.Uint16(pi.LatestPSEISVSVN[:]),
+	}
+
+	cpuOutOfDate := tcbFlags&tcbFlagCPUSVN != 0
+	for i, latest := range latestCPUSVN {
+		cpuOutOfDate = cpuOutOfDate || quote.ReportBody.CPUSVN[i] < latest
+	}
+	if cpuOutOfDate {
+		u.Actions = append(u.Actions, "cpu_svn")
+	}
+	if tcbFlags&tcbFlagQESVN != 0 {
+		u.Actions = append(u.Actions, "qe_svn")
+	}
+	if tcbFlags&tcbFlagPCESVN != 0 || u.PCESVN < u.LatestPCESVN {
+		u.Actions = append(u.Actions, "pce_svn")
+	}
+	if pseFlags&pseFlagISVSVN != 0 {
+		u.Actions = append(u.Actions, "pse_isvsvn")
+	}
+	if tcbFlags&tcbFlagConfigNeeded != 0 {
+		u.Actions = append(u.Actions, "configuration")
+	}
+	if pi.EPIDGroupFlags&(groupFlagRevoked|groupFlagOutOfDate) != 0 {
+		u.Actions = append(u.Actions, "epid_group")
+	}
+	u.Required = len(u.Actions) > 0
+	return u, nil
+}
@@ -0,0 +1,193 @@
+package ratls
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"reflect"
+	"testing"
+)
+
+// testPlatformInfo returns an sgx_platform_info_t, after applying edit to
+// it if not nil, signed with key.
+func testPlatformInfo(t *testing.T, key *ecdsa.PrivateKey, edit func(*platformInfo)) []byte {
+	t.Helper()
+	var pi platformInfo
+	if edit != nil {
+		edit(&pi)
+	}
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, pi)
+	info := buf.Bytes()
+	digest := sha256.Sum256(info[:platformInfoSigned])
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.FillBytes(info[platformInfoSigned : platformInfoSigned+32])
+	s.FillBytes(info[platformInfoSigned+32:])
+	return info
+}
+
+func TestDecodePlatformInfo(t *testing.T) {
+	info := make([]byte, platformInfoSize)
+	tlv := func(typ byte, size uint16, body []byte) []byte {
+		head := []byte{typ, 2, byte(size >> 8), byte(size)}
+		return append(head, body...)
+	}
+	tests := []struct {
+		name    string
+		blob    []byte
+		wantErr bool
+	}{
+		{name: "platform info", blob: tlv(platformInfoTLVType, platformInfoSize, info)},
+		{name: "other type", blob: tlv(22, platformInfoSize, info), wantErr: true},
+		{name: "length mismatch", blob: tlv(platformInfoTLVType, platformInfoSize-1, info), wantErr: true},
+		{name: "truncated", blob: tlv(platformInfoTLVType, platformInfoSize, info[1:]), wantErr: true},
+		{name: "trailing data", blob: tlv(platformInfoTLVType, platformInfoSize, append(info, 0)), wantErr: true},
+		{name: "empty", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodePlatformInfo(tt.blob)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodePlatformInfo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !bytes.Equal(got, info) {
+				t.Errorf("decodePlatformInfo() = %x, want %x", got, info)
+			}
+		})
+	}
+}
+
+func TestVerifyPlatformInfo(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := testPlatformInfo(t, key, func(pi *platformInfo) { pi.GID = 0x2a })
+	tampered := append([]byte(nil), info...)
+	tampered[0] ^= 1
+	badSig := append([]byte(nil), info...)
+	badSig[platformInfoSize-1] ^= 1
+
+	tests := []struct {
+		name       string
+		info       []byte
+		key        *ecdsa.PublicKey
+		wantErr    bool
+		wantBadSig bool
+	}{
+		{name: "signed", info: info, key: &key.PublicKey},
+		{name: "other key", info: info, key: &other.PublicKey, wantErr: true, wantBadSig: true},
+		{name: "tampered", info: tampered, key: &key.PublicKey, wantErr: true, wantBadSig: true},
+		{name: "bad signature", info: badSig, key: &key.PublicKey, wantErr: true, wantBadSig: true},
+		{name: "zero signature", info: append(info[:platformInfoSigned:platformInfoSigned], make([]byte, 64)...), key: &key.PublicKey, wantErr: true, wantBadSig: true},
+		{name: "truncated", info: info[:platformInfoSize-1], key: &key.PublicKey, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyPlatformInfo(tt.info, tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyPlatformInfo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrBadSignature) != tt.wantBadSig {
+				t.Errorf("verifyPlatformInfo() error = %v, want ErrBadSignature %v", err, tt.wantBadSig)
+			}
+		})
+	}
+}
+
+func TestPlatformUpdate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The quote is at CPUSVN 02 01 and PCESVN 10.
+	quote := &EPIDQuote{Header: EPIDQuoteHeader{PCESVN: 10}}
+	quote.ReportBody.CPUSVN[0], quote.ReportBody.CPUSVN[1] = 2, 1
+	latest := func(cpusvn0 byte, pcesvn uint16) func(*platformInfo) {
+		return func(pi *platformInfo) {
+			pi.LatestEquivalentTCBPSVN[0] = cpusvn0
+			binary.LittleEndian.PutUint16(pi.LatestEquivalentTCBPSVN[16:], pcesvn)
+		}
+	}
+	// The evaluation flags are in network byte order.
+	tcbFlags := func(flags uint16) func(*platformInfo) {
+		return func(pi *platformInfo) {
+			latest(2, 10)(pi)
+			pi.TCBEvaluationFlags = bits.ReverseBytes16(flags)
+		}
+	}
+	tests := []struct {
+		name        string
+		info        []byte
+		wantActions []string
+		wantErr     bool
+	}{
+		{name: "up to date", info: testPlatformInfo(t, key, latest(2, 10))},
+		{name: "newer than latest", info: testPlatformInfo(t, key, latest(1, 9))},
+		{name: "CPUSVN component behind", info: testPlatformInfo(t, key, latest(3, 10)), wantActions: []string{"cpu_svn"}},
+		{name: "PCESVN behind", info: testPlatformInfo(t, key, latest(2, 11)), wantActions: []string{"pce_svn"}},
+		{name: "CPUSVN flag", info: testPlatformInfo(t, key, tcbFlags(tcbFlagCPUSVN)), wantActions: []string{"cpu_svn"}},
+		{name: "QESVN flag", info: testPlatformInfo(t, key, tcbFlags(tcbFlagQESVN)), wantActions: []string{"qe_svn"}},
+		{name: "PCESVN flag", info: testPlatformInfo(t, key, tcbFlags(tcbFlagPCESVN)), wantActions: []string{"pce_svn"}},
+		{name: "configuration needed", info: testPlatformInfo(t, key, tcbFlags(tcbFlagConfigNeeded)), wantActions: []string{"configuration"}},
+		{
+			name: "PSE ISVSVN flag",
+			info: testPlatformInfo(t, key, func(pi *platformInfo) {
+				latest(2, 10)(pi)
+				pi.PSEEvaluationFlags = bits.ReverseBytes16(pseFlagISVSVN)
+			}),
+			wantActions: []string{"pse_isvsvn"},
+		},
+		{
+			name: "EPID group revoked",
+			info: testPlatformInfo(t, key, func(pi *platformInfo) {
+				latest(2, 10)(pi)
+				pi.EPIDGroupFlags = groupFlagRevoked
+			}),
+			wantActions: []string{"epid_group"},
+		},
+		{
+			name: "everything",
+			info: testPlatformInfo(t, key, func(pi *platformInfo) {
+				tcbFlags(tcbFlagQESVN | tcbFlagConfigNeeded)(pi)
+				latest(3, 11)(pi)
+				pi.PSEEvaluationFlags = bits.ReverseBytes16(pseFlagISVSVN)
+				pi.EPIDGroupFlags = groupFlagOutOfDate
+			}),
+			wantActions: []string{"cpu_svn", "qe_svn", "pce_svn", "pse_isvsvn", "configuration", "epid_group"},
+		},
+		{name: "truncated", info: testPlatformInfo(t, key, nil)[:platformInfoSize-65], wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := platformUpdate(tt.info, quote)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("platformUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got.Actions, tt.wantActions) {
+				t.Errorf("platformUpdate() actions = %v, want %v", got.Actions, tt.wantActions)
+			}
+			if got.Required != (len(tt.wantActions) > 0) {
+				t.Errorf("platformUpdate() required = %v with actions %v", got.Required, got.Actions)
+			}
+			if got.CPUSVN[:4] != "0201" || got.PCESVN != 10 {
+				t.Errorf("platformUpdate() current TCB = %s, %d, want the quote's", got.CPUSVN, got.PCESVN)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package ratls
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Measurement is one allowed enclave identity. Empty fields match any value,
+// so an entry with only MrSigner admits every enclave signed by that key.
+type Measurement struct {
+	MrEnclave string `json:"mr_enclave,omitempty"`
+	MrSigner  string `json:"mr_signer,omitempty"`
+}
+
+func (m Measurement) matches(mrEnclave, mrSigner string) bool {
+	if m.MrEnclave == "" && m.MrSigner == "" {
+		return false
+	}
+	if m.MrEnclave != "" && !strings.EqualFold(m.MrEnclave, mrEnclave) {
+		return false
+	}
+	if m.MrSigner != "" && !strings.EqualFold(m.MrSigner, mrSigner) {
+		return false
+	}
+	return true
+}
+
+type measurementFile struct {
+	Allowed []Measurement `json:"allowed"`
+}
+
+// MeasurementPolicy is an allowlist of enclave measurements loaded from a
+// JSON file of the form
+//
+//	{"allowed": [{"mr_enclave": "<hex>", "mr_signer": "<hex>"}]}
+//
+// It is safe for concurrent use and can be reloaded while in use.
+type MeasurementPolicy struct {
+	path string
+
+	mu      sync.RWMutex
+	allowed []Measurement
+	modTime time.Time
+	// generation counts the reloads, so results checked against an older
+	// allowlist can be told apart.
+	generation uint64
+}
+
+// NewMeasurementPolicy returns a fixed allowlist, not backed by a file.
+func NewMeasurementPolicy(allowed ...Measurement) *MeasurementPolicy {
+	return &MeasurementPolicy{allowed: allowed}
+}
+
+// LoadMeasurementPolicy reads the allowlist at path.
+func LoadMeasurementPolicy(path string) (*MeasurementPolicy, error) {
+	p := &MeasurementPolicy{path: path}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload re-reads the policy file. On error the previous allowlist is kept.
+func (p *MeasurementPolicy) Reload() error {
+	if p.path == "" {
+		return errors.New("measurement policy is not loaded from a file")
+	}
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(p.path)
+	if err != nil {
+		return err
+	}
+	var f measurementFile
+	if err := json.Unmarshal(content, &f); err != nil {
+		return fmt.Errorf("parse measurement policy %s: %v", p.path, err)
+	}
+
+	p.mu.Lock()
+	p.allowed = f.Allowed
+	p.modTime = info.ModTime()
+	p.generation++
+	p.mu.Unlock()
+	return nil
+}
+
+// Watch polls the policy file every interval and reloads it when its
+// modification time changes. Reload errors are passed to onError if it is
+// not nil. Calling the returned function stops watching.
+func (p *MeasurementPolicy) Watch(interval time.Duration, onError func(error)) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			info, err := os.Stat(p.path)
+			if err == nil {
+				p.mu.RLock()
+				changed := !info.ModTime().Equal(p.modTime)
+				p.mu.RUnlock()
+				if !changed {
+					continue
+				}
+				err = p.Reload()
+			}
+			if err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// loaded returns the number of reloads of p, zero if p is nil.
+func (p *MeasurementPolicy) loaded() uint64 {
+	if p == nil {
+		return 0
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.generation
+}
+
+// Check returns an error unless the given hex encoded measurements match
+// an entry of the allowlist.
+func (p *MeasurementPolicy) Check(mrEnclave, mrSigner string) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, m := range p.allowed {
+		if m.matches(mrEnclave, mrSigner) {
+			return nil
+		}
+	}
+	return errors.New("enclave measurement is not in the allowlist")
+}
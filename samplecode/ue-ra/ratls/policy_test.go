@@ -0,0 +1,82 @@
+package ratls
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMeasurementPolicyCheck(t *testing.T) {
+	const (
+		enclave = "aa00"
+		signer  = "bb00"
+	)
+	tests := []struct {
+		name      string
+		allowed   []Measurement
+		mrEnclave string
+		mrSigner  string
+		wantErr   bool
+	}{
+		{name: "enclave allowed", allowed: []Measurement{{MrEnclave: enclave}}, mrEnclave: enclave, mrSigner: signer},
+		{name: "signer allowed", allowed: []Measurement{{MrSigner: signer}}, mrEnclave: "cc00", mrSigner: signer},
+		{name: "both must match", allowed: []Measurement{{MrEnclave: enclave, MrSigner: signer}}, mrEnclave: enclave, mrSigner: "cc00", wantErr: true},
+		{name: "case insensitive", allowed: []Measurement{{MrEnclave: "AA00"}}, mrEnclave: enclave, mrSigner: signer},
+		{name: "second entry", allowed: []Measurement{{MrEnclave: "cc00"}, {MrSigner: signer}}, mrEnclave: enclave, mrSigner: signer},
+		{name: "empty entry matches nothing", allowed: []Measurement{{}}, mrEnclave: enclave, mrSigner: signer, wantErr: true},
+		{name: "empty allowlist", mrEnclave: enclave, mrSigner: signer, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewMeasurementPolicy(tt.allowed...).Check(tt.mrEnclave, tt.mrSigner)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMeasurementPolicyReload(t *testing.T) {
+	tests := []struct {
+		name string
+		// update is written over the policy before reloading, or the file
+		// removed if nil.
+		update      []byte
+		wantErr     bool
+		wantAllowed string
+	}{
+		{name: "updated", update: []byte(`{"allowed": [{"mr_enclave": "bb"}]}`), wantAllowed: "bb"},
+		{name: "invalid JSON keeps the allowlist", update: []byte(`{"allowed": [`), wantErr: true, wantAllowed: "aa"},
+		{name: "removed file keeps the allowlist", wantErr: true, wantAllowed: "aa"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "policy.json")
+			if err := os.WriteFile(path, []byte(`{"allowed": [{"mr_enclave": "aa"}]}`), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			p, err := LoadMeasurementPolicy(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.update != nil {
+				err = os.WriteFile(path, tt.update, 0o644)
+			} else {
+				err = os.Remove(path)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Reload(); (err != nil) != tt.wantErr {
+				t.Fatalf("Reload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err := p.Check(tt.wantAllowed, ""); err != nil {
+				t.Errorf("Check(%q) error = %v", tt.wantAllowed, err)
+			}
+		})
+	}
+
+	if err := NewMeasurementPolicy().Reload(); err == nil {
+		t.Error("Reload() of a fixed policy succeeded")
+	}
+}
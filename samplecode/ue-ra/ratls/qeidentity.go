@@ -0,0 +1,148 @@
+package ratls
+
+import (
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// QEIdentity is the signed identity of the Intel quoting enclave, as served
+// by the PCS /qe/identity endpoint. Byte fields are hex encoded.
+type QEIdentity struct {
+	ID                      string            `json:"id"`
+	Version                 int               `json:"version"`
+	IssueDate               time.Time         `json:"issueDate"`
+	NextUpdate              time.Time         `json:"nextUpdate"`
+	TCBEvaluationDataNumber int               `json:"tcbEvaluationDataNumber"`
+	MiscSelect              string            `json:"miscselect"`
+	MiscSelectMask          string            `json:"miscselectMask"`
+	Attributes              string            `json:"attributes"`
+	AttributesMask          string            `json:"attributesMask"`
+	MrSigner                string            `json:"mrsigner"`
+	ISVProdID               uint16            `json:"isvprodid"`
+	TCBLevels               []QEIdentityLevel `json:"tcbLevels"`
+}
+
+// QEIdentityLevel is one entry of QEIdentity.TCBLevels, ordered from newest
+// to oldest.
+type QEIdentityLevel struct {
+	TCB struct {
+		ISVSVN uint16 `json:"isvsvn"`
+	} `json:"tcb"`
+	TCBDate     string   `json:"tcbDate"`
+	TCBStatus   string   `json:"tcbStatus"`
+	AdvisoryIDs []string `json:"advisoryIDs"`
+}
+
+// Identity IDs of the SGX and the TDX quoting enclave.
+const (
+	QEIdentityIDSGX = "QE"
+	QEIdentityIDTDX = "TD_QE"
+)
+
+// VerifyQEIdentity checks the signature and issuer chain of a QE identity
+// document as returned by CollateralClient.GetQEIdentity and decodes it.
+// It must be signed by the Intel SGX TCB Signing certificate, so that no
+// other key chaining to the root can vouch for a rogue QE. Identities of
+// other enclaves, such as the QVE, are rejected.
+func VerifyQEIdentity(doc []byte, chain []*x509.Certificate, roots *x509.CertPool, now time.Time) (*QEIdentity, error) {
+	raw, err := verifySignedJSON(doc, "enclaveIdentity", chain, roots, now)
+	if err != nil {
+		return nil, fmt.Errorf("QE identity: %w", err)
+	}
+	var id QEIdentity
+	if err := json.Unmarshal(raw, &id); err != nil {
+		return nil, err
+	}
+	if id.ID != QEIdentityIDSGX && id.ID != QEIdentityIDTDX {
+		return nil, fmt.Errorf("QE identity is for %q, not a quoting enclave", id.ID)
+	}
+	if now.After(id.NextUpdate) {
+		return nil, failuref(ErrStaleReport, "QE identity expired at %v", id.NextUpdate)
+	}
+	return &id, nil
+}
+
+// Match checks that a QE report was produced by the identified quoting
+// enclave and returns the TCB level of its ISV SVN.
+func (id *QEIdentity) Match(report *ReportBody) (*QEIdentityLevel, error) {
+	mrSigner, err := hex.DecodeString(id.MrSigner)
+	if err != nil || !equalBytes(mrSigner, report.MrSigner[:]) {
+		return nil, errors.New("QE report MRSIGNER does not match QE identity")
+	}
+	if report.ISVProdID != id.ISVProdID {
+		return nil, fmt.Errorf("QE report ISV product ID %d, want %d", report.ISVProdID, id.ISVProdID)
+	}
+
+	var misc [4]byte
+	binary.BigEndian.PutUint32(misc[:], report.MiscSelect)
+	if ok, err := maskedEqual(misc[:], id.MiscSelect, id.MiscSelectMask); err != nil || !ok {
+		return nil, errors.New("QE report MISCSELECT does not match QE identity")
+	}
+	var attrs [16]byte
+	binary.LittleEndian.PutUint64(attrs[:8], report.Attributes.Flags)
+	binary.LittleEndian.PutUint64(attrs[8:], report.Attributes.Xfrm)
+	if ok, err := maskedEqual(attrs[:], id.Attributes, id.AttributesMask); err != nil || !ok {
+		return nil, errors.New("QE report attributes do not match QE identity")
+	}
+
+	for i := range id.TCBLevels {
+		level := &id.TCBLevels[i]
+		if report.ISVSVN >= level.TCB.ISVSVN {
+			return level, nil
+		}
+	}
+	return nil, errors.New("QE ISV SVN is lower than every QE identity TCB level")
+}
+
+// maskedEqual reports whether value & mask equals want & mask, with want and
+// mask hex encoded.
+func maskedEqual(value []byte, wantHex, maskHex string) (bool, error) {
+	want, err := hex.DecodeString(wantHex)
+	if err != nil {
+		return false, err
+	}
+	mask, err := hex.DecodeString(maskHex)
+	if err != nil {
+		return false, err
+	}
+	if len(want) != len(value) || len(mask) != len(value) {
+		return false, nil
+	}
+	for i := range value {
+		if value[i]&mask[i] != want[i]&mask[i] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (v *Verifier) checkQEIdentity(col *Collateral, qeReport *ReportBody, now time.Time, res *VerificationResult) error {
+	id, err := VerifyQEIdentity(col.QEIdentity, col.QEIdentityIssuerChain, v.sgxRoots(), now)
+	if err != nil {
+		return err
+	}
+	if err := v.checkSignerRevocation(col.QEIdentityIssuerChain, col, now); err != nil {
+		return fmt.Errorf("QE identity: %w", err)
+	}
+	want := QEIdentityIDSGX
+	if res.Mode == ModeTDX {
+		want = QEIdentityIDTDX
+	}
+	if id.ID != want {
+		return fmt.Errorf("QE identity is for %q, want %q", id.ID, want)
+	}
+	level, err := id.Match(qeReport)
+	if err != nil {
+		return err
+	}
+	res.QEStatus = level.TCBStatus
+	if err := v.checkTCBStatus(level.TCBStatus, level.AdvisoryIDs); err != nil {
+		return fmt.Errorf("quoting enclave: %w", err)
+	}
+	return nil
+}
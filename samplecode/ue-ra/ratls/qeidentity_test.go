@@ -0,0 +1,148 @@
+package ratls
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// sampleQEIdentity returns a version 2 QE identity modelled on the PCS
+// sample, changed by edit if it is not nil.
+func sampleQEIdentity(t *testing.T, edit func(id map[string]any)) []byte {
+	t.Helper()
+	level := func(isvsvn int, date, status string, advisories ...string) map[string]any {
+		l := map[string]any{
+			"tcb":       map[string]int{"isvsvn": isvsvn},
+			"tcbDate":   date,
+			"tcbStatus": status,
+		}
+		if len(advisories) > 0 {
+			l["advisoryIDs"] = advisories
+		}
+		return l
+	}
+	id := map[string]any{
+		"id":                      "QE",
+		"version":                 2,
+		"issueDate":               "2023-01-10T00:00:00Z",
+		"nextUpdate":              "2023-02-09T00:00:00Z",
+		"tcbEvaluationDataNumber": 14,
+		"miscselect":              "00000000",
+		"miscselectMask":          "FFFFFFFF",
+		"attributes":              "11000000000000000000000000000000",
+		"attributesMask":          "FBFFFFFFFFFFFFFF0000000000000000",
+		"mrsigner":                "8C4F5775D796503E96137F77C68A829A0056AC8DED70140B081B094490C57BFF",
+		"isvprodid":               1,
+		"tcbLevels": []any{
+			level(8, "2022-08-10T00:00:00Z", "UpToDate"),
+			level(6, "2021-11-10T00:00:00Z", "OutOfDate", "INTEL-SA-00615"),
+		},
+	}
+	if edit != nil {
+		edit(id)
+	}
+	body, err := json.Marshal(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+// sampleQEReport returns a QE report matching sampleQEIdentity at ISV SVN
+// isvsvn.
+func sampleQEReport(t *testing.T, isvsvn uint16) *ReportBody {
+	t.Helper()
+	r := &ReportBody{ISVProdID: 1, ISVSVN: isvsvn, Attributes: Attributes{Flags: 0x11, Xfrm: 0xe7}}
+	mrSigner, err := hex.DecodeString("8c4f5775d796503e96137f77c68a829a0056ac8ded70140b081b094490c57bff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(r.MrSigner[:], mrSigner)
+	return r
+}
+
+func TestVerifyQEIdentity(t *testing.T) {
+	p := newTestCollateralPKI(t)
+	pckCert, pckKey := newTestCert(t, pckCertCN, false, p.root, p.rootKey)
+
+	withID := func(id string) []byte {
+		return signDocument(t, p.signerKey, "enclaveIdentity", sampleQEIdentity(t, func(m map[string]any) { m["id"] = id }))
+	}
+
+	tests := []struct {
+		name    string
+		doc     []byte
+		signer  bool
+		now     time.Time
+		cause   error
+		wantErr bool
+	}{
+		{name: "QE", doc: withID(QEIdentityIDSGX), signer: true, now: testNow},
+		{name: "TD_QE", doc: withID(QEIdentityIDTDX), signer: true, now: testNow},
+		{name: "QVE", doc: withID("QVE"), signer: true, now: testNow, wantErr: true},
+		{name: "expired", doc: withID(QEIdentityIDSGX), signer: true, now: time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC), cause: ErrStaleReport, wantErr: true},
+		{name: "signed by a PCK certificate", doc: signDocument(t, pckKey, "enclaveIdentity", sampleQEIdentity(t, nil)), now: testNow, cause: ErrBadSignature, wantErr: true},
+		{name: "wrong member", doc: signDocument(t, p.signerKey, "qeIdentity", sampleQEIdentity(t, nil)), signer: true, now: testNow, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := []*x509.Certificate{pckCert, p.root}
+			if tt.signer {
+				chain = []*x509.Certificate{p.signer, p.root}
+			}
+			id, err := VerifyQEIdentity(tt.doc, chain, p.roots, tt.now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyQEIdentity() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.cause != nil && !errors.Is(err, tt.cause) {
+				t.Errorf("VerifyQEIdentity() error = %v, want %v", err, tt.cause)
+			}
+			if err == nil && id.ISVProdID != 1 {
+				t.Errorf("VerifyQEIdentity() = %+v, not the signed identity", id)
+			}
+		})
+	}
+}
+
+func TestQEIdentityMatch(t *testing.T) {
+	var id QEIdentity
+	if err := json.Unmarshal(sampleQEIdentity(t, nil), &id); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		edit       func(r *ReportBody)
+		isvsvn     uint16
+		wantStatus string
+		wantErr    bool
+	}{
+		{name: "newest level", isvsvn: 8, wantStatus: "UpToDate"},
+		{name: "above newest level", isvsvn: 9, wantStatus: "UpToDate"},
+		{name: "older level", isvsvn: 7, wantStatus: "OutOfDate"},
+		{name: "below every level", isvsvn: 5, wantErr: true},
+		{name: "masked attribute bit", isvsvn: 8, edit: func(r *ReportBody) { r.Attributes.Flags |= 0x04 }, wantStatus: "UpToDate"},
+		{name: "debug QE", isvsvn: 8, edit: func(r *ReportBody) { r.Attributes.Flags |= 0x02 }, wantErr: true},
+		{name: "other MRSIGNER", isvsvn: 8, edit: func(r *ReportBody) { r.MrSigner[0] ^= 1 }, wantErr: true},
+		{name: "other ISV product ID", isvsvn: 8, edit: func(r *ReportBody) { r.ISVProdID = 2 }, wantErr: true},
+		{name: "other MISCSELECT", isvsvn: 8, edit: func(r *ReportBody) { r.MiscSelect = 1 }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := sampleQEReport(t, tt.isvsvn)
+			if tt.edit != nil {
+				tt.edit(report)
+			}
+			level, err := id.Match(report)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Match() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && level.TCBStatus != tt.wantStatus {
+				t.Errorf("Match() status = %s, want %s", level.TCBStatus, tt.wantStatus)
+			}
+		})
+	}
+}
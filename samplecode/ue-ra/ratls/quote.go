@@ -0,0 +1,214 @@
+package ratls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+const (
+	quoteHeaderLen = 48
+	reportBodyLen  = 384
+	ecdsaSigLen    = 64
+	ecdsaPubKeyLen = 64
+	quoteVersionV3 = 3
+	quoteVersionV4 = 4
+	attKeyTypeP256 = 2
+)
+
+// TEE types of the quote header.
+const (
+	TEETypeSGX = 0x00000000
+	TEETypeTDX = 0x00000081
+)
+
+// Certification data types of sgx_ql_certification_data_t.
+const (
+	CertTypePPIDCleartext    = 1
+	CertTypePPIDRSA2048      = 2
+	CertTypePPIDRSA3072      = 3
+	CertTypePCKCleartext     = 4
+	CertTypePCKCertChain     = 5
+	CertTypeECDSASigAuxData  = 6
+	CertTypePlatformManifest = 7
+)
+
+// QuoteHeader mirrors sgx_quote_header_t of a quote v3 and v4. TEEType is
+// TEETypeSGX or TEETypeTDX, and reserved (zero) in v3.
+type QuoteHeader struct {
+	Version            uint16
+	AttestationKeyType uint16
+	TEEType            uint32
+	QESVN              uint16
+	PCESVN             uint16
+	QEVendorID         [16]byte
+	UserData           [20]byte
+}
+
+// Attributes mirrors sgx_attributes_t.
+type Attributes struct {
+	Flags uint64
+	Xfrm  uint64
+}
+
+// ReportBody mirrors sgx_report_body_t, the enclave identity signed in a
+// quote or local report.
+type ReportBody struct {
+	CPUSVN       [16]byte
+	MiscSelect   uint32
+	Reserved1    [12]byte
+	ISVExtProdID [16]byte
+	Attributes   Attributes
+	MrEnclave    [32]byte
+	Reserved2    [32]byte
+	MrSigner     [32]byte
+	Reserved3    [32]byte
+	ConfigID     [64]byte
+	ISVProdID    uint16
+	ISVSVN       uint16
+	ConfigSVN    uint16
+	Reserved4    [42]byte
+	ISVFamilyID  [16]byte
+	ReportData   [64]byte
+}
+
+// CertificationData mirrors sgx_ql_certification_data_t.
+type CertificationData struct {
+	Type uint16
+	Data []byte
+}
+
+// ECDSASignatureData mirrors sgx_ql_ecdsa_sig_data_t together with the
+// variable length QE authentication and certification data following it.
+type ECDSASignatureData struct {
+	Signature         [64]byte
+	AttestationKey    [64]byte
+	QEReport          ReportBody
+	QEReportSignature [64]byte
+	QEAuthData        []byte
+	CertificationData CertificationData
+
+	qeReportRaw []byte
+}
+
+// QuoteV3 is a parsed ECDSA (DCAP) quote, layout of sgx_quote_3_t.
+type QuoteV3 struct {
+	Header        QuoteHeader
+	ReportBody    ReportBody
+	SignatureData ECDSASignatureData
+
+	// signed is the header and report body covered by Signature.
+	signed []byte
+}
+
+// ParseQuoteV3 decodes a version 3 quote signed with an ECDSA P-256
+// attestation key.
+func ParseQuoteV3(raw []byte) (*QuoteV3, error) {
+	if len(raw) < quoteHeaderLen+reportBodyLen+4 {
+		return nil, errors.New("DCAP quote is too short")
+	}
+
+	q := &QuoteV3{signed: raw[:quoteHeaderLen+reportBodyLen]}
+	r := bytes.NewReader(raw)
+	if err := binary.Read(r, binary.LittleEndian, &q.Header); err != nil {
+		return nil, err
+	}
+	if q.Header.Version != quoteVersionV3 {
+		return nil, fmt.Errorf("unsupported DCAP quote version %d", q.Header.Version)
+	}
+	if q.Header.AttestationKeyType != attKeyTypeP256 {
+		return nil, fmt.Errorf("unsupported attestation key type %d", q.Header.AttestationKeyType)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &q.ReportBody); err != nil {
+		return nil, err
+	}
+
+	var sigDataLen uint32
+	if err := binary.Read(r, binary.LittleEndian, &sigDataLen); err != nil {
+		return nil, err
+	}
+	offset := quoteHeaderLen + reportBodyLen + 4
+	if uint64(len(raw)-offset) < uint64(sigDataLen) {
+		return nil, errors.New("DCAP quote signature data is truncated")
+	}
+	sig, err := parseECDSASignatureData(raw[offset : offset+int(sigDataLen)])
+	if err != nil {
+		return nil, err
+	}
+	q.SignatureData = *sig
+	return q, nil
+}
+
+// Layout follows sgx_ql_ecdsa_sig_data_t from the Intel DCAP headers.
+func parseECDSASignatureData(sigData []byte) (*ECDSASignatureData, error) {
+	if len(sigData) < ecdsaSigLen+ecdsaPubKeyLen {
+		return nil, errors.New("DCAP quote signature data is too short")
+	}
+	s := &ECDSASignatureData{}
+	copy(s.Signature[:], sigData[0:64])
+	copy(s.AttestationKey[:], sigData[64:128])
+	if err := s.parseQEReportCertData(sigData[128:]); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// parseQEReportCertData decodes the QE report, its signature, the QE auth
+// data and the certification data, i.e. sgx_ql_ecdsa_sig_data_t after the
+// attestation key or the body of certification data type 6 in v4 quotes.
+func (s *ECDSASignatureData) parseQEReportCertData(data []byte) error {
+	if len(data) < reportBodyLen+ecdsaSigLen+2 {
+		return errors.New("DCAP quote signature data is too short")
+	}
+	s.qeReportRaw = data[0:384]
+	if err := binary.Read(bytes.NewReader(s.qeReportRaw), binary.LittleEndian, &s.QEReport); err != nil {
+		return err
+	}
+	copy(s.QEReportSignature[:], data[384:448])
+
+	authLen := int(binary.LittleEndian.Uint16(data[448:450]))
+	offset := 450
+	if len(data) < offset+authLen+6 {
+		return errors.New("DCAP quote QE auth data is truncated")
+	}
+	s.QEAuthData = data[offset : offset+authLen]
+	offset += authLen
+
+	cert, _, err := parseCertificationData(data[offset:])
+	if err != nil {
+		return err
+	}
+	s.CertificationData = *cert
+	return nil
+}
+
+// parseCertificationData decodes a sgx_ql_certification_data_t and returns
+// the bytes following it.
+func parseCertificationData(data []byte) (*CertificationData, []byte, error) {
+	if len(data) < 6 {
+		return nil, nil, errors.New("DCAP quote certification data is truncated")
+	}
+	c := &CertificationData{Type: binary.LittleEndian.Uint16(data[0:2])}
+	size := binary.LittleEndian.Uint32(data[2:6])
+	if uint64(len(data)-6) < uint64(size) {
+		return nil, nil, errors.New("DCAP quote certification data is truncated")
+	}
+	c.Data = data[6 : 6+int(size)]
+	return c, data[6+int(size):], nil
+}
+
+// quoteReportBody converts to the hex form shared with the EPID path.
+func (b *ReportBody) quoteReportBody() *QuoteReportBody {
+	return &QuoteReportBody{
+		mrEnclave:  hex.EncodeToString(b.MrEnclave[:]),
+		mrSigner:   hex.EncodeToString(b.MrSigner[:]),
+		reportData: hex.EncodeToString(b.ReportData[:]),
+		isvProdID:  b.ISVProdID,
+		isvSvn:     b.ISVSVN,
+		cpuSvn:     hex.EncodeToString(b.CPUSVN[:]),
+		miscSelect: b.MiscSelect,
+		attributes: b.Attributes,
+	}
+}
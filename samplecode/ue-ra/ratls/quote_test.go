@@ -0,0 +1,115 @@
+package ratls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// testQuoteV3 describes a version 3 quote built by raw.
+type testQuoteV3 struct {
+	version uint16
+	keyType uint16
+	body    ReportBody
+	// sigDataLen overrides the length of the signature data if not zero.
+	sigDataLen uint32
+	sigData    []byte
+}
+
+// newTestQuoteV3 returns a well-formed quote whose report data starts with
+// 0x5a, with QE auth data "auth" and a PCK chain as certification data.
+func newTestQuoteV3() testQuoteV3 {
+	q := testQuoteV3{version: quoteVersionV3, keyType: attKeyTypeP256}
+	q.body.ReportData[0] = 0x5a
+	q.sigData = testSignatureDataV3([]byte("auth"), CertTypePCKCertChain, []byte("-----BEGIN CERTIFICATE-----"))
+	return q
+}
+
+// testSignatureDataV3 returns sgx_ql_ecdsa_sig_data_t with the given QE
+// auth data and certification data.
+func testSignatureDataV3(auth []byte, certType uint16, cert []byte) []byte {
+	var b bytes.Buffer
+	b.Write(make([]byte, ecdsaSigLen+ecdsaPubKeyLen+reportBodyLen+ecdsaSigLen))
+	binary.Write(&b, binary.LittleEndian, uint16(len(auth)))
+	b.Write(auth)
+	binary.Write(&b, binary.LittleEndian, certType)
+	binary.Write(&b, binary.LittleEndian, uint32(len(cert)))
+	b.Write(cert)
+	return b.Bytes()
+}
+
+func (q testQuoteV3) raw() []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, QuoteHeader{Version: q.version, AttestationKeyType: q.keyType})
+	binary.Write(&b, binary.LittleEndian, q.body)
+	sigDataLen := q.sigDataLen
+	if sigDataLen == 0 {
+		sigDataLen = uint32(len(q.sigData))
+	}
+	binary.Write(&b, binary.LittleEndian, sigDataLen)
+	b.Write(q.sigData)
+	return b.Bytes()
+}
+
+func TestParseQuoteV3(t *testing.T) {
+	edit := func(f func(q *testQuoteV3)) []byte {
+		q := newTestQuoteV3()
+		f(&q)
+		return q.raw()
+	}
+	valid := newTestQuoteV3().raw()
+	tests := []struct {
+		name    string
+		raw     []byte
+		wantErr bool
+	}{
+		{name: "valid", raw: valid},
+		{name: "trailing data", raw: append(bytes.Clone(valid), 0, 0)},
+		{name: "version 4", raw: edit(func(q *testQuoteV3) { q.version = quoteVersionV4 }), wantErr: true},
+		{name: "P-384 attestation key", raw: edit(func(q *testQuoteV3) { q.keyType = 3 }), wantErr: true},
+		{name: "header only", raw: valid[:quoteHeaderLen], wantErr: true},
+		{name: "no signature data length", raw: valid[:quoteHeaderLen+reportBodyLen], wantErr: true},
+		{name: "signature data truncated", raw: valid[:len(valid)-1], wantErr: true},
+		{name: "signature data too short", raw: edit(func(q *testQuoteV3) { q.sigData = q.sigData[:ecdsaSigLen] }), wantErr: true},
+		{name: "no QE report", raw: edit(func(q *testQuoteV3) { q.sigData = q.sigData[:ecdsaSigLen+ecdsaPubKeyLen+reportBodyLen] }), wantErr: true},
+		{
+			name: "QE auth data truncated",
+			raw: edit(func(q *testQuoteV3) {
+				q.sigData = testSignatureDataV3([]byte("auth"), CertTypePCKCertChain, nil)
+				binary.LittleEndian.PutUint16(q.sigData[ecdsaSigLen+ecdsaPubKeyLen+reportBodyLen+ecdsaSigLen:], 0xffff)
+			}),
+			wantErr: true,
+		},
+		{
+			name: "certification data truncated",
+			raw: edit(func(q *testQuoteV3) {
+				q.sigData = testSignatureDataV3(nil, CertTypePCKCertChain, []byte("chain"))
+				q.sigData = q.sigData[:len(q.sigData)-1]
+			}),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := ParseQuoteV3(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseQuoteV3() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if q.ReportBody.ReportData[0] != 0x5a {
+				t.Errorf("report data = %x, want 5a...", q.ReportBody.ReportData[:4])
+			}
+			if string(q.SignatureData.QEAuthData) != "auth" {
+				t.Errorf("QE auth data = %q, want %q", q.SignatureData.QEAuthData, "auth")
+			}
+			if c := q.SignatureData.CertificationData; c.Type != CertTypePCKCertChain || !bytes.HasPrefix(c.Data, []byte("-----BEGIN")) {
+				t.Errorf("certification data = %d %q", c.Type, c.Data)
+			}
+			if !bytes.Equal(q.signed, tt.raw[:quoteHeaderLen+reportBodyLen]) {
+				t.Error("signed part is not the header and report body")
+			}
+		})
+	}
+}
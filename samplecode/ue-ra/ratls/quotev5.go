@@ -0,0 +1,157 @@
+package ratls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const quoteVersionV5 = 5
+
+// Report body types of a version 5 quote.
+const (
+	BodyTypeSGX  = 1 // sgx_report_body_t
+	BodyTypeTD10 = 2 // TDREPORT 1.0
+	BodyTypeTD15 = 3 // TDREPORT 1.5
+)
+
+// tdReport15Len is the size of a TDREPORT 1.5 body.
+const tdReport15Len = tdReportLen + 64
+
+// TDReport15 holds the fields TDREPORT 1.5 (sgx_report2_body_v1_5_t)
+// appends to TDReport.
+type TDReport15 struct {
+	TEETCBSVN2  [16]byte
+	MrServiceTD [48]byte
+}
+
+// QuoteV5 is a parsed version 5 quote. The body following the header is
+// typed: BodyType selects ReportBody for SGX enclaves, or TDReport for
+// trust domains, with TDReport15 for TDREPORT 1.5.
+type QuoteV5 struct {
+	Header        QuoteHeader
+	BodyType      uint16
+	BodySize      uint32
+	ReportBody    *ReportBody
+	TDReport      *TDReport
+	TDReport15    *TDReport15
+	SignatureData ECDSASignatureData
+
+	signed []byte
+}
+
+// ParseQuoteV5 decodes a version 5 SGX or TDX quote signed with an ECDSA
+// P-256 attestation key.
+func ParseQuoteV5(raw []byte) (*QuoteV5, error) {
+	if len(raw) < quoteHeaderLen+6 {
+		return nil, errors.New("DCAP quote is too short")
+	}
+	q := &QuoteV5{}
+	r := bytes.NewReader(raw)
+	if err := binary.Read(r, binary.LittleEndian, &q.Header); err != nil {
+		return nil, err
+	}
+	if q.Header.Version != quoteVersionV5 {
+		return nil, fmt.Errorf("unsupported DCAP quote version %d", q.Header.Version)
+	}
+	if q.Header.AttestationKeyType != attKeyTypeP256 {
+		return nil, fmt.Errorf("unsupported attestation key type %d", q.Header.AttestationKeyType)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &q.BodyType); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &q.BodySize); err != nil {
+		return nil, err
+	}
+	if uint64(q.BodySize) > uint64(r.Len()) {
+		return nil, fmt.Errorf("quote body size %d exceeds the %d bytes left", q.BodySize, r.Len())
+	}
+
+	var bodyLen int
+	var teeType uint32
+	switch q.BodyType {
+	case BodyTypeSGX:
+		bodyLen, teeType = reportBodyLen, TEETypeSGX
+	case BodyTypeTD10:
+		bodyLen, teeType = tdReportLen, TEETypeTDX
+	case BodyTypeTD15:
+		bodyLen, teeType = tdReport15Len, TEETypeTDX
+	default:
+		return nil, fmt.Errorf("unsupported quote body type %d", q.BodyType)
+	}
+	if q.Header.TEEType != teeType {
+		return nil, fmt.Errorf("quote body type %d does not match TEE type %#x", q.BodyType, q.Header.TEEType)
+	}
+	if q.BodySize != uint32(bodyLen) {
+		return nil, fmt.Errorf("quote body type %d has size %d, want %d", q.BodyType, q.BodySize, bodyLen)
+	}
+	offset := quoteHeaderLen + 6 + bodyLen
+	if len(raw) < offset+4 {
+		return nil, errors.New("DCAP quote body is truncated")
+	}
+	var err error
+	switch q.BodyType {
+	case BodyTypeSGX:
+		q.ReportBody = &ReportBody{}
+		err = binary.Read(r, binary.LittleEndian, q.ReportBody)
+	default:
+		q.TDReport = &TDReport{}
+		err = binary.Read(r, binary.LittleEndian, q.TDReport)
+		if err == nil && q.BodyType == BodyTypeTD15 {
+			q.TDReport15 = &TDReport15{}
+			err = binary.Read(r, binary.LittleEndian, q.TDReport15)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The signature covers the header and the typed body
+	q.signed = raw[:offset]
+
+	sigDataLen := binary.LittleEndian.Uint32(raw[offset:])
+	offset += 4
+	if uint64(len(raw)-offset) < uint64(sigDataLen) {
+		return nil, errors.New("DCAP quote signature data is truncated")
+	}
+	sig, err := parseECDSASignatureDataV4(raw[offset : offset+int(sigDataLen)])
+	if err != nil {
+		return nil, err
+	}
+	q.SignatureData = *sig
+	return q, nil
+}
+
+// parseECDSAQuote decodes a version 3, 4 or 5 quote into the version 4
+// form shared by the verification paths.
+func parseECDSAQuote(raw []byte) (*QuoteV4, error) {
+	if len(raw) < 2 {
+		return nil, errors.New("DCAP quote is too short")
+	}
+	switch binary.LittleEndian.Uint16(raw) {
+	case quoteVersionV3:
+		q, err := ParseQuoteV3(raw)
+		if err != nil {
+			return nil, err
+		}
+		return &QuoteV4{
+			Header:        q.Header,
+			ReportBody:    &q.ReportBody,
+			SignatureData: q.SignatureData,
+			signed:        q.signed,
+		}, nil
+	case quoteVersionV5:
+		q, err := ParseQuoteV5(raw)
+		if err != nil {
+			return nil, err
+		}
+		return &QuoteV4{
+			Header:        q.Header,
+			ReportBody:    q.ReportBody,
+			TDReport:      q.TDReport,
+			SignatureData: q.SignatureData,
+			signed:        q.signed,
+		}, nil
+	}
+	return ParseQuoteV4(raw)
+}
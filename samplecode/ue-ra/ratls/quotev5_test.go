@@ -0,0 +1,148 @@
+package ratls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// testQuoteV5 describes a version 5 quote built by raw.
+type testQuoteV5 struct {
+	version  uint16
+	keyType  uint16
+	teeType  uint32
+	bodyType uint16
+	bodySize uint32
+	body     []byte
+	// sigDataLen overrides the length of the signature data if not zero.
+	sigDataLen uint32
+	sigData    []byte
+}
+
+// newTestQuoteV5 returns a well-formed quote of bodyType whose report data
+// starts with 0x5a.
+func newTestQuoteV5(bodyType uint16) testQuoteV5 {
+	q := testQuoteV5{version: quoteVersionV5, keyType: attKeyTypeP256, bodyType: bodyType, sigData: testSignatureDataV4()}
+	switch bodyType {
+	case BodyTypeSGX:
+		q.teeType, q.body = TEETypeSGX, make([]byte, reportBodyLen)
+	case BodyTypeTD10:
+		q.teeType, q.body = TEETypeTDX, make([]byte, tdReportLen)
+	case BodyTypeTD15:
+		q.teeType, q.body = TEETypeTDX, make([]byte, tdReport15Len)
+	}
+	// report_data ends sgx_report_body_t and TDREPORT 1.0
+	if bodyType == BodyTypeSGX {
+		q.body[reportBodyLen-64] = 0x5a
+	} else {
+		q.body[tdReportLen-64] = 0x5a
+	}
+	q.bodySize = uint32(len(q.body))
+	return q
+}
+
+func (q testQuoteV5) raw() []byte {
+	var b bytes.Buffer
+	header := QuoteHeader{Version: q.version, AttestationKeyType: q.keyType, TEEType: q.teeType}
+	binary.Write(&b, binary.LittleEndian, header)
+	binary.Write(&b, binary.LittleEndian, q.bodyType)
+	binary.Write(&b, binary.LittleEndian, q.bodySize)
+	b.Write(q.body)
+	sigDataLen := q.sigDataLen
+	if sigDataLen == 0 {
+		sigDataLen = uint32(len(q.sigData))
+	}
+	binary.Write(&b, binary.LittleEndian, sigDataLen)
+	b.Write(q.sigData)
+	return b.Bytes()
+}
+
+// testSignatureDataV4 returns signature data in the v4 layout: the quote
+// signature and attestation key, then certification data of type 6 with
+// the QE report and a PCK chain of certification data type 5.
+func testSignatureDataV4() []byte {
+	certData := func(typ uint16, data []byte) []byte {
+		b := make([]byte, 6, 6+len(data))
+		binary.LittleEndian.PutUint16(b, typ)
+		binary.LittleEndian.PutUint32(b[2:], uint32(len(data)))
+		return append(b, data...)
+	}
+	qeReportCertData := make([]byte, reportBodyLen+ecdsaSigLen+2)
+	qeReportCertData = append(qeReportCertData, certData(CertTypePCKCertChain, []byte("-----BEGIN CERTIFICATE-----"))...)
+	sigData := make([]byte, ecdsaSigLen+ecdsaPubKeyLen)
+	return append(sigData, certData(CertTypeECDSASigAuxData, qeReportCertData)...)
+}
+
+func TestParseQuoteV5(t *testing.T) {
+	edit := func(bodyType uint16, f func(q *testQuoteV5)) []byte {
+		q := newTestQuoteV5(bodyType)
+		f(&q)
+		return q.raw()
+	}
+
+	tests := []struct {
+		name     string
+		raw      []byte
+		bodyType uint16
+		wantErr  bool
+	}{
+		{name: "SGX", raw: newTestQuoteV5(BodyTypeSGX).raw(), bodyType: BodyTypeSGX},
+		{name: "TDREPORT 1.0", raw: newTestQuoteV5(BodyTypeTD10).raw(), bodyType: BodyTypeTD10},
+		{name: "TDREPORT 1.5", raw: newTestQuoteV5(BodyTypeTD15).raw(), bodyType: BodyTypeTD15},
+		{name: "too short", raw: newTestQuoteV5(BodyTypeSGX).raw()[:quoteHeaderLen+5], wantErr: true},
+		{name: "version 4", raw: edit(BodyTypeSGX, func(q *testQuoteV5) { q.version = quoteVersionV4 }), wantErr: true},
+		{name: "EPID key", raw: edit(BodyTypeSGX, func(q *testQuoteV5) { q.keyType = 0 }), wantErr: true},
+		{name: "unknown body type", raw: edit(BodyTypeSGX, func(q *testQuoteV5) { q.bodyType = 4 }), wantErr: true},
+		{name: "SGX body in a TDX quote", raw: edit(BodyTypeSGX, func(q *testQuoteV5) { q.teeType = TEETypeTDX }), wantErr: true},
+		{name: "TD body in an SGX quote", raw: edit(BodyTypeTD10, func(q *testQuoteV5) { q.teeType = TEETypeSGX }), wantErr: true},
+		{name: "body size of another type", raw: edit(BodyTypeTD15, func(q *testQuoteV5) { q.bodySize = tdReportLen }), wantErr: true},
+		{name: "body size beyond the quote", raw: edit(BodyTypeSGX, func(q *testQuoteV5) { q.bodySize = 1 << 31 }), wantErr: true},
+		{name: "truncated body", raw: newTestQuoteV5(BodyTypeTD10).raw()[:quoteHeaderLen+6+reportBodyLen], wantErr: true},
+		{name: "missing signature data length", raw: newTestQuoteV5(BodyTypeSGX).raw()[:quoteHeaderLen+6+reportBodyLen+2], wantErr: true},
+		{name: "truncated signature data", raw: edit(BodyTypeSGX, func(q *testQuoteV5) { q.sigDataLen = uint32(len(q.sigData) + 1) }), wantErr: true},
+		{name: "short signature data", raw: edit(BodyTypeSGX, func(q *testQuoteV5) { q.sigData = q.sigData[:100] }), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := ParseQuoteV5(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseQuoteV5() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if q.BodyType != tt.bodyType {
+				t.Errorf("BodyType = %d, want %d", q.BodyType, tt.bodyType)
+			}
+			var reportData byte
+			switch {
+			case q.ReportBody != nil:
+				reportData = q.ReportBody.ReportData[0]
+			case q.TDReport != nil:
+				reportData = q.TDReport.ReportData[0]
+			}
+			if reportData != 0x5a {
+				t.Error("report data was not decoded from the body")
+			}
+			if (q.TDReport15 != nil) != (tt.bodyType == BodyTypeTD15) {
+				t.Errorf("TDReport15 = %v for body type %d", q.TDReport15, tt.bodyType)
+			}
+			if q.SignatureData.CertificationData.Type != CertTypePCKCertChain {
+				t.Errorf("certification data type %d, want %d", q.SignatureData.CertificationData.Type, CertTypePCKCertChain)
+			}
+			if want := quoteHeaderLen + 6 + int(q.BodySize); len(q.signed) != want {
+				t.Errorf("signed %d bytes, want %d", len(q.signed), want)
+			}
+		})
+	}
+}
+
+func TestParseECDSAQuoteV5(t *testing.T) {
+	q, err := parseECDSAQuote(newTestQuoteV5(BodyTypeTD15).raw())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.TDReport == nil || q.ReportBody != nil || q.Header.Version != quoteVersionV5 {
+		t.Errorf("parseECDSAQuote() = %+v, want the TD report of a v5 quote", q)
+	}
+}
@@ -0,0 +1,64 @@
+//go:build qvl
+
+package ratls
+
+/*
+#cgo LDFLAGS: -lsgx_dcap_quoteverify
+#include <time.h>
+#include <sgx_dcap_quoteverify.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unsafe"
+)
+
+// QVLBackend verifies quotes with sgx_qv_verify_quote from Intel's
+// libsgx_dcap_quoteverify, in untrusted mode. The library fetches the
+// collateral itself through the quote provider library, configured in
+// /etc/sgx_default_qcnl.conf. It is only available with the qvl build tag.
+type QVLBackend struct{}
+
+// VerifyQuote implements QuoteBackend.
+func (QVLBackend) VerifyQuote(quote []byte, now time.Time) (string, error) {
+	if len(quote) == 0 {
+		return "", errors.New("empty quote")
+	}
+	var expired C.uint32_t
+	var result C.sgx_ql_qv_result_t
+	ret := C.sgx_qv_verify_quote(
+		(*C.uint8_t)(unsafe.Pointer(&quote[0])), C.uint32_t(len(quote)),
+		nil, C.time_t(now.Unix()),
+		&expired, &result,
+		nil, 0, nil)
+	if ret != C.SGX_QL_SUCCESS {
+		return "", fmt.Errorf("sgx_qv_verify_quote failed: %#x", uint32(ret))
+	}
+	if expired != 0 {
+		return "", errors.New("quote verification collateral has expired")
+	}
+
+	switch result {
+	case C.SGX_QL_QV_RESULT_OK:
+		return "UpToDate", nil
+	case C.SGX_QL_QV_RESULT_CONFIG_NEEDED:
+		return "ConfigurationNeeded", nil
+	case C.SGX_QL_QV_RESULT_OUT_OF_DATE:
+		return "OutOfDate", nil
+	case C.SGX_QL_QV_RESULT_OUT_OF_DATE_CONFIG_NEEDED:
+		return "OutOfDateConfigurationNeeded", nil
+	case C.SGX_QL_QV_RESULT_SW_HARDENING_NEEDED:
+		return "SWHardeningNeeded", nil
+	case C.SGX_QL_QV_RESULT_CONFIG_AND_SW_HARDENING_NEEDED:
+		return "ConfigurationAndSWHardeningNeeded", nil
+	case C.SGX_QL_QV_RESULT_REVOKED:
+		return "", &QuoteStatusError{Status: "Revoked"}
+	case C.SGX_QL_QV_RESULT_INVALID_SIGNATURE:
+		return "", failure(ErrBadSignature, errors.New("QVL: quote signature is invalid"))
+	default:
+		return "", fmt.Errorf("QVL: quote verification failed: %#x", uint32(result))
+	}
+}
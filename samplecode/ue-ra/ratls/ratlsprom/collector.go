@@ -0,0 +1,109 @@
+// Package ratlsprom exports the metrics of ratls verifiers to Prometheus.
+//
+//	c := ratlsprom.NewCollector("ratls")
+//	prometheus.MustRegister(c)
+//	verifier.Metrics = c
+//	cache.Metrics = c
+package ratlsprom
+
+import (
+	"time"
+
+	"github.com/apache/incubator-teaclave-sgx-sdk/samplecode/ue-ra/ratls"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector is a prometheus.Collector implementing ratls.Metrics. It
+// exports:
+//
+//   - <namespace>_verifications_total{mode,outcome,reason}: verifications
+//     by outcome, "accept" or "reject", and for rejections by cause, as
+//     given by ratls.Reason
+//   - <namespace>_attestation_status_total{mode,status}: IAS quote statuses
+//     and DCAP/TDX TCB statuses of the verified evidence
+//   - <namespace>_verification_duration_seconds{mode}: verification latency
+//   - <namespace>_collateral_cache_lookups_total{result}: collateral cache
+//     "hit" and "miss" counts
+type Collector struct {
+	verifications *prometheus.CounterVec
+	statuses      *prometheus.CounterVec
+	duration      *prometheus.HistogramVec
+	cache         *prometheus.CounterVec
+}
+
+// NewCollector returns a Collector whose metric names start with
+// namespace.
+func NewCollector(namespace string) *Collector {
+	return &Collector{
+		verifications: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "verifications_total",
+			Help:      "RA-TLS verifications by attestation mode, outcome and failure reason.",
+		}, []string{"mode", "outcome", "reason"}),
+		statuses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "attestation_status_total",
+			Help:      "Quote and TCB statuses of verified attestation evidence.",
+		}, []string{"mode", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "verification_duration_seconds",
+			Help:      "Duration of RA-TLS verifications, including collateral fetches.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 8),
+		}, []string{"mode"}),
+		cache: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "collateral_cache_lookups_total",
+			Help:      "Collateral cache lookups by result.",
+		}, []string{"result"}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.verifications.Describe(ch)
+	c.statuses.Describe(ch)
+	c.duration.Describe(ch)
+	c.cache.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.verifications.Collect(ch)
+	c.statuses.Collect(ch)
+	c.duration.Collect(ch)
+	c.cache.Collect(ch)
+}
+
+// ObserveVerification implements ratls.Metrics.
+func (c *Collector) ObserveVerification(res *ratls.VerificationResult, err error, elapsed time.Duration) {
+	// Failures before the evidence is decoded have no result
+	mode := "unknown"
+	if res != nil {
+		mode = res.Mode.String()
+	}
+	outcome := "accept"
+	if err != nil {
+		outcome = "reject"
+	}
+	c.verifications.WithLabelValues(mode, outcome, ratls.Reason(err)).Inc()
+	c.duration.WithLabelValues(mode).Observe(elapsed.Seconds())
+	if res == nil {
+		return
+	}
+	if res.QuoteStatus != "" {
+		c.statuses.WithLabelValues(mode, res.QuoteStatus).Inc()
+	}
+	if res.TCBStatus != "" {
+		c.statuses.WithLabelValues(mode, res.TCBStatus).Inc()
+	}
+}
+
+// ObserveCollateralLookup implements ratls.Metrics.
+func (c *Collector) ObserveCollateralLookup(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	c.cache.WithLabelValues(result).Inc()
+}
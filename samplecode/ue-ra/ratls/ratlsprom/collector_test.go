@@ -0,0 +1,108 @@
+package ratlsprom
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/incubator-teaclave-sgx-sdk/samplecode/ue-ra/ratls"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// gather returns the counter values and histogram sample counts of c, keyed
+// by metric name and labels.
+func gather(t *testing.T, c *Collector) map[string]float64 {
+	t.Helper()
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(c); err != nil {
+		t.Fatal(err)
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]float64)
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			var labels []string
+			for _, l := range m.GetLabel() {
+				labels = append(labels, l.GetName()+"="+l.GetValue())
+			}
+			key := fmt.Sprintf("%s{%s}", f.GetName(), strings.Join(labels, ","))
+			if h := m.GetHistogram(); h != nil {
+				got[key] = float64(h.GetSampleCount())
+			} else {
+				got[key] = m.GetCounter().GetValue()
+			}
+		}
+	}
+	return got
+}
+
+func TestCollector(t *testing.T) {
+	dcap := &ratls.VerificationResult{Mode: ratls.ModeDCAP, TCBStatus: "UpToDate"}
+	epid := &ratls.VerificationResult{Mode: ratls.ModeEPID, QuoteStatus: "GROUP_OUT_OF_DATE"}
+	tests := []struct {
+		name    string
+		observe func(c *Collector)
+		want    map[string]float64
+	}{
+		{
+			name: "accepted",
+			observe: func(c *Collector) {
+				c.ObserveVerification(dcap, nil, time.Millisecond)
+				c.ObserveVerification(dcap, nil, time.Millisecond)
+			},
+			want: map[string]float64{
+				"test_verifications_total{mode=dcap,outcome=accept,reason=}": 2,
+				"test_attestation_status_total{mode=dcap,status=UpToDate}":   2,
+				"test_verification_duration_seconds{mode=dcap}":              2,
+			},
+		},
+		{
+			name: "rejected by status",
+			observe: func(c *Collector) {
+				c.ObserveVerification(epid, fmt.Errorf("quote: %w", ratls.ErrQuoteStatus), time.Second)
+			},
+			want: map[string]float64{
+				"test_verifications_total{mode=epid,outcome=reject,reason=quote_status}": 1,
+				"test_attestation_status_total{mode=epid,status=GROUP_OUT_OF_DATE}":      1,
+				"test_verification_duration_seconds{mode=epid}":                          1,
+			},
+		},
+		{
+			name: "malformed evidence",
+			observe: func(c *Collector) {
+				c.ObserveVerification(nil, errors.New("certificate carries no attestation payload"), 0)
+			},
+			want: map[string]float64{
+				"test_verifications_total{mode=unknown,outcome=reject,reason=other}": 1,
+				"test_verification_duration_seconds{mode=unknown}":                   1,
+			},
+		},
+		{
+			name: "collateral cache",
+			observe: func(c *Collector) {
+				c.ObserveCollateralLookup(false)
+				c.ObserveCollateralLookup(true)
+				c.ObserveCollateralLookup(true)
+			},
+			want: map[string]float64{
+				"test_collateral_cache_lookups_total{result=hit}":  2,
+				"test_collateral_cache_lookups_total{result=miss}": 1,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCollector("test")
+			tt.observe(c)
+			if got := gather(t, c); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("metrics = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
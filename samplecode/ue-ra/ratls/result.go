@@ -0,0 +1,146 @@
+package ratls
+
+import (
+	"encoding/hex"
+	"time"
+)
+
+// VerificationResult describes the attested enclave and the evidence it
+// presented, so applications can make authorization decisions on it.
+type VerificationResult struct {
+	Mode Mode
+
+	// PublicKey is the value report_data must start with to bind the
+	// certificate public key, as selected by Verifier.Binding. For TCG DICE
+	// tagged evidence it is the SHA-256 of the claims carrying the key
+	// hash. For evidence sent after the handshake it is the
+	// ChannelBinding of the connection. KeyBound reports whether
+	// report_data starts with it.
+	PublicKey []byte
+	KeyType   string
+	KeyBound  bool
+	// altPublicKey is the value report_data may start with instead of
+	// PublicKey, see BindingKeyOrSPKIHash.
+	altPublicKey []byte
+	// nonceBound reports that PublicKey hashes Verifier.Nonce in, so
+	// KeyBound covers the challenge as well.
+	nonceBound bool
+	// PKIVerified reports whether the certificate chains to
+	// Verifier.PKIRoots.
+	PKIVerified bool
+	// InsecureTestRoots reports that the evidence was verified against
+	// test roots of trust, which proves nothing about genuine hardware.
+	InsecureTestRoots bool
+	// Simulated reports that the enclave runs in simulation mode, see
+	// Verifier.AllowSimulation. Its identity is not attested at all.
+	Simulated bool
+
+	// Enclave identity from the quote's report body, hex encoded where the
+	// SGX type is a byte array. In ModeTDX, MrEnclave holds MRTD.
+	MrEnclave  string
+	MrSigner   string
+	ReportData string
+	ISVProdID  uint16
+	ISVSVN     uint16
+	Debug      bool
+	CPUSVN     string
+	MiscSelect uint32
+	Attributes Attributes
+
+	QuoteVersion int
+	// SignType is the EPID signature type, only set in ModeEPID.
+	SignType EPIDSignType
+	// EPIDGroupID is the EPID group of the platform, only set in ModeEPID.
+	EPIDGroupID string
+
+	// TDReport is the trust domain identity, only set in ModeTDX.
+	TDReport *TDReport
+
+	// IAS report fields, only set in ModeEPID.
+	ReportID     string
+	Timestamp    time.Time
+	QuoteStatus  string
+	AdvisoryURL  string
+	PlatformInfo *PlatformInfoBlob
+	// PlatformUpdate is the TCB recovery advice of PlatformInfo.
+	PlatformUpdate *PlatformUpdate
+	// PlatformInfoVerified reports whether the signature of PlatformInfo
+	// was checked against Verifier.PlatformInfoKey.
+	PlatformInfoVerified bool
+	// IASConfirmed reports whether IAS returned the same report when
+	// queried by its ID, see Verifier.IAS.
+	IASConfirmed bool
+	// ReportSignatureAlgorithm is the algorithm the report is signed
+	// with, e.g. "SHA256-RSA".
+	ReportSignatureAlgorithm string
+
+	// Claims are the application-defined claims appended to an IAS report
+	// bundle, decoded from JSON or CBOR, and RawClaims their encoding. They
+	// are nil if the enclave sent none.
+	Claims    map[string]any
+	RawClaims []byte
+
+	// Attestation token issuer and validity, only set in ModeMAA and
+	// ModeITA. The token binds the key through the runtime data the enclave
+	// submitted rather than report_data.
+	TokenIssuer   string
+	TokenIssuedAt time.Time
+	TokenExpiry   time.Time
+
+	// TCB level of the platform and of the quoting enclave, only set in
+	// ModeDCAP when the Verifier has a collateral source. In ModeITA,
+	// TCBStatus and TCBDate are taken from the token.
+	FMSPC     string
+	TCBStatus string
+	TCBDate   string
+	QEStatus  string
+
+	// AdvisoryIDs lists the security advisories reported by IAS or
+	// attached to the platform TCB level.
+	AdvisoryIDs []string
+
+	// PolicyReasons explains the decision of Verifier.Policy, if set.
+	PolicyReasons []string
+}
+
+func (r *VerificationResult) setReportBody(body *QuoteReportBody) {
+	r.MrEnclave = body.mrEnclave
+	r.MrSigner = body.mrSigner
+	r.ReportData = body.reportData
+	r.ISVProdID = body.isvProdID
+	r.ISVSVN = body.isvSvn
+	r.CPUSVN = body.cpuSvn
+	r.MiscSelect = body.miscSelect
+	r.Attributes = body.attributes
+	r.Debug = body.attributes.Flags&sgxFlagsDebug != 0
+	r.KeyBound = r.bindsKey()
+}
+
+func (r *VerificationResult) setTDReport(td *TDReport) {
+	r.TDReport = td
+	r.MrEnclave = hex.EncodeToString(td.MrTD[:])
+	r.ReportData = hex.EncodeToString(td.ReportData[:])
+	r.Debug = td.Debug()
+	r.KeyBound = r.bindsKey()
+}
+
+func (r *VerificationResult) bindsKey() bool {
+	reportData, err := hex.DecodeString(r.ReportData)
+	return err == nil && r.binds(reportData, false)
+}
+
+// binds reports whether data starts with, or if exact is equal to,
+// PublicKey or the alternative binding value, which then becomes
+// PublicKey.
+func (r *VerificationResult) binds(data []byte, exact bool) bool {
+	for _, key := range [][]byte{r.PublicKey, r.altPublicKey} {
+		if len(key) == 0 {
+			continue
+		}
+		if exact && equalBytes(data, key) || !exact && hasPrefixBytes(data, key) {
+			r.PublicKey = key
+			return true
+		}
+	}
+	return false
+}
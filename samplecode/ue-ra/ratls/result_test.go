@@ -0,0 +1,53 @@
+package ratls
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSetReportBody(t *testing.T) {
+	key := bytes.Repeat([]byte{0x5a}, 64)
+	body := func(edit func(*ReportBody)) *ReportBody {
+		b := &ReportBody{ISVProdID: 2, ISVSVN: 3, MiscSelect: 4}
+		b.MrEnclave[0] = 0xaa
+		b.MrSigner[0] = 0xbb
+		b.CPUSVN[0] = 0xcc
+		copy(b.ReportData[:], key)
+		if edit != nil {
+			edit(b)
+		}
+		return b
+	}
+	tests := []struct {
+		name         string
+		body         *ReportBody
+		publicKey    []byte
+		wantKeyBound bool
+		wantDebug    bool
+	}{
+		{name: "key bound", body: body(nil), publicKey: key, wantKeyBound: true},
+		{name: "other key", body: body(nil), publicKey: bytes.Repeat([]byte{0x01}, 64)},
+		{name: "key prefix", body: body(nil), publicKey: key[:32], wantKeyBound: true},
+		{name: "no key", body: body(nil)},
+		{name: "debug", body: body(func(b *ReportBody) { b.Attributes.Flags = sgxFlagsDebug }), publicKey: key, wantKeyBound: true, wantDebug: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &VerificationResult{PublicKey: tt.publicKey}
+			res.setReportBody(tt.body.quoteReportBody())
+			if !strings.HasPrefix(res.MrEnclave, "aa00") || !strings.HasPrefix(res.MrSigner, "bb00") || !strings.HasPrefix(res.CPUSVN, "cc00") {
+				t.Errorf("MrEnclave, MrSigner, CPUSVN = %s, %s, %s", res.MrEnclave, res.MrSigner, res.CPUSVN)
+			}
+			if res.ISVProdID != 2 || res.ISVSVN != 3 || res.MiscSelect != 4 {
+				t.Errorf("ISVProdID, ISVSVN, MiscSelect = %d, %d, %d", res.ISVProdID, res.ISVSVN, res.MiscSelect)
+			}
+			if res.KeyBound != tt.wantKeyBound {
+				t.Errorf("KeyBound = %v, want %v", res.KeyBound, tt.wantKeyBound)
+			}
+			if res.Debug != tt.wantDebug {
+				t.Errorf("Debug = %v, want %v", res.Debug, tt.wantDebug)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package ratls
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RevocationPolicy selects how a Verifier reacts when a CRL needed to check
+// the PCK certificate chain is unavailable or expired.
+type RevocationPolicy int
+
+const (
+	// RevocationSoftFail checks every certificate a valid CRL is available
+	// for and accepts the others.
+	RevocationSoftFail RevocationPolicy = iota
+	// RevocationHardFail rejects the chain unless every certificate can be
+	// checked against a valid CRL.
+	RevocationHardFail
+)
+
+var errCRLUnavailable = errors.New("no valid CRL available")
+
+// ParseCRL decodes a PEM or DER encoded CRL.
+func ParseCRL(raw []byte) (*x509.RevocationList, error) {
+	if block, _ := pem.Decode(raw); block != nil {
+		raw = block.Bytes
+	}
+	return x509.ParseRevocationList(raw)
+}
+
+// checkRevocation checks every certificate of a verified path, such as the
+// PCK path from the PCK certificate to the root, against the CRL of its
+// issuer. CRLs come from the Verifier and from the collateral, if any.
+func (v *Verifier) checkRevocation(path []*x509.Certificate, col *Collateral, now time.Time) error {
+	var crls []*x509.RevocationList
+	raws := v.CRLs
+	if col != nil {
+		raws = append(raws[:len(raws):len(raws)], col.PCKCRL, col.RootCACRL)
+	}
+	for _, raw := range raws {
+		if len(raw) == 0 {
+			continue
+		}
+		crl, err := ParseCRL(raw)
+		if err != nil {
+			return fmt.Errorf("parse CRL: %v", err)
+		}
+		crls = append(crls, crl)
+	}
+
+	for i := 0; i+1 < len(path); i++ {
+		cert, issuer := path[i], path[i+1]
+		err := checkCRL(cert, issuer, crls, now)
+		if err == errCRLUnavailable && v.Revocation == RevocationSoftFail {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("certificate %q: %w", cert.Subject.CommonName, err)
+		}
+	}
+	return nil
+}
+
+// checkSignerRevocation checks the TCB Signing certificate of a collateral
+// issuer chain, already verified, against the Root CA CRL like the PCK
+// chain.
+func (v *Verifier) checkSignerRevocation(chain []*x509.Certificate, col *Collateral, now time.Time) error {
+	path, err := verifyIssuerChain(chain, v.sgxRoots(), now)
+	if err != nil {
+		return failure(ErrBadSignature, err)
+	}
+	return v.checkRevocation(path, col, now)
+}
+
+func checkCRL(cert, issuer *x509.Certificate, crls []*x509.RevocationList, now time.Time) error {
+	for _, crl := range crls {
+		if !bytes.Equal(crl.RawIssuer, issuer.RawSubject) || crl.CheckSignatureFrom(issuer) != nil {
+			continue
+		}
+		if now.Before(crl.ThisUpdate) || now.After(crl.NextUpdate) {
+			continue
+		}
+		for _, entry := range crl.RevokedCertificateEntries {
+			if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				return failuref(ErrRevoked, "revoked at %v", entry.RevocationTime)
+			}
+		}
+		return nil
+	}
+	return errCRLUnavailable
+}
@@ -0,0 +1,155 @@
+package ratls
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// newTestCRL returns a DER CRL of issuer valid from thisUpdate for a month
+// and listing the given certificates.
+func newTestCRL(t *testing.T, issuer *x509.Certificate, key *ecdsa.PrivateKey, thisUpdate time.Time, revoked ...*x509.Certificate) []byte {
+	t.Helper()
+	tmpl := &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: thisUpdate,
+		NextUpdate: thisUpdate.AddDate(0, 1, 0),
+	}
+	for _, cert := range revoked {
+		tmpl.RevokedCertificateEntries = append(tmpl.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   cert.SerialNumber,
+			RevocationTime: thisUpdate,
+		})
+	}
+	crl, err := x509.CreateRevocationList(rand.Reader, tmpl, issuer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crl
+}
+
+func TestCheckSignerRevocation(t *testing.T) {
+	pki := newTestCollateralPKI(t)
+	other, _ := newTestCert(t, tcbSigningCN, false, pki.root, pki.rootKey)
+	platformCA, platformCAKey := newTestCert(t, "Intel SGX PCK Platform CA", true, pki.root, pki.rootKey)
+	chain := []*x509.Certificate{pki.signer, pki.root}
+
+	tests := []struct {
+		name       string
+		crls       [][]byte
+		rootCACRL  []byte
+		revocation RevocationPolicy
+		wantErr    bool
+		is         error
+	}{
+		{name: "not revoked", rootCACRL: newTestCRL(t, pki.root, pki.rootKey, testNow.AddDate(0, 0, -1), other)},
+		{name: "revoked in the collateral CRL", rootCACRL: newTestCRL(t, pki.root, pki.rootKey, testNow.AddDate(0, 0, -1), pki.signer), wantErr: true, is: ErrRevoked},
+		{name: "revoked in a supplied CRL", crls: [][]byte{newTestCRL(t, pki.root, pki.rootKey, testNow.AddDate(0, 0, -1), pki.signer)}, wantErr: true, is: ErrRevoked},
+		{name: "no CRL, soft-fail"},
+		{name: "no CRL, hard-fail", revocation: RevocationHardFail, wantErr: true},
+		{name: "expired CRL, hard-fail", rootCACRL: newTestCRL(t, pki.root, pki.rootKey, testNow.AddDate(0, -2, 0), pki.signer), revocation: RevocationHardFail, wantErr: true},
+		{name: "CRL of another issuer", rootCACRL: newTestCRL(t, platformCA, platformCAKey, testNow.AddDate(0, 0, -1), pki.signer), revocation: RevocationHardFail, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Verifier{SGXRoots: pki.roots, CRLs: tt.crls, Revocation: tt.revocation}
+			err := v.checkSignerRevocation(chain, &Collateral{RootCACRL: tt.rootCACRL}, testNow)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkSignerRevocation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.is != nil && !errors.Is(err, tt.is) {
+				t.Errorf("checkSignerRevocation() error = %v, want %v", err, tt.is)
+			}
+		})
+	}
+}
+
+func TestEvaluateTCBSignerRevoked(t *testing.T) {
+	pki := newTestCollateralPKI(t)
+	pck := &PCKExtensions{PCESVN: 11, FMSPC: "00906ed50000", PCEID: "0000"}
+	copy(pck.CompSVN[:], []int{17, 17, 2, 4, 1, 128})
+
+	tests := []struct {
+		name    string
+		revoked []*x509.Certificate
+		wantErr bool
+	}{
+		{name: "signer valid"},
+		{name: "signer revoked", revoked: []*x509.Certificate{pki.signer}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			col := &Collateral{
+				TCBInfo:            signDocument(t, pki.signerKey, "tcbInfo", sampleTCBInfo(t, nil)),
+				TCBInfoIssuerChain: []*x509.Certificate{pki.signer, pki.root},
+				RootCACRL:          newTestCRL(t, pki.root, pki.rootKey, testNow.AddDate(0, 0, -1), tt.revoked...),
+			}
+			v := &Verifier{SGXRoots: pki.roots}
+			res := &VerificationResult{}
+			err := v.evaluateTCB(pck, col, nil, testNow, res)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("evaluateTCB() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrRevoked) {
+				t.Errorf("evaluateTCB() error = %v, want %v", err, ErrRevoked)
+			}
+		})
+	}
+}
+
+func TestCheckRevocation(t *testing.T) {
+	pki := newTestCollateralPKI(t)
+	ca, caKey := newTestCert(t, pckPlatformCACN, true, pki.root, pki.rootKey)
+	leaf, _ := newTestCert(t, pckCertCN, false, ca, caKey)
+	other, _ := newTestCert(t, pckCertCN, false, ca, caKey)
+	// forged claims the name of ca with another key
+	forged, forgedKey := newTestCert(t, pckPlatformCACN, true, pki.root, pki.rootKey)
+	path := []*x509.Certificate{leaf, ca, pki.root}
+	yesterday := testNow.AddDate(0, 0, -1)
+	rootCACRL := newTestCRL(t, pki.root, pki.rootKey, yesterday)
+
+	tests := []struct {
+		name       string
+		col        *Collateral
+		crls       [][]byte
+		revocation RevocationPolicy
+		wantErr    bool
+		is         error
+	}{
+		{name: "not revoked", col: &Collateral{PCKCRL: newTestCRL(t, ca, caKey, yesterday, other), RootCACRL: rootCACRL}, revocation: RevocationHardFail},
+		{name: "PCK certificate revoked", col: &Collateral{PCKCRL: newTestCRL(t, ca, caKey, yesterday, leaf), RootCACRL: rootCACRL}, wantErr: true, is: ErrRevoked},
+		{name: "PCK CA revoked", col: &Collateral{PCKCRL: newTestCRL(t, ca, caKey, yesterday), RootCACRL: newTestCRL(t, pki.root, pki.rootKey, yesterday, ca)}, wantErr: true, is: ErrRevoked},
+		{
+			name:    "PEM CRL",
+			crls:    [][]byte{pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: newTestCRL(t, ca, caKey, yesterday, leaf)})},
+			wantErr: true,
+			is:      ErrRevoked,
+		},
+		{name: "malformed CRL", crls: [][]byte{[]byte("not a CRL")}, wantErr: true},
+		{name: "no PCK CRL, soft-fail", col: &Collateral{RootCACRL: rootCACRL}},
+		{name: "no PCK CRL, hard-fail", col: &Collateral{RootCACRL: rootCACRL}, revocation: RevocationHardFail, wantErr: true},
+		{
+			name:       "CRL signed by another key",
+			col:        &Collateral{PCKCRL: newTestCRL(t, forged, forgedKey, yesterday), RootCACRL: rootCACRL},
+			revocation: RevocationHardFail,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Verifier{CRLs: tt.crls, Revocation: tt.revocation}
+			err := v.checkRevocation(path, tt.col, testNow)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkRevocation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.is != nil && !errors.Is(err, tt.is) {
+				t.Errorf("checkRevocation() error = %v, want %v", err, tt.is)
+			}
+		})
+	}
+}
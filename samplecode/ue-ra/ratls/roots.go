@@ -0,0 +1,46 @@
+package ratls
+
+import (
+	"crypto/x509"
+	_ "embed"
+)
+
+// iasRootPEM is the Intel SGX Attestation Report Signing CA, shipped with
+// the module so clients work without extra files.
+//
+//go:embed certs/AttestationReportSigningCACert.pem
+var iasRootPEM []byte
+
+// IntelIASRoots returns a pool holding the embedded Intel attestation report
+// signing CA. It is used when Verifier.IASRoots is nil.
+func IntelIASRoots() *x509.CertPool {
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(iasRootPEM) {
+		panic("ratls: embedded IAS root certificate is invalid")
+	}
+	return roots
+}
+
+// sgxRootPEM is the Intel SGX Root CA, which anchors PCK certificate
+// chains and the TCB Signing certificate of DCAP collateral.
+//
+//go:embed certs/Intel_SGX_Provisioning_Certification_RootCA.pem
+var sgxRootPEM []byte
+
+// IntelSGXRoots returns a pool holding the embedded Intel SGX Root CA. It
+// is used when Verifier.SGXRoots is nil.
+func IntelSGXRoots() *x509.CertPool {
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(sgxRootPEM) {
+		panic("ratls: embedded Intel SGX root certificate is invalid")
+	}
+	return roots
+}
+
+// sgxRoots returns SGXRoots, or the embedded Intel SGX Root CA if unset.
+func (v *Verifier) sgxRoots() *x509.CertPool {
+	if v.SGXRoots != nil {
+		return v.SGXRoots
+	}
+	return IntelSGXRoots()
+}
@@ -0,0 +1,53 @@
+package ratls
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+)
+
+func TestEmbeddedRoots(t *testing.T) {
+	tests := []struct {
+		name  string
+		pem   []byte
+		pool  func() *x509.CertPool
+		cn    string
+		valid time.Time
+	}{
+		{name: "IAS", pem: iasRootPEM, pool: IntelIASRoots, cn: "Intel SGX Attestation Report Signing CA", valid: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "SGX", pem: sgxRootPEM, pool: IntelSGXRoots, cn: sgxRootCACN, valid: time.Date(2049, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block, _ := pem.Decode(tt.pem)
+			if block == nil {
+				t.Fatal("embedded root is not PEM encoded")
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cert.Subject.CommonName != tt.cn {
+				t.Errorf("CN = %q, want %q", cert.Subject.CommonName, tt.cn)
+			}
+			if err := cert.CheckSignatureFrom(cert); err != nil {
+				t.Errorf("root is not self-signed: %v", err)
+			}
+			opts := x509.VerifyOptions{Roots: tt.pool(), CurrentTime: tt.valid}
+			if _, err := cert.Verify(opts); err != nil {
+				t.Errorf("root does not verify against its pool: %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifierSGXRoots(t *testing.T) {
+	custom := x509.NewCertPool()
+	if got := (&Verifier{SGXRoots: custom}).sgxRoots(); got != custom {
+		t.Error("sgxRoots() does not return SGXRoots")
+	}
+	if !(&Verifier{}).sgxRoots().Equal(IntelSGXRoots()) {
+		t.Error("sgxRoots() of the zero Verifier is not the embedded Intel SGX Root CA")
+	}
+}
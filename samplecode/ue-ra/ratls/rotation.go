@@ -0,0 +1,120 @@
+package ratls
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sync"
+)
+
+// IdentityChange describes a peer that presented a different certificate
+// than before, on reconnect or renegotiation. The new certificate has
+// passed verification.
+type IdentityChange struct {
+	Peer     string
+	Old, New *VerificationResult
+	// Changes lists the attested claims that differ, see CompareIdentity.
+	// If empty, only the certificate was rotated, as when an enclave
+	// restarts and generates a new key.
+	Changes []string
+}
+
+// CompareIdentity describes how the enclave attested in cur differs from
+// the one in old: MRENCLAVE, MRSIGNER, ISV product ID and SVN, debug mode,
+// quote and TCB status. The certificate key is not compared, it is fresh
+// for every enclave instance.
+func CompareIdentity(old, cur *VerificationResult) []string {
+	fields := []struct {
+		name     string
+		old, cur any
+	}{
+		{"mr_enclave", old.MrEnclave, cur.MrEnclave},
+		{"mr_signer", old.MrSigner, cur.MrSigner},
+		{"isv_prod_id", old.ISVProdID, cur.ISVProdID},
+		{"isv_svn", old.ISVSVN, cur.ISVSVN},
+		{"debug", old.Debug, cur.Debug},
+		{"quote status", old.QuoteStatus, cur.QuoteStatus},
+		{"tcb status", old.TCBStatus, cur.TCBStatus},
+	}
+	var changes []string
+	for _, f := range fields {
+		if f.old != f.cur {
+			changes = append(changes, fmt.Sprintf("%s changed from %v to %v", f.name, f.old, f.cur))
+		}
+	}
+	return changes
+}
+
+// RotationMonitor remembers the certificate each peer last presented and
+// notices when it changes. OnChange is then told how the attested enclave
+// changed, so the application can e.g. drop state tied to the old instance
+// or alert on an unexpected update. Its Verifier wraps a PeerVerifier to
+// feed it.
+//
+// Conn verifies the certificate of every handshake, renegotiations
+// included, with its PeerVerifier, so a RotationMonitor sees rotations
+// within a connection as well as across reconnects. Resumed sessions do
+// not present a certificate and are not seen.
+type RotationMonitor struct {
+	// OnChange, if set, is called after a peer presented a different,
+	// valid certificate. It must not block.
+	OnChange func(change *IdentityChange)
+
+	mu    sync.Mutex
+	peers map[string]*peerCert
+}
+
+// peerCert is the certificate a peer last presented and its result.
+type peerCert struct {
+	fingerprint [sha256.Size]byte
+	res         *VerificationResult
+}
+
+// Observe records that peer presented the certificate chain rawCerts,
+// verified as res, and reports a change of its leaf to OnChange.
+func (m *RotationMonitor) Observe(peer string, rawCerts [][]byte, res *VerificationResult) {
+	ordered, err := OrderChain(rawCerts)
+	if err != nil {
+		return
+	}
+	cur := &peerCert{fingerprint: sha256.Sum256(ordered[0]), res: res}
+
+	m.mu.Lock()
+	if m.peers == nil {
+		m.peers = make(map[string]*peerCert)
+	}
+	old := m.peers[peer]
+	m.peers[peer] = cur
+	m.mu.Unlock()
+
+	if old != nil && old.fingerprint != cur.fingerprint && m.OnChange != nil {
+		m.OnChange(&IdentityChange{Peer: peer, Old: old.res, New: res, Changes: CompareIdentity(old.res, res)})
+	}
+}
+
+// Forget drops what is remembered about peer, e.g. when it is removed
+// from service.
+func (m *RotationMonitor) Forget(peer string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.peers, peer)
+}
+
+// Verifier returns a PeerVerifier that verifies with v and observes every
+// accepted certificate. Verifiers returned for different connections, e.g.
+// each with its own Nonce, share what m remembers.
+func (m *RotationMonitor) Verifier(v PeerVerifier) PeerVerifier {
+	return monitoredVerifier{monitor: m, PeerVerifier: v}
+}
+
+type monitoredVerifier struct {
+	PeerVerifier
+	monitor *RotationMonitor
+}
+
+func (mv monitoredVerifier) VerifyPeerChain(peer string, rawCerts [][]byte) (*VerificationResult, error) {
+	res, err := mv.PeerVerifier.VerifyPeerChain(peer, rawCerts)
+	if err == nil {
+		mv.monitor.Observe(peer, rawCerts, res)
+	}
+	return res, err
+}
@@ -0,0 +1,85 @@
+package ratls
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareIdentity(t *testing.T) {
+	old := &VerificationResult{MrEnclave: "aa", MrSigner: "bb", ISVSVN: 1, QuoteStatus: "OK"}
+	tests := []struct {
+		name string
+		edit func(*VerificationResult)
+		want []string
+	}{
+		{name: "same enclave", edit: func(*VerificationResult) {}},
+		{name: "key only", edit: func(r *VerificationResult) { r.ReportData = "cc" }},
+		{name: "MRENCLAVE", edit: func(r *VerificationResult) { r.MrEnclave = "ab" }, want: []string{"mr_enclave changed from aa to ab"}},
+		{
+			name: "signer update",
+			edit: func(r *VerificationResult) { r.MrSigner, r.ISVSVN = "bc", 2 },
+			want: []string{"mr_signer changed from bb to bc", "isv_svn changed from 1 to 2"},
+		},
+		{name: "debug", edit: func(r *VerificationResult) { r.Debug = true }, want: []string{"debug changed from false to true"}},
+		{
+			name: "TCB",
+			edit: func(r *VerificationResult) { r.QuoteStatus, r.TCBStatus = "GROUP_OUT_OF_DATE", "OutOfDate" },
+			want: []string{"quote status changed from OK to GROUP_OUT_OF_DATE", "tcb status changed from  to OutOfDate"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cur := *old
+			tt.edit(&cur)
+			if got := CompareIdentity(old, &cur); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CompareIdentity() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRotationMonitor(t *testing.T) {
+	first := newSimulatedCert(t, 0xaa)
+	tests := []struct {
+		name   string
+		peer   string
+		next   []byte
+		forget bool
+		// wantChanges is the number of claim changes reported, -1 if no
+		// rotation should be reported.
+		wantChanges int
+	}{
+		{name: "same certificate", peer: "a", next: first, wantChanges: -1},
+		{name: "new key", peer: "a", next: newSimulatedCert(t, 0xaa), wantChanges: 0},
+		{name: "new enclave", peer: "a", next: newSimulatedCert(t, 0xbb), wantChanges: 1},
+		{name: "other peer", peer: "b", next: newSimulatedCert(t, 0xbb), wantChanges: -1},
+		{name: "forgotten", peer: "a", next: newSimulatedCert(t, 0xbb), forget: true, wantChanges: -1},
+		{name: "rejected", peer: "a", next: []byte("certificate"), wantChanges: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var changes []*IdentityChange
+			m := &RotationMonitor{OnChange: func(c *IdentityChange) { changes = append(changes, c) }}
+			v := m.Verifier(&Verifier{AllowSimulation: true, Clock: FixedClock(testNow)})
+			if _, err := v.VerifyPeerChain("a", [][]byte{first}); err != nil {
+				t.Fatal(err)
+			}
+			if tt.forget {
+				m.Forget("a")
+			}
+			v.VerifyPeerChain(tt.peer, [][]byte{tt.next})
+			if tt.wantChanges < 0 {
+				if len(changes) != 0 {
+					t.Errorf("OnChange called with %+v, want no rotation", changes[0])
+				}
+				return
+			}
+			if len(changes) != 1 {
+				t.Fatalf("OnChange called %d times, want once", len(changes))
+			}
+			if c := changes[0]; c.Peer != tt.peer || c.Old == nil || c.New == nil || len(c.Changes) != tt.wantChanges {
+				t.Errorf("OnChange(%+v), want %d changes for peer %q", c, tt.wantChanges, tt.peer)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package ratls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+)
+
+// ServerConfig returns a TLS configuration for Go servers accepting
+// connections from enclaves. Clients must present an RA-TLS certificate,
+// which is verified with v during the handshake; connections whose
+// evidence does not pass v are rejected before any application data is
+// exchanged.
+func (v *Verifier) ServerConfig(cert tls.Certificate) *tls.Config {
+	conf := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		// RA-TLS certificates are self-signed, so no chain is built; trust
+		// comes from the attestation evidence, and from v.PKIRoots in
+		// hybrid mode.
+		ClientAuth:            tls.RequireAnyClientCert,
+		VerifyPeerCertificate: v.VerifyPeerCertificate,
+	}
+	// Label audit records with the address of each client
+	conf.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		if v.Audit == nil || hello.Conn == nil {
+			return nil, nil
+		}
+		peer := hello.Conn.RemoteAddr().String()
+		c := conf.Clone()
+		c.GetConfigForClient = nil
+		c.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			_, err := v.VerifyPeerChain(peer, rawCerts)
+			return err
+		}
+		return c, nil
+	}
+	return conf
+}
+
+// Listen announces on the local network address and returns a TLS listener
+// authenticating its clients as described for ServerConfig. Accepted
+// connections are of type *Conn, whose Identity describes the client
+// enclave.
+func (v *Verifier) Listen(network, address string, cert tls.Certificate) (net.Listener, error) {
+	inner, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewListener(inner, v.ServerConfig(cert), v), nil
+}
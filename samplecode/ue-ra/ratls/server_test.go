@@ -0,0 +1,47 @@
+package ratls
+
+import (
+	"crypto"
+	"crypto/tls"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestServerConfig(t *testing.T) {
+	serverCert := newSimulatedKeyPair(t, func(*ReportBody) {})
+	enclave := newSimulatedKeyPair(t, func(body *ReportBody) { body.MrEnclave[0] = 1 })
+	other := newSimulatedKeyPair(t, func(body *ReportBody) { body.MrEnclave[0] = 2 })
+	plain := tls.Certificate{Certificate: [][]byte{newTestRATLSCert(t, enclave.PrivateKey.(crypto.Signer))}, PrivateKey: enclave.PrivateKey}
+	allowed := NewMeasurementPolicy(Measurement{MrEnclave: "01" + strings.Repeat("00", 31)})
+
+	tests := []struct {
+		name    string
+		client  []tls.Certificate
+		wantErr bool
+	}{
+		{name: "attested client", client: []tls.Certificate{enclave}},
+		{name: "enclave not allowed", client: []tls.Certificate{other}, wantErr: true},
+		{name: "no evidence", client: []tls.Certificate{plain}, wantErr: true},
+		{name: "no client certificate", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Verifier{AllowSimulation: true, Measurements: allowed}
+			c, s := net.Pipe()
+			server := tls.Server(s, v.ServerConfig(serverCert))
+			client := tls.Client(c, &tls.Config{InsecureSkipVerify: true, Certificates: tt.client})
+			done := make(chan error, 1)
+			go func() {
+				done <- client.Handshake()
+				c.Close()
+			}()
+			err := server.Handshake()
+			s.Close()
+			<-done
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Handshake() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package ratls
+
+import (
+	"crypto/tls"
+	"sync"
+	"time"
+)
+
+// SessionCache is a tls.ClientSessionCache that bounds how long a verified
+// peer can be trusted through TLS session resumption. Resumed handshakes do
+// not present the certificate again: Conn.Identity verifies the certificate
+// kept with the session once more, but its evidence is no fresher than the
+// full handshake. Once MaxAge has passed since the full handshake of a session,
+// or it has been resumed MaxResumptions times, the session is dropped and
+// the next connection performs a full handshake with fresh evidence.
+//
+// Only connections that did resume are counted, as reported by Client and
+// PostHandshakeClient connections, whose configuration uses the cache.
+type SessionCache struct {
+	// Cache stores the sessions. If nil, an LRU cache of default size is
+	// used.
+	Cache tls.ClientSessionCache
+	// MaxAge is the time a session may be resumed for after its full
+	// handshake. Zero means no limit.
+	MaxAge time.Duration
+	// MaxResumptions is the number of times a session may be resumed. Zero
+	// means no limit.
+	MaxResumptions int
+	// Clock, if set, is used instead of the system time.
+	Clock Clock
+
+	once     sync.Once
+	mu       sync.Mutex
+	sessions map[string]*sessionState
+}
+
+// sessionState tracks the session stored under a cache key since its last
+// full handshake. Tickets received on resumed connections inherit it.
+type sessionState struct {
+	verified time.Time
+	resumed  int
+}
+
+func (c *SessionCache) init() {
+	c.once.Do(func() {
+		if c.Cache == nil {
+			c.Cache = tls.NewLRUClientSessionCache(0)
+		}
+		c.sessions = make(map[string]*sessionState)
+	})
+}
+
+func (c *SessionCache) now() time.Time {
+	if c.Clock != nil {
+		return c.Clock.Now()
+	}
+	return time.Now()
+}
+
+// Get returns the session stored under key if policy still allows it to be
+// resumed.
+func (c *SessionCache) Get(key string) (*tls.ClientSessionState, bool) {
+	c.init()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	st := c.sessions[key]
+	expired := st == nil ||
+		(c.MaxAge > 0 && c.now().Sub(st.verified) >= c.MaxAge) ||
+		(c.MaxResumptions > 0 && st.resumed >= c.MaxResumptions)
+	if expired {
+		if st != nil {
+			// The next Put then comes from a full handshake. Putting nil
+			// for an absent key would store a nil entry in the LRU cache.
+			delete(c.sessions, key)
+			c.Cache.Put(key, nil)
+		}
+		return nil, false
+	}
+	return c.Cache.Get(key)
+}
+
+// resumed counts a connection that resumed the session stored under key.
+func (c *SessionCache) resumed(key string) {
+	c.init()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if st := c.sessions[key]; st != nil {
+		st.resumed++
+	}
+}
+
+// Put stores a session under key, starting its policy window if it comes
+// from a full handshake.
+func (c *SessionCache) Put(key string, cs *tls.ClientSessionState) {
+	c.init()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cs == nil {
+		delete(c.sessions, key)
+	} else if c.sessions[key] == nil {
+		c.sessions[key] = &sessionState{verified: c.now()}
+	}
+	c.Cache.Put(key, cs)
+}
@@ -0,0 +1,71 @@
+package ratls
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+)
+
+func TestSessionCache(t *testing.T) {
+	tests := []struct {
+		name           string
+		maxAge         time.Duration
+		maxResumptions int
+		// resumptions are counted and elapsed passes after the full
+		// handshake; ticket stores the ticket of a resumed connection.
+		resumptions int
+		elapsed     time.Duration
+		ticket      bool
+		// dropped removes the session, as tls does after a failed
+		// resumption, and stores a new full handshake.
+		dropped bool
+		wantHit bool
+	}{
+		{name: "no limits", resumptions: 100, elapsed: 24 * time.Hour, wantHit: true},
+		{name: "within age", maxAge: time.Hour, elapsed: 59 * time.Minute, wantHit: true},
+		{name: "age reached", maxAge: time.Hour, elapsed: time.Hour},
+		{name: "within resumptions", maxResumptions: 3, resumptions: 2, wantHit: true},
+		{name: "resumptions reached", maxResumptions: 3, resumptions: 3},
+		{name: "ticket of resumed connection keeps the window", maxAge: time.Hour, elapsed: time.Hour, ticket: true},
+		{name: "full handshake restarts the window", maxAge: time.Hour, maxResumptions: 1, resumptions: 1, elapsed: time.Hour, dropped: true, wantHit: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &testClock{now: testNow}
+			c := &SessionCache{MaxAge: tt.maxAge, MaxResumptions: tt.maxResumptions, Clock: clock}
+			if _, ok := c.Get("enclave"); ok {
+				t.Fatal("Get() of an empty cache hit")
+			}
+			c.Put("enclave", &tls.ClientSessionState{})
+			for i := 0; i < tt.resumptions; i++ {
+				c.resumed("enclave")
+			}
+			clock.now = clock.now.Add(tt.elapsed)
+			if tt.ticket {
+				c.Put("enclave", &tls.ClientSessionState{})
+			}
+			if tt.dropped {
+				c.Put("enclave", nil)
+				c.Put("enclave", &tls.ClientSessionState{})
+			}
+			if _, ok := c.Get("enclave"); ok != tt.wantHit {
+				t.Fatalf("Get() hit = %v, want %v", ok, tt.wantHit)
+			}
+			if !tt.wantHit {
+				// An expired session stays dropped until a full handshake
+				if _, ok := c.Get("enclave"); ok {
+					t.Error("Get() hit after the session expired")
+				}
+				if _, ok := c.Cache.Get("enclave"); ok {
+					t.Error("expired session left in the underlying cache")
+				}
+			}
+			if _, ok := c.Get("other"); ok {
+				t.Error("Get() of another key hit")
+			}
+			if _, ok := c.Cache.Get("other"); ok {
+				t.Error("Get() of another key stored an entry in the underlying cache")
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package ratls
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"strings"
+)
+
+// DefaultReportSignatureAlgorithms are the algorithms an IAS report may be
+// signed with if Verifier.ReportSignatureAlgorithms is empty: RSA with
+// SHA-256, as IAS signs today, and the stronger RSA, RSA-PSS and ECDSA
+// variants it may move to.
+var DefaultReportSignatureAlgorithms = []x509.SignatureAlgorithm{
+	x509.SHA256WithRSA,
+	x509.SHA384WithRSA,
+	x509.SHA512WithRSA,
+	x509.SHA256WithRSAPSS,
+	x509.SHA384WithRSAPSS,
+	x509.SHA512WithRSAPSS,
+	x509.ECDSAWithSHA256,
+	x509.ECDSAWithSHA384,
+	x509.ECDSAWithSHA512,
+}
+
+// minReportRSABits is the smallest accepted RSA report signing key.
+const minReportRSABits = 2048
+
+// signatureKeyAlgorithms gives the key type of the accepted report
+// signature algorithms.
+var signatureKeyAlgorithms = map[x509.SignatureAlgorithm]x509.PublicKeyAlgorithm{
+	x509.SHA256WithRSA:    x509.RSA,
+	x509.SHA384WithRSA:    x509.RSA,
+	x509.SHA512WithRSA:    x509.RSA,
+	x509.SHA256WithRSAPSS: x509.RSA,
+	x509.SHA384WithRSAPSS: x509.RSA,
+	x509.SHA512WithRSAPSS: x509.RSA,
+	x509.ECDSAWithSHA256:  x509.ECDSA,
+	x509.ECDSAWithSHA384:  x509.ECDSA,
+	x509.ECDSAWithSHA512:  x509.ECDSA,
+	x509.PureEd25519:      x509.Ed25519,
+}
+
+// ParseSignatureAlgorithms accepts a comma separated list of algorithm
+// names as printed by x509.SignatureAlgorithm, e.g.
+// "SHA256-RSA,ECDSA-SHA384".
+func ParseSignatureAlgorithms(s string) ([]x509.SignatureAlgorithm, error) {
+	var algs []x509.SignatureAlgorithm
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for alg := range signatureKeyAlgorithms {
+			if strings.EqualFold(alg.String(), name) {
+				algs = append(algs, alg)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unsupported signature algorithm %q", name)
+		}
+	}
+	return algs, nil
+}
+
+// checkReportSignature verifies the IAS report signature sig with the
+// signing certificate cert. The report carries no algorithm identifier, so
+// every allowed algorithm for the key of cert is tried; the one that
+// verifies is returned. The signature algorithm of cert itself is how its
+// CA signed it and says nothing about the report.
+func checkReportSignature(cert *x509.Certificate, report, sig []byte, allowed []x509.SignatureAlgorithm) (x509.SignatureAlgorithm, error) {
+	if len(allowed) == 0 {
+		allowed = DefaultReportSignatureAlgorithms
+	}
+	if key, ok := cert.PublicKey.(*rsa.PublicKey); ok && key.N.BitLen() < minReportRSABits {
+		return 0, failuref(ErrBadSignature, "report signing key of %d bits is too weak", key.N.BitLen())
+	}
+	var lastErr error
+	for _, alg := range allowed {
+		if keyAlg, ok := signatureKeyAlgorithms[alg]; !ok || keyAlg != cert.PublicKeyAlgorithm {
+			continue
+		}
+		lastErr = cert.CheckSignature(alg, report, sig)
+		if lastErr == nil {
+			return alg, nil
+		}
+	}
+	if lastErr == nil {
+		return 0, failuref(ErrBadSignature, "no allowed report signature algorithm for a %v key", cert.PublicKeyAlgorithm)
+	}
+	return 0, failure(ErrBadSignature, lastErr)
+}
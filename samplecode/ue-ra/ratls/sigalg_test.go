@@ -0,0 +1,121 @@
+package ratls
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseSignatureAlgorithms(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    []x509.SignatureAlgorithm
+		wantErr bool
+	}{
+		{s: "SHA256-RSA", want: []x509.SignatureAlgorithm{x509.SHA256WithRSA}},
+		{s: "sha256-rsa, ECDSA-SHA384", want: []x509.SignatureAlgorithm{x509.SHA256WithRSA, x509.ECDSAWithSHA384}},
+		{s: "Ed25519", want: []x509.SignatureAlgorithm{x509.PureEd25519}},
+		{s: "SHA1-RSA", wantErr: true},
+		{s: "SHA256-RSA,", wantErr: true},
+		{s: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseSignatureAlgorithms(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSignatureAlgorithms() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSignatureAlgorithms() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckReportSignature(t *testing.T) {
+	report := []byte(`{"id": "1"}`)
+	digest := sha256.Sum256(report)
+	digest384 := sha512.Sum384(report)
+	cert := func(key crypto.Signer) *x509.Certificate {
+		c, err := x509.ParseCertificate(newTestRATLSCert(t, key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	weakKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs1, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pss, err := rsa.SignPSS(rand.Reader, rsaKey, crypto.SHA384, digest384[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	weak, err := rsa.SignPKCS1v15(rand.Reader, weakKey, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec, err := ecdsa.SignASN1(rand.Reader, ecKey, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed := ed25519.Sign(edKey, report)
+
+	tests := []struct {
+		name    string
+		cert    *x509.Certificate
+		sig     []byte
+		allowed []x509.SignatureAlgorithm
+		want    x509.SignatureAlgorithm
+		wantErr bool
+	}{
+		{name: "RSA", cert: cert(rsaKey), sig: pkcs1, want: x509.SHA256WithRSA},
+		{name: "RSA-PSS", cert: cert(rsaKey), sig: pss, want: x509.SHA384WithRSAPSS},
+		{name: "ECDSA", cert: cert(ecKey), sig: ec, want: x509.ECDSAWithSHA256},
+		{name: "Ed25519 allowed", cert: cert(edKey), sig: ed, allowed: []x509.SignatureAlgorithm{x509.PureEd25519}, want: x509.PureEd25519},
+		{name: "Ed25519 by default", cert: cert(edKey), sig: ed, wantErr: true},
+		{name: "ECDSA not allowed", cert: cert(ecKey), sig: ec, allowed: []x509.SignatureAlgorithm{x509.SHA256WithRSA}, wantErr: true},
+		{name: "RSA-PSS not allowed", cert: cert(rsaKey), sig: pss, allowed: []x509.SignatureAlgorithm{x509.SHA256WithRSA, x509.SHA384WithRSA}, wantErr: true},
+		{name: "weak RSA key", cert: cert(weakKey), sig: weak, wantErr: true},
+		{name: "other key", cert: cert(rsaKey), sig: weak, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checkReportSignature(tt.cert, report, tt.sig, tt.allowed)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkReportSignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrBadSignature) {
+				t.Errorf("checkReportSignature() error = %v, want %v", err, ErrBadSignature)
+			}
+			if got != tt.want {
+				t.Errorf("checkReportSignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package ratls
+
+import "errors"
+
+// SimulatedQuoteStatus is the QuoteStatus of results accepted from the
+// evidence of a simulation mode enclave.
+const SimulatedQuoteStatus = "SIMULATED"
+
+// isSimulatedQuote reports whether ev carries a bare EPID quote, as enclaves
+// built with SGX_MODE=SW obtain from the simulated quoting enclave, instead
+// of an IAS report or another format.
+func isSimulatedQuote(ev *evidence) bool {
+	if ev.report != nil || ev.quote != nil {
+		return false
+	}
+	quote, err := ParseEPIDQuote(ev.comment)
+	return err == nil && (quote.Header.Version == 1 || quote.Header.Version == 2)
+}
+
+// verifySimulatedQuote takes the enclave identity from the quote of a
+// simulation mode enclave. Its signature is not checked: nothing vouches
+// for it, as there is no hardware. The result is marked Simulated.
+func (v *Verifier) verifySimulatedQuote(raw []byte, res *VerificationResult) error {
+	if !v.AllowSimulation {
+		return errors.New("evidence of a simulation mode enclave requires AllowSimulation")
+	}
+	qrData, err := parseReport(raw)
+	if err != nil {
+		return err
+	}
+	res.Simulated = true
+	res.QuoteStatus = SimulatedQuoteStatus
+	res.QuoteVersion = qrData.version
+	res.SignType = qrData.signType
+	res.setReportBody(&qrData.reportBody)
+	res.EPIDGroupID = qrData.reportBody.epidGroupID
+	return v.checkNonce("", res)
+}
@@ -0,0 +1,76 @@
+package ratls
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIsSimulatedQuote(t *testing.T) {
+	version := func(v uint16) []byte {
+		return testEPIDQuote(func(q *EPIDQuote) { q.Header.Version = v })
+	}
+	tests := []struct {
+		name string
+		ev   *evidence
+		want bool
+	}{
+		{name: "version 2", ev: &evidence{comment: version(2)}, want: true},
+		{name: "version 1", ev: &evidence{comment: version(1)}, want: true},
+		{name: "version 3", ev: &evidence{comment: version(3)}},
+		{name: "truncated", ev: &evidence{comment: version(2)[:100]}},
+		{name: "IAS report", ev: &evidence{comment: version(2), report: []byte("{}")}},
+		{name: "raw quote", ev: &evidence{comment: version(2), quote: newTestQuoteV3().raw()}},
+		{name: "no comment", ev: &evidence{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isSimulatedQuote(tt.ev); got != tt.want {
+				t.Errorf("isSimulatedQuote() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifySimulated(t *testing.T) {
+	tests := []struct {
+		name     string
+		cert     []byte
+		verifier *Verifier
+		wantErr  bool
+		wantIs   error
+	}{
+		{name: "allowed", cert: newSimulatedCert(t, 0xaa), verifier: &Verifier{AllowSimulation: true}},
+		{name: "allowed in EPID mode", cert: newSimulatedCert(t, 0xaa), verifier: &Verifier{Mode: ModeEPID, AllowSimulation: true}},
+		{name: "not allowed", cert: newSimulatedCert(t, 0xaa), verifier: &Verifier{}, wantErr: true},
+		{
+			name:     "debug enclave",
+			cert:     newSimulatedCertWith(t, func(body *ReportBody) { body.Attributes.Flags = sgxFlagsDebug }),
+			verifier: &Verifier{AllowSimulation: true},
+			wantErr:  true,
+			wantIs:   ErrDebugEnclave,
+		},
+		{
+			name:     "debug enclave allowed",
+			cert:     newSimulatedCertWith(t, func(body *ReportBody) { body.Attributes.Flags = sgxFlagsDebug }),
+			verifier: &Verifier{AllowSimulation: true, AllowDebug: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.verifier.Clock = FixedClock(testNow)
+			res, err := tt.verifier.VerifyPeerChain("peer", [][]byte{tt.cert})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyPeerChain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Errorf("VerifyPeerChain() error = %v, want %v", err, tt.wantIs)
+			}
+			if err != nil {
+				return
+			}
+			if res.Mode != ModeSim || !res.Simulated || res.QuoteStatus != SimulatedQuoteStatus {
+				t.Errorf("VerifyPeerChain() = %+v, want a simulated enclave", res)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package ratls
+
+import (
+	"fmt"
+	"strings"
+)
+
+// QuoteStatusPolicy lists the isvEnclaveQuoteStatus values of an IAS report
+// that are accepted.
+type QuoteStatusPolicy struct {
+	Allowed []string
+}
+
+var (
+	// StrictQuoteStatus only accepts fully up to date platforms.
+	StrictQuoteStatus = QuoteStatusPolicy{Allowed: []string{"OK"}}
+
+	// PermissiveQuoteStatus also accepts platforms that need a TCB,
+	// configuration or software hardening update, but never revoked ones.
+	// It is used when a Verifier has no policy set.
+	PermissiveQuoteStatus = QuoteStatusPolicy{Allowed: []string{
+		"OK",
+		"GROUP_OUT_OF_DATE",
+		"CONFIGURATION_NEEDED",
+		"SW_HARDENING_NEEDED",
+		"CONFIGURATION_AND_SW_HARDENING_NEEDED",
+	}}
+)
+
+var (
+	// StrictTCBStatus only accepts DCAP platforms whose TCB is up to date.
+	StrictTCBStatus = QuoteStatusPolicy{Allowed: []string{"UpToDate"}}
+
+	// PermissiveTCBStatus is the DCAP counterpart of PermissiveQuoteStatus.
+	PermissiveTCBStatus = QuoteStatusPolicy{Allowed: []string{
+		"UpToDate",
+		"SWHardeningNeeded",
+		"ConfigurationNeeded",
+		"ConfigurationAndSWHardeningNeeded",
+		"OutOfDate",
+		"OutOfDateConfigurationNeeded",
+	}}
+)
+
+// ParseQuoteStatusPolicy accepts "strict", "permissive" or a comma separated
+// list of statuses.
+func ParseQuoteStatusPolicy(s string) (QuoteStatusPolicy, error) {
+	return parseStatusPolicy(s, StrictQuoteStatus, PermissiveQuoteStatus)
+}
+
+// ParseTCBStatusPolicy is ParseQuoteStatusPolicy for DCAP TCB statuses.
+func ParseTCBStatusPolicy(s string) (QuoteStatusPolicy, error) {
+	return parseStatusPolicy(s, StrictTCBStatus, PermissiveTCBStatus)
+}
+
+func parseStatusPolicy(s string, strict, permissive QuoteStatusPolicy) (QuoteStatusPolicy, error) {
+	switch s {
+	case "strict":
+		return strict, nil
+	case "permissive":
+		return permissive, nil
+	}
+	var p QuoteStatusPolicy
+	for _, status := range strings.Split(s, ",") {
+		if status = strings.TrimSpace(status); status != "" {
+			p.Allowed = append(p.Allowed, status)
+		}
+	}
+	if len(p.Allowed) == 0 {
+		return p, fmt.Errorf("empty quote status policy %q", s)
+	}
+	return p, nil
+}
+
+// Check returns a *QuoteStatusError if status is not allowed.
+func (p QuoteStatusPolicy) Check(status string) error {
+	for _, allowed := range p.Allowed {
+		if status == allowed {
+			return nil
+		}
+	}
+	return &QuoteStatusError{Status: status}
+}
+
+// QuoteStatusError reports an IAS quote status or DCAP TCB status rejected
+// by the policy.
+type QuoteStatusError struct {
+	Status string
+}
+
+func (e *QuoteStatusError) Error() string {
+	return "quote status not accepted: " + e.Status
+}
@@ -0,0 +1,67 @@
+package ratls
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseStatusPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		parse   func(string) (QuoteStatusPolicy, error)
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{name: "strict quote status", parse: ParseQuoteStatusPolicy, in: "strict", want: StrictQuoteStatus.Allowed},
+		{name: "permissive quote status", parse: ParseQuoteStatusPolicy, in: "permissive", want: PermissiveQuoteStatus.Allowed},
+		{name: "strict TCB status", parse: ParseTCBStatusPolicy, in: "strict", want: StrictTCBStatus.Allowed},
+		{name: "permissive TCB status", parse: ParseTCBStatusPolicy, in: "permissive", want: PermissiveTCBStatus.Allowed},
+		{name: "list", parse: ParseQuoteStatusPolicy, in: "OK, SW_HARDENING_NEEDED,", want: []string{"OK", "SW_HARDENING_NEEDED"}},
+		{name: "empty", parse: ParseTCBStatusPolicy, in: " , ", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := tt.parse(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parse(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(p.Allowed, tt.want) {
+				t.Errorf("parse(%q) = %v, want %v", tt.in, p.Allowed, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatusPolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy QuoteStatusPolicy
+		status string
+		ok     bool
+	}{
+		{name: "strict quote status OK", policy: StrictQuoteStatus, status: "OK", ok: true},
+		{name: "strict quote status out of date", policy: StrictQuoteStatus, status: "GROUP_OUT_OF_DATE"},
+		{name: "permissive quote status out of date", policy: PermissiveQuoteStatus, status: "GROUP_OUT_OF_DATE", ok: true},
+		{name: "permissive quote status revoked", policy: PermissiveQuoteStatus, status: "GROUP_REVOKED"},
+		{name: "permissive quote status signature invalid", policy: PermissiveQuoteStatus, status: "SIGNATURE_INVALID"},
+		{name: "strict TCB status up to date", policy: StrictTCBStatus, status: "UpToDate", ok: true},
+		{name: "strict TCB status out of date", policy: StrictTCBStatus, status: "OutOfDate"},
+		{name: "permissive TCB status out of date", policy: PermissiveTCBStatus, status: "OutOfDate", ok: true},
+		{name: "permissive TCB status revoked", policy: PermissiveTCBStatus, status: "Revoked"},
+		{name: "statuses are case sensitive", policy: StrictTCBStatus, status: "uptodate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.status)
+			if (err == nil) != tt.ok {
+				t.Fatalf("Check(%q) error = %v, want ok %v", tt.status, err, tt.ok)
+			}
+			var statusErr *QuoteStatusError
+			if err != nil && (!errors.As(err, &statusErr) || statusErr.Status != tt.status) {
+				t.Errorf("Check(%q) error = %#v, want a *QuoteStatusError", tt.status, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package ratls
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TCBInfo is the signed description of the TCB levels of one FMSPC, as
+// served by the PCS /tcb endpoint (versions 2 and 3). ID is "SGX" or "TDX".
+type TCBInfo struct {
+	ID                      string     `json:"id"`
+	Version                 int        `json:"version"`
+	IssueDate               time.Time  `json:"issueDate"`
+	NextUpdate              time.Time  `json:"nextUpdate"`
+	FMSPC                   string     `json:"fmspc"`
+	PCEID                   string     `json:"pceId"`
+	TCBType                 int        `json:"tcbType"`
+	TCBEvaluationDataNumber int        `json:"tcbEvaluationDataNumber"`
+	TCBLevels               []TCBLevel `json:"tcbLevels"`
+}
+
+// TCBLevel is one entry of TCBInfo.TCBLevels, ordered from newest to oldest.
+type TCBLevel struct {
+	TCB         TCBComponents `json:"tcb"`
+	TCBDate     string        `json:"tcbDate"`
+	TCBStatus   string        `json:"tcbStatus"`
+	AdvisoryIDs []string      `json:"advisoryIDs"`
+}
+
+// TCBComponents are the SVNs a platform must reach for a TCB level.
+// TDXCompSVN is only set in TDX TCB info.
+type TCBComponents struct {
+	CompSVN    [16]int
+	PCESVN     int
+	TDXCompSVN []int
+}
+
+// UnmarshalJSON accepts both the v3 "sgxtcbcomponents" array and the v2
+// "sgxtcbcompNNsvn" fields.
+func (c *TCBComponents) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if raw, ok := fields["pcesvn"]; ok {
+		if err := json.Unmarshal(raw, &c.PCESVN); err != nil {
+			return err
+		}
+	}
+	if raw, ok := fields["tdxtcbcomponents"]; ok {
+		svns, err := unmarshalTCBComponents(raw)
+		if err != nil {
+			return err
+		}
+		c.TDXCompSVN = svns
+	}
+	if raw, ok := fields["sgxtcbcomponents"]; ok {
+		svns, err := unmarshalTCBComponents(raw)
+		if err != nil {
+			return err
+		}
+		copy(c.CompSVN[:], svns)
+		return nil
+	}
+	for i := range c.CompSVN {
+		raw, ok := fields[fmt.Sprintf("sgxtcbcomp%02dsvn", i+1)]
+		if !ok {
+			return fmt.Errorf("TCB level misses sgxtcbcomp%02dsvn", i+1)
+		}
+		if err := json.Unmarshal(raw, &c.CompSVN[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unmarshalTCBComponents(raw json.RawMessage) ([]int, error) {
+	var comps []struct {
+		SVN int `json:"svn"`
+	}
+	if err := json.Unmarshal(raw, &comps); err != nil {
+		return nil, err
+	}
+	if len(comps) != 16 {
+		return nil, fmt.Errorf("TCB level has %d components, want 16", len(comps))
+	}
+	svns := make([]int, len(comps))
+	for i, comp := range comps {
+		svns[i] = comp.SVN
+	}
+	return svns, nil
+}
+
+// VerifyTCBInfo checks the signature and issuer chain of a TCB info document
+// as returned by CollateralClient.GetTCBInfo and decodes it.
+func VerifyTCBInfo(doc []byte, chain []*x509.Certificate, roots *x509.CertPool, now time.Time) (*TCBInfo, error) {
+	raw, err := verifySignedJSON(doc, "tcbInfo", chain, roots, now)
+	if err != nil {
+		return nil, fmt.Errorf("TCB info: %w", err)
+	}
+	var info TCBInfo
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return nil, err
+	}
+	if err := info.checkFormat(); err != nil {
+		return nil, fmt.Errorf("TCB info: %w", err)
+	}
+	if now.After(info.NextUpdate) {
+		return nil, failuref(ErrStaleReport, "TCB info expired at %v", info.NextUpdate)
+	}
+	return &info, nil
+}
+
+// checkFormat rejects TCB info of a version, ID or TCB type whose levels
+// this package cannot interpret. Version 2 has no ID and is for SGX.
+func (t *TCBInfo) checkFormat() error {
+	switch {
+	case t.Version == 2 && t.ID != "":
+		return fmt.Errorf("version 2 TCB info has ID %q", t.ID)
+	case t.Version == 3 && t.ID != "SGX" && t.ID != "TDX":
+		return fmt.Errorf("unknown TCB info ID %q", t.ID)
+	case t.Version != 2 && t.Version != 3:
+		return fmt.Errorf("unsupported TCB info version %d", t.Version)
+	case t.TCBType != 0:
+		return fmt.Errorf("unsupported TCB type %d", t.TCBType)
+	}
+	return nil
+}
+
+// Evaluate returns the TCB level of a platform: the first level whose
+// component SVNs and PCESVN are all met by the PCK certificate.
+func (t *TCBInfo) Evaluate(pck *PCKExtensions) (*TCBLevel, error) {
+	if t.ID == "TDX" {
+		return nil, errors.New("TCB info is for TDX, not SGX")
+	}
+	return t.evaluate(pck, nil)
+}
+
+// EvaluateTDX is Evaluate for a TD, whose TEE TCB SVN from the TD report
+// must also meet the TDX components of the level.
+func (t *TCBInfo) EvaluateTDX(pck *PCKExtensions, teeTCBSVN []byte) (*TCBLevel, error) {
+	if t.ID != "TDX" {
+		return nil, fmt.Errorf("TCB info is for %q, not TDX", t.ID)
+	}
+	return t.evaluate(pck, teeTCBSVN)
+}
+
+func (t *TCBInfo) evaluate(pck *PCKExtensions, teeTCBSVN []byte) (*TCBLevel, error) {
+	if !strings.EqualFold(t.FMSPC, pck.FMSPC) {
+		return nil, fmt.Errorf("TCB info is for FMSPC %s, platform has %s", t.FMSPC, pck.FMSPC)
+	}
+	if !strings.EqualFold(t.PCEID, pck.PCEID) {
+		return nil, fmt.Errorf("TCB info is for PCEID %s, platform has %s", t.PCEID, pck.PCEID)
+	}
+	for i := range t.TCBLevels {
+		level := &t.TCBLevels[i]
+		if level.TCB.isMetBy(pck) && (teeTCBSVN == nil || level.TCB.isMetByTEE(teeTCBSVN)) {
+			return level, nil
+		}
+	}
+	return nil, errors.New("platform TCB is lower than every TCB level")
+}
+
+func (c *TCBComponents) isMetBy(pck *PCKExtensions) bool {
+	for i, svn := range c.CompSVN {
+		if pck.CompSVN[i] < svn {
+			return false
+		}
+	}
+	return pck.PCESVN >= c.PCESVN
+}
+
+func (c *TCBComponents) isMetByTEE(teeTCBSVN []byte) bool {
+	if len(c.TDXCompSVN) != len(teeTCBSVN) {
+		return false
+	}
+	for i, svn := range c.TDXCompSVN {
+		if int(teeTCBSVN[i]) < svn {
+			return false
+		}
+	}
+	return true
+}
+
+// verifySignedJSON verifies the hex encoded ECDSA signature of a collateral
+// document over the exact bytes of its field member, and the issuer chain
+// of the signing certificate. It returns the signed member.
+func verifySignedJSON(doc []byte, field string, chain []*x509.Certificate, roots *x509.CertPool, now time.Time) (json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &fields); err != nil {
+		return nil, err
+	}
+	body, ok := fields[field]
+	if !ok {
+		return nil, fmt.Errorf("document has no %q member", field)
+	}
+	var sigHex string
+	if err := json.Unmarshal(fields["signature"], &sigHex); err != nil {
+		return nil, errors.New("document has no signature")
+	}
+	sig, err := hex.DecodeString(sigHex)
+	if err != nil || len(sig) != ecdsaSigLen {
+		return nil, errors.New("malformed signature")
+	}
+
+	if _, err := verifyIssuerChain(chain, roots, now); err != nil {
+		return nil, failure(ErrBadSignature, err)
+	}
+	pub, ok := chain[0].PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("signing certificate does not carry an ECDSA key")
+	}
+	if !verifyRawECDSA(pub, body, sig) {
+		return nil, failure(ErrBadSignature, errors.New("signature is invalid"))
+	}
+	return body, nil
+}
+
+// verifyIssuerChain checks a collateral signing chain (signer first): the
+// signer must be the Intel SGX TCB Signing certificate, issued directly by
+// the Intel SGX Root CA in roots. Other certificates chaining to the root,
+// such as PCK certificates, must not be able to sign collateral. It returns
+// the verified path, signer and root.
+func verifyIssuerChain(chain []*x509.Certificate, roots *x509.CertPool, now time.Time) ([]*x509.Certificate, error) {
+	if len(chain) == 0 {
+		return nil, errors.New("empty issuer chain")
+	}
+	signer := chain[0]
+	if signer.Subject.CommonName != tcbSigningCN {
+		return nil, fmt.Errorf("collateral signed by %q, not the %s certificate", signer.Subject.CommonName, tcbSigningCN)
+	}
+	if signer.IsCA {
+		return nil, errors.New("collateral signing certificate is a CA")
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range chain[1:] {
+		intermediates.AddCert(c)
+	}
+	chains, err := signer.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range chains {
+		if len(path) == 2 && path[1].Subject.CommonName == sgxRootCACN {
+			return path, nil
+		}
+	}
+	return nil, fmt.Errorf("%s certificate is not issued by the %s", tcbSigningCN, sgxRootCACN)
+}
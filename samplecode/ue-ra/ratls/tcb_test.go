@@ -0,0 +1,256 @@
+package ratls
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// testNow lies within the validity of the test collateral.
+var testNow = time.Date(2023, 1, 15, 0, 0, 0, 0, time.UTC)
+
+// testCollateralPKI stands in for the Intel SGX Root CA and the TCB Signing
+// certificate it issues for collateral.
+type testCollateralPKI struct {
+	roots     *x509.CertPool
+	root      *x509.Certificate
+	rootKey   *ecdsa.PrivateKey
+	signer    *x509.Certificate
+	signerKey *ecdsa.PrivateKey
+}
+
+func newTestCollateralPKI(t *testing.T) *testCollateralPKI {
+	t.Helper()
+	p := &testCollateralPKI{roots: x509.NewCertPool()}
+	p.root, p.rootKey = newTestCert(t, sgxRootCACN, true, nil, nil)
+	p.roots.AddCert(p.root)
+	p.signer, p.signerKey = newTestCert(t, tcbSigningCN, false, p.root, p.rootKey)
+	return p
+}
+
+// newTestCert issues a P-256 certificate named cn by parent, or a
+// self-signed one if parent is nil.
+func newTestCert(t *testing.T, cn string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn, Organization: []string{"Intel Corporation"}},
+		NotBefore:             time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if isCA {
+		tmpl.KeyUsage |= x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// signDocument returns a collateral document carrying body as its field
+// member, signed by key the way the PCS signs collateral.
+func signDocument(t *testing.T, key *ecdsa.PrivateKey, field string, body []byte) []byte {
+	t.Helper()
+	digest := sha256.Sum256(body)
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, ecdsaSigLen)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return []byte(fmt.Sprintf(`{"%s":%s,"signature":"%s"}`, field, body, hex.EncodeToString(sig)))
+}
+
+// sampleTCBInfo returns a version 3 SGX TCB info modelled on the PCS sample,
+// changed by edit if it is not nil.
+func sampleTCBInfo(t *testing.T, edit func(info map[string]any)) []byte {
+	t.Helper()
+	level := func(svns []int, pcesvn int, date, status string, advisories ...string) map[string]any {
+		comps := make([]map[string]int, 16)
+		for i := range comps {
+			comps[i] = map[string]int{"svn": 0}
+			if i < len(svns) {
+				comps[i]["svn"] = svns[i]
+			}
+		}
+		l := map[string]any{
+			"tcb":       map[string]any{"sgxtcbcomponents": comps, "pcesvn": pcesvn},
+			"tcbDate":   date,
+			"tcbStatus": status,
+		}
+		if len(advisories) > 0 {
+			l["advisoryIDs"] = advisories
+		}
+		return l
+	}
+	info := map[string]any{
+		"id":                      "SGX",
+		"version":                 3,
+		"issueDate":               "2023-01-10T00:00:00Z",
+		"nextUpdate":              "2023-02-09T00:00:00Z",
+		"fmspc":                   "00906ED50000",
+		"pceId":                   "0000",
+		"tcbType":                 0,
+		"tcbEvaluationDataNumber": 14,
+		"tcbLevels": []any{
+			level([]int{17, 17, 2, 4, 1, 128}, 11, "2022-08-10T00:00:00Z", "UpToDate"),
+			level([]int{15, 15, 2, 4, 1, 128}, 11, "2021-11-10T00:00:00Z", "OutOfDate", "INTEL-SA-00586", "INTEL-SA-00614"),
+			level([]int{2, 2, 2, 4, 1, 128}, 5, "2018-01-04T00:00:00Z", "OutOfDate", "INTEL-SA-00106"),
+		},
+	}
+	if edit != nil {
+		edit(info)
+	}
+	body, err := json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestVerifyTCBInfo(t *testing.T) {
+	p := newTestCollateralPKI(t)
+	chain := []*x509.Certificate{p.signer, p.root}
+
+	pckCA, pckCAKey := newTestCert(t, pckPlatformCACN, true, p.root, p.rootKey)
+	nested, nestedKey := newTestCert(t, tcbSigningCN, false, pckCA, pckCAKey)
+	pckCert, pckKey := newTestCert(t, pckCertCN, false, p.root, p.rootKey)
+	caSigner, caSignerKey := newTestCert(t, tcbSigningCN, true, p.root, p.rootKey)
+	otherRoot, otherRootKey := newTestCert(t, sgxRootCACN, true, nil, nil)
+	foreign, foreignKey := newTestCert(t, tcbSigningCN, false, otherRoot, otherRootKey)
+
+	valid := signDocument(t, p.signerKey, "tcbInfo", sampleTCBInfo(t, nil))
+	tampered := bytes.Replace(valid, []byte(`"pcesvn":11`), []byte(`"pcesvn":10`), 1)
+
+	withInfo := func(edit func(map[string]any)) []byte {
+		return signDocument(t, p.signerKey, "tcbInfo", sampleTCBInfo(t, edit))
+	}
+
+	tests := []struct {
+		name  string
+		doc   []byte
+		chain []*x509.Certificate
+		now   time.Time
+		// cause, if set, is the sentinel the error must match.
+		cause   error
+		wantErr bool
+	}{
+		{name: "v3 SGX", doc: valid, chain: chain, now: testNow},
+		{name: "v3 TDX", doc: withInfo(func(m map[string]any) { m["id"] = "TDX" }), chain: chain, now: testNow},
+		{name: "v2", doc: withInfo(func(m map[string]any) {
+			delete(m, "id")
+			m["version"] = 2
+			m["tcbLevels"] = []any{map[string]any{
+				"tcb": map[string]int{
+					"sgxtcbcomp01svn": 17, "sgxtcbcomp02svn": 17, "sgxtcbcomp03svn": 2, "sgxtcbcomp04svn": 4,
+					"sgxtcbcomp05svn": 1, "sgxtcbcomp06svn": 128, "sgxtcbcomp07svn": 0, "sgxtcbcomp08svn": 0,
+					"sgxtcbcomp09svn": 0, "sgxtcbcomp10svn": 0, "sgxtcbcomp11svn": 0, "sgxtcbcomp12svn": 0,
+					"sgxtcbcomp13svn": 0, "sgxtcbcomp14svn": 0, "sgxtcbcomp15svn": 0, "sgxtcbcomp16svn": 0,
+					"pcesvn": 11,
+				},
+				"tcbDate":   "2022-08-10T00:00:00Z",
+				"tcbStatus": "UpToDate",
+			}}
+		}), chain: chain, now: testNow},
+		{name: "v2 with ID", doc: withInfo(func(m map[string]any) { m["version"] = 2 }), chain: chain, now: testNow, wantErr: true},
+		{name: "unknown ID", doc: withInfo(func(m map[string]any) { m["id"] = "QE" }), chain: chain, now: testNow, wantErr: true},
+		{name: "unknown version", doc: withInfo(func(m map[string]any) { m["version"] = 4 }), chain: chain, now: testNow, wantErr: true},
+		{name: "unknown TCB type", doc: withInfo(func(m map[string]any) { m["tcbType"] = 1 }), chain: chain, now: testNow, wantErr: true},
+		{name: "expired", doc: valid, chain: chain, now: time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC), cause: ErrStaleReport, wantErr: true},
+		{name: "tampered", doc: tampered, chain: chain, now: testNow, cause: ErrBadSignature, wantErr: true},
+		{name: "signed by a PCK certificate", doc: signDocument(t, pckKey, "tcbInfo", sampleTCBInfo(t, nil)), chain: []*x509.Certificate{pckCert, p.root}, now: testNow, cause: ErrBadSignature, wantErr: true},
+		{name: "signer below an intermediate CA", doc: signDocument(t, nestedKey, "tcbInfo", sampleTCBInfo(t, nil)), chain: []*x509.Certificate{nested, pckCA, p.root}, now: testNow, cause: ErrBadSignature, wantErr: true},
+		{name: "signer is a CA", doc: signDocument(t, caSignerKey, "tcbInfo", sampleTCBInfo(t, nil)), chain: []*x509.Certificate{caSigner, p.root}, now: testNow, cause: ErrBadSignature, wantErr: true},
+		{name: "untrusted root", doc: signDocument(t, foreignKey, "tcbInfo", sampleTCBInfo(t, nil)), chain: []*x509.Certificate{foreign, otherRoot}, now: testNow, cause: ErrBadSignature, wantErr: true},
+		{name: "empty chain", doc: valid, now: testNow, cause: ErrBadSignature, wantErr: true},
+		{name: "unsigned", doc: []byte(`{"tcbInfo":{}}`), chain: chain, now: testNow, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := VerifyTCBInfo(tt.doc, tt.chain, p.roots, tt.now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyTCBInfo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.cause != nil && !errors.Is(err, tt.cause) {
+				t.Errorf("VerifyTCBInfo() error = %v, want %v", err, tt.cause)
+			}
+			if err == nil && (info.FMSPC != "00906ED50000" || len(info.TCBLevels) == 0 || info.TCBLevels[0].TCB.CompSVN[0] != 17) {
+				t.Errorf("VerifyTCBInfo() = %+v, not the signed TCB info", info)
+			}
+		})
+	}
+}
+
+func TestTCBInfoEvaluate(t *testing.T) {
+	var info TCBInfo
+	if err := json.Unmarshal(sampleTCBInfo(t, nil), &info); err != nil {
+		t.Fatal(err)
+	}
+	pck := func(svns []int, pcesvn int) *PCKExtensions {
+		ext := &PCKExtensions{PCESVN: pcesvn, FMSPC: "00906ed50000", PCEID: "0000"}
+		copy(ext.CompSVN[:], svns)
+		return ext
+	}
+
+	tests := []struct {
+		name       string
+		pck        *PCKExtensions
+		wantStatus string
+		wantErr    bool
+	}{
+		{name: "newest level", pck: pck([]int{17, 17, 2, 4, 1, 128}, 11), wantStatus: "UpToDate"},
+		{name: "above newest level", pck: pck([]int{18, 18, 3, 4, 1, 128, 1}, 12), wantStatus: "UpToDate"},
+		{name: "one component behind", pck: pck([]int{17, 16, 2, 4, 1, 128}, 11), wantStatus: "OutOfDate"},
+		{name: "PCESVN behind", pck: pck([]int{17, 17, 2, 4, 1, 128}, 10), wantStatus: "OutOfDate"},
+		{name: "below every level", pck: pck([]int{1, 1, 2, 4, 1, 128}, 11), wantErr: true},
+		{name: "other FMSPC", pck: &PCKExtensions{FMSPC: "00606A000000", PCEID: "0000"}, wantErr: true},
+		{name: "other PCEID", pck: &PCKExtensions{FMSPC: "00906ED50000", PCEID: "0001"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level, err := info.Evaluate(tt.pck)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Evaluate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && level.TCBStatus != tt.wantStatus {
+				t.Errorf("Evaluate() status = %s, want %s", level.TCBStatus, tt.wantStatus)
+			}
+		})
+	}
+
+	info.ID = "TDX"
+	if _, err := info.Evaluate(pck([]int{17, 17, 2, 4, 1, 128}, 11)); err == nil {
+		t.Error("Evaluate() accepted TDX TCB info for an SGX platform")
+	}
+}
@@ -0,0 +1,148 @@
+package ratls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	tdReportLen = 584
+
+	// tdAttributesDebug is the DEBUG bit of TDATTRIBUTES.
+	tdAttributesDebug = 0x01
+)
+
+// TDReport mirrors sgx_report2_body_t (TDREPORT 1.0), the identity of a
+// trust domain signed in a TDX quote.
+type TDReport struct {
+	TEETCBSVN      [16]byte
+	MrSeam         [48]byte
+	MrSignerSeam   [48]byte
+	SeamAttributes [8]byte
+	TDAttributes   [8]byte
+	XFAM           [8]byte
+	MrTD           [48]byte
+	MrConfigID     [48]byte
+	MrOwner        [48]byte
+	MrOwnerConfig  [48]byte
+	RTMR           [4][48]byte
+	ReportData     [64]byte
+}
+
+// Debug reports whether the TD runs in debug mode.
+func (r *TDReport) Debug() bool {
+	return r.TDAttributes[0]&tdAttributesDebug != 0
+}
+
+// QuoteV4 is a parsed version 4 quote. ReportBody is set for SGX enclaves
+// and TDReport for trust domains, following Header.TEEType.
+type QuoteV4 struct {
+	Header        QuoteHeader
+	ReportBody    *ReportBody
+	TDReport      *TDReport
+	SignatureData ECDSASignatureData
+
+	signed []byte
+}
+
+// ParseQuoteV4 decodes a version 4 SGX or TDX quote signed with an ECDSA
+// P-256 attestation key.
+func ParseQuoteV4(raw []byte) (*QuoteV4, error) {
+	if len(raw) < quoteHeaderLen {
+		return nil, errors.New("DCAP quote is too short")
+	}
+	q := &QuoteV4{}
+	r := bytes.NewReader(raw)
+	if err := binary.Read(r, binary.LittleEndian, &q.Header); err != nil {
+		return nil, err
+	}
+	if q.Header.Version != quoteVersionV4 {
+		return nil, fmt.Errorf("unsupported DCAP quote version %d", q.Header.Version)
+	}
+	if q.Header.AttestationKeyType != attKeyTypeP256 {
+		return nil, fmt.Errorf("unsupported attestation key type %d", q.Header.AttestationKeyType)
+	}
+
+	bodyLen := reportBodyLen
+	switch q.Header.TEEType {
+	case TEETypeSGX:
+		q.ReportBody = &ReportBody{}
+		if err := binary.Read(r, binary.LittleEndian, q.ReportBody); err != nil {
+			return nil, err
+		}
+	case TEETypeTDX:
+		bodyLen = tdReportLen
+		q.TDReport = &TDReport{}
+		if err := binary.Read(r, binary.LittleEndian, q.TDReport); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported TEE type %#x", q.Header.TEEType)
+	}
+	offset := quoteHeaderLen + bodyLen
+	q.signed = raw[:offset]
+
+	var sigDataLen uint32
+	if err := binary.Read(r, binary.LittleEndian, &sigDataLen); err != nil {
+		return nil, err
+	}
+	offset += 4
+	if uint64(len(raw)-offset) < uint64(sigDataLen) {
+		return nil, errors.New("DCAP quote signature data is truncated")
+	}
+	sig, err := parseECDSASignatureDataV4(raw[offset : offset+int(sigDataLen)])
+	if err != nil {
+		return nil, err
+	}
+	q.SignatureData = *sig
+	return q, nil
+}
+
+// parseECDSASignatureDataV4 decodes the v4 signature data, where the QE
+// report and the PCK chain are nested in certification data type 6.
+func parseECDSASignatureDataV4(sigData []byte) (*ECDSASignatureData, error) {
+	if len(sigData) < ecdsaSigLen+ecdsaPubKeyLen {
+		return nil, errors.New("DCAP quote signature data is too short")
+	}
+	s := &ECDSASignatureData{}
+	copy(s.Signature[:], sigData[0:64])
+	copy(s.AttestationKey[:], sigData[64:128])
+
+	outer, _, err := parseCertificationData(sigData[128:])
+	if err != nil {
+		return nil, err
+	}
+	if outer.Type != CertTypeECDSASigAuxData {
+		return nil, fmt.Errorf("unexpected v4 certification data type %d", outer.Type)
+	}
+	if err := s.parseQEReportCertData(outer.Data); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (v *Verifier) verifyTDXQuote(rawQuote []byte, res *VerificationResult) error {
+	now := v.now()
+	quote, err := parseECDSAQuote(rawQuote)
+	if err != nil {
+		return err
+	}
+	if quote.TDReport == nil {
+		return errors.New("quote does not carry a TD report")
+	}
+	res.QuoteVersion = int(quote.Header.Version)
+
+	pck, col, err := v.verifyECDSAQuote(quote.signed, &quote.SignatureData, now, res)
+	if err != nil {
+		return err
+	}
+	res.setTDReport(quote.TDReport)
+	if col == nil {
+		return nil
+	}
+
+	// 7. Evaluate the platform and TDX module TCB level
+	return v.evaluateTCB(pck, col, quote.TDReport.TEETCBSVN[:], now, res)
+}
@@ -0,0 +1,79 @@
+package ratls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// testQuoteV4 returns a version 4 quote of teeType with body, whose
+// signature data is sigData.
+func testQuoteV4(version uint16, teeType uint32, body, sigData []byte) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, QuoteHeader{Version: version, AttestationKeyType: attKeyTypeP256, TEEType: teeType})
+	b.Write(body)
+	binary.Write(&b, binary.LittleEndian, uint32(len(sigData)))
+	b.Write(sigData)
+	return b.Bytes()
+}
+
+func TestParseQuoteV4(t *testing.T) {
+	sgxBody := make([]byte, reportBodyLen)
+	sgxBody[reportBodyLen-64] = 0x5a
+	tdBody := make([]byte, tdReportLen)
+	tdBody[tdReportLen-64] = 0x5a
+	debugTD := bytes.Clone(tdBody)
+	// TDATTRIBUTES follow TEE_TCB_SVN, MRSEAM, MRSIGNERSEAM and SEAMATTRIBUTES
+	debugTD[16+48+48+8] = tdAttributesDebug
+	sigData := testSignatureDataV4()
+	// otherSigData nests the QE report in certification data type 5
+	otherSigData := bytes.Clone(sigData)
+	binary.LittleEndian.PutUint16(otherSigData[ecdsaSigLen+ecdsaPubKeyLen:], CertTypePCKCertChain)
+
+	tests := []struct {
+		name      string
+		raw       []byte
+		wantTD    bool
+		wantDebug bool
+		wantErr   bool
+	}{
+		{name: "SGX", raw: testQuoteV4(quoteVersionV4, TEETypeSGX, sgxBody, sigData)},
+		{name: "TDX", raw: testQuoteV4(quoteVersionV4, TEETypeTDX, tdBody, sigData), wantTD: true},
+		{name: "debug TD", raw: testQuoteV4(quoteVersionV4, TEETypeTDX, debugTD, sigData), wantTD: true, wantDebug: true},
+		{name: "version 3", raw: testQuoteV4(quoteVersionV3, TEETypeSGX, sgxBody, sigData), wantErr: true},
+		{name: "unknown TEE type", raw: testQuoteV4(quoteVersionV4, 0x42, sgxBody, sigData), wantErr: true},
+		{name: "TD report truncated", raw: testQuoteV4(quoteVersionV4, TEETypeTDX, sgxBody, nil), wantErr: true},
+		{name: "signature data truncated", raw: testQuoteV4(quoteVersionV4, TEETypeTDX, tdBody, sigData)[:quoteHeaderLen+tdReportLen+100], wantErr: true},
+		{name: "QE report not nested", raw: testQuoteV4(quoteVersionV4, TEETypeSGX, sgxBody, otherSigData), wantErr: true},
+		{name: "header only", raw: testQuoteV4(quoteVersionV4, TEETypeSGX, nil, nil)[:quoteHeaderLen], wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := ParseQuoteV4(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseQuoteV4() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if (q.TDReport != nil) != tt.wantTD || (q.ReportBody != nil) == tt.wantTD {
+				t.Fatalf("TDReport, ReportBody set = %v, %v, want TD %v", q.TDReport != nil, q.ReportBody != nil, tt.wantTD)
+			}
+			var reportData [64]byte
+			if tt.wantTD {
+				reportData = q.TDReport.ReportData
+			} else {
+				reportData = q.ReportBody.ReportData
+			}
+			if reportData[0] != 0x5a {
+				t.Errorf("report data = %x, want 5a...", reportData[:4])
+			}
+			if tt.wantTD && q.TDReport.Debug() != tt.wantDebug {
+				t.Errorf("Debug() = %v, want %v", q.TDReport.Debug(), tt.wantDebug)
+			}
+			if len(q.signed) != len(tt.raw)-4-len(sigData) {
+				t.Errorf("signed part of %d bytes", len(q.signed))
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package ratls
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// TestRoots is a root of trust for enclaves built in SIM mode or signed
+// with test keys, so that RA-TLS can be exercised in CI: CAs standing in
+// for the Intel IAS and SGX roots, and collateral signed under them.
+// Evidence accepted with test roots proves nothing about genuine hardware.
+type TestRoots struct {
+	// Roots are the test CA certificates, trusted for IAS reports and PCK
+	// certificate chains.
+	Roots []*x509.Certificate
+	// Collateral, if set, serves the test TCB info, QE identity and CRLs.
+	Collateral CollateralSource
+}
+
+// LoadTestRoots reads a test trust bundle from dir: roots.pem, holding the
+// test CA certificates, and optionally collateral saved in the layout of a
+// CollateralCache directory.
+func LoadTestRoots(dir string) (*TestRoots, error) {
+	path := filepath.Join(dir, "roots.pem")
+	pemBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	roots, err := parsePEMCerts(pemBytes)
+	if err != nil {
+		return nil, err
+	}
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("no certificate found in %s", path)
+	}
+	t := &TestRoots{Roots: roots}
+	if saved, _ := filepath.Glob(filepath.Join(dir, "collateral-*.json")); len(saved) > 0 {
+		t.Collateral = OfflineCollateral{Dir: dir}
+	}
+	return t, nil
+}
+
+// WithInsecureTestRoots replaces the Intel roots by the test roots t, and
+// the collateral source by its collateral if it has any. Results are
+// marked with InsecureTestRoots. Never use it in production.
+func WithInsecureTestRoots(t *TestRoots) Option {
+	return func(v *Verifier) {
+		v.IASRoots, v.SGXRoots = nil, nil
+		for _, cert := range t.Roots {
+			WithIASRoot(cert)(v)
+			WithSGXRoot(cert)(v)
+		}
+		if t.Collateral != nil {
+			v.Collateral = t.Collateral
+		}
+		v.InsecureTestRoots = true
+	}
+}
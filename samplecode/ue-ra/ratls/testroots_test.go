@@ -0,0 +1,58 @@
+package ratls
+
+import (
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadTestRoots(t *testing.T) {
+	pki := newTestCollateralPKI(t)
+	rootPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: pki.root.Raw})
+	tests := []struct {
+		name           string
+		files          map[string]string
+		wantCollateral bool
+		wantErr        bool
+	}{
+		{name: "roots", files: map[string]string{"roots.pem": string(rootPEM)}},
+		{
+			name:           "roots and collateral",
+			files:          map[string]string{"roots.pem": string(rootPEM), "collateral-00906ed50000-platform.json": "{}"},
+			wantCollateral: true,
+		},
+		{name: "no roots", files: map[string]string{"collateral-00906ed50000-platform.json": "{}"}, wantErr: true},
+		{name: "no certificate", files: map[string]string{"roots.pem": "roots"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			roots, err := LoadTestRoots(dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadTestRoots() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(roots.Roots) != 1 || (roots.Collateral != nil) != tt.wantCollateral {
+				t.Errorf("LoadTestRoots() = %d roots, collateral %v", len(roots.Roots), roots.Collateral)
+			}
+
+			v := NewVerifier(WithInsecureTestRoots(roots))
+			if !v.InsecureTestRoots || (v.Collateral != nil) != tt.wantCollateral {
+				t.Errorf("WithInsecureTestRoots() = InsecureTestRoots %v, collateral %v", v.InsecureTestRoots, v.Collateral)
+			}
+			v.Clock = FixedClock(testNow)
+			res, err := v.VerifyEvidence(testIASPayload(t, pki, testIASReport(t, EPIDUnlinkable, nil)))
+			if err != nil || !res.InsecureTestRoots {
+				t.Errorf("VerifyEvidence() = %+v, %v, want a result marked InsecureTestRoots", res, err)
+			}
+		})
+	}
+}
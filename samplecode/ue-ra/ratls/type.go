@@ -0,0 +1,162 @@
+package ratls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+type QuoteReport struct {
+	ID                    string   `json:"id"`
+	Timestamp             string   `json:"timestamp"`
+	Version               int      `json:"version"`
+	IsvEnclaveQuoteStatus string   `json:"isvEnclaveQuoteStatus"`
+	PlatformInfoBlob      string   `json:"platformInfoBlob"`
+	IsvEnclaveQuoteBody   string   `json:"isvEnclaveQuoteBody"`
+	AdvisoryURL           string   `json:"advisoryURL"`
+	AdvisoryIDs           []string `json:"advisoryIDs"`
+	Nonce                 string   `json:"nonce"`
+}
+
+type QuoteReportData struct {
+	version    int
+	signType   EPIDSignType
+	reportBody QuoteReportBody
+}
+
+type QuoteReportBody struct {
+	mrEnclave   string
+	mrSigner    string
+	reportData  string
+	isvProdID   uint16
+	isvSvn      uint16
+	cpuSvn      string
+	miscSelect  uint32
+	attributes  Attributes
+	epidGroupID string
+}
+
+// EPIDQuoteHeader mirrors the fields of sgx_quote_t preceding the report
+// body in an IAS isvEnclaveQuoteBody.
+type EPIDQuoteHeader struct {
+	Version     uint16
+	SignType    EPIDSignType
+	EPIDGroupID [4]byte
+	QESVN       uint16
+	PCESVN      uint16
+	XEID        uint32
+	Basename    [32]byte
+}
+
+// EPIDSignType is the sign_type of an EPID quote, sgx_quote_sign_type_t.
+type EPIDSignType uint16
+
+const (
+	// EPIDUnlinkable signatures cannot be told apart across quotes of a
+	// platform.
+	EPIDUnlinkable EPIDSignType = 0
+	// EPIDLinkable signatures carry a pseudonym, the same for every quote
+	// of a platform under an SPID, by which the service provider can
+	// recognize the platform.
+	EPIDLinkable EPIDSignType = 1
+)
+
+func (t EPIDSignType) String() string {
+	switch t {
+	case EPIDUnlinkable:
+		return "unlinkable"
+	case EPIDLinkable:
+		return "linkable"
+	}
+	return "sign type " + strconv.Itoa(int(t))
+}
+
+// ParseEPIDSignType accepts "linkable" or "unlinkable".
+func ParseEPIDSignType(s string) (EPIDSignType, error) {
+	switch s {
+	case "unlinkable":
+		return EPIDUnlinkable, nil
+	case "linkable":
+		return EPIDLinkable, nil
+	}
+	return 0, fmt.Errorf("unknown EPID signature type %q", s)
+}
+
+// SGX_FLAGS_DEBUG in sgx_attributes_t.flags
+const sgxFlagsDebug = 0x02
+
+type PlatformInfoBlob struct {
+	Sgx_epid_group_flags       uint8             `json:"sgx_epid_group_flags"`
+	Sgx_tcb_evaluation_flags   uint32            `json:"sgx_tcb_evaluation_flags"`
+	Pse_evaluation_flags       uint32            `json:"pse_evaluation_flags"`
+	Latest_equivalent_tcb_psvn string            `json:"latest_equivalent_tcb_psvn"`
+	Latest_pse_isvsvn          string            `json:"latest_pse_isvsvn"`
+	Latest_psda_svn            string            `json:"latest_psda_svn"`
+	Xeid                       uint32            `json:"xeid"`
+	Gid                        uint32            `json:"gid"`
+	Sgx_ec256_signature_t      SGXEC256Signature `json:"sgx_ec256_signature_t"`
+}
+
+type SGXEC256Signature struct {
+	Gx string `json:"gx"`
+	Gy string `json:"gy"`
+}
+
+// parseReport decodes an isvEnclaveQuoteBody: the sgx_quote_t header and
+// report body, without the signature.
+func parseReport(quoteBytes []byte) (*QuoteReportData, error) {
+	quote, err := ParseEPIDQuote(quoteBytes)
+	if err != nil {
+		return nil, err
+	}
+	qrData := &QuoteReportData{
+		version:    int(quote.Header.Version),
+		signType:   quote.Header.SignType,
+		reportBody: *quote.ReportBody.quoteReportBody(),
+	}
+	// The group ID is a little endian uint32, printed as IAS does
+	gid := binary.LittleEndian.Uint32(quote.Header.EPIDGroupID[:])
+	qrData.reportBody.epidGroupID = fmt.Sprintf("%08x", gid)
+	return qrData, nil
+}
+
+// parsePlatform decodes a sgx_platform_info_t. Byte arrays are rendered as
+// decimal lists, the flags and IDs as little endian integers.
+func parsePlatform(piBlobByte []byte) (*PlatformInfoBlob, error) {
+	if len(piBlobByte) != platformInfoSize {
+		return nil, errors.New("illegal PlatformInfoBlob")
+	}
+	var info platformInfo
+	if err := binary.Read(bytes.NewReader(piBlobByte), binary.LittleEndian, &info); err != nil {
+		return nil, err
+	}
+	piBlob := &PlatformInfoBlob{
+		Sgx_epid_group_flags:       info.EPIDGroupFlags,
+		Sgx_tcb_evaluation_flags:   uint32(info.TCBEvaluationFlags),
+		Pse_evaluation_flags:       uint32(info.PSEEvaluationFlags),
+		Latest_equivalent_tcb_psvn: bytesToString(info.LatestEquivalentTCBPSVN[:]),
+		Latest_pse_isvsvn:          bytesToString(info.LatestPSEISVSVN[:]),
+		Latest_psda_svn:            bytesToString(info.LatestPSDASVN[:]),
+		Xeid:                       info.XEID,
+		Gid:                        info.GID,
+		Sgx_ec256_signature_t: SGXEC256Signature{
+			Gx: bytesToString(info.Signature[:32]),
+			Gy: bytesToString(info.Signature[32:]),
+		},
+	}
+	return piBlob, nil
+}
+
+func bytesToString(byteSlice []byte) string {
+	if len(byteSlice) == 0 {
+		return "[]"
+	}
+	var byteString string
+	for i := 0; i < len(byteSlice); i++ {
+		byteString += strconv.Itoa(int(byteSlice[i])) + ", "
+	}
+	byteString = "[" + byteString[:len(byteString)-2] + "]"
+	return byteString
+}
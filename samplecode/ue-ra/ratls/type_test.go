@@ -0,0 +1,157 @@
+package ratls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// testEPIDQuote returns an unlinkable EPID quote after applying edit to it
+// if not nil. The signature and its length are only appended if set.
+func testEPIDQuote(edit func(*EPIDQuote)) []byte {
+	q := EPIDQuote{Header: EPIDQuoteHeader{Version: 2, SignType: EPIDUnlinkable}}
+	if edit != nil {
+		edit(&q)
+	}
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, q.Header)
+	binary.Write(&buf, binary.LittleEndian, q.ReportBody)
+	if q.Signature != nil {
+		binary.Write(&buf, binary.LittleEndian, uint32(len(q.Signature)))
+		buf.Write(q.Signature)
+	}
+	return buf.Bytes()
+}
+
+func TestParseReport(t *testing.T) {
+	quote := testEPIDQuote(func(q *EPIDQuote) {
+		q.Header.SignType = EPIDLinkable
+		q.Header.EPIDGroupID = [4]byte{0x2a, 0x0b, 0, 0}
+		q.ReportBody.CPUSVN[0] = 0x0e
+		q.ReportBody.MiscSelect = 1
+		q.ReportBody.Attributes = Attributes{Flags: sgxFlagsDebug | 0x04, Xfrm: 0x07}
+		q.ReportBody.MrEnclave[0] = 0xaa
+		q.ReportBody.MrSigner[31] = 0xbb
+		q.ReportBody.ISVProdID = 3
+		q.ReportBody.ISVSVN = 4
+		q.ReportBody.ReportData[63] = 0xcc
+	})
+	tests := []struct {
+		name    string
+		raw     []byte
+		want    func(*QuoteReportData) bool
+		wantErr bool
+	}{
+		{
+			name: "identity",
+			raw:  quote,
+			want: func(d *QuoteReportData) bool {
+				b := d.reportBody
+				return d.version == 2 && d.signType == EPIDLinkable &&
+					b.mrEnclave[:2] == "aa" && b.mrSigner[62:] == "bb" && b.reportData[126:] == "cc" &&
+					b.isvProdID == 3 && b.isvSvn == 4
+			},
+		},
+		{
+			name: "attributes",
+			raw:  quote,
+			want: func(d *QuoteReportData) bool {
+				b := d.reportBody
+				return b.attributes.Flags == sgxFlagsDebug|0x04 && b.attributes.Xfrm == 0x07 &&
+					b.cpuSvn == "0e000000000000000000000000000000" && b.miscSelect == 1
+			},
+		},
+		{name: "group ID as printed by IAS", raw: quote, want: func(d *QuoteReportData) bool { return d.reportBody.epidGroupID == "00000b2a" }},
+		{name: "signature ignored", raw: testEPIDQuote(func(q *EPIDQuote) { q.Signature = []byte("signature") }), want: func(*QuoteReportData) bool { return true }},
+		{name: "truncated body", raw: quote[:len(quote)-1], wantErr: true},
+		{name: "header only", raw: quote[:48], wantErr: true},
+		{name: "empty", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseReport(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseReport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !tt.want(got) {
+				t.Errorf("parseReport() = %+v", got)
+			}
+		})
+	}
+}
+
+func TestParseEPIDSignType(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    EPIDSignType
+		wantErr bool
+	}{
+		{s: "unlinkable", want: EPIDUnlinkable},
+		{s: "linkable", want: EPIDLinkable},
+		{s: "Linkable", wantErr: true},
+		{s: "1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseEPIDSignType(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseEPIDSignType() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseEPIDSignType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePlatform(t *testing.T) {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, platformInfo{
+		EPIDGroupFlags:          groupFlagOutOfDate,
+		TCBEvaluationFlags:      0x0102,
+		PSEEvaluationFlags:      pseFlagISVSVN,
+		LatestEquivalentTCBPSVN: [18]byte{3, 4},
+		LatestPSEISVSVN:         [2]byte{5},
+		LatestPSDASVN:           [4]byte{6},
+		XEID:                    7,
+		GID:                     0x2a0b,
+	})
+	info := buf.Bytes()
+	tests := []struct {
+		name    string
+		info    []byte
+		want    func(*PlatformInfoBlob) bool
+		wantErr bool
+	}{
+		{
+			name: "flags and IDs",
+			info: info,
+			want: func(p *PlatformInfoBlob) bool {
+				return p.Sgx_epid_group_flags == groupFlagOutOfDate && p.Sgx_tcb_evaluation_flags == 0x0102 &&
+					p.Pse_evaluation_flags == pseFlagISVSVN && p.Xeid == 7 && p.Gid == 0x2a0b
+			},
+		},
+		{
+			name: "SVNs",
+			info: info,
+			want: func(p *PlatformInfoBlob) bool {
+				return p.Latest_equivalent_tcb_psvn == "[3, 4, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0]" &&
+					p.Latest_pse_isvsvn == "[5, 0]" && p.Latest_psda_svn == "[6, 0, 0, 0]"
+			},
+		},
+		{name: "truncated", info: info[:platformInfoSize-1], wantErr: true},
+		{name: "trailing data", info: append(bytes.Clone(info), 0), wantErr: true},
+		{name: "empty", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePlatform(tt.info)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePlatform() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !tt.want(got) {
+				t.Errorf("parsePlatform() = %+v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,511 @@
+// Package ratls verifies RA-TLS certificates produced by Teaclave SGX SDK
+// enclaves, i.e. self-signed certificates carrying remote attestation
+// evidence in an X.509 extension.
+package ratls
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"errors"
+	"time"
+)
+
+// Mode selects the kind of attestation evidence expected in the certificate.
+type Mode int
+
+const (
+	// ModeEPID expects an IAS attestation report bundle ("report|sig|cert").
+	ModeEPID Mode = iota
+	// ModeDCAP expects a raw ECDSA quote.
+	ModeDCAP
+	// ModeTDX expects a raw ECDSA quote v4 of a TDX trust domain.
+	ModeTDX
+	// ModeAuto detects the evidence format of each certificate, so one
+	// verifier accepts enclaves of any of the modes above. The mode used is
+	// reported in VerificationResult.Mode.
+	ModeAuto
+	// ModeMAA expects a Microsoft Azure Attestation token, obtained by the
+	// enclave for its quote and embedded as the certificate payload.
+	ModeMAA
+	// ModeITA expects an Intel Trust Authority token of an SGX enclave or
+	// TDX trust domain, embedded like in ModeMAA.
+	ModeITA
+	// ModeSim is the mode of results accepted from simulation mode
+	// enclaves, see Verifier.AllowSimulation. It is detected whatever Mode
+	// is set.
+	ModeSim
+)
+
+// CertFormat is a set of RA-TLS certificate layouts, i.e. of extensions the
+// attestation evidence may be read from.
+type CertFormat uint
+
+const (
+	// FormatTeaclave is the Netscape comment payload of Teaclave enclaves.
+	FormatTeaclave CertFormat = 1 << iota
+	// FormatIntel is the layout of Intel's RA-TLS and sgx-ra-tls
+	// (1.2.840.113741.1337.2-.6), also used by early rats-tls releases.
+	FormatIntel
+	// FormatGramine covers both Gramine layouts, see oidGramineQuote.
+	FormatGramine
+	// FormatLibrats is the TCG DICE tagged evidence of librats and
+	// rats-tls, used by Occlum and Inclavare Containers.
+	FormatLibrats
+
+	// FormatAll accepts every supported layout.
+	FormatAll = FormatTeaclave | FormatIntel | FormatGramine | FormatLibrats
+)
+
+// DefaultMaxReportAge is the report age accepted when Verifier.MaxReportAge
+// is zero.
+const DefaultMaxReportAge = 24 * time.Hour
+
+// Clock provides the current time to a Verifier.
+type Clock interface {
+	Now() time.Time
+}
+
+// FixedClock is a Clock stopped at a given time. Verifying recorded
+// evidence with a FixedClock and offline collateral gives the same result
+// on every run, which regression tests and replay tools rely on.
+type FixedClock time.Time
+
+// Now returns the fixed time.
+func (c FixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+// Verifier checks the attestation evidence embedded in an enclave's
+// certificate. A Verifier must not be modified after first use.
+type Verifier struct {
+	// Mode is the evidence format expected from the peer.
+	Mode Mode
+
+	// Formats restricts the certificate layouts evidence is read from;
+	// extensions of other layouts are ignored. Zero means FormatAll.
+	Formats CertFormat
+
+	// IASRoots holds the Intel attestation report signing CA used to verify
+	// IAS reports. If nil, the embedded Intel root is used; override it only
+	// for test environments.
+	IASRoots *x509.CertPool
+
+	// SGXRoots holds the Intel SGX root CA used to verify PCK certificate
+	// chains and DCAP collateral. Nil means the embedded Intel SGX Root CA,
+	// see IntelSGXRoots.
+	SGXRoots *x509.CertPool
+
+	// Collateral, if set, provides the signed TCB info and QE identity used
+	// in ModeDCAP and ModeTDX to evaluate the platform TCB level from its
+	// PCK certificate and to check the quoting enclave that signed the
+	// quote. If nil, neither is checked.
+	Collateral CollateralSource
+
+	// PCKCerts, if set, provides the PCK certificate chain of platforms
+	// whose quotes carry their PPID instead (certification data types 1
+	// to 3). CollateralClient fetches it from the PCS or a PCCS.
+	PCKCerts PCKCertSource
+
+	// Backend, if set, verifies quotes in ModeDCAP and ModeTDX instead of
+	// the built-in verifier. SGXRoots, Collateral, CRLs and Revocation are
+	// then unused; TCBStatuses applies to the status it reports.
+	Backend QuoteBackend
+
+	// CRLs are PEM or DER encoded CRLs of the PCK hierarchy supplied
+	// offline, checked together with those from Collateral.
+	CRLs [][]byte
+
+	// Revocation selects whether a PCK chain is rejected when a CRL it must
+	// be checked against is unavailable. The default is soft-fail.
+	Revocation RevocationPolicy
+
+	// TCBStatuses lists the accepted DCAP TCB statuses of the platform and
+	// of the quoting enclave. If empty, PermissiveTCBStatus is used.
+	TCBStatuses QuoteStatusPolicy
+
+	// QuoteStatuses lists the accepted IAS quote statuses. If empty,
+	// PermissiveQuoteStatus is used.
+	QuoteStatuses QuoteStatusPolicy
+
+	// IAS, if set, is queried for every IAS report by its ID. The report it
+	// returns must be validly signed and give the same quote and status,
+	// which confirms the report online for high assurance deployments.
+	IAS *IASClient
+
+	// MAA is the Azure Attestation provider trusted in ModeMAA, see
+	// NewMAAIssuer.
+	MAA *TokenIssuer
+
+	// ITA is the Intel Trust Authority service trusted in ModeITA, see
+	// NewITAIssuer. The TCB status it reports is checked against
+	// TCBStatuses.
+	ITA *TokenIssuer
+
+	// ReportSignatureAlgorithms lists the algorithms an IAS report may be
+	// signed with. If empty, DefaultReportSignatureAlgorithms is used.
+	ReportSignatureAlgorithms []x509.SignatureAlgorithm
+
+	// ReportRevocation, if set, checks the IAS report signing certificate
+	// chain for revocation with OCSP and CRLs.
+	ReportRevocation *OCSPChecker
+
+	// Advisories, if set, rejects IAS reports carrying security advisories
+	// that are not explicitly allowed.
+	Advisories *AdvisoryPolicy
+
+	// AdvisoryExceptions, if set, accepts IAS reports and DCAP, TDX or
+	// token TCB statuses of out of date platforms (GROUP_OUT_OF_DATE,
+	// OutOfDate, ...) only if every advisory they carry has an exception,
+	// regardless of QuoteStatuses and TCBStatuses. Statuses reported by a
+	// Backend, which reports no advisories, are checked as usual.
+	AdvisoryExceptions *AdvisoryExceptions
+
+	// PlatformInfoKey, if set, is Intel's platform info blob signing key
+	// (the PIB key of the SGX platform software). The platformInfoBlob of
+	// IAS reports, which carries TCB recovery advice for out of date
+	// platforms, must then be signed by it.
+	PlatformInfoKey *ecdsa.PublicKey
+
+	// MaxReportAge is the maximum age of an IAS report or attestation
+	// token, measured from its timestamp. Zero means DefaultMaxReportAge, a
+	// negative value disables the check.
+	MaxReportAge time.Duration
+
+	// Binding selects what report_data must start with to bind the
+	// certificate key, BindingKeyOrSPKIHash by default. Certificates whose evidence
+	// does not bind their key are rejected. BindingData computes the value
+	// for BindingCustom.
+	Binding     KeyBinding
+	BindingData BindingDataFunc
+
+	// InsecureTestRoots records that IASRoots, SGXRoots and Collateral are
+	// test roots of trust, see WithInsecureTestRoots. Every result is
+	// marked with it.
+	InsecureTestRoots bool
+
+	// Nonce, if set, is the challenge sent to the enclave for this
+	// connection (see NonceProtocol), which prevents replay of stale
+	// evidence. It must be echoed in the IAS report nonce field, in
+	// report_data[32:] or bound with BindingKeyNonceHash. report_data[32:]
+	// is only free with a key binding of at most 32 bytes, so DCAP, TDX and
+	// simulated quotes binding a raw P-256 key need BindingKeyNonceHash. Use a fresh Verifier copy per connection.
+	Nonce []byte
+
+	// AllowSimulation accepts enclaves built in simulation mode, which
+	// present a bare EPID quote that nothing vouches for. Their results are
+	// marked Simulated, in ModeSim with SimulatedQuoteStatus. Only enable it
+	// on development machines without SGX.
+	AllowSimulation bool
+
+	// AllowDebug accepts enclaves launched in debug mode, whose memory can
+	// be read by the host. Only enable it for development.
+	AllowDebug bool
+
+	// CheckISVProdID requires the enclave's ISV product ID to equal
+	// ISVProdID.
+	CheckISVProdID bool
+	ISVProdID      uint16
+
+	// CheckSignType requires EPID quotes to be signed with SignType, e.g.
+	// EPIDUnlinkable where platforms must not be recognizable across
+	// attestations.
+	CheckSignType bool
+	SignType      EPIDSignType
+
+	// MinISVSVN rejects enclaves whose ISV SVN is lower, i.e. builds that
+	// predate a security fix.
+	MinISVSVN uint16
+
+	// Measurements, if set, restricts the accepted MRENCLAVE/MRSIGNER
+	// values. It may be reloaded while the Verifier is in use.
+	Measurements *MeasurementPolicy
+
+	// Policy, if set, appraises every enclave that passed the checks above
+	// and may reject it, see RulePolicy. It can only restrict what the
+	// other fields accept.
+	Policy Appraiser
+
+	// Metrics, if set, is told the outcome and duration of every
+	// verification.
+	Metrics Metrics
+
+	// Audit, if set, receives a record of every verification, labelled
+	// with PolicyVersion.
+	Audit         AuditSink
+	PolicyVersion string
+
+	// Evidence, if set, archives the complete evidence of every accepted
+	// verification for compliance audits. Evidence it fails to archive is
+	// rejected. A CachingVerifier archives it when first verified.
+	Evidence EvidenceSink
+
+	// PKIRoots, if set, enables hybrid trust: besides carrying valid
+	// evidence, the peer certificate must chain to one of these CAs through
+	// the intermediates the peer presents, as in conventional TLS. Enclaves
+	// then obtain a CA issued certificate for their attested key. If
+	// PKIName is set, the certificate must also be valid for that host
+	// name.
+	PKIRoots *x509.CertPool
+	PKIName  string
+
+	// CheckCertStructure requires the leaf certificate to be valid now and
+	// not a CA, and a certificate presented without intermediates to be
+	// self-signed by its key, as enclaves generate it.
+	CheckCertStructure bool
+
+	// Clock, if set, is used instead of the system time for every time
+	// based check: report age, certificate, CRL and collateral validity.
+	Clock Clock
+}
+
+func (v *Verifier) now() time.Time {
+	if v.Clock != nil {
+		return v.Clock.Now()
+	}
+	return time.Now()
+}
+
+// maxReportAge returns MaxReportAge with its default applied, negative if
+// the check is disabled.
+func (v *Verifier) maxReportAge() time.Duration {
+	if v.MaxReportAge == 0 {
+		return DefaultMaxReportAge
+	}
+	return v.MaxReportAge
+}
+
+// VerifyPeerCertificate verifies the leaf of rawCerts. It has the signature
+// of tls.Config.VerifyPeerCertificate and can be plugged in directly.
+func (v *Verifier) VerifyPeerCertificate(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	_, err := v.VerifyPeerChain("", rawCerts)
+	return err
+}
+
+// Verify checks a DER encoded RA-TLS certificate and describes the attested
+// enclave. On failure the result, if not nil, holds what was established
+// before the failing check.
+func (v *Verifier) Verify(rawCert []byte) (*VerificationResult, error) {
+	return v.VerifyPeer("", rawCert)
+}
+
+// VerifyPeer is Verify for a certificate presented by the peer at address
+// peer, which is recorded in the audit record.
+func (v *Verifier) VerifyPeer(peer string, rawCert []byte) (*VerificationResult, error) {
+	return v.VerifyPeerChain(peer, [][]byte{rawCert})
+}
+
+// VerifyPeerChain is VerifyPeer for the certificate chain presented by the
+// peer, which is put in order first, see OrderChain. The evidence is read
+// from the leaf, the intermediates are only used with PKIRoots.
+func (v *Verifier) VerifyPeerChain(peer string, rawCerts [][]byte) (*VerificationResult, error) {
+	rawCerts, err := OrderChain(rawCerts)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	var res *VerificationResult
+	if v.CheckCertStructure {
+		err = v.checkLeaf(rawCerts)
+	}
+	var ev *evidence
+	if err == nil {
+		res, ev, err = v.verify(rawCerts[0])
+	}
+	if err == nil && v.PKIRoots != nil {
+		err = v.verifyPKI(rawCerts)
+		res.PKIVerified = err == nil
+	}
+	if err == nil && v.Evidence != nil {
+		err = v.archive(peer, res, ev, rawCerts)
+	}
+	v.observe(peer, res, err, start)
+	return res, err
+}
+
+// verifyPKI checks the conventional certificate chain of a peer for hybrid
+// trust.
+func (v *Verifier) verifyPKI(rawCerts [][]byte) error {
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs[i] = cert
+	}
+	opts := x509.VerifyOptions{
+		Roots:         v.PKIRoots,
+		Intermediates: x509.NewCertPool(),
+		DNSName:       v.PKIName,
+		CurrentTime:   v.now(),
+		// Servers and clients are checked alike
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	if _, err := certs[0].Verify(opts); err != nil {
+		return failuref(ErrBadSignature, "certificate does not chain to a trusted CA: %v", err)
+	}
+	return nil
+}
+
+// VerifyEvidence checks attestation evidence saved without its certificate:
+// an IAS report bundle ("report|sig|cert") in ModeEPID or a raw quote in
+// ModeDCAP and ModeTDX. No key binding can be established, so KeyBound is
+// false. Together with offline collateral it needs no network access.
+func (v *Verifier) VerifyEvidence(payload []byte) (*VerificationResult, error) {
+	start := time.Now()
+	if len(v.Nonce) > 0 && v.Binding == BindingKeyNonceHash {
+		return nil, errors.New("nonce key binding cannot be checked without the certificate")
+	}
+	ev := &evidence{comment: payload}
+	res, err := v.verifyEvidence(ev)
+	if err == nil && v.Evidence != nil {
+		err = v.archive("", res, ev, nil)
+	}
+	v.observe("", res, err, start)
+	return res, err
+}
+
+// observe reports a verification started at start to Audit and Metrics.
+func (v *Verifier) observe(peer string, res *VerificationResult, err error, start time.Time) {
+	if v.Metrics != nil {
+		v.Metrics.ObserveVerification(res, err, time.Since(start))
+	}
+	if v.Audit != nil {
+		v.audit(peer, res, err)
+	}
+}
+
+func (v *Verifier) verify(rawCert []byte) (*VerificationResult, *evidence, error) {
+	// get the pubkey and evidence from raw data
+	formats := v.Formats
+	if formats == 0 {
+		formats = FormatAll
+	}
+	ev, err := unmarshalCert(rawCert, formats)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ev.pubKey, err = v.bindingValue(ev); err != nil {
+		return nil, nil, err
+	}
+	res, err := v.verifyEvidence(ev)
+	return res, ev, err
+}
+
+func (v *Verifier) verifyEvidence(ev *evidence) (*VerificationResult, error) {
+	var err error
+	mode := v.Mode
+	if v.AllowSimulation && isSimulatedQuote(ev) {
+		mode = ModeSim
+	} else if mode == ModeAuto {
+		if mode, err = detectMode(ev); err != nil {
+			return nil, err
+		}
+	}
+	res := &VerificationResult{Mode: mode, PublicKey: ev.pubKey, altPublicKey: ev.altPubKey, nonceBound: ev.nonceBound, KeyType: ev.keyType, InsecureTestRoots: v.InsecureTestRoots}
+
+	switch mode {
+	case ModeEPID:
+		roots := v.IASRoots
+		if roots == nil {
+			roots = IntelIASRoots()
+		}
+		if ev.report == nil {
+			if ev.comment == nil {
+				return nil, errors.New("certificate carries no IAS report")
+			}
+			ev.report, ev.signature, ev.signingCert, ev.claims, err = splitIASPayload(ev.comment)
+			if err != nil {
+				return nil, err
+			}
+		}
+		// Verify Cert and Signature
+		var chain []*x509.Certificate
+		var alg x509.SignatureAlgorithm
+		if chain, alg, err = verifyCert(ev.report, ev.signature, ev.signingCert, roots, v.now(), v.ReportSignatureAlgorithms); err != nil {
+			return nil, err
+		}
+		res.ReportSignatureAlgorithm = alg.String()
+		if v.ReportRevocation != nil {
+			if err := v.ReportRevocation.check(chain, v.now()); err != nil {
+				return nil, err
+			}
+		}
+		// Verify attestation report
+		err = v.verifyAttReport(ev.report, res)
+		if err == nil && v.IAS != nil {
+			err = v.recheckReport(ev.report, roots, res)
+		}
+	case ModeDCAP, ModeTDX:
+		// The payload is a raw ECDSA quote, verify it against the PCK chain
+		quote := ev.quote
+		if quote == nil {
+			quote = ev.comment
+		}
+		if quote == nil {
+			return nil, errors.New("certificate carries no DCAP quote")
+		}
+		if v.Backend != nil {
+			err = v.verifyWithBackend(quote, v.now(), res)
+		} else if mode == ModeTDX {
+			err = v.verifyTDXQuote(quote, res)
+		} else {
+			err = v.verifyDCAPQuote(quote, res)
+		}
+		if err == nil {
+			err = v.checkNonce("", res)
+		}
+	case ModeMAA, ModeITA:
+		if ev.comment == nil {
+			return nil, errors.New("certificate carries no attestation token")
+		}
+		if mode == ModeITA {
+			err = v.verifyITAToken(ev.comment, res)
+		} else {
+			err = v.verifyMAAToken(ev.comment, res)
+		}
+	case ModeSim:
+		err = v.verifySimulatedQuote(ev.comment, res)
+	default:
+		return nil, errors.New("unknown attestation mode")
+	}
+	if err != nil {
+		return res, err
+	}
+	if ev.cert != nil && !res.KeyBound {
+		return res, failure(ErrKeyNotBound, errors.New("report_data does not bind the certificate key"))
+	}
+	if ev.channel && !res.KeyBound {
+		return res, failure(ErrKeyNotBound, errors.New("report_data does not bind the TLS channel"))
+	}
+	if ev.claims != nil {
+		if err := checkClaims(ev, res); err != nil {
+			return res, err
+		}
+	}
+
+	if res.Debug && !v.AllowDebug {
+		return res, failure(ErrDebugEnclave, errors.New("enclave is running in debug mode"))
+	}
+	if v.CheckISVProdID && res.ISVProdID != v.ISVProdID {
+		return res, failuref(ErrMeasurementMismatch, "enclave ISV product ID %d, want %d", res.ISVProdID, v.ISVProdID)
+	}
+	if res.ISVSVN < v.MinISVSVN {
+		return res, failuref(ErrMeasurementMismatch, "enclave ISV SVN %d is below the minimum %d", res.ISVSVN, v.MinISVSVN)
+	}
+
+	if v.Measurements != nil {
+		if err := v.Measurements.Check(res.MrEnclave, res.MrSigner); err != nil {
+			return res, failure(ErrMeasurementMismatch, err)
+		}
+	}
+	if v.Policy != nil {
+		if err := v.appraise(res); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
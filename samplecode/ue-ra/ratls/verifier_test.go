@@ -0,0 +1,226 @@
+package ratls
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestISVPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		verifier Verifier
+		prodID   uint16
+		svn      uint16
+		wantErr  bool
+	}{
+		{name: "no policy", prodID: 7, svn: 1},
+		{name: "product ID", verifier: Verifier{CheckISVProdID: true, ISVProdID: 7}, prodID: 7},
+		{name: "other product ID", verifier: Verifier{CheckISVProdID: true, ISVProdID: 7}, prodID: 8, wantErr: true},
+		{name: "product ID zero", verifier: Verifier{CheckISVProdID: true}, prodID: 1, wantErr: true},
+		{name: "minimum SVN", verifier: Verifier{MinISVSVN: 3}, svn: 3},
+		{name: "newer SVN", verifier: Verifier{MinISVSVN: 3}, svn: 4},
+		{name: "older SVN", verifier: Verifier{MinISVSVN: 3}, svn: 2, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := tt.verifier
+			v.AllowSimulation = true
+			raw := newSimulatedCertWith(t, func(body *ReportBody) {
+				body.ISVProdID = tt.prodID
+				body.ISVSVN = tt.svn
+			})
+			res, err := v.VerifyPeerChain("", [][]byte{raw})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyPeerChain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrMeasurementMismatch) {
+					t.Errorf("VerifyPeerChain() error = %v, want %v", err, ErrMeasurementMismatch)
+				}
+				return
+			}
+			if res.ISVProdID != tt.prodID || res.ISVSVN != tt.svn {
+				t.Errorf("ISV product ID, SVN = %d, %d, want %d, %d", res.ISVProdID, res.ISVSVN, tt.prodID, tt.svn)
+			}
+		})
+	}
+}
+
+func TestDebugEnclave(t *testing.T) {
+	tests := []struct {
+		name       string
+		allowDebug bool
+		flags      uint64
+		wantErr    bool
+	}{
+		{name: "production enclave", flags: 0x01},
+		{name: "debug enclave", flags: 0x01 | sgxFlagsDebug, wantErr: true},
+		{name: "debug enclave allowed", allowDebug: true, flags: 0x01 | sgxFlagsDebug},
+		{name: "other flags", flags: 0x01 | 0x04 | 0x80},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Verifier{AllowSimulation: true, AllowDebug: tt.allowDebug}
+			raw := newSimulatedCertWith(t, func(body *ReportBody) { body.Attributes.Flags = tt.flags })
+			res, err := v.VerifyPeerChain("", [][]byte{raw})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyPeerChain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrDebugEnclave) {
+				t.Errorf("VerifyPeerChain() error = %v, want %v", err, ErrDebugEnclave)
+			}
+			if want := tt.flags&sgxFlagsDebug != 0; res != nil && res.Debug != want {
+				t.Errorf("Debug = %v, want %v", res.Debug, want)
+			}
+		})
+	}
+}
+
+func TestVerifierClock(t *testing.T) {
+	report := testIASReport(t, EPIDLinkable, nil)
+	tests := []struct {
+		name    string
+		clock   Clock
+		wantErr bool
+	}{
+		{name: "at issue", clock: FixedClock(testNow)},
+		{name: "a day later", clock: FixedClock(testNow.Add(DefaultMaxReportAge - time.Second))},
+		{name: "too late", clock: FixedClock(testNow.Add(DefaultMaxReportAge + time.Second)), wantErr: true},
+		{name: "system time", wantErr: true},
+		{name: "clock set by hand", clock: &testClock{now: testNow.Add(time.Hour)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Verifier{Clock: tt.clock}
+			// A fixed clock gives the same outcome every time
+			for i := 0; i < 2; i++ {
+				res := &VerificationResult{}
+				if err := v.verifyAttReport(report, res); (err != nil) != tt.wantErr {
+					t.Fatalf("verifyAttReport() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if !res.Timestamp.Equal(testNow) {
+					t.Errorf("Timestamp = %v, want %v", res.Timestamp, testNow)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyEvidence(t *testing.T) {
+	pki := newTestCollateralPKI(t)
+	payload := testIASPayload(t, pki, testIASReport(t, EPIDLinkable, nil))
+	tampered := bytes.Replace(payload, []byte(`"OK"`), []byte(`"ok"`), 1)
+	simulated := EPIDQuote{Header: EPIDQuoteHeader{Version: 2, SignType: EPIDLinkable}}
+	var quote bytes.Buffer
+	binary.Write(&quote, binary.LittleEndian, simulated.Header)
+	binary.Write(&quote, binary.LittleEndian, simulated.ReportBody)
+
+	tests := []struct {
+		name     string
+		verifier Verifier
+		payload  []byte
+		wantErr  bool
+		is       error
+	}{
+		{name: "IAS report bundle", verifier: Verifier{IASRoots: pki.roots}, payload: payload},
+		{name: "untrusted signer", verifier: Verifier{}, payload: payload, wantErr: true, is: ErrBadSignature},
+		{name: "tampered report", verifier: Verifier{IASRoots: pki.roots}, payload: tampered, wantErr: true, is: ErrBadSignature},
+		{name: "simulated quote", verifier: Verifier{AllowSimulation: true}, payload: quote.Bytes()},
+		{name: "nonce binding", verifier: Verifier{IASRoots: pki.roots, Nonce: make([]byte, NonceSize), Binding: BindingKeyNonceHash}, payload: payload, wantErr: true},
+		{name: "no payload", verifier: Verifier{IASRoots: pki.roots}, payload: nil, wantErr: true},
+		{name: "malformed bundle", verifier: Verifier{IASRoots: pki.roots}, payload: []byte("report|sig"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := tt.verifier
+			v.Clock = FixedClock(testNow)
+			res, err := v.VerifyEvidence(tt.payload)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyEvidence() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.is != nil && !errors.Is(err, tt.is) {
+				t.Errorf("VerifyEvidence() error = %v, want %v", err, tt.is)
+			}
+			if err == nil && res.KeyBound {
+				t.Error("evidence without a certificate is key bound")
+			}
+		})
+	}
+}
+
+// newIssuedSimulatedCert returns the certificate of newSimulatedKeyPair
+// issued by parent for dnsName instead of self-signed.
+func newIssuedSimulatedCert(t *testing.T, dnsName string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) []byte {
+	t.Helper()
+	pair := newSimulatedKeyPair(t, func(*ReportBody) {})
+	self, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: dnsName},
+		DNSNames:     []string{dnsName},
+		NotBefore:    self.NotBefore,
+		NotAfter:     self.NotAfter,
+	}
+	for _, ext := range self.Extensions {
+		if ext.Id.Equal(oidNetscapeComment) {
+			tmpl.ExtraExtensions = append(tmpl.ExtraExtensions, ext)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, self.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestHybridPKI(t *testing.T) {
+	ca, caKey := newTestCert(t, "Enterprise CA", true, nil, nil)
+	inter, interKey := newTestCert(t, "Enterprise Issuing CA", true, ca, caKey)
+	other, otherKey := newTestCert(t, "Other CA", true, nil, nil)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	leaf := newIssuedSimulatedCert(t, "enclave.example.com", ca, caKey)
+	viaInter := newIssuedSimulatedCert(t, "enclave.example.com", inter, interKey)
+
+	tests := []struct {
+		name            string
+		roots           *x509.CertPool
+		pkiName         string
+		rawCerts        [][]byte
+		wantErr         bool
+		wantPKIVerified bool
+	}{
+		{name: "attestation only", rawCerts: [][]byte{newSimulatedCert(t, 1)}},
+		{name: "issued by root", roots: roots, rawCerts: [][]byte{leaf}, wantPKIVerified: true},
+		{name: "issued by intermediate", roots: roots, rawCerts: [][]byte{viaInter, inter.Raw}, wantPKIVerified: true},
+		{name: "host name", roots: roots, pkiName: "enclave.example.com", rawCerts: [][]byte{leaf}, wantPKIVerified: true},
+		{name: "other host name", roots: roots, pkiName: "other.example.com", rawCerts: [][]byte{leaf}, wantErr: true},
+		{name: "intermediate missing", roots: roots, rawCerts: [][]byte{viaInter}, wantErr: true},
+		{name: "untrusted CA", roots: roots, rawCerts: [][]byte{newIssuedSimulatedCert(t, "enclave.example.com", other, otherKey)}, wantErr: true},
+		{name: "self-signed", roots: roots, rawCerts: [][]byte{newSimulatedCert(t, 1)}, wantErr: true},
+		{name: "malformed intermediate", roots: roots, rawCerts: [][]byte{viaInter, []byte("certificate")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Verifier{AllowSimulation: true, PKIRoots: tt.roots, PKIName: tt.pkiName, Clock: FixedClock(testNow)}
+			res, err := v.VerifyPeerChain("", tt.rawCerts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyPeerChain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if res != nil && res.PKIVerified != tt.wantPKIVerified {
+				t.Errorf("PKIVerified = %v, want %v", res.PKIVerified, tt.wantPKIVerified)
+			}
+		})
+	}
+}
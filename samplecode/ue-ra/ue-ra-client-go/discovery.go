@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// envOr returns the environment variable name, or def if it is unset.
+func envOr(name, def string) string {
+	if v, ok := os.LookupEnv(name); ok {
+		return v
+	}
+	return def
+}
+
+// lookupSRV resolves the -srv record; tests replace it.
+var lookupSRV = net.LookupSRV
+
+// serverAddrs returns the addresses to try in order: the targets of the
+// -srv record, ordered by priority and weight, or the -server address.
+func serverAddrs() ([]string, error) {
+	if *srvName == "" {
+		return []string{*serverAddr}, nil
+	}
+	_, records, err := lookupSRV("", "", *srvName)
+	if err != nil {
+		return nil, fmt.Errorf("look up SRV %s: %v", *srvName, err)
+	}
+	var addrs []string
+	for _, srv := range records {
+		target := strings.TrimSuffix(srv.Target, ".")
+		// A target of "." means the service is deliberately unavailable
+		if target == "" {
+			continue
+		}
+		addrs = append(addrs, net.JoinHostPort(target, strconv.Itoa(int(srv.Port))))
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("SRV " + *srvName + " lists no server")
+	}
+	return addrs, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestServerAddrs(t *testing.T) {
+	addr, name, lookup := *serverAddr, *srvName, lookupSRV
+	defer func() { *serverAddr, *srvName, lookupSRV = addr, name, lookup }()
+	*serverAddr = "localhost:3443"
+	tests := []struct {
+		name    string
+		srv     string
+		records []*net.SRV
+		err     error
+		want    []string
+		wantErr bool
+	}{
+		{name: "server flag", want: []string{"localhost:3443"}},
+		{
+			name:    "SRV targets",
+			srv:     "_ra._tcp.example.com",
+			records: []*net.SRV{{Target: "a.example.com.", Port: 3443}, {Target: "b.example.com.", Port: 4443}},
+			want:    []string{"a.example.com:3443", "b.example.com:4443"},
+		},
+		{
+			name:    "unavailable target skipped",
+			srv:     "_ra._tcp.example.com",
+			records: []*net.SRV{{Target: ".", Port: 0}, {Target: "b.example.com.", Port: 4443}},
+			want:    []string{"b.example.com:4443"},
+		},
+		{name: "service unavailable", srv: "_ra._tcp.example.com", records: []*net.SRV{{Target: "."}}, wantErr: true},
+		{name: "no records", srv: "_ra._tcp.example.com", wantErr: true},
+		{name: "lookup fails", srv: "_ra._tcp.example.com", err: errors.New("no such host"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*srvName = tt.srv
+			lookupSRV = func(service, proto, name string) (string, []*net.SRV, error) {
+				if name != tt.srv {
+					t.Errorf("looked up %q, want %q", name, tt.srv)
+				}
+				return "", tt.records, tt.err
+			}
+			got, err := serverAddrs()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("serverAddrs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("serverAddrs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvOr(t *testing.T) {
+	tests := []struct {
+		name  string
+		set   bool
+		value string
+		want  string
+	}{
+		{name: "unset", want: "localhost:3443"},
+		{name: "set", set: true, value: "enclave:3443", want: "enclave:3443"},
+		{name: "set empty", set: true, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.set {
+				t.Setenv("UE_RA_TEST_SERVER", tt.value)
+			}
+			if got := envOr("UE_RA_TEST_SERVER", "localhost:3443"); got != tt.want {
+				t.Errorf("envOr() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,16 +1,127 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"flag"
 	"log"
+	"log/syslog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/apache/incubator-teaclave-sgx-sdk/samplecode/ue-ra/ratls"
 )
 
 const SERVERADDR = "localhost:3443"
 
+// maxRetryWait caps the backoff between connection attempts.
+const maxRetryWait = 30 * time.Second
+
+// qvlBackend is set by builds with the qvl tag.
+var qvlBackend ratls.QuoteBackend
+
+// sessionCache holds the sessions resumed across connections of this run.
+var sessionCache = &ratls.SessionCache{}
+
+var (
+	mode          = flag.String("mode", "epid", "attestation evidence presented by the server: epid (IAS report), dcap (ECDSA quote), tdx (TD quote), maa (Azure Attestation token), ita (Intel Trust Authority token) or auto (detected per certificate)")
+	maaURL        = flag.String("maa-url", "", "Azure Attestation provider, e.g. https://sharedeus.eus.attest.azure.net, whose tokens are trusted in maa mode (and auto mode if set)")
+	itaURL        = flag.String("ita-url", "", "Intel Trust Authority portal serving the token signing keys, "+ratls.DefaultITAURL+" if empty in ita mode; trusts its tokens in auto mode if set")
+	policy        = flag.String("policy", "", "JSON file of allowed mr_enclave/mr_signer values, reloaded on SIGHUP or change")
+	rules         = flag.String("rules", "", "JSON appraisal rules (per-signer SVN floors, TCB statuses, advisory exceptions) the enclave must also satisfy")
+	testRoots     = flag.String("insecure-test-roots", "", "INSECURE: directory of a test trust bundle (roots.pem and saved collateral) replacing the Intel roots, for SIM mode and test-signed enclaves in CI")
+	iasRoot       = flag.String("ias-root", "", "PEM file overriding the embedded Intel attestation report signing CA (for test environments)")
+	sgxRoot       = flag.String("sgx-root", "", "PEM file overriding the embedded Intel SGX Root CA in dcap, tdx and auto mode (for test environments)")
+	status        = flag.String("quote-status", "permissive", "accepted IAS quote statuses: strict, permissive or a comma separated list")
+	serverAddr    = flag.String("server", envOr("UE_RA_SERVER", SERVERADDR), "host:port of the attested server ($UE_RA_SERVER)")
+	srvName       = flag.String("srv", os.Getenv("UE_RA_SRV"), "DNS SRV record listing the attested servers, e.g. _ue-ra._tcp.example.com, tried in priority and weight order instead of -server ($UE_RA_SRV)")
+	advisories    = flag.String("allowed-advisories", "", "comma separated advisory IDs an IAS report may carry; reports with other advisories are rejected (\"none\" rejects any)")
+	exceptions    = flag.String("advisory-exceptions", "", "file of advisory IDs, one per line, with which GROUP_OUT_OF_DATE/SW_HARDENING_NEEDED (OutOfDate/SWHardeningNeeded) platforms are accepted; others with these statuses are rejected")
+	pkiRoot       = flag.String("pki-root", "", "PEM file of CAs the server certificate must also chain to (hybrid PKI and attestation trust)")
+	pkiName       = flag.String("pki-name", "", "host name the server certificate must be valid for, with -pki-root")
+	mintKey       = flag.String("mint-key", "", "PEM private key (EC P-256/P-384 or RSA) with which to issue a short-lived JWT of the verified enclave identity for downstream services")
+	mintIssuer    = flag.String("mint-issuer", "ue-ra-client-go", "iss claim of tokens issued with -mint-key")
+	checkCert     = flag.Bool("check-cert", false, "require the server certificate to be valid now, not a CA and, without intermediates, self-signed by its key")
+	reportSigAlgs = flag.String("report-sig-algs", "", "comma separated algorithms the IAS report may be signed with, e.g. SHA256-RSA,SHA384-RSA; RSA, RSA-PSS and ECDSA with SHA-256 or stronger if empty")
+	iasOCSP       = flag.Bool("ias-ocsp", false, "check the IAS report signing certificate for revocation with OCSP, falling back to its CRL")
+	iasOCSPFiles  = flag.String("ias-ocsp-response", "", "comma separated DER OCSP responses for the IAS report signing certificate to use instead of querying the responder (implies -ias-ocsp)")
+	iasOCSPHard   = flag.Bool("ias-ocsp-hard-fail", false, "with -ias-ocsp, reject reports whose signing certificate status cannot be established")
+	pibKey        = flag.String("pib-key", "", "PEM file of Intel's platform info blob signing key; the platformInfoBlob of IAS reports must then be signed by it")
+	allowSim      = flag.Bool("allow-sim", false, "accept servers built in simulation mode (SGX_MODE=SW), which present a bare quote nothing vouches for (development only, INSECURE)")
+	allowDebug    = flag.Bool("allow-debug", false, "accept enclaves running in debug mode (development only)")
+	prodID        = flag.Int("isv-prod-id", -1, "required ISV product ID of the enclave, -1 to accept any")
+	minSVN        = flag.Uint("min-isv-svn", 0, "minimum accepted ISV SVN of the enclave")
+	signType      = flag.String("sign-type", "", "required EPID signature type, linkable or unlinkable; any if empty")
+	binding       = flag.String("binding", "auto", "what report_data must start with: auto (spki-sha256 or key), key (the key encoding), spki-sha256 (SHA-256 of the SubjectPublicKeyInfo) or key-nonce-sha256 (SHA-256 of the key encoding and the -nonce challenge)")
+	evidenceURL   = flag.String("evidence-url", "", "verify the evidence served at "+ratls.WellKnownEvidencePath+" of this URL, for enclaves behind a TLS-terminating load balancer, and exit")
+	postHandshake = flag.Bool("post-handshake", false, "expect the server evidence in an attest message after the handshake, bound to the TLS channel, instead of in its certificate")
+	useNonce      = flag.Bool("nonce", false, "send a fresh challenge in ALPN and require the enclave evidence to reflect it")
+	maxAge        = flag.Duration("max-report-age", ratls.DefaultMaxReportAge, "maximum age of the IAS report, negative to disable")
+	collateral    = flag.String("collateral-url", "", "PCS or PCCS certification API used to evaluate the DCAP platform TCB level, e.g. "+ratls.DefaultPCSURL)
+	iasAPIKey     = flag.String("ias-api-key", "", "IAS subscription key; if set, every IAS report is retrieved again by its ID and must still match")
+	iasURL        = flag.String("ias-url", "", "IAS attestation API queried with -ias-api-key, "+ratls.DefaultIASURL+" if empty")
+	pcsAPIKey     = flag.String("pcs-api-key", "", "Intel PCS subscription key, if required by -collateral-url")
+	cacheDir      = flag.String("collateral-cache", "", "directory caching collateral fetched from -collateral-url between runs")
+	cacheTTL      = flag.Duration("collateral-ttl", ratls.DefaultCollateralTTL, "maximum lifetime of cached collateral, shortened by its nextUpdate")
+	auditLog      = flag.String("audit", "", "append a JSON audit record of each verification to this file (\"-\" for stdout)")
+	evidenceLog   = flag.String("evidence-log", "", "keep the complete evidence of every accepted server for compliance audits: a directory receiving one read-only JSON file each, or \"syslog\"")
+	policyVer     = flag.String("policy-version", "", "policy version recorded in audit records")
+	offline       = flag.String("offline", "", "verify a saved RA certificate (PEM or DER) or raw evidence file without network access, then exit")
+	verifyAt      = flag.String("at", "", "verify as of this RFC 3339 time instead of now, to replay recorded evidence")
+	useQVL        = flag.Bool("qvl", false, "verify DCAP/TDX quotes with Intel's quote verification library (needs a build with -tags qvl)")
+	crls          = flag.String("crl", "", "comma separated PEM or DER CRL files of the PCK hierarchy, checked in DCAP mode in addition to fetched ones")
+	crlHard       = flag.Bool("crl-hard-fail", false, "reject PCK chains that cannot be checked against a valid CRL")
+	tcbStatus     = flag.String("tcb-status", "permissive", "accepted DCAP and Trust Authority TCB statuses: strict, permissive or a comma separated list")
+	proxy         = flag.String("proxy", "", "HTTP CONNECT (http://host:port) or SOCKS5 (socks5://host:port) proxy to reach the server through, with optional user:password@; defaults to HTTPS_PROXY unless NO_PROXY matches")
+	dialTimeout   = flag.Duration("dial-timeout", 10*time.Second, "timeout of connecting and the RA-TLS handshake, 0 for none")
+	ioTimeout     = flag.Duration("timeout", 30*time.Second, "read and write deadline of each exchange with the server, 0 for none")
+	retries       = flag.Int("retries", 0, "number of times a failed connection is retried")
+	retryWait     = flag.Duration("retry-backoff", time.Second, "wait before the first retry, doubled after each attempt up to 30s")
+	interact      = flag.Bool("interactive", false, "after the greeting, send each line of stdin to the server over a new attested session and print the reply")
+	sendPath      = flag.String("send-file", "", "after the greeting, transfer this file to the server, one attested session per 1024 byte message")
+	tlsVersion    = flag.String("tls-version", "1.2", "minimum TLS version: 1.2 or 1.3 (TLS 1.3 only)")
+	cipherSuites  = flag.String("cipher-suites", "", "comma separated TLS 1.2 cipher suites to offer, e.g. TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384")
+	curves        = flag.String("curves", "", "comma separated key exchange curves to offer: X25519, P-256, P-384, P-521")
+	renegotiate   = flag.Bool("renegotiate", false, "accept TLS 1.2 renegotiation by the server; every new certificate is verified and certificate or identity changes are logged")
+	resume        = flag.Bool("resume", false, "resume TLS sessions on reconnects instead of verifying the server again, within -resume-max-age and -resume-max")
+	resumeAge     = flag.Duration("resume-max-age", time.Hour, "time after a verified handshake its session may be resumed for")
+	resumeMax     = flag.Int("resume-max", 10, "number of times a verified session may be resumed")
+	reattestEvery = flag.Duration("reattest", 0, "verify the server again over a new connection at this interval during -interactive or -send-file sessions, closing the session if its identity or TCB status changed")
+	exportLen     = flag.Int("export-keying-material", 0, "derive and print this many bytes of keying material bound to the attested channel")
+)
+
 func main() {
+	flag.Parse()
 	log.SetFlags(log.Lshortfile)
 	println("Starting ue-ra-client-go")
 
+	verifier := newVerifier()
+	sessionCache.MaxAge = *resumeAge
+	sessionCache.MaxResumptions = *resumeMax
+	sessionCache.Clock = verifier.Clock
+
+	if *offline != "" {
+		verifyOffline(verifier, *offline)
+		return
+	}
+	if *evidenceURL != "" {
+		res, err := verifier.FetchEvidence(context.Background(), nil, *evidenceURL)
+		if res != nil {
+			printResult(res)
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+		println("HTTP evidence verification passed")
+		return
+	}
+
 	certPem, keyPem := loadCert()
 	pem := []byte(certPem + keyPem)
 	cert, err := tls.X509KeyPair(pem, pem)
@@ -18,33 +129,477 @@ func main() {
 		log.Fatalln(err)
 	}
 
-	println("Connecting to ", SERVERADDR)
+	if *useNonce {
+		nonce, err := ratls.NewNonce()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		withNonce := *verifier
+		withNonce.Nonce = nonce
+		verifier = &withNonce
+	}
 
-	conn, err := tls.Dial("tcp", SERVERADDR, make_config(cert))
+	addrs, err := serverAddrs()
 	if err != nil {
 		log.Fatalln(err)
 	}
-	defer conn.Close()
+	println("Connecting to ", strings.Join(addrs, ", "))
 
-	n, err := conn.Write([]byte("hello ue-ra go client"))
+	conn, err := connect(addrs, make_config(cert, verifier), monitoredVerifier{mraVerifier{verifier}})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer conn.Close()
+	peerResult, err := conn.Identity()
 	if err != nil {
 		log.Fatalln(err)
 	}
 
-	buf := make([]byte, 100)
-	n, err = conn.Read(buf)
+	if *mintKey != "" {
+		minter := &ratls.TokenMinter{Issuer: *mintIssuer, Key: loadSigner(*mintKey), Clock: verifier.Clock}
+		token, err := minter.Mint(peerResult)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		println("attestation token: ", token)
+	}
+
+	if *exportLen > 0 {
+		ekm, err := ratls.ExportKeyingMaterial(conn.ConnectionState(), peerResult, *exportLen)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		println("exported keying material: ", hex.EncodeToString(ekm))
+	}
+
+	reply, err := exchange(conn.Conn, []byte("hello ue-ra go client"), make([]byte, 100))
 	if err != nil {
 		log.Fatalln(err)
 	}
+	println("server replied: ", string(reply))
 
-	println("server replied: ", string(buf[:n]))
+	if *reattestEvery > 0 {
+		go reattest(conn, cert, verifier, peerResult, *reattestEvery)
+	}
+
+	if *sendPath != "" {
+		if err := sendFile(cert, verifier, *sendPath); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if *interact {
+		if err := interactive(cert, verifier); err != nil {
+			log.Fatalln(err)
+		}
+	}
 }
 
-func make_config(cert tls.Certificate) *tls.Config {
-	conf := &tls.Config{
-		InsecureSkipVerify: true,
+// connectedAddr is the address of the server connect reached, which
+// re-attestation connects to again.
+var connectedAddr string
+
+// connect dials the first of addrs that answers and completes the RA-TLS
+// handshake. Failures of every server, whether unreachable or rejected by
+// verify_mra_cert, are retried with exponential backoff, so the client
+// survives enclave restarts. The last error is returned once -retries is
+// exhausted.
+func connect(addrs []string, conf *tls.Config, verifier serverVerifier) (*ratls.Conn, error) {
+	backoff := *retryWait
+	for attempt := 0; ; attempt++ {
+		var conn *ratls.Conn
+		var err error
+		for _, addr := range addrs {
+			if conn, err = handshake(addr, conf, verifier); err == nil {
+				connectedAddr = addr
+				return conn, nil
+			}
+			log.Printf("connect to %s: %v", addr, err)
+		}
+		if attempt >= *retries {
+			return nil, err
+		}
+		log.Printf("retrying in %v", backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > maxRetryWait {
+			backoff = maxRetryWait
+		}
 	}
+}
+
+// serverVerifier verifies the server from its certificate or, with
+// -post-handshake, from its attest message.
+type serverVerifier interface {
+	ratls.PeerVerifier
+	ratls.ChannelVerifier
+}
+
+func handshake(addr string, conf *tls.Config, verifier serverVerifier) (*ratls.Conn, error) {
+	rawConn, err := dialTCP(addr)
+	if err != nil {
+		return nil, err
+	}
+	var conn *ratls.Conn
+	if *postHandshake {
+		conn = ratls.PostHandshakeClient(rawConn, conf, verifier)
+	} else {
+		conn = ratls.Client(rawConn, conf, verifier)
+	}
+	ctx := context.Background()
+	if *dialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *dialTimeout)
+		defer cancel()
+	}
+	if err := conn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func make_config(cert tls.Certificate, verifier *ratls.Verifier) *tls.Config {
+	conf := &tls.Config{}
 	conf.Certificates = []tls.Certificate{cert}
-	conf.VerifyPeerCertificate = verify_mra_cert
+	if err := applyTLSProfile(conf); err != nil {
+		log.Fatalln(err)
+	}
+	if *renegotiate {
+		conf.Renegotiation = tls.RenegotiateFreelyAsClient
+	}
+	if verifier.Nonce != nil {
+		conf.NextProtos = []string{ratls.NonceProtocol(verifier.Nonce)}
+	} else if *resume {
+		// A challenge must be answered by fresh evidence, so sessions are
+		// only resumed without -nonce
+		conf.ClientSessionCache = sessionCache
+	}
 	return conf
 }
+
+func newVerifier() *ratls.Verifier {
+	verifier := &ratls.Verifier{}
+	switch *mode {
+	case "epid":
+		verifier.Mode = ratls.ModeEPID
+	case "dcap":
+		verifier.Mode = ratls.ModeDCAP
+	case "tdx":
+		verifier.Mode = ratls.ModeTDX
+	case "maa":
+		verifier.Mode = ratls.ModeMAA
+	case "ita":
+		verifier.Mode = ratls.ModeITA
+	case "auto":
+		// Servers may present EPID or DCAP evidence, e.g. while migrating
+		verifier.Mode = ratls.ModeAuto
+	default:
+		log.Fatalln("unknown attestation mode:", *mode)
+	}
+
+	if verifier.Mode == ratls.ModeEPID || verifier.Mode == ratls.ModeAuto {
+		statuses, err := ratls.ParseQuoteStatusPolicy(*status)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if *iasRoot != "" {
+			verifier.IASRoots = loadCertPool(*iasRoot)
+		}
+		verifier.QuoteStatuses = statuses
+		verifier.MaxReportAge = *maxAge
+		if *pibKey != "" {
+			verifier.PlatformInfoKey = loadECPublicKey(*pibKey)
+		}
+		if *iasAPIKey != "" {
+			verifier.IAS = &ratls.IASClient{BaseURL: *iasURL, APIKey: *iasAPIKey}
+		}
+		if *reportSigAlgs != "" {
+			if verifier.ReportSignatureAlgorithms, err = ratls.ParseSignatureAlgorithms(*reportSigAlgs); err != nil {
+				log.Fatalln(err)
+			}
+		}
+		if *iasOCSP || *iasOCSPFiles != "" {
+			verifier.ReportRevocation = &ratls.OCSPChecker{}
+			if *iasOCSPFiles != "" {
+				for _, path := range strings.Split(*iasOCSPFiles, ",") {
+					raw, err := os.ReadFile(path)
+					if err != nil {
+						log.Fatalln(err)
+					}
+					verifier.ReportRevocation.Stapled = append(verifier.ReportRevocation.Stapled, raw)
+				}
+			}
+			if *iasOCSPHard {
+				verifier.ReportRevocation.Policy = ratls.RevocationHardFail
+			}
+		}
+		if *advisories != "" {
+			verifier.Advisories = &ratls.AdvisoryPolicy{}
+			if *advisories != "none" {
+				verifier.Advisories.Allowed = strings.Split(*advisories, ",")
+			}
+		}
+	}
+	if verifier.Mode == ratls.ModeMAA || verifier.Mode == ratls.ModeAuto && *maaURL != "" {
+		if *maaURL == "" {
+			log.Fatalln("-maa-url is required in maa mode")
+		}
+		verifier.MAA = ratls.NewMAAIssuer(*maaURL)
+		verifier.MaxReportAge = *maxAge
+	}
+	if verifier.Mode == ratls.ModeITA || verifier.Mode == ratls.ModeAuto && *itaURL != "" {
+		statuses, err := ratls.ParseTCBStatusPolicy(*tcbStatus)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		verifier.ITA = ratls.NewITAIssuer(*itaURL)
+		verifier.TCBStatuses = statuses
+		verifier.MaxReportAge = *maxAge
+	}
+	if verifier.Mode == ratls.ModeDCAP || verifier.Mode == ratls.ModeTDX || verifier.Mode == ratls.ModeAuto {
+		if *testRoots == "" && *sgxRoot != "" {
+			verifier.SGXRoots = loadCertPool(*sgxRoot)
+		}
+		if *offline != "" && *cacheDir != "" {
+			statuses, err := ratls.ParseTCBStatusPolicy(*tcbStatus)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			verifier.Collateral = ratls.OfflineCollateral{Dir: *cacheDir}
+			verifier.TCBStatuses = statuses
+		} else if *collateral != "" && *offline == "" {
+			statuses, err := ratls.ParseTCBStatusPolicy(*tcbStatus)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			client := &ratls.CollateralClient{BaseURL: *collateral, APIKey: *pcsAPIKey}
+			if verifier.Mode == ratls.ModeTDX {
+				// PCS and PCCS serve TDX TCB info and QE identity under /tdx/
+				client.TCBBaseURL = strings.Replace(*collateral, "/sgx/", "/tdx/", 1)
+			}
+			verifier.Collateral = &ratls.CollateralCache{
+				Source: client,
+				TTL:    *cacheTTL,
+				Dir:    *cacheDir,
+			}
+			verifier.PCKCerts = client
+			verifier.TCBStatuses = statuses
+		}
+		if *crls != "" {
+			for _, path := range strings.Split(*crls, ",") {
+				crl, err := readFile(path)
+				if err != nil {
+					log.Fatalln(err)
+				}
+				verifier.CRLs = append(verifier.CRLs, []byte(crl))
+			}
+		}
+		if *crlHard {
+			verifier.Revocation = ratls.RevocationHardFail
+		}
+		if *useQVL {
+			if qvlBackend == nil {
+				log.Fatalln("-qvl requires a build with -tags qvl (make qvl)")
+			}
+			statuses, err := ratls.ParseTCBStatusPolicy(*tcbStatus)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			verifier.Backend = qvlBackend
+			verifier.TCBStatuses = statuses
+		}
+	}
+
+	verifier.AllowDebug = *allowDebug
+	if *allowSim {
+		log.Println("WARNING: -allow-sim accepts simulation mode enclaves, whose identity is NOT attested; never use it in production")
+		verifier.AllowSimulation = true
+	}
+	verifier.CheckCertStructure = *checkCert
+	keyBinding, err := ratls.ParseKeyBinding(*binding)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if keyBinding == ratls.BindingKeyNonceHash && !*useNonce {
+		log.Fatalln("-binding key-nonce-sha256 requires -nonce")
+	}
+	if *useNonce && keyBinding == ratls.BindingKey && (*mode == "dcap" || *mode == "tdx") {
+		log.Fatalln("-nonce with -binding key leaves no room for the challenge in report_data, use -binding key-nonce-sha256")
+	}
+	verifier.Binding = keyBinding
+	if *pkiRoot != "" {
+		verifier.PKIRoots = loadCertPool(*pkiRoot)
+		verifier.PKIName = *pkiName
+	}
+	if *auditLog != "" {
+		out := os.Stdout
+		if *auditLog != "-" {
+			f, err := os.OpenFile(*auditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			out = f
+		}
+		verifier.Audit = ratls.NewJSONAuditSink(out)
+		verifier.PolicyVersion = *policyVer
+	}
+	if *evidenceLog == "syslog" {
+		w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_AUTH, "ue-ra-client-go")
+		if err != nil {
+			log.Fatalln(err)
+		}
+		verifier.Evidence = ratls.NewJSONEvidenceSink(w)
+		verifier.PolicyVersion = *policyVer
+	} else if *evidenceLog != "" {
+		verifier.Evidence = ratls.EvidenceDir{Dir: *evidenceLog}
+		verifier.PolicyVersion = *policyVer
+	}
+	if *verifyAt != "" {
+		at, err := time.Parse(time.RFC3339, *verifyAt)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		verifier.Clock = ratls.FixedClock(at)
+	}
+	if *prodID >= 0 {
+		verifier.CheckISVProdID = true
+		verifier.ISVProdID = uint16(*prodID)
+	}
+	verifier.MinISVSVN = uint16(*minSVN)
+	if *signType != "" {
+		t, err := ratls.ParseEPIDSignType(*signType)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		verifier.CheckSignType = true
+		verifier.SignType = t
+	}
+
+	if *policy != "" {
+		measurements, err := ratls.LoadMeasurementPolicy(*policy)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		watchPolicy(measurements)
+		verifier.Measurements = measurements
+	}
+	if *rules != "" {
+		p, err := ratls.LoadRulePolicy(*rules)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		verifier.Policy = p
+	}
+	if *exceptions != "" {
+		e, err := ratls.LoadAdvisoryExceptions(*exceptions)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		verifier.AdvisoryExceptions = e
+	}
+	if *testRoots != "" {
+		roots, err := ratls.LoadTestRoots(*testRoots)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		log.Println("WARNING: trusting insecure test roots from", *testRoots)
+		ratls.WithInsecureTestRoots(roots)(verifier)
+	}
+	return verifier
+}
+
+// watchPolicy reloads the measurement policy on SIGHUP and whenever the
+// file changes on disk.
+func watchPolicy(measurements *ratls.MeasurementPolicy) {
+	measurements.Watch(time.Second, func(err error) {
+		log.Println("reload policy:", err)
+	})
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := measurements.Reload(); err != nil {
+				log.Println("reload policy:", err)
+			} else {
+				log.Println("policy reloaded")
+			}
+		}
+	}()
+}
+
+// verifyOffline checks evidence saved to a file: an RA certificate, or the
+// raw payload of one (an IAS report bundle or a quote).
+func verifyOffline(verifier *ratls.Verifier, path string) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if block, _ := pem.Decode(raw); block != nil && block.Type == "CERTIFICATE" {
+		raw = block.Bytes
+	}
+
+	var res *ratls.VerificationResult
+	if _, perr := x509.ParseCertificate(raw); perr == nil {
+		printCert(raw)
+		res, err = verifier.Verify(raw)
+	} else {
+		res, err = verifier.VerifyEvidence(raw)
+	}
+	if res != nil {
+		printResult(res)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+	println("offline verification passed")
+}
+
+// mraVerifier verifies the server with verify_mra_cert.
+type mraVerifier struct {
+	verifier *ratls.Verifier
+}
+
+func (m mraVerifier) VerifyPeerChain(peer string, rawCerts [][]byte) (*ratls.VerificationResult, error) {
+	return verify_mra_cert(m.verifier, peer, rawCerts)
+}
+
+func (m mraVerifier) VerifyChannelEvidence(peer string, cs tls.ConnectionState, payload []byte) (*ratls.VerificationResult, error) {
+	printCert(cs.PeerCertificates[0].Raw)
+
+	res, err := m.verifier.VerifyChannelEvidence(peer, cs, payload)
+	if res != nil {
+		printResult(res)
+	}
+	if err != nil {
+		log.Println("attestation rejected:", err)
+		return nil, err
+	}
+	if res.KeyBound {
+		println("ue RA done!")
+	}
+	return res, nil
+}
+
+func verify_mra_cert(verifier *ratls.Verifier, peer string, rawCerts [][]byte) (*ratls.VerificationResult, error) {
+	// The leaf carries the evidence, wherever the server put it in the chain
+	rawCerts, err := ratls.OrderChain(rawCerts)
+	if err != nil {
+		log.Println("certificate chain rejected:", err)
+		return nil, err
+	}
+	printCert(rawCerts[0])
+
+	res, err := verifier.VerifyPeerChain(peer, rawCerts)
+	if res != nil {
+		printResult(res)
+	}
+	if err != nil {
+		log.Println("attestation rejected:", err)
+		return nil, err
+	}
+	if res.KeyBound {
+		println("ue RA done!")
+	}
+	return res, nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/apache/incubator-teaclave-sgx-sdk/samplecode/ue-ra/ratls"
+)
+
+// testServer accepts TLS connections on a loopback port until the test
+// ends and returns its address.
+func testServer(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "enclave"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// closedAddr returns a loopback address nothing listens on.
+func closedAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+// flakyVerifier rejects the first fail servers it verifies.
+type flakyVerifier struct {
+	fail  int
+	calls int
+}
+
+func (f *flakyVerifier) VerifyPeerChain(peer string, rawCerts [][]byte) (*ratls.VerificationResult, error) {
+	f.calls++
+	if f.calls <= f.fail {
+		return nil, errors.New("enclave restarting")
+	}
+	return &ratls.VerificationResult{}, nil
+}
+
+func (f *flakyVerifier) VerifyChannelEvidence(peer string, cs tls.ConnectionState, payload []byte) (*ratls.VerificationResult, error) {
+	return f.VerifyPeerChain(peer, nil)
+}
+
+func TestConnect(t *testing.T) {
+	server, closed := testServer(t), closedAddr(t)
+	wait, timeout, n := *retryWait, *dialTimeout, *retries
+	defer func() { *retryWait, *dialTimeout, *retries = wait, timeout, n }()
+	*retryWait, *dialTimeout = time.Millisecond, 5*time.Second
+	tests := []struct {
+		name      string
+		addrs     []string
+		fail      int
+		retries   int
+		wantAddr  string
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "first attempt", addrs: []string{server}, wantAddr: server, wantCalls: 1},
+		{name: "fallback address", addrs: []string{closed, server}, wantAddr: server, wantCalls: 1},
+		{name: "rejected then accepted", addrs: []string{server}, fail: 2, retries: 2, wantAddr: server, wantCalls: 3},
+		{name: "retries exhausted", addrs: []string{server}, fail: 3, retries: 2, wantCalls: 3, wantErr: true},
+		{name: "no retries", addrs: []string{server}, fail: 1, wantCalls: 1, wantErr: true},
+		{name: "unreachable", addrs: []string{closed}, retries: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*retries = tt.retries
+			connectedAddr = ""
+			verifier := &flakyVerifier{fail: tt.fail}
+			conn, err := connect(tt.addrs, &tls.Config{}, verifier)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("connect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if conn != nil {
+				conn.Close()
+			}
+			if connectedAddr != tt.wantAddr || verifier.calls != tt.wantCalls {
+				t.Errorf("connected to %q after %d verifications, want %q after %d", connectedAddr, verifier.calls, tt.wantAddr, tt.wantCalls)
+			}
+		})
+	}
+}
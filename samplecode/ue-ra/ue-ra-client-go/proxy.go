@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// proxyURL returns the proxy to reach addr through: the -proxy flag, else
+// HTTPS_PROXY honoring NO_PROXY (see http.ProxyFromEnvironment, which never
+// proxies localhost), or nil to dial directly.
+func proxyURL(addr string) (*url.URL, error) {
+	if *proxy != "" {
+		return url.Parse(*proxy)
+	}
+	req := &http.Request{URL: &url.URL{Scheme: "https", Host: addr}}
+	return http.ProxyFromEnvironment(req)
+}
+
+// dialTCP connects to addr, through a proxy if one is configured. The RA-TLS
+// handshake runs end to end over the tunnel, so the proxy is not trusted.
+func dialTCP(addr string) (net.Conn, error) {
+	proxy, err := proxyURL(addr)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: *dialTimeout}
+	if proxy == nil {
+		return dialer.Dial("tcp", addr)
+	}
+
+	host := proxy.Host
+	if proxy.Port() == "" {
+		port := "1080"
+		if proxy.Scheme == "http" {
+			port = "8080"
+		}
+		host = net.JoinHostPort(proxy.Hostname(), port)
+	}
+	conn, err := dialer.Dial("tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	if *dialTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(*dialTimeout))
+	}
+	switch proxy.Scheme {
+	case "http":
+		err = httpConnect(conn, addr, proxy.User)
+	case "socks5", "socks5h":
+		err = socks5Connect(conn, addr, proxy.User)
+	default:
+		err = fmt.Errorf("unsupported proxy scheme %q", proxy.Scheme)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s: %v", proxy.Redacted(), err)
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// httpConnect opens a tunnel to addr with an HTTP CONNECT request.
+func httpConnect(conn net.Conn, addr string, user *url.Userinfo) error {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if user != nil {
+		pass, _ := user.Password()
+		cred := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + pass))
+		req.Header.Set("Proxy-Authorization", "Basic "+cred)
+	}
+	if err := req.Write(conn); err != nil {
+		return err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("CONNECT %s: %s", addr, resp.Status)
+	}
+	// The server speaks only after the TLS client hello
+	if br.Buffered() > 0 {
+		return errors.New("unexpected data after CONNECT response")
+	}
+	return nil
+}
+
+// socks5Connect opens a tunnel to addr with a SOCKS5 CONNECT command
+// (RFC 1928), authenticating with a username and password (RFC 1929) if
+// given. The proxy resolves the host name.
+func socks5Connect(conn net.Conn, addr string, user *url.Userinfo) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return err
+	}
+	if len(host) > 255 {
+		return errors.New("host name too long")
+	}
+
+	methods := []byte{0x00}
+	if user != nil {
+		methods = []byte{0x02}
+	}
+	if _, err := conn.Write(append([]byte{0x05, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != 0x05 || reply[1] != methods[0] {
+		return errors.New("SOCKS5 authentication method rejected")
+	}
+	if user != nil {
+		pass, _ := user.Password()
+		if len(user.Username()) > 255 || len(pass) > 255 {
+			return errors.New("SOCKS5 credentials too long")
+		}
+		auth := []byte{0x01, byte(len(user.Username()))}
+		auth = append(auth, user.Username()...)
+		auth = append(auth, byte(len(pass)))
+		auth = append(auth, pass...)
+		if _, err := conn.Write(auth); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0x00 {
+			return errors.New("SOCKS5 authentication failed")
+		}
+	}
+
+	req := []byte{0x05, 0x01, 0x00, 0x03, byte(len(host))}
+	req = append(req, host...)
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return err
+	}
+	if head[1] != 0x00 {
+		return fmt.Errorf("SOCKS5 CONNECT %s failed with code %d", addr, head[1])
+	}
+	// Skip the bound address and port
+	var skip int
+	switch head[3] {
+	case 0x01:
+		skip = net.IPv4len + 2
+	case 0x04:
+		skip = net.IPv6len + 2
+	case 0x03:
+		n := make([]byte, 1)
+		if _, err := io.ReadFull(conn, n); err != nil {
+			return err
+		}
+		skip = int(n[0]) + 2
+	default:
+		return errors.New("malformed SOCKS5 reply")
+	}
+	_, err = io.ReadFull(conn, make([]byte, skip))
+	return err
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestHTTPConnect(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     *url.Userinfo
+		response string
+		wantAuth string
+		wantErr  bool
+	}{
+		{name: "tunnel", response: "HTTP/1.1 200 Connection established\r\n\r\n"},
+		{name: "credentials", user: url.UserPassword("alice", "secret"), response: "HTTP/1.1 200 OK\r\n\r\n", wantAuth: "Basic YWxpY2U6c2VjcmV0"},
+		{name: "denied", response: "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n", wantErr: true},
+		{name: "data after response", response: "HTTP/1.1 200 OK\r\n\r\nhello", wantErr: true},
+		{name: "not HTTP", response: "SSH-2.0-OpenSSH\r\n\r\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer server.Close()
+			got := make(chan *http.Request, 1)
+			go func() {
+				req, err := http.ReadRequest(bufio.NewReader(server))
+				got <- req
+				if err == nil {
+					io.WriteString(server, tt.response)
+				}
+				server.Close()
+			}()
+			err := httpConnect(client, "enclave:3443", tt.user)
+			client.Close()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("httpConnect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			req := <-got
+			if req == nil || req.Method != http.MethodConnect || req.Host != "enclave:3443" {
+				t.Fatalf("proxy received %+v", req)
+			}
+			if auth := req.Header.Get("Proxy-Authorization"); auth != tt.wantAuth {
+				t.Errorf("Proxy-Authorization = %q, want %q", auth, tt.wantAuth)
+			}
+		})
+	}
+}
+
+// socks5Proxy plays the proxy side of a SOCKS5 CONNECT on conn, answering
+// the greeting with method, the authentication with authStatus and the
+// request with reply, and returns what the client sent.
+func socks5Proxy(conn net.Conn, method, authStatus byte, reply []byte) []byte {
+	var sent bytes.Buffer
+	r := io.TeeReader(conn, &sent)
+	read := func(n int) []byte {
+		b := make([]byte, n)
+		io.ReadFull(r, b)
+		return b
+	}
+	read(int(read(2)[1]))
+	conn.Write([]byte{0x05, method})
+	if method == 0x02 {
+		read(int(read(2)[1]))
+		read(int(read(1)[0]))
+		conn.Write([]byte{0x01, authStatus})
+	}
+	head := read(5)
+	read(int(head[4]) + 2)
+	conn.Write(reply)
+	return sent.Bytes()
+}
+
+func TestSOCKS5Connect(t *testing.T) {
+	ok := []byte{0x05, 0x00, 0x00, 0x01, 10, 0, 0, 1, 0x0d, 0x73}
+	request := []byte{0x05, 0x01, 0x00, 0x03, 7, 'e', 'n', 'c', 'l', 'a', 'v', 'e', 0x0d, 0x73}
+	tests := []struct {
+		name       string
+		addr       string
+		user       *url.Userinfo
+		method     byte
+		authStatus byte
+		reply      []byte
+		wantSent   []byte
+		wantErr    bool
+	}{
+		{
+			name:     "no authentication",
+			addr:     "enclave:3443",
+			reply:    ok,
+			wantSent: append([]byte{0x05, 0x01, 0x00}, request...),
+		},
+		{
+			name:     "password",
+			addr:     "enclave:3443",
+			user:     url.UserPassword("bob", "pw"),
+			method:   0x02,
+			reply:    ok,
+			wantSent: append([]byte{0x05, 0x01, 0x02, 0x01, 3, 'b', 'o', 'b', 2, 'p', 'w'}, request...),
+		},
+		{
+			name:     "IPv6 bound address",
+			addr:     "enclave:3443",
+			reply:    append([]byte{0x05, 0x00, 0x00, 0x04}, make([]byte, net.IPv6len+2)...),
+			wantSent: append([]byte{0x05, 0x01, 0x00}, request...),
+		},
+		{
+			name:     "domain bound address",
+			addr:     "enclave:3443",
+			reply:    []byte{0x05, 0x00, 0x00, 0x03, 5, 'p', 'r', 'o', 'x', 'y', 0x04, 0x38},
+			wantSent: append([]byte{0x05, 0x01, 0x00}, request...),
+		},
+		{name: "method rejected", addr: "enclave:3443", method: 0xff, wantErr: true},
+		{name: "bad password", addr: "enclave:3443", user: url.UserPassword("bob", "pw"), method: 0x02, authStatus: 0x01, wantErr: true},
+		{name: "connection refused", addr: "enclave:3443", reply: []byte{0x05, 0x05, 0x00, 0x01, 0, 0, 0, 0, 0, 0}, wantErr: true},
+		{name: "unknown address type", addr: "enclave:3443", reply: []byte{0x05, 0x00, 0x00, 0x07}, wantErr: true},
+		{name: "truncated reply", addr: "enclave:3443", reply: ok[:6], wantErr: true},
+		{name: "no port", addr: "enclave", wantErr: true},
+		{name: "port out of range", addr: "enclave:65536", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			sent := make(chan []byte, 1)
+			go func() {
+				sent <- socks5Proxy(server, tt.method, tt.authStatus, tt.reply)
+				server.Close()
+			}()
+			err := socks5Connect(client, tt.addr, tt.user)
+			client.Close()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("socks5Connect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := <-sent; tt.wantSent != nil && !bytes.Equal(got, tt.wantSent) {
+				t.Errorf("proxy received %x, want %x", got, tt.wantSent)
+			}
+		})
+	}
+}
@@ -0,0 +1,9 @@
+//go:build qvl
+
+package main
+
+import "github.com/apache/incubator-teaclave-sgx-sdk/samplecode/ue-ra/ratls"
+
+func init() {
+	qvlBackend = ratls.QVLBackend{}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/apache/incubator-teaclave-sgx-sdk/samplecode/ue-ra/ratls"
+)
+
+// reattest verifies the server again every interval by reconnecting to it.
+// As soon as its evidence no longer passes, or the enclave identity or TCB
+// status differ from the initial attestation, it closes conn and no
+// further messages are sent.
+func reattest(conn *ratls.Conn, cert tls.Certificate, base *ratls.Verifier, initial *ratls.VerificationResult, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		res, err := attestOnce(cert, base)
+		if err == nil {
+			err = compareAttestation(initial, res)
+		}
+		if err != nil {
+			log.Println("re-attestation failed, closing the session:", err)
+			reattestFailure.Store(fmt.Errorf("re-attestation failed: %v", err))
+			conn.Close()
+			return
+		}
+		log.Println("re-attestation passed")
+	}
+}
+
+// reattestFailure holds the error of a failed re-attestation, after which
+// send refuses to reach the server.
+var reattestFailure atomic.Value
+
+// attestOnce performs a full RA-TLS handshake with the server and returns
+// the verification result.
+func attestOnce(cert tls.Certificate, base *ratls.Verifier) (*ratls.VerificationResult, error) {
+	conn, err := openSession(cert, base)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.Identity()
+}
+
+// openSession connects to the server connect reached again, with a fresh
+// challenge if -nonce is set, and verifies it like the first session.
+func openSession(cert tls.Certificate, base *ratls.Verifier) (*ratls.Conn, error) {
+	verifier := *base
+	conf := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}
+	if err := applyTLSProfile(conf); err != nil {
+		return nil, err
+	}
+	if *useNonce {
+		nonce, err := ratls.NewNonce()
+		if err != nil {
+			return nil, err
+		}
+		verifier.Nonce = nonce
+		conf.NextProtos = []string{ratls.NonceProtocol(nonce)}
+	}
+	return handshake(connectedAddr, conf, monitoredVerifier{&verifier})
+}
+
+// compareAttestation reports a change of the attested enclave between two
+// verifications. The certificate key is fresh for every session.
+func compareAttestation(old, cur *ratls.VerificationResult) error {
+	if changes := ratls.CompareIdentity(old, cur); len(changes) > 0 {
+		return errors.New(strings.Join(changes, ", "))
+	}
+	return nil
+}
+
+// rotations notices when the server presents a new certificate, on
+// re-attestation or renegotiation.
+var rotations = &ratls.RotationMonitor{OnChange: func(change *ratls.IdentityChange) {
+	if len(change.Changes) == 0 {
+		log.Printf("server %s rotated its certificate, enclave identity unchanged", change.Peer)
+		return
+	}
+	log.Printf("WARNING: server %s presented a new enclave identity: %s", change.Peer, strings.Join(change.Changes, ", "))
+}}
+
+// monitoredVerifier reports the certificates accepted by serverVerifier to
+// rotations.
+type monitoredVerifier struct {
+	serverVerifier
+}
+
+func (m monitoredVerifier) VerifyPeerChain(peer string, rawCerts [][]byte) (*ratls.VerificationResult, error) {
+	res, err := m.serverVerifier.VerifyPeerChain(peer, rawCerts)
+	if err == nil {
+		rotations.Observe(peer, rawCerts, res)
+	}
+	return res, err
+}
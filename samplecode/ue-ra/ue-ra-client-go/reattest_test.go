@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apache/incubator-teaclave-sgx-sdk/samplecode/ue-ra/ratls"
+)
+
+func TestCompareAttestation(t *testing.T) {
+	initial := ratls.VerificationResult{
+		MrEnclave:   "aa",
+		MrSigner:    "bb",
+		ISVProdID:   1,
+		ISVSVN:      2,
+		QuoteStatus: "OK",
+		TCBStatus:   "UpToDate",
+		PublicKey:   []byte("key"),
+		Timestamp:   time.Date(2023, 1, 15, 0, 0, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		name    string
+		edit    func(*ratls.VerificationResult)
+		wantErr bool
+	}{
+		{name: "same enclave", edit: func(*ratls.VerificationResult) {}},
+		{
+			name: "fresh key and report",
+			edit: func(r *ratls.VerificationResult) {
+				r.PublicKey = []byte("other key")
+				r.Timestamp = r.Timestamp.Add(time.Hour)
+			},
+		},
+		{name: "other enclave", edit: func(r *ratls.VerificationResult) { r.MrEnclave = "cc" }, wantErr: true},
+		{name: "other signer", edit: func(r *ratls.VerificationResult) { r.MrSigner = "cc" }, wantErr: true},
+		{name: "other product", edit: func(r *ratls.VerificationResult) { r.ISVProdID = 3 }, wantErr: true},
+		{name: "other SVN", edit: func(r *ratls.VerificationResult) { r.ISVSVN = 3 }, wantErr: true},
+		{name: "debug", edit: func(r *ratls.VerificationResult) { r.Debug = true }, wantErr: true},
+		{name: "quote status", edit: func(r *ratls.VerificationResult) { r.QuoteStatus = "GROUP_OUT_OF_DATE" }, wantErr: true},
+		{name: "TCB status", edit: func(r *ratls.VerificationResult) { r.TCBStatus = "OutOfDate" }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cur := initial
+			tt.edit(&cur)
+			if err := compareAttestation(&initial, &cur); (err != nil) != tt.wantErr {
+				t.Errorf("compareAttestation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/apache/incubator-teaclave-sgx-sdk/samplecode/ue-ra/ratls"
+)
+
+// maxMessage is the read buffer size of the ue-ra enclave; longer messages
+// are sent in several parts.
+const maxMessage = 1024
+
+// The ue-ra server reads a single message per session, answers it and
+// closes the session. -interactive and -send-file therefore send every
+// message over a session of its own, each verified like the first one.
+
+// exchange sends msg and reads the reply into buf.
+func exchange(conn *tls.Conn, msg, buf []byte) ([]byte, error) {
+	if *ioTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(*ioTimeout))
+	}
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+	n, err := conn.Read(buf)
+	return buf[:n], err
+}
+
+// send delivers msg over a new attested session with the server and
+// returns its reply.
+func send(cert tls.Certificate, base *ratls.Verifier, msg, buf []byte) ([]byte, error) {
+	if err, ok := reattestFailure.Load().(error); ok {
+		return nil, err
+	}
+	conn, err := openSession(cert, base)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return exchange(conn.Conn, msg, buf)
+}
+
+// interactive sends each line read from stdin to the server and prints the
+// reply, until end of input.
+func interactive(cert tls.Certificate, base *ratls.Verifier) error {
+	buf := make([]byte, maxMessage)
+	in := bufio.NewScanner(os.Stdin)
+	fmt.Fprint(os.Stderr, "> ")
+	for in.Scan() {
+		line := in.Bytes()
+		for len(line) > 0 {
+			part := line[:min(len(line), maxMessage)]
+			line = line[len(part):]
+			reply, err := send(cert, base, part, buf)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("server replied: %s\n", reply)
+		}
+		fmt.Fprint(os.Stderr, "> ")
+	}
+	return in.Err()
+}
+
+// sendFile transfers the file at path to the server, one message of at
+// most maxMessage bytes per session, and requires each to be answered.
+func sendFile(cert tls.Certificate, base *ratls.Verifier, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	msg := make([]byte, maxMessage)
+	buf := make([]byte, maxMessage)
+	var total int64
+	sessions := 0
+	for {
+		n, err := io.ReadFull(f, msg)
+		if n > 0 {
+			reply, err := send(cert, base, msg[:n], buf)
+			if err != nil {
+				return fmt.Errorf("send %s at offset %d: %v", path, total, err)
+			}
+			if len(reply) == 0 {
+				return fmt.Errorf("server did not answer the data at offset %d", total)
+			}
+			total += int64(n)
+			sessions++
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Printf("sent %d bytes of %s in %d sessions\n", total, path, sessions)
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+var curveIDs = map[string]tls.CurveID{
+	"X25519": tls.X25519,
+	"P-256":  tls.CurveP256,
+	"P-384":  tls.CurveP384,
+	"P-521":  tls.CurveP521,
+}
+
+// applyTLSProfile constrains conf to the protocol version, cipher suites
+// and curves given on the command line.
+func applyTLSProfile(conf *tls.Config) error {
+	switch *tlsVersion {
+	case "1.2":
+		conf.MinVersion = tls.VersionTLS12
+	case "1.3":
+		conf.MinVersion = tls.VersionTLS13
+	default:
+		return fmt.Errorf("unsupported TLS version %q", *tlsVersion)
+	}
+	// Evidence is bound to the handshake, never renegotiate
+	conf.Renegotiation = tls.RenegotiateNever
+
+	if *cipherSuites != "" {
+		if conf.MinVersion == tls.VersionTLS13 {
+			return fmt.Errorf("-cipher-suites only applies to TLS 1.2, TLS 1.3 suites are not configurable")
+		}
+		byName := make(map[string]uint16)
+		for _, suite := range tls.CipherSuites() {
+			byName[suite.Name] = suite.ID
+		}
+		for _, name := range strings.Split(*cipherSuites, ",") {
+			id, ok := byName[strings.TrimSpace(name)]
+			if !ok {
+				return fmt.Errorf("unknown or insecure cipher suite %q", name)
+			}
+			conf.CipherSuites = append(conf.CipherSuites, id)
+		}
+	}
+	if *curves != "" {
+		for _, name := range strings.Split(*curves, ",") {
+			id, ok := curveIDs[strings.TrimSpace(name)]
+			if !ok {
+				return fmt.Errorf("unknown curve %q", name)
+			}
+			conf.CurvePreferences = append(conf.CurvePreferences, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/tls"
+	"reflect"
+	"testing"
+)
+
+func TestApplyTLSProfile(t *testing.T) {
+	version, suites, curveList := *tlsVersion, *cipherSuites, *curves
+	defer func() { *tlsVersion, *cipherSuites, *curves = version, suites, curveList }()
+	tests := []struct {
+		name       string
+		version    string
+		suites     string
+		curves     string
+		wantMin    uint16
+		wantSuites []uint16
+		wantCurves []tls.CurveID
+		wantErr    bool
+	}{
+		{name: "TLS 1.2", version: "1.2", wantMin: tls.VersionTLS12},
+		{name: "TLS 1.3", version: "1.3", wantMin: tls.VersionTLS13},
+		{
+			name:       "cipher suites",
+			version:    "1.2",
+			suites:     "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256",
+			wantMin:    tls.VersionTLS12,
+			wantSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256},
+		},
+		{name: "curves", version: "1.3", curves: "X25519,P-384", wantMin: tls.VersionTLS13, wantCurves: []tls.CurveID{tls.X25519, tls.CurveP384}},
+		{name: "TLS 1.1", version: "1.1", wantErr: true},
+		{name: "suites with TLS 1.3", version: "1.3", suites: "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", wantErr: true},
+		{name: "insecure suite", version: "1.2", suites: "TLS_RSA_WITH_RC4_128_SHA", wantErr: true},
+		{name: "unknown suite", version: "1.2", suites: "TLS_NULL", wantErr: true},
+		{name: "unknown curve", version: "1.2", curves: "P-224", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*tlsVersion, *cipherSuites, *curves = tt.version, tt.suites, tt.curves
+			conf := &tls.Config{Renegotiation: tls.RenegotiateFreelyAsClient}
+			err := applyTLSProfile(conf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyTLSProfile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if conf.MinVersion != tt.wantMin || !reflect.DeepEqual(conf.CipherSuites, tt.wantSuites) || !reflect.DeepEqual(conf.CurvePreferences, tt.wantCurves) {
+				t.Errorf("applyTLSProfile() = version %x, suites %v, curves %v", conf.MinVersion, conf.CipherSuites, conf.CurvePreferences)
+			}
+			if conf.Renegotiation != tls.RenegotiateNever {
+				t.Errorf("applyTLSProfile() renegotiation = %v, want never", conf.Renegotiation)
+			}
+		})
+	}
+}
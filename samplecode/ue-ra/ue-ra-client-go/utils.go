@@ -1,10 +1,20 @@
 package main
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"strings"
+	"time"
+
+	"github.com/apache/incubator-teaclave-sgx-sdk/samplecode/ue-ra/ratls"
 )
 
 func printCert(rawByte []byte) {
@@ -27,6 +37,86 @@ func printCert(rawByte []byte) {
 	println("\")]")
 }
 
+func printResult(res *ratls.VerificationResult) {
+	if res.Simulated {
+		fmt.Println("WARNING: SIMULATED enclave, its identity is NOT attested by SGX hardware")
+	}
+	if res.QuoteStatus != "" {
+		fmt.Println("isvEnclaveQuoteStatus = ", res.QuoteStatus)
+		if !res.Timestamp.IsZero() {
+			fmt.Println("Time diff = ", int64(time.Since(res.Timestamp)/time.Second))
+			fmt.Println("report signature algorithm = ", res.ReportSignatureAlgorithm)
+		}
+		if res.IASConfirmed {
+			fmt.Println("report confirmed by IAS")
+		}
+	}
+	if res.PlatformInfo != nil {
+		piBlobJson, err := json.Marshal(res.PlatformInfo)
+		if err == nil {
+			fmt.Println("Platform info is: " + string(piBlobJson))
+		}
+		fmt.Println("Platform info signature verified: ", res.PlatformInfoVerified)
+	}
+	if u := res.PlatformUpdate; u != nil && u.Required {
+		fmt.Println("platform update required: ", strings.Join(u.Actions, ", "))
+		fmt.Printf("cpu svn %s, latest %s; pce svn %d, latest %d; latest pse isvsvn %d\n", u.CPUSVN, u.LatestCPUSVN, u.PCESVN, u.LatestPCESVN, u.LatestPSEISVSVN)
+	}
+	if res.Claims != nil {
+		claims, err := json.Marshal(res.Claims)
+		if err == nil {
+			fmt.Println("enclave claims = ", string(claims))
+		}
+	}
+	if res.TokenIssuer != "" {
+		fmt.Println("token issuer = ", res.TokenIssuer)
+		fmt.Println("token expiry = ", res.TokenExpiry)
+	}
+	if res.TCBStatus != "" {
+		fmt.Println("fmspc = ", res.FMSPC)
+		fmt.Println("tcbStatus = ", res.TCBStatus)
+		fmt.Println("tcbDate = ", res.TCBDate)
+		fmt.Println("QE tcbStatus = ", res.QEStatus)
+	}
+	if len(res.AdvisoryIDs) > 0 {
+		fmt.Println("advisoryIDs = ", res.AdvisoryIDs)
+		fmt.Println("advisoryURL = ", res.AdvisoryURL)
+	}
+	if len(res.PolicyReasons) > 0 {
+		fmt.Println("policy = ", strings.Join(res.PolicyReasons, "; "))
+	}
+	fmt.Println("sgx quote version = ", res.QuoteVersion)
+	if res.EPIDGroupID != "" {
+		fmt.Println("sgx quote signature type = ", res.SignType)
+		fmt.Println("sgx quote epid group id = ", res.EPIDGroupID)
+	}
+	fmt.Println("sgx quote report_data = ", res.ReportData)
+	if td := res.TDReport; td != nil {
+		fmt.Println("td report mr_td = ", hex.EncodeToString(td.MrTD[:]))
+		fmt.Println("td report mr_seam = ", hex.EncodeToString(td.MrSeam[:]))
+		fmt.Println("td report mr_config_id = ", hex.EncodeToString(td.MrConfigID[:]))
+		fmt.Println("td report mr_owner = ", hex.EncodeToString(td.MrOwner[:]))
+		for i, rtmr := range td.RTMR {
+			fmt.Printf("td report rtmr%d =  %x\n", i, rtmr)
+		}
+	} else {
+		fmt.Println("sgx quote mr_enclave = ", res.MrEnclave)
+		fmt.Println("sgx quote mr_signer = ", res.MrSigner)
+		fmt.Println("sgx quote isv_prod_id = ", res.ISVProdID)
+		fmt.Println("sgx quote isv_svn = ", res.ISVSVN)
+		fmt.Println("sgx quote cpu_svn = ", res.CPUSVN)
+		fmt.Printf("sgx quote misc_select =  %#08x\n", res.MiscSelect)
+		fmt.Printf("sgx quote attributes =  flags %#016x xfrm %#016x\n", res.Attributes.Flags, res.Attributes.Xfrm)
+	}
+	fmt.Println("Anticipated public key = ", hex.EncodeToString(res.PublicKey))
+	if res.InsecureTestRoots {
+		fmt.Println("WARNING: verified against insecure test roots")
+	}
+	if res.PKIVerified {
+		fmt.Println("certificate chains to a trusted CA")
+	}
+}
+
 
 func loadCert() (string, string) {
 	certPem, err := readFile("./../../cert/client.crt")
@@ -41,6 +131,67 @@ func loadCert() (string, string) {
 	return certPem, keyPEM
 }
 
+func loadCertPool(filePth string) *x509.CertPool {
+	cacert, err := readFile(filePth)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	roots := x509.NewCertPool()
+	if ok := roots.AppendCertsFromPEM([]byte(cacert)); !ok {
+		log.Fatalln("failed to parse root certificate", filePth)
+	}
+	return roots
+}
+
+func loadECPublicKey(filePth string) *ecdsa.PublicKey {
+	keyPem, err := readFile(filePth)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	block, _ := pem.Decode([]byte(keyPem))
+	if block == nil {
+		log.Fatalln("failed to parse public key", filePth)
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	key, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		log.Fatalln("not an ECDSA public key", filePth)
+	}
+	return key
+}
+
+// loadSigner reads a PEM private key (PKCS #8, SEC 1 or PKCS #1).
+func loadSigner(filePth string) crypto.Signer {
+	keyPem, err := readFile(filePth)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	block, _ := pem.Decode([]byte(keyPem))
+	if block == nil {
+		log.Fatalln("failed to parse private key", filePth)
+	}
+	var key any
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		log.Fatalln("not a signing key", filePth)
+	}
+	return signer
+}
+
 func readFile(filePth string) (string, error) {
 	f, err := os.Open(filePth)
 	if err != nil {